@@ -0,0 +1,130 @@
+// Package cincinnati 把 openshift_version 中的版本通道 (stable-4.16、latest-4.16 等)
+// 解析为具体的 z-stream 版本，并锁定到集群目录的 version.lock 中。
+package cincinnati
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/uuid"
+	"golang.org/x/net/http/httpproxy"
+
+	"ocpack/pkg/config"
+	clog "ocpack/pkg/mirror/log"
+	"ocpack/pkg/mirror/release"
+)
+
+// ResolveVersion 在 openshift_version 为版本通道时查询 Cincinnati 更新图，取通道中该次版本的最新版本，
+// 写入 version.lock 并更新 cfg。已有匹配的版本锁时直接使用 (LoadConfig 已应用)，refresh 为 true 时重新查询。
+// 返回 cfg 使用的具体版本。
+func ResolveVersion(ctx context.Context, cfg *config.ClusterConfig, clusterDir string, refresh bool) (string, error) {
+	channel, ok := cfg.VersionChannel()
+	if !ok {
+		return cfg.ClusterInfo.OpenShiftVersion, nil
+	}
+	if cfg.VersionResolved() && !refresh {
+		fmt.Printf("🔒 %s 已锁定为 %s (%s)\n", cfg.RequestedVersion(), cfg.ClusterInfo.OpenShiftVersion, config.VersionLockFilename)
+		return cfg.ClusterInfo.OpenShiftVersion, nil
+	}
+
+	fmt.Printf("🔍 正在查询 %s 通道的最新版本 (%s)...\n", channel, cfg.TargetArch())
+	version, err := latestInChannel(ctx, cfg, channel)
+	if err != nil {
+		return "", err
+	}
+
+	lock := cfg.NewVersionLock(channel, version)
+	if err := lock.Save(clusterDir); err != nil {
+		return "", err
+	}
+	cfg.PinVersion(lock)
+	fmt.Printf("✅ %s 解析为 %s，已写入 %s\n", cfg.RequestedVersion(), version, config.VersionLockFilename)
+	return version, nil
+}
+
+// latestInChannel 返回通道中与通道次版本相同的最新版本。stable-4.16 等通道同时包含
+// 可以升级到 4.16 的 4.15.z，因此不能直接取整个通道的最大版本。
+func latestInChannel(ctx context.Context, cfg *config.ClusterConfig, channel string) (string, error) {
+	minor, err := semver.ParseTolerant(channel[strings.LastIndex(channel, "-")+1:])
+	if err != nil {
+		return "", fmt.Errorf("无效的版本通道 %s: %w", channel, err)
+	}
+
+	cs, cleanup, err := newCincinnati(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	versions, err := release.GetVersions(ctx, *cs, channel)
+	if err != nil {
+		return "", fmt.Errorf("查询 %s 通道失败: %w", channel, err)
+	}
+	// GetVersions 按版本升序返回
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if v.Major == minor.Major && v.Minor == minor.Minor && len(v.Pre) == 0 {
+			return v.String(), nil
+		}
+	}
+	return "", fmt.Errorf("%s 通道中没有 %d.%d 版本 (集群架构 %s)", channel, minor.Major, minor.Minor, cfg.TargetArch())
+}
+
+// proxyFunc 按 [download] 的代理配置选择代理，no_proxy 中的内部 Cincinnati 服务直接访问
+func proxyFunc(cfg *config.ClusterConfig) func(*http.Request) (*url.URL, error) {
+	proxy := (&httpproxy.Config{
+		HTTPProxy:  cfg.HTTPProxyURL(),
+		HTTPSProxy: cfg.HTTPSProxyURL(),
+		NoProxy:    strings.Join(cfg.Download.NoProxy, ","),
+	}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
+
+// newCincinnati 创建查询 OpenShift 更新图的客户端，使用 [download] 中的代理和 CA 配置。
+// 可以通过 UPDATE_URL_OVERRIDE 环境变量指定内部的 Cincinnati 服务。
+func newCincinnati(cfg *config.ClusterConfig) (*release.CincinnatiSchema, func(), error) {
+	log := clog.New("error")
+	client, err := release.NewOCPClient(uuid.New(), log)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建 Cincinnati 客户端失败: %w", err)
+	}
+
+	transport := client.GetTransport()
+	if cfg.UseProxy() {
+		if _, err := url.Parse(cfg.HTTPSProxyURL()); err != nil {
+			return nil, nil, fmt.Errorf("无效的代理地址: %w", err)
+		}
+		transport.Proxy = proxyFunc(cfg)
+	}
+	if cfg.Download.CABundle != "" {
+		pem, err := os.ReadFile(cfg.Download.CABundle)
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+		}
+		if !transport.TLSClientConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("%s 中没有有效的 PEM 证书", cfg.Download.CABundle)
+		}
+	}
+
+	// 更新图会缓存到 GraphDataDir 中，只在本次查询中使用
+	graphDir, err := os.MkdirTemp("", "ocpack-cincinnati-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	cs := &release.CincinnatiSchema{
+		Log:    log,
+		Client: client,
+		CincinnatiParams: release.CincinnatiParams{
+			GraphDataDir: graphDir,
+			Arch:         config.GoArch(cfg.TargetArch()),
+		},
+	}
+	return cs, func() { os.RemoveAll(graphDir) }, nil
+}
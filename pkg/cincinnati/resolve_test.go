@@ -0,0 +1,85 @@
+package cincinnati
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"ocpack/pkg/config"
+)
+
+func TestResolveVersion(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Query().Get("channel") != "stable-4.16" || r.URL.Query().Get("arch") != "arm64" {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		// 通道中包含可以升级到 4.16 的 4.15.z
+		fmt.Fprint(w, `{"nodes":[
+			{"version":"4.15.30","payload":"quay.io/openshift-release-dev/ocp-release:4.15.30"},
+			{"version":"4.16.21","payload":"quay.io/openshift-release-dev/ocp-release:4.16.21"},
+			{"version":"4.16.3","payload":"quay.io/openshift-release-dev/ocp-release:4.16.3"}
+		],"edges":[]}`)
+	}))
+	defer server.Close()
+	t.Setenv("UPDATE_URL_OVERRIDE", server.URL)
+
+	dir := t.TempDir()
+	cfg := &config.ClusterConfig{}
+	cfg.ClusterInfo.OpenShiftVersion = "stable-4.16"
+	cfg.ClusterInfo.TargetArch = "aarch64"
+
+	version, err := ResolveVersion(context.Background(), cfg, dir, false)
+	if err != nil {
+		t.Fatalf("ResolveVersion() error = %v", err)
+	}
+	if version != "4.16.21" || cfg.ClusterInfo.OpenShiftVersion != "4.16.21" || cfg.RequestedVersion() != "stable-4.16" {
+		t.Errorf("resolved %s, config version %s, requested %s", version, cfg.ClusterInfo.OpenShiftVersion, cfg.RequestedVersion())
+	}
+
+	lock, err := config.LoadVersionLock(dir)
+	if err != nil || lock == nil {
+		t.Fatalf("LoadVersionLock() = %v, %v", lock, err)
+	}
+	if lock.Version != "4.16.21" || lock.Channel != "stable-4.16" || lock.Arch != "aarch64" {
+		t.Errorf("lock = %+v", lock)
+	}
+
+	// 已锁定的版本不再查询
+	if _, err := ResolveVersion(context.Background(), cfg, dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Cincinnati queried %d times, want 1", n)
+	}
+}
+
+func TestProxyFuncNoProxy(t *testing.T) {
+	cfg := &config.ClusterConfig{}
+	cfg.Download.HTTPSProxy = "http://proxy.example.com:3128"
+	cfg.Download.NoProxy = []string{"updates.internal", "10.0.0.0/8"}
+	proxy := proxyFunc(cfg)
+
+	for _, tt := range []struct {
+		url   string
+		proxy string
+	}{
+		{"https://api.openshift.com/api/upgrades_info/v1/graph", "http://proxy.example.com:3128"},
+		{"https://cincinnati.updates.internal/graph", ""},
+		{"https://10.1.2.3/graph", ""},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		got, err := proxy(req)
+		if err != nil {
+			t.Fatalf("proxy(%s) error = %v", tt.url, err)
+		}
+		if (got == nil && tt.proxy != "") || (got != nil && got.String() != tt.proxy) {
+			t.Errorf("proxy(%s) = %v, want %q", tt.url, got, tt.proxy)
+		}
+	}
+}
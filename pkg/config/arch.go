@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// 集群 CPU 架构，使用 mirror.openshift.com 目录和 agent ISO 文件名中的写法
+const (
+	ArchX86_64  = "x86_64"
+	ArchAarch64 = "aarch64"
+	ArchPPC64LE = "ppc64le"
+	ArchS390X   = "s390x"
+)
+
+// 运行 ocpack 和客户端工具的操作系统
+const (
+	ClientOSLinux  = "linux"
+	ClientOSDarwin = "darwin"
+)
+
+// archAliases 把 Go 的架构名称 (amd64、arm64) 映射到集群架构
+var archAliases = map[string]string{
+	"x86_64":  ArchX86_64,
+	"amd64":   ArchX86_64,
+	"aarch64": ArchAarch64,
+	"arm64":   ArchAarch64,
+	"ppc64le": ArchPPC64LE,
+	"s390x":   ArchS390X,
+}
+
+// Architectures 返回所有支持的集群架构
+func Architectures() []string {
+	return []string{ArchX86_64, ArchAarch64, ArchPPC64LE, ArchS390X}
+}
+
+// NormalizeArch 把 amd64、arm64 等写法统一为集群架构名称，不支持的架构返回 false
+func NormalizeArch(arch string) (string, bool) {
+	normalized, ok := archAliases[strings.ToLower(arch)]
+	return normalized, ok
+}
+
+// GoArch 返回集群架构对应的 Go 架构名称，也是 install-config.yaml 中 architecture 的取值
+func GoArch(arch string) string {
+	switch arch {
+	case ArchX86_64:
+		return "amd64"
+	case ArchAarch64:
+		return "arm64"
+	}
+	return arch
+}
+
+// TargetArch 返回集群节点的架构，默认 x86_64
+func (c *ClusterConfig) TargetArch() string {
+	if arch, ok := NormalizeArch(c.ClusterInfo.TargetArch); ok {
+		return arch
+	}
+	return ArchX86_64
+}
+
+// InstallConfigArch 返回 install-config.yaml 中 compute 和 controlPlane 的 architecture
+func (c *ClusterConfig) InstallConfigArch() string {
+	return GoArch(c.TargetArch())
+}
+
+// ClientOS 返回下载的客户端工具运行的操作系统，默认为当前系统
+func (c *ClusterConfig) ClientOS() string {
+	if c.Download.ClientOS != "" {
+		return normalizeOS(c.Download.ClientOS)
+	}
+	return normalizeOS(runtime.GOOS)
+}
+
+// ClientArch 返回下载的客户端工具运行的架构，默认为当前机器的架构
+func (c *ClusterConfig) ClientArch() (string, error) {
+	arch := c.Download.ClientArch
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	normalized, ok := NormalizeArch(arch)
+	if !ok {
+		return "", fmt.Errorf("不支持的客户端架构: %s，请在 [download] 中设置 client_arch (可选值: %s)", arch, strings.Join(Architectures(), ", "))
+	}
+	return normalized, nil
+}
+
+// MirrorRegistryArchive 返回 mirror-registry 安装包的文件名。Registry 节点与集群节点架构相同，
+// mirror-registry 只发布 x86_64 和 aarch64 版本
+func (c *ClusterConfig) MirrorRegistryArchive() string {
+	if c.TargetArch() == ArchAarch64 {
+		return "mirror-registry-aarch64.tar.gz"
+	}
+	return "mirror-registry-amd64.tar.gz"
+}
+
+func normalizeOS(os string) string {
+	if strings.ToLower(os) == "mac" {
+		return ClientOSDarwin
+	}
+	return strings.ToLower(os)
+}
+
+// checkArch 检查 target_arch、client_os 和 client_arch 的取值
+func checkArch(cfg *ClusterConfig, r *ValidationResult) {
+	if arch := cfg.ClusterInfo.TargetArch; arch != "" {
+		if _, ok := NormalizeArch(arch); !ok {
+			r.addError("cluster_info.target_arch", fmt.Sprintf("不支持的集群架构: %s", arch), "可选值: "+strings.Join(Architectures(), ", "))
+		}
+	}
+	if arch := cfg.Download.ClientArch; arch != "" {
+		if _, ok := NormalizeArch(arch); !ok {
+			r.addError("download.client_arch", fmt.Sprintf("不支持的客户端架构: %s", arch), "可选值: "+strings.Join(Architectures(), ", "))
+		}
+	}
+	if os := cfg.ClientOS(); os != ClientOSLinux && os != ClientOSDarwin {
+		r.addError("download.client_os", fmt.Sprintf("不支持的客户端操作系统: %s", os), "可选值: linux, darwin")
+	}
+}
+
+// checkDownloadArch 提示当前架构组合下无法下载的工具
+func checkDownloadArch(cfg *ClusterConfig, r *ValidationResult) {
+	if cfg.ClientOS() == ClientOSDarwin {
+		r.addWarning("download.client_os", "macOS 上没有 oc-mirror，将跳过下载，镜像同步需要在 Linux 上进行", "")
+	}
+	if arch := cfg.TargetArch(); arch == ArchPPC64LE || arch == ArchS390X {
+		r.addWarning("cluster_info.target_arch", fmt.Sprintf("mirror-registry 没有 %s 版本，将下载 x86_64 版本", arch), "Registry 节点需要使用 x86_64 架构")
+	}
+}
+
+// checkHostTools 提示 downloads/bin 中的 oc、kubectl 无法在 Linux 主机上运行
+func checkHostTools(cfg *ClusterConfig, r *ValidationResult) {
+	if cfg.ClientOS() != ClientOSLinux {
+		r.addWarning("download.client_os", fmt.Sprintf("下载的是 %s 版本的客户端工具，复制到 Bastion/Registry 的 oc 和 kubectl 无法运行", cfg.ClientOS()), "在 Linux 上运行 ocpack 或手动安装 oc")
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"ocpack/pkg/utils"
 
@@ -11,59 +13,103 @@ import (
 
 // ClusterConfig 表示集群配置
 type ClusterConfig struct {
+	// 配置文件 schema 版本，见 CurrentSchemaVersion
+	SchemaVersion int `toml:"schema_version"`
+
 	// 集群基本信息
 	ClusterInfo struct {
 		Name             string `toml:"name"`
 		Domain           string `toml:"domain"`
 		ClusterID        string `toml:"cluster_id"`
 		OpenShiftVersion string `toml:"openshift_version"`
+		Topology         string `toml:"topology,omitempty"`    // sno、compact 或 standard，为空时根据节点数量推断
+		TargetArch       string `toml:"target_arch,omitempty"` // 集群节点架构: x86_64、aarch64、ppc64le 或 s390x，默认 x86_64
 	} `toml:"cluster_info"`
 
 	// Bastion 节点配置
 	Bastion struct {
-		IP         string `toml:"ip"`
-		Username   string `toml:"username"`
-		SSHKeyPath string `toml:"ssh_key_path"`
-		Password   string `toml:"password"`
+		IP               string `toml:"ip"`
+		IPv6             string `toml:"ipv6,omitempty"` // 双栈集群中 Bastion 的 IPv6 地址，用于 AAAA 记录
+		Username         string `toml:"username"`
+		SSHKeyPath       string `toml:"ssh_key_path"`
+		SSHKeyPassphrase string `toml:"ssh_key_passphrase,omitempty"` // 加密私钥的口令，建议使用 secret: 引用
+		SSHPort          int    `toml:"ssh_port,omitempty"`           // 默认 22
+		Password         string `toml:"password"`
 	} `toml:"bastion"`
 
 	// Registry 节点配置
 	Registry struct {
-		IP           string `toml:"ip"`
-		Username     string `toml:"username"`
-		SSHKeyPath   string `toml:"ssh_key_path"`
-		Password     string `toml:"password"`
-		StoragePath  string `toml:"storage_path"`
-		RegistryUser string `toml:"registry_user"`
+		IP               string `toml:"ip"`
+		IPv6             string `toml:"ipv6,omitempty"` // 双栈集群中 Registry 的 IPv6 地址，用于 AAAA 记录
+		Username         string `toml:"username"`
+		SSHKeyPath       string `toml:"ssh_key_path"`
+		SSHKeyPassphrase string `toml:"ssh_key_passphrase,omitempty"` // 加密私钥的口令，建议使用 secret: 引用
+		SSHPort          int    `toml:"ssh_port,omitempty"`           // 默认 22
+		Password         string `toml:"password"`
+		StoragePath      string `toml:"storage_path"`
+		RegistryUser     string `toml:"registry_user"`
 	} `toml:"registry"`
 
+	// SSH 连接配置，适用于 Go SSH 客户端、ssh 命令和 Ansible
+	SSH struct {
+		HostKeyPolicy  string `toml:"host_key_policy,omitempty"`  // strict、tofu 或 insecure，默认 tofu
+		KnownHostsFile string `toml:"known_hosts_file,omitempty"` // 默认为集群目录下的 known_hosts
+		UseAgent       bool   `toml:"use_agent,omitempty"`        // 使用 SSH_AUTH_SOCK 指向的 ssh-agent
+	} `toml:"ssh"`
+
+	// 节点安全配置
+	Security struct {
+		Mode string `toml:"mode,omitempty"` // disabled (默认) 或 hardened
+	} `toml:"security"`
+
+	// API 和 Ingress 负载均衡配置
+	LoadBalancer struct {
+		Mode            string             `toml:"mode,omitempty"`              // bastion-haproxy (默认)、haproxy-ha 或 external
+		APIVIP          string             `toml:"api_vip,omitempty"`           // api 和 api-int 记录指向的地址: haproxy-ha 的 keepalived VIP 或外部负载均衡地址
+		APIVIPv6        string             `toml:"api_vip_v6,omitempty"`        // 双栈集群中的 IPv6 API 地址
+		IngressVIP      string             `toml:"ingress_vip,omitempty"`       // *.apps 记录指向的地址，可以与 api_vip 相同
+		IngressVIPv6    string             `toml:"ingress_vip_v6,omitempty"`    // 双栈集群中的 IPv6 Ingress 地址
+		VirtualRouterID int                `toml:"virtual_router_id,omitempty"` // haproxy-ha 中 API VIP 的 VRRP 路由 ID，Ingress VIP 使用下一个，默认 51
+		Nodes           []LoadBalancerNode `toml:"nodes,omitempty"`             // haproxy-ha 的两个 HAProxy 节点
+	} `toml:"load_balancer"`
+
 	// 集群节点配置
 	Cluster struct {
 		// Control Plane 节点
-		ControlPlane []struct {
-			Name string `toml:"name"`
-			IP   string `toml:"ip"`
-			MAC  string `toml:"mac"`
-		} `toml:"control_plane"`
+		ControlPlane []Node `toml:"control_plane"`
 
 		// Worker 节点
-		Worker []struct {
-			Name string `toml:"name"`
-			IP   string `toml:"ip"`
-			MAC  string `toml:"mac"`
-		} `toml:"worker"`
+		Worker []Node `toml:"worker"`
 
 		// 网络配置
+		// 每个网络最多包含一个 IPv4 和一个 IPv6 网段，第一个网段的地址族为主地址族
 		Network struct {
-			ClusterNetwork string `toml:"cluster_network"`
-			ServiceNetwork string `toml:"service_network"`
-			MachineNetwork string `toml:"machine_network"`
+			ClusterNetwork []string `toml:"cluster_network"`
+			ServiceNetwork []string `toml:"service_network"`
+			MachineNetwork []string `toml:"machine_network"`
+			HostPrefix     int      `toml:"host_prefix,omitempty"`    // IPv4 集群网络分配给每个节点的前缀长度，默认 23
+			HostPrefixV6   int      `toml:"host_prefix_v6,omitempty"` // IPv6 集群网络分配给每个节点的前缀长度，默认 64
 		} `toml:"network"`
 	} `toml:"cluster"`
 
 	// 下载配置
 	Download struct {
-		LocalPath string `toml:"local_path"`
+		LocalPath   string `toml:"local_path"`
+		Retries     int    `toml:"retries,omitempty"`     // 下载失败后的重试次数，默认 3
+		Concurrency int    `toml:"concurrency,omitempty"` // 同时下载的文件数，默认 3
+		ClientOS    string `toml:"client_os,omitempty"`   // 客户端工具运行的系统: linux 或 darwin，默认为当前系统
+		ClientArch  string `toml:"client_arch,omitempty"` // 客户端工具运行的架构，默认为当前机器的架构
+
+		BaseURL           string `toml:"base_url,omitempty"`            // OpenShift 客户端下载地址，默认 https://mirror.openshift.com/pub/openshift-v4
+		MirrorRegistryURL string `toml:"mirror_registry_url,omitempty"` // mirror-registry 安装包地址
+		CABundle          string `toml:"ca_bundle,omitempty"`           // 额外信任的 CA 证书 (PEM)，用于 TLS 拦截代理或内部镜像站
+
+		// 代理配置，同时写入 install-config.yaml 的 proxy 段
+		HTTPProxy     string   `toml:"http_proxy,omitempty"`
+		HTTPSProxy    string   `toml:"https_proxy,omitempty"`
+		NoProxy       []string `toml:"no_proxy,omitempty"` // 不经过代理的域名、IP 或 CIDR
+		ProxyUsername string   `toml:"proxy_username,omitempty"`
+		ProxyPassword string   `toml:"proxy_password,omitempty"` // 建议使用 secret: 引用
 	} `toml:"download"`
 
 	// 镜像保存配置
@@ -74,22 +120,82 @@ type ClusterConfig struct {
 		AdditionalImages []string `toml:"additional_images"`
 	} `toml:"save_image"`
 
-	// Operator 配置列表
-	Operators []struct {
-		Name      string `toml:"name"`
-		Namespace string `toml:"namespace"`
-	} `toml:"operators"`
+	// 加载时文件中的 schema 版本，用于提示执行 ocpack config migrate
+	loadedSchemaVersion int
+
+	// 从密钥库解析的配置项及其原始 secret: 引用，保存时写回引用
+	secretRefs map[string]string
+
+	// openshift_version 为版本通道时的原始取值，保存时写回通道
+	requestedVersion string
+}
+
+// Node 集群节点配置
+type Node struct {
+	Name string `toml:"name"`
+	IP   string `toml:"ip"`             // 主地址，IPv6 单栈集群中为 IPv6 地址
+	IPv6 string `toml:"ipv6,omitempty"` // 双栈集群中的 IPv6 地址
+	MAC  string `toml:"mac"`
+
+	// 以下为可选的网络配置，未设置时使用单网卡 ens3 和机器网络的第一个地址作为网关
+	Interface  string          `toml:"interface,omitempty"`  // 主网卡名称，对应 mac
+	Interfaces []NodeInterface `toml:"interfaces,omitempty"` // 额外的物理网卡，通常作为 bond 成员
+	Bond       *Bond           `toml:"bond,omitempty"`
+	VLAN       int             `toml:"vlan,omitempty"`       // VLAN ID，节点 IP 配置在 VLAN 接口上
+	Gateway    string          `toml:"gateway,omitempty"`    // 主地址族的默认网关
+	GatewayV6  string          `toml:"gateway_v6,omitempty"` // 双栈集群中的 IPv6 默认网关
+	Routes     []Route         `toml:"routes,omitempty"`     // 额外的静态路由
+}
+
+// NodeInterface 节点物理网卡
+type NodeInterface struct {
+	Name string `toml:"name"`
+	MAC  string `toml:"mac"`
+}
+
+// Bond 网卡绑定配置
+type Bond struct {
+	Name    string            `toml:"name"`              // 默认 bond0
+	Mode    string            `toml:"mode"`              // 如 802.3ad、active-backup
+	Ports   []string          `toml:"ports,omitempty"`   // 成员网卡名称，默认为节点的所有网卡
+	Options map[string]string `toml:"options,omitempty"` // 如 miimon = "100"
+}
+
+// Route 静态路由
+type Route struct {
+	Destination string `toml:"destination"`
+	Gateway     string `toml:"gateway,omitempty"` // 默认使用节点网关
+	Metric      int    `toml:"metric,omitempty"`
+}
+
+// LoadedSchemaVersion 返回配置文件迁移前的 schema 版本
+func (c *ClusterConfig) LoadedSchemaVersion() int {
+	if c.loadedSchemaVersion == 0 {
+		return c.SchemaVersion
+	}
+	return c.loadedSchemaVersion
 }
 
-// NewDefaultConfig 创建默认配置
+// NewDefaultConfig 创建默认配置 (standard 拓扑)
 func NewDefaultConfig(clusterName string) *ClusterConfig {
-	config := &ClusterConfig{}
+	config, _ := NewTopologyConfig(clusterName, TopologyStandard)
+	return config
+}
+
+// NewTopologyConfig 创建指定集群拓扑的默认配置，节点列表与拓扑一致
+func NewTopologyConfig(clusterName, topology string) (*ClusterConfig, error) {
+	controlPlane, worker, err := topologyNodes(topology)
+	if err != nil {
+		return nil, err
+	}
+	config := &ClusterConfig{SchemaVersion: CurrentSchemaVersion}
 
 	// 设置默认值
 	config.ClusterInfo.Name = clusterName
 	config.ClusterInfo.Domain = "example.com"
 	config.ClusterInfo.ClusterID = clusterName
 	config.ClusterInfo.OpenShiftVersion = "4.14.0"
+	config.ClusterInfo.Topology = topology
 
 	config.Bastion.Username = "root"
 
@@ -97,30 +203,17 @@ func NewDefaultConfig(clusterName string) *ClusterConfig {
 	config.Registry.StoragePath = "/var/lib/registry"
 	config.Registry.RegistryUser = "ocp4"
 
-	// 设置集群节点默认值
-	config.Cluster.ControlPlane = []struct {
-		Name string `toml:"name"`
-		IP   string `toml:"ip"`
-		MAC  string `toml:"mac"`
-	}{
-		{Name: "master-0", IP: "", MAC: ""},
-		{Name: "master-1", IP: "", MAC: ""},
-		{Name: "master-2", IP: "", MAC: ""},
-	}
+	config.SSH.HostKeyPolicy = utils.HostKeyPolicyTOFU
 
-	config.Cluster.Worker = []struct {
-		Name string `toml:"name"`
-		IP   string `toml:"ip"`
-		MAC  string `toml:"mac"`
-	}{
-		{Name: "worker-0", IP: "", MAC: ""},
-		{Name: "worker-1", IP: "", MAC: ""},
-	}
+	// 设置集群节点默认值
+	config.Cluster.ControlPlane = controlPlane
+	config.Cluster.Worker = worker
 
 	// 设置网络默认值
-	config.Cluster.Network.ClusterNetwork = "10.128.0.0/14"
-	config.Cluster.Network.ServiceNetwork = "172.30.0.0/16"
-	config.Cluster.Network.MachineNetwork = "192.168.1.0/24"
+	config.Cluster.Network.ClusterNetwork = []string{"10.128.0.0/14"}
+	config.Cluster.Network.ServiceNetwork = []string{"172.30.0.0/16"}
+	config.Cluster.Network.MachineNetwork = []string{"192.168.1.0/24"}
+	config.Cluster.Network.HostPrefix = DefaultHostPrefix
 
 	config.Download.LocalPath = "downloads"
 
@@ -136,22 +229,34 @@ func NewDefaultConfig(clusterName string) *ClusterConfig {
 		// 示例额外镜像，用户可以根据需要添加
 	}
 
-	return config
+	return config, nil
 }
 
 // GenerateDefaultConfig 生成默认配置文件
 func GenerateDefaultConfig(filePath string, clusterName string) error {
-	config := NewDefaultConfig(clusterName)
+	return GenerateTopologyConfig(filePath, clusterName, TopologyStandard)
+}
+
+// GenerateTopologyConfig 生成指定集群拓扑的默认配置文件 (ocpack new cluster --topology)
+func GenerateTopologyConfig(filePath, clusterName, topology string) error {
+	config, err := NewTopologyConfig(clusterName, topology)
+	if err != nil {
+		return err
+	}
 
 	// 使用自定义模板生成配置文件
 	configContent := fmt.Sprintf(`# OpenShift 集群配置文件
 # 请根据实际环境修改以下配置项
 
+schema_version = %d             # 配置文件格式版本，请勿手动修改
+
 [cluster_info]
 name = "%s"                    # 集群名称
 domain = "%s"                  # 集群域名
 cluster_id = "%s"              # 集群ID
-openshift_version = "%s"       # OpenShift 版本
+openshift_version = "%s"       # OpenShift 版本，也可以写作 stable-4.16、fast-4.16、latest-4.16 等版本通道
+topology = "%s"                # 集群拓扑: sno (单节点)、compact (3 节点) 或 standard
+# target_arch = "x86_64"       # 集群节点架构: x86_64、aarch64、ppc64le 或 s390x
 
 [bastion]
 ip = ""                        # Bastion 节点 IP (必填)
@@ -167,40 +272,53 @@ password = ""                  # SSH 密码 (可选，与 ssh_key_path 二选一
 storage_path = "%s"            # 镜像存储路径
 registry_user = "%s"           # Registry 用户名
 
-# Control Plane 节点配置
-[[cluster.control_plane]]
-name = "master-0"
-ip = ""                        # 节点 IP (必填)
-mac = ""                       # 节点 MAC 地址 (必填)
-
-[[cluster.control_plane]]
-name = "master-1"
-ip = ""
-mac = ""
-
-[[cluster.control_plane]]
-name = "master-2"
-ip = ""
-mac = ""
-
-# Worker 节点配置
-[[cluster.worker]]
-name = "worker-0"
-ip = ""                        # 节点 IP (必填)
-mac = ""                       # 节点 MAC 地址 (必填)
-
-[[cluster.worker]]
-name = "worker-1"
-ip = ""
-mac = ""
+# Bastion 和 Registry 还可以设置 ssh_port (默认 22) 和 ssh_key_passphrase (加密私钥的口令)
+[ssh]
+host_key_policy = "%s"         # 主机密钥校验: strict、tofu (首次连接时记录) 或 insecure
+known_hosts_file = ""          # known_hosts 文件，默认为集群目录下的 known_hosts
+use_agent = false              # 使用 SSH_AUTH_SOCK 指向的 ssh-agent 认证
+
+[security]
+# mode = "hardened"            # disabled (默认): 关闭 firewalld 和 SELinux；hardened: 保持启用，只开放需要的端口
 
+[load_balancer]
+# mode = "haproxy-ha"          # bastion-haproxy (默认): Bastion 上的 HAProxy；haproxy-ha: 两台 HAProxy 节点和 keepalived VIP；external: 外部负载均衡
+# api_vip = "192.168.1.5"      # haproxy-ha 的 VIP 或外部负载均衡的 API 地址
+# ingress_vip = "192.168.1.6"  # *.apps 指向的地址
+# nodes = [{ name = "lb-0", ip = "192.168.1.7" }, { name = "lb-1", ip = "192.168.1.8" }]  # haproxy-ha 的节点，使用 [bastion] 的 SSH 用户和认证方式
+
+# Control Plane 节点配置
+#
+# 节点网络默认为单网卡 ens3，网关为机器网络的第一个地址。需要时可在节点下添加:
+# interface = "eno1"                                     # 主网卡名称 (对应 mac)
+# interfaces = [{ name = "eno2", mac = "52:54:00:aa:bb:cd" }]  # 额外网卡
+# bond = { mode = "802.3ad", options = { miimon = "100" } }     # 绑定所有网卡为 bond0
+# vlan = 100                                             # 节点 IP 配置在 VLAN 接口上
+# gateway = "192.168.1.254"                              # 默认网关
+# routes = [{ destination = "10.10.0.0/16", gateway = "192.168.1.253" }]
+%s
+# 双栈集群在每个网络中同时列出 IPv4 和 IPv6 网段 (如 ["10.128.0.0/14", "fd01::/48"])，
+# 并为节点设置 ipv6 地址；IPv6 单栈集群只列出 IPv6 网段
 [cluster.network]
-cluster_network = "%s"         # 集群网络 CIDR
-service_network = "%s"         # 服务网络 CIDR
-machine_network = "%s"         # 机器网络 CIDR
+cluster_network = ["%s"]       # 集群网络 CIDR 列表
+service_network = ["%s"]       # 服务网络 CIDR 列表
+machine_network = ["%s"]       # 机器网络 CIDR 列表
+host_prefix = %d               # 每个节点从 IPv4 集群网络分配的前缀长度
 
 [download]
 local_path = "%s"              # 下载文件存储路径
+retries = 3                    # 下载失败后的重试次数 (指数退避，支持断点续传)
+concurrency = 3                # 同时下载的文件数
+# client_os = "linux"          # 客户端工具 (oc、openshift-install) 运行的系统: linux 或 darwin，默认为当前系统
+# client_arch = "x86_64"       # 客户端工具运行的架构，默认为当前机器的架构
+# base_url = "https://mirror.openshift.com/pub/openshift-v4"   # 内部镜像站地址
+# mirror_registry_url = ""     # mirror-registry 安装包地址
+# http_proxy = "http://proxy.example.com:3128"                # 代理同时写入 install-config.yaml
+# https_proxy = "http://proxy.example.com:3128"
+# no_proxy = [".example.com", "192.168.1.0/24"]
+# proxy_username = ""
+# proxy_password = ""          # 建议使用 secret: 引用
+# ca_bundle = ""               # 额外信任的 CA 证书 (PEM)，用于 TLS 拦截代理
 
 [save_image]
 include_operators = %t         # 是否包含 Operator 镜像
@@ -211,17 +329,22 @@ ops = [                        # 需要的 Operator 列表
 ]
 additional_images = []         # 额外的镜像列表
 `,
+		config.SchemaVersion,
 		config.ClusterInfo.Name,
 		config.ClusterInfo.Domain,
 		config.ClusterInfo.ClusterID,
 		config.ClusterInfo.OpenShiftVersion,
+		config.ClusterInfo.Topology,
 		config.Bastion.Username,
 		config.Registry.Username,
 		config.Registry.StoragePath,
 		config.Registry.RegistryUser,
-		config.Cluster.Network.ClusterNetwork,
-		config.Cluster.Network.ServiceNetwork,
-		config.Cluster.Network.MachineNetwork,
+		config.SSH.HostKeyPolicy,
+		defaultNodesConfig(config),
+		config.Cluster.Network.ClusterNetwork[0],
+		config.Cluster.Network.ServiceNetwork[0],
+		config.Cluster.Network.MachineNetwork[0],
+		config.Cluster.Network.HostPrefix,
 		config.Download.LocalPath,
 		config.SaveImage.IncludeOperators,
 		config.SaveImage.OperatorCatalog,
@@ -237,24 +360,63 @@ additional_images = []         # 额外的镜像列表
 	return nil
 }
 
+// defaultNodesConfig 生成配置文件模板中的节点配置段，只有每类节点的第一个带注释
+func defaultNodesConfig(config *ClusterConfig) string {
+	var b strings.Builder
+	writeNodes := func(table string, nodes []Node) {
+		for i, node := range nodes {
+			if i == 0 {
+				fmt.Fprintf(&b, "[[%s]]\nname = %q\nip = \"\"                        # 节点 IP (必填)\nmac = \"\"                       # 节点 MAC 地址 (必填)\n", table, node.Name)
+				continue
+			}
+			fmt.Fprintf(&b, "\n[[%s]]\nname = %q\nip = \"\"\nmac = \"\"\n", table, node.Name)
+		}
+	}
+
+	writeNodes("cluster.control_plane", config.Cluster.ControlPlane)
+	b.WriteString("\n# Worker 节点配置\n")
+	if len(config.Cluster.Worker) == 0 {
+		fmt.Fprintf(&b, "# %s 拓扑没有 Worker 节点，Control Plane 节点同时运行业务负载\n", config.ClusterInfo.Topology)
+	}
+	writeNodes("cluster.worker", config.Cluster.Worker)
+	return b.String()
+}
+
 // LoadConfig 从文件加载配置
+//
+// 旧版本的配置文件会在内存中迁移到当前 schema，未知的配置项将返回 *UnknownKeysError。
+// password = "secret:<name>" 形式的引用从集群目录的密钥库中解析。
+// openshift_version 为 stable-4.16 等版本通道时使用集群目录中 version.lock 记录的版本。
 func LoadConfig(filePath string) (*ClusterConfig, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("读取配置文件失败: %w", err)
 	}
 
-	config := &ClusterConfig{}
-	if err := toml.Unmarshal(data, config); err != nil {
-		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	config, _, err := decodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.resolveSecrets(filepath.Dir(filePath)); err != nil {
+		return nil, err
+	}
+	if err := config.applyVersionLock(filepath.Dir(filePath)); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
 // SaveConfig 保存配置到文件
+//
+// 整体重新序列化会丢失注释和原有顺序，修改单个配置项请使用 Document。
+// 从密钥库解析的配置项写回原始引用，不会写入明文；已解析的版本通道写回通道。
 func SaveConfig(config *ClusterConfig, filePath string) error {
-	data, err := toml.Marshal(config)
+	config.SchemaVersion = CurrentSchemaVersion
+	out := config.withSecretRefs()
+	out.ClusterInfo.OpenShiftVersion = config.RequestedVersion()
+	data, err := toml.Marshal(out)
 	if err != nil {
 		return fmt.Errorf("序列化配置失败: %w", err)
 	}
@@ -266,189 +428,24 @@ func SaveConfig(config *ClusterConfig, filePath string) error {
 	return nil
 }
 
-// ValidateConfig 验证配置是否有效
+// ValidateConfig 验证生成安装介质所需的完整配置
 func ValidateConfig(config *ClusterConfig) error {
-	// 验证集群基本信息
-	if config.ClusterInfo.Name == "" {
-		return fmt.Errorf("集群名称不能为空")
-	}
-	if config.ClusterInfo.Domain == "" {
-		return fmt.Errorf("集群域名不能为空")
-	}
-	if config.ClusterInfo.ClusterID == "" {
-		return fmt.Errorf("集群ID不能为空")
-	}
-	if config.ClusterInfo.OpenShiftVersion == "" {
-		return fmt.Errorf("OpenShift版本不能为空")
-	}
-
-	// 验证Bastion节点配置
-	if config.Bastion.IP == "" {
-		return fmt.Errorf("Bastion节点IP不能为空")
-	}
-	if config.Bastion.Username == "" {
-		return fmt.Errorf("Bastion节点用户名不能为空")
-	}
-	if config.Bastion.SSHKeyPath == "" && config.Bastion.Password == "" {
-		return fmt.Errorf("Bastion节点必须提供SSH密钥或密码")
-	}
-
-	// 验证Registry节点配置
-	if config.Registry.IP == "" {
-		return fmt.Errorf("Registry节点IP不能为空")
-	}
-	if config.Registry.Username == "" {
-		return fmt.Errorf("Registry节点用户名不能为空")
-	}
-	if config.Registry.SSHKeyPath == "" && config.Registry.Password == "" {
-		return fmt.Errorf("Registry节点必须提供SSH密钥或密码")
-	}
-	if config.Registry.StoragePath == "" {
-		return fmt.Errorf("Registry节点存储路径不能为空")
-	}
-
-	// 验证集群节点配置
-	if len(config.Cluster.ControlPlane) == 0 {
-		return fmt.Errorf("至少需要配置一个Control Plane节点")
-	}
-
-	for i, cp := range config.Cluster.ControlPlane {
-		if cp.Name == "" {
-			return fmt.Errorf("Control Plane节点[%d]名称不能为空", i)
-		}
-		if cp.IP == "" {
-			return fmt.Errorf("Control Plane节点[%d] %s 的IP不能为空", i, cp.Name)
-		}
-		if cp.MAC == "" {
-			return fmt.Errorf("Control Plane节点[%d] %s 的MAC地址不能为空", i, cp.Name)
-		}
-	}
-
-	for i, worker := range config.Cluster.Worker {
-		if worker.Name == "" {
-			return fmt.Errorf("Worker节点[%d]名称不能为空", i)
-		}
-		if worker.IP == "" {
-			return fmt.Errorf("Worker节点[%d] %s 的IP不能为空", i, worker.Name)
-		}
-		if worker.MAC == "" {
-			return fmt.Errorf("Worker节点[%d] %s 的MAC地址不能为空", i, worker.Name)
-		}
-	}
-
-	// 验证网络配置
-	if config.Cluster.Network.ClusterNetwork == "" {
-		return fmt.Errorf("集群网络CIDR不能为空")
-	}
-	if config.Cluster.Network.ServiceNetwork == "" {
-		return fmt.Errorf("服务网络CIDR不能为空")
-	}
-	if config.Cluster.Network.MachineNetwork == "" {
-		return fmt.Errorf("机器网络CIDR不能为空")
-	}
-
-	return nil
+	return Validate(config, StageISO).Err()
 }
 
 // ValidateBastionConfig 验证 Bastion 部署所需的配置
 func ValidateBastionConfig(config *ClusterConfig) error {
-	// 验证集群基本信息
-	if config.ClusterInfo.Name == "" {
-		return fmt.Errorf("集群名称不能为空")
-	}
-	if config.ClusterInfo.Domain == "" {
-		return fmt.Errorf("集群域名不能为空")
-	}
-	if config.ClusterInfo.ClusterID == "" {
-		return fmt.Errorf("集群ID不能为空")
-	}
-	if config.ClusterInfo.OpenShiftVersion == "" {
-		return fmt.Errorf("OpenShift版本不能为空")
-	}
-
-	// 验证Bastion节点配置
-	if config.Bastion.IP == "" {
-		return fmt.Errorf("Bastion节点IP不能为空")
-	}
-	if config.Bastion.Username == "" {
-		return fmt.Errorf("Bastion节点用户名不能为空")
-	}
-	if config.Bastion.SSHKeyPath == "" && config.Bastion.Password == "" {
-		return fmt.Errorf("Bastion节点必须提供SSH密钥或密码")
-	}
-
-	// 验证Registry节点IP（Bastion需要配置Registry的DNS解析）
-	if config.Registry.IP == "" {
-		return fmt.Errorf("Registry节点IP不能为空（Bastion需要配置Registry的DNS解析）")
-	}
-
-	// 验证集群节点配置（Bastion 需要这些信息来配置 DNS 和 HAProxy）
-	if len(config.Cluster.ControlPlane) == 0 {
-		return fmt.Errorf("至少需要配置一个Control Plane节点")
-	}
-
-	for i, cp := range config.Cluster.ControlPlane {
-		if cp.Name == "" {
-			return fmt.Errorf("Control Plane节点[%d]名称不能为空", i)
-		}
-		if cp.IP == "" {
-			return fmt.Errorf("Control Plane节点[%d] %s 的IP不能为空", i, cp.Name)
-		}
-		// MAC 地址对于 Bastion 部署不是必需的
-	}
-
-	for i, worker := range config.Cluster.Worker {
-		if worker.Name == "" {
-			return fmt.Errorf("Worker节点[%d]名称不能为空", i)
-		}
-		if worker.IP == "" {
-			return fmt.Errorf("Worker节点[%d] %s 的IP不能为空", i, worker.Name)
-		}
-		// MAC 地址对于 Bastion 部署不是必需的
-	}
-
-	// 验证网络配置
-	if config.Cluster.Network.ClusterNetwork == "" {
-		return fmt.Errorf("集群网络CIDR不能为空")
-	}
-	if config.Cluster.Network.ServiceNetwork == "" {
-		return fmt.Errorf("服务网络CIDR不能为空")
-	}
-	if config.Cluster.Network.MachineNetwork == "" {
-		return fmt.Errorf("机器网络CIDR不能为空")
-	}
-
-	return nil
+	return Validate(config, StageBastion).Err()
 }
 
 // ValidateRegistryConfig 验证 Registry 部署所需的配置
 func ValidateRegistryConfig(config *ClusterConfig) error {
-	// 验证集群基本信息
-	if config.ClusterInfo.Name == "" {
-		return fmt.Errorf("集群名称不能为空")
-	}
-	if config.ClusterInfo.OpenShiftVersion == "" {
-		return fmt.Errorf("OpenShift版本不能为空")
-	}
-
-	// 验证Registry节点配置
-	if config.Registry.IP == "" {
-		return fmt.Errorf("Registry节点IP不能为空")
-	}
-	if config.Registry.Username == "" {
-		return fmt.Errorf("Registry节点用户名不能为空")
-	}
-	if config.Registry.SSHKeyPath == "" && config.Registry.Password == "" {
-		return fmt.Errorf("Registry节点必须提供SSH密钥或密码")
-	}
-	if config.Registry.StoragePath == "" {
-		return fmt.Errorf("Registry节点存储路径不能为空")
-	}
-
-	return nil
+	return Validate(config, StageRegistry).Err()
 }
 
 // ValidateRegistryConfigWithDownloads 验证 Registry 部署所需的配置和下载文件
+//
+// Deprecated: 使用 preflight.Check(cfg, clusterDir, config.StageRegistry)，它同时报告所有缺失的文件。
 func ValidateRegistryConfigWithDownloads(config *ClusterConfig, downloadDir string) error {
 	// 先验证基本配置
 	if err := ValidateRegistryConfig(config); err != nil {
@@ -461,7 +458,7 @@ func ValidateRegistryConfigWithDownloads(config *ClusterConfig, downloadDir stri
 		description string
 	}{
 		{
-			path:        downloadDir + "/mirror-registry-amd64.tar.gz",
+			path:        downloadDir + "/" + config.MirrorRegistryArchive(),
 			description: "Quay 镜像仓库安装包",
 		},
 		{
@@ -489,10 +486,5 @@ func ValidateRegistryConfigWithDownloads(config *ClusterConfig, downloadDir stri
 
 // ValidateDownloadConfig 验证下载功能所需的配置
 func ValidateDownloadConfig(config *ClusterConfig) error {
-	// 验证集群基本信息
-	if config.ClusterInfo.OpenShiftVersion == "" {
-		return fmt.Errorf("OpenShift版本不能为空")
-	}
-
-	return nil
+	return Validate(config, StageDownload).Err()
 }
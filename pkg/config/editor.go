@@ -0,0 +1,525 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Document 保留注释、键顺序和格式的 config.toml 编辑器
+//
+// 只修改目标值所在的字节范围，文件其余部分原样保留。
+type Document struct {
+	data    []byte
+	entries []docEntry
+	tables  []docTable
+	// 第一个表头的位置，顶层键需要插入在它之前
+	firstHeader int
+}
+
+// docEntry 文档中的一个键值对
+type docEntry struct {
+	table      string // 规范化的表路径，如 cluster.control_plane[1]
+	key        string
+	valueStart int
+	valueEnd   int
+}
+
+// docTable 文档中的一个表
+type docTable struct {
+	path  string
+	start int // 表头所在行的起始位置
+	end   int // 表中最后一行 (表头或键值对) 的行尾位置
+}
+
+// pathSegmentPattern 匹配 TOML 路径中的一段，如 control_plane[1]
+var pathSegmentPattern = regexp.MustCompile(`^([A-Za-z0-9_-]+)(?:\[(\d+)\])?$`)
+
+// LoadDocument 读取配置文件用于编辑
+func LoadDocument(filePath string) (*Document, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	return ParseDocument(data)
+}
+
+// ParseDocument 解析配置内容用于编辑
+func ParseDocument(data []byte) (*Document, error) {
+	d := &Document{data: append([]byte(nil), data...)}
+	if err := d.scan(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Bytes 返回编辑后的文档内容
+func (d *Document) Bytes() []byte {
+	return d.data
+}
+
+// Save 写回配置文件，写入前以严格模式校验编辑结果
+func (d *Document) Save(filePath string) error {
+	if _, _, err := decodeConfig(d.data); err != nil {
+		return fmt.Errorf("编辑后的配置无效: %w", err)
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(filePath, d.data, mode); err != nil {
+		return fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	return nil
+}
+
+// Set 设置指定路径的值，如 bastion.ip 或 cluster.control_plane[1].mac
+//
+// 已存在的键只替换值本身，行尾注释保持原来的列位置；跨行数组内部的注释属于值的一部分，
+// 会随旧值一起被替换。不存在的键追加到所在表的末尾。
+func (d *Document) Set(path string, value interface{}) error {
+	table, key, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	formatted, err := formatValue(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if entry := d.find(table, key); entry != nil {
+		d.replaceValue(entry, formatted)
+		return d.scan()
+	}
+
+	line := fmt.Sprintf("%s = %s", key, formatted)
+	switch tbl := d.findTable(table); {
+	case table == "":
+		if d.firstHeader < 0 {
+			d.insert(len(d.data), d.lineBreakBefore(len(d.data))+line+"\n")
+		} else {
+			d.insert(d.firstHeader, line+"\n\n")
+		}
+	case tbl != nil:
+		d.insert(tbl.end, "\n"+line)
+	case strings.Contains(table, "["):
+		return fmt.Errorf("%s: 表 %s 不存在", path, table)
+	default:
+		d.insert(len(d.data), d.lineBreakBefore(len(d.data))+fmt.Sprintf("\n[%s]\n%s\n", table, line))
+	}
+	return d.scan()
+}
+
+// Remove 删除指定路径的键或表，如 operators 会删除所有 [[operators]] 表及其子表
+//
+// 键连同所在行一起删除；表从表头删除到表中最后一个键值对，并删除表头前的空行。
+// 路径不存在时不做任何修改。
+func (d *Document) Remove(path string) error {
+	table, key, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	if entry := d.find(table, key); entry != nil {
+		start := bytes.LastIndexByte(d.data[:entry.valueStart], '\n') + 1
+		d.cut(start, lineEnd(d.data, entry.valueEnd))
+		if err := d.scan(); err != nil {
+			return err
+		}
+	}
+	for {
+		i := slices.IndexFunc(d.tables, func(t docTable) bool {
+			return t.path == path || strings.HasPrefix(t.path, path+"[") || strings.HasPrefix(t.path, path+".")
+		})
+		if i < 0 {
+			return nil
+		}
+		start := d.tables[i].start
+		for start > 0 {
+			prev := bytes.LastIndexByte(d.data[:start-1], '\n') + 1
+			if len(bytes.TrimSpace(d.data[prev:start])) > 0 {
+				break
+			}
+			start = prev
+		}
+		d.cut(start, d.tables[i].end)
+		if err := d.scan(); err != nil {
+			return err
+		}
+	}
+}
+
+// SetConfigValue 按 ClusterConfig 中的字段类型解析字符串值并写回配置文件
+//
+// 供 ocpack config set 使用，列表类型的值以逗号分隔。
+func SetConfigValue(filePath, path, value string) error {
+	kind, err := fieldKind(path)
+	if err != nil {
+		return err
+	}
+
+	var typed interface{}
+	switch kind {
+	case reflect.String:
+		typed = value
+	case reflect.Bool:
+		if typed, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s 需要布尔值 (true/false): %s", path, value)
+		}
+	case reflect.Int:
+		if typed, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s 需要整数: %s", path, value)
+		}
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		typed = items
+	default:
+		return fmt.Errorf("%s 不是可直接设置的值", path)
+	}
+
+	doc, err := LoadDocument(filePath)
+	if err != nil {
+		return err
+	}
+	if err := doc.Set(path, typed); err != nil {
+		return err
+	}
+	return doc.Save(filePath)
+}
+
+// fieldKind 根据 toml 标签查找 ClusterConfig 中对应字段的类型
+func fieldKind(path string) (reflect.Kind, error) {
+	t := reflect.TypeOf(ClusterConfig{})
+	for _, segment := range strings.Split(path, ".") {
+		m := pathSegmentPattern.FindStringSubmatch(segment)
+		if m == nil || t.Kind() != reflect.Struct {
+			return reflect.Invalid, fmt.Errorf("未知的配置项: %s", path)
+		}
+		field, ok := fieldByTag(t, m[1])
+		if !ok {
+			return reflect.Invalid, fmt.Errorf("未知的配置项: %s", path)
+		}
+		t = field.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if m[2] != "" {
+			if t.Kind() != reflect.Slice {
+				return reflect.Invalid, fmt.Errorf("配置项 %s 不是数组", m[1])
+			}
+			t = t.Elem()
+		}
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.String {
+		return reflect.Invalid, fmt.Errorf("%s 不是可直接设置的值", path)
+	}
+	return t.Kind(), nil
+}
+
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.Split(field.Tag.Get("toml"), ",")[0] == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// splitPath 将 a.b[1].c 拆分为表路径 a.b[1] 和键 c
+func splitPath(path string) (string, string, error) {
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if !pathSegmentPattern.MatchString(segment) {
+			return "", "", fmt.Errorf("无效的配置路径: %s", path)
+		}
+	}
+	key := segments[len(segments)-1]
+	if strings.Contains(key, "[") {
+		return "", "", fmt.Errorf("配置路径必须以键名结尾: %s", path)
+	}
+	return strings.Join(segments[:len(segments)-1], "."), key, nil
+}
+
+// formatValue 将 Go 值格式化为 TOML 字面量
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return quoteString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case []string:
+		quoted := make([]string, len(v))
+		for i, item := range v {
+			quoted[i] = quoteString(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]", nil
+	default:
+		return "", fmt.Errorf("不支持的值类型 %T", value)
+	}
+}
+
+// quoteString 生成 TOML 基本字符串
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func (d *Document) find(table, key string) *docEntry {
+	for i := range d.entries {
+		if d.entries[i].table == table && d.entries[i].key == key {
+			return &d.entries[i]
+		}
+	}
+	return nil
+}
+
+func (d *Document) findTable(path string) *docTable {
+	for i := range d.tables {
+		if d.tables[i].path == path {
+			return &d.tables[i]
+		}
+	}
+	return nil
+}
+
+// replaceValue 替换值，并调整与行尾注释之间的空白使注释列位置不变
+func (d *Document) replaceValue(entry *docEntry, formatted string) {
+	old := string(d.data[entry.valueStart:entry.valueEnd])
+	end := entry.valueEnd
+
+	if !strings.Contains(old, "\n") {
+		pad := 0
+		for end+pad < len(d.data) && (d.data[end+pad] == ' ' || d.data[end+pad] == '\t') {
+			pad++
+		}
+		if end+pad < len(d.data) && d.data[end+pad] == '#' {
+			newPad := pad - (len(formatted) - len(old))
+			if newPad < 1 {
+				newPad = 1
+			}
+			end += pad
+			formatted += strings.Repeat(" ", newPad)
+		}
+	}
+
+	d.data = append(d.data[:entry.valueStart:entry.valueStart], append([]byte(formatted), d.data[end:]...)...)
+}
+
+// cut 删除 [start, end) 以及 end 处的换行
+func (d *Document) cut(start, end int) {
+	if end < len(d.data) && d.data[end] == '\n' {
+		end++
+	}
+	d.data = append(d.data[:start:start], d.data[end:]...)
+}
+
+func (d *Document) insert(offset int, text string) {
+	d.data = append(d.data[:offset:offset], append([]byte(text), d.data[offset:]...)...)
+}
+
+// lineBreakBefore 在非空且不以换行结尾的内容后追加时返回需要补充的换行
+func (d *Document) lineBreakBefore(offset int) string {
+	if offset > 0 && d.data[offset-1] != '\n' {
+		return "\n"
+	}
+	return ""
+}
+
+// --- Scanner ---
+
+// scan 记录所有表和键值对的位置
+func (d *Document) scan() error {
+	d.entries = nil
+	d.tables = []docTable{{path: ""}}
+	d.firstHeader = -1
+	arrayCounts := make(map[string]int)
+	current := &d.tables[0]
+
+	data := d.data
+	pos := 0
+	for pos < len(data) {
+		lineStart := pos
+		for pos < len(data) && (data[pos] == ' ' || data[pos] == '\t') {
+			pos++
+		}
+		if pos >= len(data) {
+			break
+		}
+
+		switch c := data[pos]; {
+		case c == '\n' || c == '\r' || c == '#':
+			pos = lineEnd(data, pos)
+		case c == '[':
+			isArray := pos+1 < len(data) && data[pos+1] == '['
+			end := lineEnd(data, pos)
+			header := string(data[pos:end])
+			if i := strings.Index(header, "#"); i >= 0 {
+				header = header[:i]
+			}
+			header = strings.TrimSpace(header)
+			if isArray {
+				header = strings.TrimSuffix(strings.TrimPrefix(header, "[["), "]]")
+			} else {
+				header = strings.TrimSuffix(strings.TrimPrefix(header, "["), "]")
+			}
+			if d.firstHeader < 0 {
+				d.firstHeader = lineStart
+			}
+			d.tables = append(d.tables, docTable{path: resolveHeader(header, isArray, arrayCounts), start: lineStart, end: end})
+			current = &d.tables[len(d.tables)-1]
+			pos = end
+		default:
+			eq := bytes.IndexByte(data[pos:lineEnd(data, pos)], '=')
+			if eq < 0 {
+				return fmt.Errorf("解析配置文件失败: 第 %d 行缺少 '='", lineNumber(data, pos))
+			}
+			key := strings.Trim(strings.TrimSpace(string(data[pos:pos+eq])), `"'`)
+			pos += eq + 1
+			for pos < len(data) && (data[pos] == ' ' || data[pos] == '\t') {
+				pos++
+			}
+			valueEnd, err := scanValue(data, pos)
+			if err != nil {
+				return fmt.Errorf("解析配置文件失败: 第 %d 行: %w", lineNumber(data, pos), err)
+			}
+			d.entries = append(d.entries, docEntry{table: current.path, key: key, valueStart: pos, valueEnd: valueEnd})
+			pos = lineEnd(data, valueEnd)
+			current.end = pos
+		}
+		if pos < len(data) && data[pos] == '\n' {
+			pos++
+		}
+	}
+	return nil
+}
+
+// resolveHeader 将表头规范化为带数组下标的路径
+func resolveHeader(header string, isArray bool, arrayCounts map[string]int) string {
+	parts := strings.Split(header, ".")
+	path := ""
+	for i, part := range parts {
+		part = strings.Trim(strings.TrimSpace(part), `"'`)
+		if path != "" {
+			path += "."
+		}
+		path += part
+		if i == len(parts)-1 && isArray {
+			break
+		}
+		if n, ok := arrayCounts[path]; ok {
+			path = fmt.Sprintf("%s[%d]", path, n-1)
+		}
+	}
+	if isArray {
+		index := arrayCounts[path]
+		arrayCounts[path] = index + 1
+		path = fmt.Sprintf("%s[%d]", path, index)
+	}
+	return path
+}
+
+// scanValue 返回从 pos 开始的值的结束位置，支持多行字符串和跨行数组
+func scanValue(data []byte, pos int) (int, error) {
+	if pos >= len(data) {
+		return pos, fmt.Errorf("缺少值")
+	}
+	switch data[pos] {
+	case '"', '\'':
+		quote := data[pos : pos+1]
+		if pos+2 < len(data) && data[pos+1] == data[pos] && data[pos+2] == data[pos] {
+			delim := strings.Repeat(string(quote), 3)
+			i := bytes.Index(data[pos+3:], []byte(delim))
+			if i < 0 {
+				return pos, fmt.Errorf("多行字符串未结束")
+			}
+			end := pos + 3 + i + 3
+			// 结束符后允许最多两个额外的引号
+			for n := 0; n < 2 && end < len(data) && data[end] == quote[0]; n++ {
+				end++
+			}
+			return end, nil
+		}
+		for i := pos + 1; i < len(data) && data[i] != '\n'; i++ {
+			if data[i] == '\\' && quote[0] == '"' {
+				i++
+				continue
+			}
+			if data[i] == quote[0] {
+				return i + 1, nil
+			}
+		}
+		return pos, fmt.Errorf("字符串未结束")
+	case '[', '{':
+		depth := 0
+		for i := pos; i < len(data); i++ {
+			switch data[i] {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			case '#':
+				i = lineEnd(data, i) - 1
+			case '"', '\'':
+				end, err := scanValue(data, i)
+				if err != nil {
+					return pos, err
+				}
+				i = end - 1
+			}
+		}
+		return pos, fmt.Errorf("数组未结束")
+	default:
+		end := pos
+		for end < len(data) && !strings.ContainsRune(" \t\r\n#", rune(data[end])) {
+			end++
+		}
+		return end, nil
+	}
+}
+
+// lineEnd 返回 pos 所在行的换行符位置
+func lineEnd(data []byte, pos int) int {
+	if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+		return pos + i
+	}
+	return len(data)
+}
+
+func lineNumber(data []byte, pos int) int {
+	return bytes.Count(data[:pos], []byte("\n")) + 1
+}
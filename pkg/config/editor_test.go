@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocumentSetPreservesComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := GenerateDefaultConfig(path, "demo"); err != nil {
+		t.Fatal(err)
+	}
+	original, _ := os.ReadFile(path)
+
+	if err := SetConfigValue(path, "bastion.ip", "10.0.0.5"); err != nil {
+		t.Fatalf("SetConfigValue(bastion.ip) error = %v", err)
+	}
+	if err := SetConfigValue(path, "cluster.control_plane[1].mac", "52:54:00:00:00:02"); err != nil {
+		t.Fatalf("SetConfigValue(cluster.control_plane[1].mac) error = %v", err)
+	}
+	if err := SetConfigValue(path, "save_image.include_operators", "true"); err != nil {
+		t.Fatalf("SetConfigValue(save_image.include_operators) error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	content := string(data)
+	for _, line := range []string{
+		`ip = "10.0.0.5"                # Bastion 节点 IP (必填)`,
+		`include_operators = true          # 是否包含 Operator 镜像`,
+		"[[cluster.control_plane]]\nname = \"master-1\"\nip = \"\"\nmac = \"52:54:00:00:00:02\"\n",
+		"# Control Plane 节点配置",
+	} {
+		if !strings.Contains(content, line) {
+			t.Errorf("edited file does not contain %q", line)
+		}
+	}
+	if got, want := strings.Count(content, "\n"), strings.Count(string(original), "\n"); got != want {
+		t.Errorf("edited file has %d lines, expected %d", got, want)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Bastion.IP != "10.0.0.5" || cfg.Cluster.ControlPlane[1].MAC != "52:54:00:00:00:02" || !cfg.SaveImage.IncludeOperators {
+		t.Errorf("LoadConfig() did not see the edited values: %+v", cfg)
+	}
+}
+
+func TestDocumentSetInsertsMissingKeys(t *testing.T) {
+	doc, err := ParseDocument([]byte("# header\n[bastion]\nip = \"192.168.1.10\" # bastion\n\n[download]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		path  string
+		value interface{}
+	}{
+		{"bastion.username", "root"},
+		{"download.local_path", "downloads"},
+		{"save_image.ops", []string{"cluster-logging"}},
+		{"schema_version", 3},
+	} {
+		if err := doc.Set(tt.path, tt.value); err != nil {
+			t.Fatalf("Set(%s) error = %v", tt.path, err)
+		}
+	}
+
+	expected := `# header
+schema_version = 3
+
+[bastion]
+ip = "192.168.1.10" # bastion
+username = "root"
+
+[download]
+local_path = "downloads"
+
+[save_image]
+ops = ["cluster-logging"]
+`
+	if got := string(doc.Bytes()); got != expected {
+		t.Errorf("Bytes() = %q, expected %q", got, expected)
+	}
+}
+
+func TestSetConfigValueRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := GenerateDefaultConfig(path, "demo"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"bastion.pasword", "cluster.worker[5].ip", "save_image.include_operators"} {
+		value := "x"
+		if err := SetConfigValue(path, key, value); err == nil {
+			t.Errorf("SetConfigValue(%s, %s) expected error", key, value)
+		}
+	}
+}
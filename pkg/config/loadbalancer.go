@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"ocpack/pkg/utils"
+)
+
+// API 和 Ingress 的负载均衡方式
+const (
+	// LoadBalancerBastionHAProxy DNS 指向 Bastion，由 Bastion 上的 HAProxy 转发 (默认)
+	LoadBalancerBastionHAProxy = "bastion-haproxy"
+	// LoadBalancerHAProxyHA 两台节点运行 HAProxy，keepalived 管理 API 和 Ingress VIP，DNS 指向 VIP
+	LoadBalancerHAProxyHA = "haproxy-ha"
+	// LoadBalancerExternal DNS 指向用户提供的负载均衡地址，不部署 HAProxy
+	LoadBalancerExternal = "external"
+)
+
+// DefaultVirtualRouterID keepalived 中 API VIP 默认的 VRRP 路由 ID
+const DefaultVirtualRouterID = 51
+
+// loadBalancerNodeCount haproxy-ha 模式的 HAProxy 节点数量
+const loadBalancerNodeCount = 2
+
+// LoadBalancerNode haproxy-ha 模式的 HAProxy 节点，通过 [bastion] 的 SSH 用户和认证方式连接
+type LoadBalancerNode struct {
+	Name      string `toml:"name"`
+	IP        string `toml:"ip"`
+	IPv6      string `toml:"ipv6,omitempty"`      // 双栈集群中的 IPv6 地址
+	Interface string `toml:"interface,omitempty"` // keepalived 绑定 VIP 的网卡，默认为默认路由所在的网卡
+}
+
+// LoadBalancerModes 返回所有支持的负载均衡方式
+func LoadBalancerModes() []string {
+	return []string{LoadBalancerBastionHAProxy, LoadBalancerHAProxyHA, LoadBalancerExternal}
+}
+
+// LoadBalancerMode 返回 load_balancer.mode，未配置时为 bastion-haproxy
+func (c *ClusterConfig) LoadBalancerMode() string {
+	if c.LoadBalancer.Mode == "" {
+		return LoadBalancerBastionHAProxy
+	}
+	return c.LoadBalancer.Mode
+}
+
+// VirtualRouterID 返回 API VIP 的 VRRP 路由 ID，Ingress VIP 使用下一个
+func (c *ClusterConfig) VirtualRouterID() int {
+	if c.LoadBalancer.VirtualRouterID == 0 {
+		return DefaultVirtualRouterID
+	}
+	return c.LoadBalancer.VirtualRouterID
+}
+
+// LoadBalancerSSH 返回连接 HAProxy 节点的 SSH 选项，用户和认证方式与 Bastion 相同
+func (c *ClusterConfig) LoadBalancerSSH(clusterDir string, node LoadBalancerNode) utils.SSHOptions {
+	opts := c.BastionSSH(clusterDir)
+	opts.Host = node.IP
+	return opts
+}
+
+// checkLoadBalancer 检查 [load_balancer] 配置段
+func checkLoadBalancer(cfg *ClusterConfig, r *ValidationResult) {
+	lb := cfg.LoadBalancer
+	mode := cfg.LoadBalancerMode()
+	switch mode {
+	case LoadBalancerBastionHAProxy, LoadBalancerHAProxyHA, LoadBalancerExternal:
+	default:
+		r.addError("load_balancer.mode", fmt.Sprintf("不支持的负载均衡方式: %s", lb.Mode), "可选值: "+strings.Join(LoadBalancerModes(), ", "))
+		return
+	}
+
+	if mode == LoadBalancerBastionHAProxy {
+		if lb.APIVIP != "" || lb.IngressVIP != "" || len(lb.Nodes) > 0 {
+			r.addWarning("load_balancer", "bastion-haproxy 模式不使用 api_vip、ingress_vip 和 nodes", "设置 mode = \"haproxy-ha\" 或 \"external\"")
+		}
+		return
+	}
+	if cfg.Topology() == TopologySNO {
+		r.addWarning("load_balancer.mode", fmt.Sprintf("sno 拓扑的 API 和应用入口直接指向节点，%s 模式不生效", mode), "")
+		return
+	}
+
+	checkIPField(r, "load_balancer.api_vip", "API 地址", lb.APIVIP, "api 和 api-int 记录指向的地址")
+	checkIPField(r, "load_balancer.ingress_vip", "Ingress 地址", lb.IngressVIP, "*.apps 记录指向的地址")
+	checkIPv6Field(r, "load_balancer.api_vip_v6", "API 地址", lb.APIVIP, lb.APIVIPv6)
+	checkIPv6Field(r, "load_balancer.ingress_vip_v6", "Ingress 地址", lb.IngressVIP, lb.IngressVIPv6)
+	if cfg.IsDualStack() && !utils.IsIPv6(lb.APIVIP) && (lb.APIVIPv6 == "" || lb.IngressVIPv6 == "") {
+		r.addWarning("load_balancer", "双栈集群没有设置 api_vip_v6 和 ingress_vip_v6，IPv6 客户端无法访问 API 和应用", "")
+	}
+
+	if mode == LoadBalancerExternal {
+		if len(lb.Nodes) > 0 {
+			r.addWarning("load_balancer.nodes", "external 模式不部署 HAProxy，nodes 不生效", "")
+		}
+		return
+	}
+	checkHAProxyHA(cfg, r)
+}
+
+// checkHAProxyHA 检查 haproxy-ha 模式的节点和 VIP
+func checkHAProxyHA(cfg *ClusterConfig, r *ValidationResult) {
+	lb := cfg.LoadBalancer
+	if len(lb.Nodes) != loadBalancerNodeCount {
+		r.addError("load_balancer.nodes", fmt.Sprintf("haproxy-ha 模式需要 %d 个 HAProxy 节点，当前为 %d 个", loadBalancerNodeCount, len(lb.Nodes)), "")
+	}
+
+	// VIP 不能与任何主机地址相同，keepalived 只能在同一个二层网络中漂移 VIP
+	used := map[string]string{cfg.Bastion.IP: "bastion.ip", cfg.Registry.IP: "registry.ip"}
+	for _, n := range collectNodes(cfg) {
+		used[n.ip] = n.path + ".ip"
+	}
+	names := map[string]bool{}
+	for i, node := range lb.Nodes {
+		path := fmt.Sprintf("load_balancer.nodes[%d]", i)
+		label := fmt.Sprintf("HAProxy节点[%d]", i)
+		if node.Name == "" {
+			r.addError(path+".name", label+"名称不能为空", "例如 lb-0")
+		} else if names[node.Name] {
+			r.addError(path+".name", fmt.Sprintf("HAProxy节点名称重复: %s", node.Name), "")
+		}
+		names[node.Name] = true
+		checkIPField(r, path+".ip", label+"IP", node.IP, "")
+		checkIPv6Field(r, path+".ipv6", label, node.IP, node.IPv6)
+		if other, ok := used[node.IP]; ok && node.IP != "" && other != "bastion.ip" {
+			r.addError(path+".ip", fmt.Sprintf("%s的 IP %s 与 %s 相同", label, node.IP, other), "")
+		}
+		used[node.IP] = path + ".ip"
+		if node.IP != "" && lb.APIVIP != "" && utils.IsIPv6(node.IP) != utils.IsIPv6(lb.APIVIP) {
+			r.addError(path+".ip", fmt.Sprintf("%s的地址族与 api_vip 不一致", label), "keepalived 通过节点的主地址发送 VRRP 报文")
+		}
+	}
+
+	for _, vip := range []struct{ path, ip string }{
+		{"load_balancer.api_vip", lb.APIVIP},
+		{"load_balancer.ingress_vip", lb.IngressVIP},
+		{"load_balancer.api_vip_v6", lb.APIVIPv6},
+		{"load_balancer.ingress_vip_v6", lb.IngressVIPv6},
+	} {
+		if vip.ip == "" || !utils.IsValidIP(vip.ip) {
+			continue
+		}
+		if other, ok := used[vip.ip]; ok {
+			r.addError(vip.path, fmt.Sprintf("VIP %s 与 %s 相同", vip.ip, other), "VIP 必须是未被使用的地址")
+		}
+		if cidr := machineNetworkFor(cfg.Cluster.Network.MachineNetwork, utils.IsIPv6(vip.ip)); cidr != "" {
+			if inside, _ := utils.CIDRContainsIP(cidr, vip.ip); !inside {
+				r.addError(vip.path, fmt.Sprintf("VIP %s 不在机器网络 %s 中", vip.ip, cidr), "keepalived 只能在节点所在的网络中漂移 VIP")
+			}
+		}
+	}
+
+	id := cfg.VirtualRouterID()
+	if id < 1 || id > 254 {
+		r.addError("load_balancer.virtual_router_id", fmt.Sprintf("VRRP 路由 ID 无效: %d", id), "取值范围为 1-254，Ingress VIP 使用下一个 ID")
+	}
+}
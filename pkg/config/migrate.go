@@ -0,0 +1,340 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// CurrentSchemaVersion 当前配置文件的 schema 版本
+//
+// 未包含 schema_version 的配置文件视为版本 1。
+const CurrentSchemaVersion = 3
+
+// migration 将配置文档从 From 版本升级到 From+1
+type migration struct {
+	From        int
+	Description string
+	Apply       func(doc map[string]interface{}) error
+	// Edit 在保留注释的文档中改写 Apply 修改的配置项，doc 为执行 Apply 之后的配置
+	Edit func(d *Document, doc map[string]interface{}) error
+}
+
+// migrations 按版本顺序注册的迁移步骤，新增字段或调整结构时在末尾追加
+var migrations = []migration{
+	{
+		From:        1,
+		Description: "将 [[operators]] 列表合并到 save_image.ops",
+		Apply:       migrateOperatorsToSaveImageOps,
+		Edit:        editOperatorsToSaveImageOps,
+	},
+	{
+		From:        2,
+		Description: "cluster.network 中的网段改为列表以支持双栈",
+		Apply:       migrateNetworksToLists,
+		Edit:        editNetworksToLists,
+	},
+}
+
+// MigrationResult 配置文件迁移结果
+type MigrationResult struct {
+	FromVersion int
+	ToVersion   int
+	Applied     []string
+	BackupPath  string
+	// Regenerated 无法在原文件上改写时为 true，此时文件按当前格式重新生成，注释和格式丢失 (备份中保留)
+	Regenerated bool
+}
+
+// UnknownKeysError 配置文件中包含无法识别的配置项
+type UnknownKeysError struct {
+	Keys []string
+}
+
+func (e *UnknownKeysError) Error() string {
+	return fmt.Sprintf("配置文件包含未知的配置项 (请检查拼写): %s", strings.Join(e.Keys, ", "))
+}
+
+// decodeConfig 解析配置内容，必要时在内存中执行迁移，并拒绝未知的配置项
+func decodeConfig(data []byte) (*ClusterConfig, []string, error) {
+	doc := map[string]interface{}{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+
+	version, err := schemaVersionOf(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied, err := migrateDocument(doc, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(applied) > 0 {
+		if data, err = toml.Marshal(doc); err != nil {
+			return nil, nil, fmt.Errorf("序列化迁移后的配置失败: %w", err)
+		}
+	}
+
+	config := &ClusterConfig{}
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		var strictErr *toml.StrictMissingError
+		if errors.As(err, &strictErr) {
+			return nil, nil, unknownKeysError(strictErr, len(applied) == 0)
+		}
+		return nil, nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	config.SchemaVersion = CurrentSchemaVersion
+	config.loadedSchemaVersion = version
+
+	return config, applied, nil
+}
+
+// schemaVersionOf 读取文档中的 schema_version，缺省为 1
+func schemaVersionOf(doc map[string]interface{}) (int, error) {
+	raw, ok := doc["schema_version"]
+	if !ok {
+		return 1, nil
+	}
+	version, ok := raw.(int64)
+	if !ok || version < 1 {
+		return 0, fmt.Errorf("schema_version 必须为正整数: %v", raw)
+	}
+	if version > CurrentSchemaVersion {
+		return 0, fmt.Errorf("配置文件 schema_version %d 高于当前支持的版本 %d，请升级 ocpack", version, CurrentSchemaVersion)
+	}
+	return int(version), nil
+}
+
+// migrateDocument 从指定版本开始逐步执行迁移，返回已执行步骤的描述
+func migrateDocument(doc map[string]interface{}, version int) ([]string, error) {
+	var applied []string
+	for _, m := range migrations {
+		if m.From < version {
+			continue
+		}
+		if err := m.Apply(doc); err != nil {
+			return nil, fmt.Errorf("配置迁移 v%d -> v%d 失败: %w", m.From, m.From+1, err)
+		}
+		applied = append(applied, fmt.Sprintf("v%d -> v%d: %s", m.From, m.From+1, m.Description))
+	}
+	doc["schema_version"] = int64(CurrentSchemaVersion)
+	return applied, nil
+}
+
+// unknownKeysError 将严格解码错误转换为未知配置项列表，未迁移时附带行号
+func unknownKeysError(strictErr *toml.StrictMissingError, withPosition bool) error {
+	var keys []string
+	for i := range strictErr.Errors {
+		key := strings.Join(strictErr.Errors[i].Key(), ".")
+		if withPosition {
+			row, _ := strictErr.Errors[i].Position()
+			key = fmt.Sprintf("%s (第 %d 行)", key, row)
+		}
+		keys = append(keys, key)
+	}
+	return &UnknownKeysError{Keys: keys}
+}
+
+// MigrateConfigFile 将配置文件升级到当前 schema 版本，原文件备份为 <file>.v<N>.bak
+func MigrateConfigFile(filePath string) (*MigrationResult, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	config, applied, err := decodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MigrationResult{
+		FromVersion: config.loadedSchemaVersion,
+		ToVersion:   CurrentSchemaVersion,
+		Applied:     applied,
+	}
+	if len(applied) == 0 {
+		return result, nil
+	}
+
+	result.BackupPath = fmt.Sprintf("%s.v%d.bak", filePath, result.FromVersion)
+	if _, err := os.Stat(result.BackupPath); err == nil {
+		result.BackupPath = fmt.Sprintf("%s.v%d.%s.bak", filePath, result.FromVersion, time.Now().Format("20060102150405"))
+	}
+	if err := os.WriteFile(result.BackupPath, data, 0600); err != nil {
+		return nil, fmt.Errorf("备份配置文件失败: %w", err)
+	}
+
+	// 只改写迁移涉及的配置项和 schema_version，保留注释和格式。
+	// 改写结果与内存中的迁移不一致时退回到重新生成整个文件
+	if doc, err := editDocument(data, result.FromVersion); err == nil && sameMigration(doc, config) {
+		if err := doc.Save(filePath); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	result.Regenerated = true
+	if err := SaveConfig(config, filePath); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// editDocument 在保留注释的文档中从指定版本开始执行迁移
+func editDocument(data []byte, version int) (*Document, error) {
+	d, err := ParseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	for _, m := range migrations {
+		if m.From < version {
+			continue
+		}
+		if err := m.Apply(doc); err != nil {
+			return nil, fmt.Errorf("配置迁移 v%d -> v%d 失败: %w", m.From, m.From+1, err)
+		}
+		if err := m.Edit(d, doc); err != nil {
+			return nil, fmt.Errorf("配置迁移 v%d -> v%d 失败: %w", m.From, m.From+1, err)
+		}
+	}
+	if err := d.Set("schema_version", int64(CurrentSchemaVersion)); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// sameMigration 检查改写后的文档与内存中迁移得到的配置是否相同
+func sameMigration(d *Document, config *ClusterConfig) bool {
+	edited, _, err := decodeConfig(d.Bytes())
+	if err != nil {
+		return false
+	}
+	edited.loadedSchemaVersion = config.loadedSchemaVersion
+	return reflect.DeepEqual(edited, config)
+}
+
+// stringList 将 TOML 数组转换为字符串列表
+func stringList(values []interface{}) []string {
+	list := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// migrateOperatorsToSaveImageOps v1 -> v2: 顶层 [[operators]] 从未被使用，
+// 其名称合并到 save_image.ops，namespace 由 oc-mirror 从 catalog 中确定
+func migrateOperatorsToSaveImageOps(doc map[string]interface{}) error {
+	raw, ok := doc["operators"]
+	if !ok {
+		return nil
+	}
+	delete(doc, "operators")
+
+	operators, ok := raw.([]interface{})
+	if !ok {
+		return fmt.Errorf("operators 必须为 [[operators]] 表数组")
+	}
+
+	saveImage, ok := doc["save_image"].(map[string]interface{})
+	if !ok {
+		saveImage = map[string]interface{}{}
+		doc["save_image"] = saveImage
+	}
+	ops, _ := saveImage["ops"].([]interface{})
+
+	seen := make(map[string]bool)
+	for _, op := range ops {
+		if name, ok := op.(string); ok {
+			seen[name] = true
+		}
+	}
+	for i, op := range operators {
+		table, ok := op.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("operators[%d] 格式无效", i)
+		}
+		name, _ := table["name"].(string)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		ops = append(ops, name)
+	}
+	if ops != nil {
+		saveImage["ops"] = ops
+	}
+	return nil
+}
+
+// editOperatorsToSaveImageOps 删除 [[operators]] 表并改写 save_image.ops
+func editOperatorsToSaveImageOps(d *Document, doc map[string]interface{}) error {
+	size := len(d.data)
+	if err := d.Remove("operators"); err != nil || len(d.data) == size {
+		return err
+	}
+	saveImage, _ := doc["save_image"].(map[string]interface{})
+	if ops, ok := saveImage["ops"].([]interface{}); ok {
+		return d.Set("save_image.ops", stringList(ops))
+	}
+	return nil
+}
+
+// migrateNetworksToLists v2 -> v3: cluster_network、service_network 和 machine_network
+// 由单个 CIDR 字符串改为 CIDR 列表
+func migrateNetworksToLists(doc map[string]interface{}) error {
+	cluster, ok := doc["cluster"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	network, ok := cluster["network"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for _, key := range []string{"cluster_network", "service_network", "machine_network"} {
+		switch value := network[key].(type) {
+		case nil, []interface{}:
+		case string:
+			if value == "" {
+				network[key] = []interface{}{}
+			} else {
+				network[key] = []interface{}{value}
+			}
+		default:
+			return fmt.Errorf("cluster.network.%s 格式无效: %v", key, value)
+		}
+	}
+	return nil
+}
+
+// editNetworksToLists 把仍为字符串的网段改写为列表
+func editNetworksToLists(d *Document, doc map[string]interface{}) error {
+	cluster, _ := doc["cluster"].(map[string]interface{})
+	network, _ := cluster["network"].(map[string]interface{})
+	for _, key := range []string{"cluster_network", "service_network", "machine_network"} {
+		entry := d.find("cluster.network", key)
+		if entry == nil || !strings.ContainsAny(string(d.data[entry.valueStart]), `"'`) {
+			continue
+		}
+		list, _ := network[key].([]interface{})
+		if err := d.Set("cluster.network."+key, stringList(list)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const legacyConfig = `[cluster_info]
+name = "demo"
+domain = "example.com"
+
+[cluster.network]
+machine_network = "192.168.1.0/24"
+
+[save_image]
+ops = ["cluster-logging"]
+
+[[operators]]
+name = "cluster-logging"
+namespace = "openshift-logging"
+
+[[operators]]
+name = "local-storage-operator"
+namespace = "openshift-local-storage"
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigMigratesLegacyFile(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, legacyConfig))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.SchemaVersion != CurrentSchemaVersion || cfg.LoadedSchemaVersion() != 1 {
+		t.Errorf("schema version = %d (loaded %d), expected %d (loaded 1)", cfg.SchemaVersion, cfg.LoadedSchemaVersion(), CurrentSchemaVersion)
+	}
+	expected := []string{"cluster-logging", "local-storage-operator"}
+	if strings.Join(cfg.SaveImage.Ops, ",") != strings.Join(expected, ",") {
+		t.Errorf("SaveImage.Ops = %v, expected %v", cfg.SaveImage.Ops, expected)
+	}
+	if got := cfg.Cluster.Network.MachineNetwork; len(got) != 1 || got[0] != "192.168.1.0/24" {
+		t.Errorf("Cluster.Network.MachineNetwork = %v, expected [192.168.1.0/24]", got)
+	}
+}
+
+func TestLoadConfigRejectsUnknownKeys(t *testing.T) {
+	content := fmt.Sprintf("schema_version = %d\n\n[bastion]\nip = \"192.168.1.10\"\npasword = \"secret\"\n", CurrentSchemaVersion)
+	_, err := LoadConfig(writeConfig(t, content))
+
+	var unknown *UnknownKeysError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("LoadConfig() error = %v, expected *UnknownKeysError", err)
+	}
+	if len(unknown.Keys) != 1 || unknown.Keys[0] != "bastion.pasword (第 5 行)" {
+		t.Errorf("Keys = %v, expected [bastion.pasword (第 5 行)]", unknown.Keys)
+	}
+}
+
+func TestLoadConfigRejectsNewerSchema(t *testing.T) {
+	if _, err := LoadConfig(writeConfig(t, "schema_version = 99\n")); err == nil {
+		t.Error("LoadConfig() expected error for newer schema_version")
+	}
+}
+
+func TestMigrateConfigFile(t *testing.T) {
+	path := writeConfig(t, legacyConfig)
+
+	result, err := MigrateConfigFile(path)
+	if err != nil {
+		t.Fatalf("MigrateConfigFile() error = %v", err)
+	}
+	if result.FromVersion != 1 || result.ToVersion != CurrentSchemaVersion || len(result.Applied) != len(migrations) || result.Regenerated {
+		t.Errorf("MigrateConfigFile() result = %+v", result)
+	}
+	backup, err := os.ReadFile(result.BackupPath)
+	if err != nil || string(backup) != legacyConfig {
+		t.Errorf("backup %s does not contain the original file: %v", result.BackupPath, err)
+	}
+
+	// 迁移后的文件应为当前版本，再次迁移无需任何操作
+	result, err = MigrateConfigFile(path)
+	if err != nil {
+		t.Fatalf("second MigrateConfigFile() error = %v", err)
+	}
+	if result.FromVersion != CurrentSchemaVersion || len(result.Applied) != 0 || result.BackupPath != "" {
+		t.Errorf("second MigrateConfigFile() result = %+v, expected no-op", result)
+	}
+}
+
+func TestMigrateConfigFilePreservesComments(t *testing.T) {
+	path := writeConfig(t, `# demo 集群
+[cluster_info]
+name = "demo"          # 集群名称
+domain = "example.com"
+
+[cluster.network]
+# 节点所在网段
+machine_network = "192.168.1.0/24" # 旧格式
+service_network = ["172.30.0.0/16"]
+
+[save_image]
+ops = ["cluster-logging"] # Operator 列表
+
+[[operators]]
+name = "local-storage-operator"
+namespace = "openshift-local-storage"
+`)
+	result, err := MigrateConfigFile(path)
+	if err != nil {
+		t.Fatalf("MigrateConfigFile() error = %v", err)
+	}
+	if result.Regenerated {
+		t.Error("MigrateConfigFile() regenerated the file")
+	}
+
+	expected := fmt.Sprintf(`# demo 集群
+schema_version = %d
+
+[cluster_info]
+name = "demo"          # 集群名称
+domain = "example.com"
+
+[cluster.network]
+# 节点所在网段
+machine_network = ["192.168.1.0/24"] # 旧格式
+service_network = ["172.30.0.0/16"]
+
+[save_image]
+ops = ["cluster-logging", "local-storage-operator"] # Operator 列表
+`, CurrentSchemaVersion)
+	if data, _ := os.ReadFile(path); string(data) != expected {
+		t.Errorf("migrated file = %q, expected %q", data, expected)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"ocpack/pkg/utils"
+)
+
+const (
+	// DefaultNodeInterface 节点未配置 interface 时使用的网卡名称
+	DefaultNodeInterface = "ens3"
+	// DefaultHostPrefix IPv4 集群网络的默认 hostPrefix
+	DefaultHostPrefix = 23
+	// DefaultHostPrefixV6 IPv6 集群网络的默认 hostPrefix
+	DefaultHostPrefixV6 = 64
+
+	defaultBondName = "bond0"
+	defaultBondMode = "active-backup"
+)
+
+// bondModes NMState 支持的 bond 模式
+var bondModes = []string{"balance-rr", "active-backup", "balance-xor", "broadcast", "802.3ad", "balance-tlb", "balance-alb"}
+
+// interfaceNamePattern Linux 网卡名称，最长 15 个字符
+var interfaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,15}$`)
+
+// ClusterNetworkEntry install-config 中的一个集群网络
+type ClusterNetworkEntry struct {
+	CIDR       string
+	HostPrefix int
+}
+
+// ClusterNetworks 返回带 hostPrefix 的集群网络列表
+func (c *ClusterConfig) ClusterNetworks() []ClusterNetworkEntry {
+	var entries []ClusterNetworkEntry
+	for _, cidr := range c.Cluster.Network.ClusterNetwork {
+		entries = append(entries, ClusterNetworkEntry{CIDR: cidr, HostPrefix: c.hostPrefixFor(cidr)})
+	}
+	return entries
+}
+
+func (c *ClusterConfig) hostPrefixFor(cidr string) int {
+	if utils.IsIPv6CIDR(cidr) {
+		if c.Cluster.Network.HostPrefixV6 > 0 {
+			return c.Cluster.Network.HostPrefixV6
+		}
+		return DefaultHostPrefixV6
+	}
+	if c.Cluster.Network.HostPrefix > 0 {
+		return c.Cluster.Network.HostPrefix
+	}
+	return DefaultHostPrefix
+}
+
+// IsDualStack 判断机器网络是否同时包含 IPv4 和 IPv6 网段
+func (c *ClusterConfig) IsDualStack() bool {
+	return machineNetworkFor(c.Cluster.Network.MachineNetwork, false) != "" &&
+		machineNetworkFor(c.Cluster.Network.MachineNetwork, true) != ""
+}
+
+// machineNetworkFor 返回指定地址族的第一个机器网络
+func machineNetworkFor(machineNetworks []string, ipv6 bool) string {
+	for _, cidr := range machineNetworks {
+		if utils.IsValidCIDR(cidr) && utils.IsIPv6CIDR(cidr) == ipv6 {
+			return cidr
+		}
+	}
+	return ""
+}
+
+// DNSServers 返回节点使用的 DNS 服务器，即 Bastion 的各个地址
+func (c *ClusterConfig) DNSServers() []string {
+	servers := []string{c.Bastion.IP}
+	if c.Bastion.IPv6 != "" {
+		servers = append(servers, c.Bastion.IPv6)
+	}
+	return servers
+}
+
+// HostAddresses 按地址族拆分主机的主地址和可选的 IPv6 地址
+func HostAddresses(ip, ipv6 string) (ipv4, ipv6Addr string) {
+	return splitAddresses(ip, ipv6)
+}
+
+// splitAddresses 按地址族拆分主地址和 IPv6 地址
+func splitAddresses(ip, ipv6 string) (string, string) {
+	if utils.IsIPv6(ip) {
+		return "", ip
+	}
+	return ip, ipv6
+}
+
+// Addresses 返回节点的 IPv4 和 IPv6 地址，未配置的地址族为空
+func (n Node) Addresses() (ipv4, ipv6 string) {
+	return splitAddresses(n.IP, n.IPv6)
+}
+
+// NodeAddress 节点在某个地址族上的地址配置
+type NodeAddress struct {
+	IP           string
+	PrefixLength int
+	Gateway      string
+}
+
+// NodeNetwork 补全默认值后的节点网络配置，供 agent-config.yaml 模板渲染 NMState
+type NodeNetwork struct {
+	Interfaces    []NodeInterface // 所有物理网卡，第一个为主网卡
+	Bond          *Bond           // 名称、模式和成员已补全
+	VLAN          int
+	BaseInterface string       // VLAN 所在的接口 (主网卡或 bond)
+	IPInterface   string       // 承载节点 IP 的接口
+	IPv4          *NodeAddress // 未配置 IPv4 时为 nil
+	IPv6          *NodeAddress // 未配置 IPv6 时为 nil
+	Routes        []Route      // 网关已按目标地址族补全
+}
+
+// Network 根据机器网络补全节点的网络配置
+func (n Node) Network(machineNetworks []string) NodeNetwork {
+	primary := n.Interface
+	if primary == "" {
+		primary = DefaultNodeInterface
+	}
+
+	network := NodeNetwork{
+		Interfaces:    append([]NodeInterface{{Name: primary, MAC: n.MAC}}, n.Interfaces...),
+		VLAN:          n.VLAN,
+		BaseInterface: primary,
+	}
+
+	ipv4, ipv6 := n.Addresses()
+	gatewayV4, gatewayV6 := n.Gateway, n.GatewayV6
+	if ipv4 == "" {
+		gatewayV4, gatewayV6 = "", n.Gateway
+	}
+	if ipv4 != "" {
+		network.IPv4 = nodeAddress(ipv4, gatewayV4, machineNetworkFor(machineNetworks, false))
+	}
+	if ipv6 != "" {
+		network.IPv6 = nodeAddress(ipv6, gatewayV6, machineNetworkFor(machineNetworks, true))
+	}
+
+	if n.Bond != nil {
+		bond := *n.Bond
+		if bond.Name == "" {
+			bond.Name = defaultBondName
+		}
+		if bond.Mode == "" {
+			bond.Mode = defaultBondMode
+		}
+		if len(bond.Ports) == 0 {
+			for _, iface := range network.Interfaces {
+				bond.Ports = append(bond.Ports, iface.Name)
+			}
+		}
+		network.Bond = &bond
+		network.BaseInterface = bond.Name
+	}
+
+	network.IPInterface = network.BaseInterface
+	if n.VLAN > 0 {
+		network.IPInterface = fmt.Sprintf("%s.%d", network.BaseInterface, n.VLAN)
+	}
+
+	for _, route := range n.Routes {
+		if route.Gateway == "" {
+			family := network.IPv4
+			if utils.IsIPv6CIDR(route.Destination) {
+				family = network.IPv6
+			}
+			if family != nil {
+				route.Gateway = family.Gateway
+			}
+		}
+		network.Routes = append(network.Routes, route)
+	}
+	return network
+}
+
+func nodeAddress(ip, gateway, machineNetwork string) *NodeAddress {
+	if gateway == "" {
+		gateway = utils.ExtractGateway(machineNetwork)
+	}
+	return &NodeAddress{IP: ip, PrefixLength: utils.ExtractPrefixLength(machineNetwork), Gateway: gateway}
+}
+
+// IsBondPort 判断网卡是否为 bond 成员
+func (n NodeNetwork) IsBondPort(name string) bool {
+	if n.Bond == nil {
+		return false
+	}
+	for _, port := range n.Bond.Ports {
+		if port == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// UseProxy 判断是否配置了下载代理
+func (c *ClusterConfig) UseProxy() bool {
+	return c.Download.HTTPProxy != "" || c.Download.HTTPSProxy != ""
+}
+
+// HTTPProxyURL 返回带认证信息的 HTTP 代理地址，未配置时返回空字符串
+func (c *ClusterConfig) HTTPProxyURL() string {
+	return c.proxyURL(c.Download.HTTPProxy)
+}
+
+// HTTPSProxyURL 返回带认证信息的 HTTPS 代理地址，未配置时使用 HTTP 代理
+func (c *ClusterConfig) HTTPSProxyURL() string {
+	if c.Download.HTTPSProxy == "" {
+		return c.HTTPProxyURL()
+	}
+	return c.proxyURL(c.Download.HTTPSProxy)
+}
+
+// proxyURL 把 proxy_username 和 proxy_password 写入代理地址，地址中已有认证信息时保持不变
+func (c *ClusterConfig) proxyURL(raw string) string {
+	if raw == "" || c.Download.ProxyUsername == "" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.User != nil {
+		return raw
+	}
+	u.User = url.UserPassword(c.Download.ProxyUsername, c.Download.ProxyPassword)
+	return u.String()
+}
+
+// ClusterNoProxy 返回写入 install-config.yaml 的 noProxy，包含 no_proxy 和本地镜像仓库域名。
+// 机器网络、集群网络和服务网络由 OpenShift 自动加入。
+func (c *ClusterConfig) ClusterNoProxy() string {
+	entries := append([]string{}, c.Download.NoProxy...)
+	registryHost := fmt.Sprintf("registry.%s.%s", c.ClusterInfo.Name, c.ClusterInfo.Domain)
+	if !contains(entries, registryHost) {
+		entries = append(entries, registryHost)
+	}
+	return strings.Join(entries, ",")
+}
+
+// WithCABundle 在信任证书之后追加 ca_bundle 中的证书，集群通过 TLS 拦截代理访问外部时同样需要信任它
+func (c *ClusterConfig) WithCABundle(bundle string) (string, error) {
+	if c.Download.CABundle == "" {
+		return bundle, nil
+	}
+	extra, err := os.ReadFile(c.Download.CABundle)
+	if err != nil {
+		return "", fmt.Errorf("读取 CA 证书 %s 失败: %w", c.Download.CABundle, err)
+	}
+	if bundle != "" && !strings.HasSuffix(bundle, "\n") {
+		bundle += "\n"
+	}
+	return bundle + strings.TrimSpace(string(extra)) + "\n", nil
+}
+
+// checkProxy 检查 [download] 中的下载地址、代理和 CA 证书
+func checkProxy(cfg *ClusterConfig, r *ValidationResult) {
+	d := cfg.Download
+	checkURL(r, "download.base_url", d.BaseURL, "http", "https")
+	checkURL(r, "download.mirror_registry_url", d.MirrorRegistryURL, "http", "https")
+	checkURL(r, "download.http_proxy", d.HTTPProxy, "http")
+	checkURL(r, "download.https_proxy", d.HTTPSProxy, "http", "https")
+
+	if (d.ProxyUsername != "" || d.ProxyPassword != "") && !cfg.UseProxy() {
+		r.addWarning("download.proxy_username", "设置了代理认证信息但没有配置代理", "设置 http_proxy 或 https_proxy")
+	}
+	for i, entry := range d.NoProxy {
+		if strings.TrimSpace(entry) == "" || strings.ContainsAny(entry, ", ") {
+			r.addError(fmt.Sprintf("download.no_proxy[%d]", i), fmt.Sprintf("无效的 no_proxy 条目: %q", entry), "每个条目为一个域名、IP 或 CIDR")
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				r.addError(fmt.Sprintf("download.no_proxy[%d]", i), fmt.Sprintf("无效的 CIDR: %s", entry), "")
+			}
+		}
+	}
+	if d.CABundle != "" {
+		if _, err := os.Stat(d.CABundle); err != nil {
+			r.addError("download.ca_bundle", fmt.Sprintf("CA 证书文件不存在: %s", d.CABundle), "应为 PEM 格式的证书文件")
+		}
+	}
+}
+
+// checkURL 检查可选的 URL 配置项
+func checkURL(r *ValidationResult, path, value string, schemes ...string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || !contains(schemes, u.Scheme) {
+		r.addError(path, fmt.Sprintf("无效的地址: %s", value), "应为 "+strings.Join(schemes, " 或 ")+" URL")
+	}
+}
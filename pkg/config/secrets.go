@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"ocpack/pkg/secrets"
+)
+
+// secretField 支持 secret: 引用的配置项
+type secretField struct {
+	path  string
+	value *string
+}
+
+func (c *ClusterConfig) secretFields() []secretField {
+	return []secretField{
+		{"bastion.password", &c.Bastion.Password},
+		{"bastion.ssh_key_passphrase", &c.Bastion.SSHKeyPassphrase},
+		{"registry.password", &c.Registry.Password},
+		{"registry.ssh_key_passphrase", &c.Registry.SSHKeyPassphrase},
+		{"download.proxy_password", &c.Download.ProxyPassword},
+	}
+}
+
+// resolveSecrets 将 secret: 引用替换为密钥库中的值，密钥库只在存在引用时打开
+func (c *ClusterConfig) resolveSecrets(clusterDir string) error {
+	var store *secrets.Store
+	for _, field := range c.secretFields() {
+		ref := *field.value
+		if !secrets.IsRef(ref) {
+			continue
+		}
+		if store == nil {
+			var err error
+			if store, err = secrets.Open(clusterDir); err != nil {
+				return fmt.Errorf("%s 引用了密钥库: %w", field.path, err)
+			}
+		}
+		value, err := store.Get(strings.TrimPrefix(ref, secrets.RefPrefix))
+		if err != nil {
+			return fmt.Errorf("解析 %s 失败: %w", field.path, err)
+		}
+		if c.secretRefs == nil {
+			c.secretRefs = map[string]string{}
+		}
+		c.secretRefs[field.path] = ref
+		*field.value = value
+	}
+	return nil
+}
+
+// SecretRef 返回配置项的 secret: 引用，配置项未使用密钥库时返回空字符串
+func (c *ClusterConfig) SecretRef(path string) string {
+	return c.secretRefs[path]
+}
+
+// withSecretRefs 返回将已解析的配置项恢复为引用的副本，用于序列化
+func (c *ClusterConfig) withSecretRefs() *ClusterConfig {
+	out := *c
+	for _, field := range out.secretFields() {
+		if ref, ok := c.secretRefs[field.path]; ok {
+			*field.value = ref
+		}
+	}
+	return &out
+}
+
+// Redacted 返回不含密码和口令的副本: 使用密钥库的配置项替换为 secret: 引用，其他配置项置空。
+// 用于计算配置摘要等不能包含明文密码的场合
+func (c *ClusterConfig) Redacted() *ClusterConfig {
+	out := c.withSecretRefs()
+	for _, field := range out.secretFields() {
+		if !secrets.IsRef(*field.value) {
+			*field.value = ""
+		}
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ocpack/pkg/secrets"
+)
+
+func TestLoadConfigSecretRefs(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	if err := GenerateDefaultConfig(configPath, "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConfigValue(configPath, "bastion.password", "secret:bastion"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(secrets.PassphraseEnv, "passphrase")
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Error("LoadConfig() expected error when the secrets store does not exist")
+	}
+
+	store, err := secrets.Create(dir, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("bastion", "s3cret-password")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Bastion.Password != "s3cret-password" || cfg.SecretRef("bastion.password") != "secret:bastion" {
+		t.Errorf("bastion.password = %q, ref %q", cfg.Bastion.Password, cfg.SecretRef("bastion.password"))
+	}
+
+	// 保存时写回引用而不是明文
+	if err := SaveConfig(cfg, configPath); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(configPath)
+	if strings.Contains(string(data), "s3cret-password") || !strings.Contains(string(data), "secret:bastion") {
+		t.Errorf("SaveConfig() wrote:\n%s", data)
+	}
+	if cfg.Bastion.Password != "s3cret-password" {
+		t.Error("SaveConfig() modified the resolved password in memory")
+	}
+
+	// Redacted 保留引用，明文密码置空
+	cfg.Registry.Password = "plain-password"
+	redacted := cfg.Redacted()
+	if redacted.Bastion.Password != "secret:bastion" || redacted.Registry.Password != "" {
+		t.Errorf("Redacted() bastion.password = %q, registry.password = %q", redacted.Bastion.Password, redacted.Registry.Password)
+	}
+	if cfg.Bastion.Password != "s3cret-password" || cfg.Registry.Password != "plain-password" {
+		t.Error("Redacted() modified the config")
+	}
+}
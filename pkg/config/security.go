@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 节点的 firewalld 和 SELinux 处理方式
+const (
+	// SecurityModeDisabled 关闭 firewalld，SELinux 设置为 disabled (默认)
+	SecurityModeDisabled = "disabled"
+	// SecurityModeHardened 保持 firewalld 和 SELinux 启用，只开放需要的端口并设置 SELinux 布尔值和上下文
+	SecurityModeHardened = "hardened"
+)
+
+// SecurityModes 返回所有支持的安全模式
+func SecurityModes() []string {
+	return []string{SecurityModeDisabled, SecurityModeHardened}
+}
+
+// SecurityMode 返回 security.mode，未配置时为 disabled
+func (c *ClusterConfig) SecurityMode() string {
+	if c.Security.Mode == "" {
+		return SecurityModeDisabled
+	}
+	return c.Security.Mode
+}
+
+func checkSecurityMode(cfg *ClusterConfig, r *ValidationResult) {
+	switch cfg.SecurityMode() {
+	case SecurityModeDisabled, SecurityModeHardened:
+	default:
+		r.addError("security.mode", fmt.Sprintf("不支持的安全模式: %s", cfg.Security.Mode), "可选值: "+strings.Join(SecurityModes(), ", "))
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"ocpack/pkg/utils"
+)
+
+const knownHostsFilename = "known_hosts"
+
+// KnownHostsFile 返回集群使用的 known_hosts 文件，默认为集群目录下的 known_hosts
+func (c *ClusterConfig) KnownHostsFile(clusterDir string) string {
+	if c.SSH.KnownHostsFile != "" {
+		return c.SSH.KnownHostsFile
+	}
+	return filepath.Join(clusterDir, knownHostsFilename)
+}
+
+// BastionSSH 返回连接 Bastion 节点的 SSH 选项
+func (c *ClusterConfig) BastionSSH(clusterDir string) utils.SSHOptions {
+	b := c.Bastion
+	return c.sshOptions(clusterDir, utils.SSHOptions{
+		Host:          b.IP,
+		Port:          b.SSHPort,
+		User:          b.Username,
+		Password:      b.Password,
+		KeyPath:       b.SSHKeyPath,
+		KeyPassphrase: b.SSHKeyPassphrase,
+	})
+}
+
+// RegistrySSH 返回连接 Registry 节点的 SSH 选项
+func (c *ClusterConfig) RegistrySSH(clusterDir string) utils.SSHOptions {
+	r := c.Registry
+	return c.sshOptions(clusterDir, utils.SSHOptions{
+		Host:          r.IP,
+		Port:          r.SSHPort,
+		User:          r.Username,
+		Password:      r.Password,
+		KeyPath:       r.SSHKeyPath,
+		KeyPassphrase: r.SSHKeyPassphrase,
+	})
+}
+
+func (c *ClusterConfig) sshOptions(clusterDir string, opts utils.SSHOptions) utils.SSHOptions {
+	opts.UseAgent = c.SSH.UseAgent
+	opts.HostKeyPolicy = c.SSH.HostKeyPolicy
+	opts.KnownHostsFile = c.KnownHostsFile(clusterDir)
+	return opts
+}
+
+// checkSSHPolicy 检查 [ssh] 配置段
+func checkSSHPolicy(cfg *ClusterConfig, r *ValidationResult) {
+	policy := cfg.SSH.HostKeyPolicy
+	switch policy {
+	case "", utils.HostKeyPolicyStrict, utils.HostKeyPolicyTOFU:
+	case utils.HostKeyPolicyInsecure:
+		r.addWarning("ssh.host_key_policy", "不校验 SSH 主机密钥，连接可能被中间人劫持", "生产环境请使用 tofu 或 strict")
+	default:
+		r.addError("ssh.host_key_policy", fmt.Sprintf("不支持的主机密钥策略: %s", policy), "可选值: "+strings.Join(utils.HostKeyPolicies(), ", "))
+	}
+}
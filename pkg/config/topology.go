@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// 集群拓扑
+const (
+	// TopologySNO 单节点 OpenShift: 1 个 Control Plane 节点，没有 Worker 节点
+	TopologySNO = "sno"
+	// TopologyCompact 紧凑集群: 3 个可调度的 Control Plane 节点，没有 Worker 节点
+	TopologyCompact = "compact"
+	// TopologyStandard 标准集群: 3 或 5 个 Control Plane 节点和至少 2 个 Worker 节点
+	TopologyStandard = "standard"
+)
+
+// standardControlPlaneCounts standard 拓扑支持的 Control Plane 节点数量
+var standardControlPlaneCounts = []int{3, 5}
+
+// Topologies 返回所有支持的集群拓扑
+func Topologies() []string {
+	return []string{TopologySNO, TopologyCompact, TopologyStandard}
+}
+
+// IsValidTopology 检查拓扑名称是否受支持
+func IsValidTopology(topology string) bool {
+	for _, t := range Topologies() {
+		if t == topology {
+			return true
+		}
+	}
+	return false
+}
+
+// Topology 返回集群拓扑。未配置 cluster_info.topology 时根据节点数量推断
+func (c *ClusterConfig) Topology() string {
+	if c.ClusterInfo.Topology != "" {
+		return c.ClusterInfo.Topology
+	}
+	if len(c.Cluster.Worker) == 0 {
+		switch len(c.Cluster.ControlPlane) {
+		case 1:
+			return TopologySNO
+		case 3:
+			return TopologyCompact
+		}
+	}
+	return TopologyStandard
+}
+
+// MastersSchedulable 判断 Control Plane 节点是否运行业务负载 (sno 和 compact 拓扑)
+func (c *ClusterConfig) MastersSchedulable() bool {
+	return c.Topology() != TopologyStandard
+}
+
+// IngressNodes 返回运行 Ingress Router 的节点，即 HAProxy 的 80/443 后端
+func (c *ClusterConfig) IngressNodes() []Node {
+	if c.MastersSchedulable() {
+		return c.Cluster.ControlPlane
+	}
+	return c.Cluster.Worker
+}
+
+// topologyNodes 返回拓扑对应的默认节点列表
+func topologyNodes(topology string) (controlPlane, worker []Node, err error) {
+	switch topology {
+	case TopologySNO:
+		return []Node{{Name: "master-0"}}, nil, nil
+	case TopologyCompact:
+		return []Node{{Name: "master-0"}, {Name: "master-1"}, {Name: "master-2"}}, nil, nil
+	case TopologyStandard:
+		return []Node{{Name: "master-0"}, {Name: "master-1"}, {Name: "master-2"}},
+			[]Node{{Name: "worker-0"}, {Name: "worker-1"}}, nil
+	}
+	return nil, nil, fmt.Errorf("不支持的集群拓扑: %s (可选: %s)", topology, strings.Join(Topologies(), ", "))
+}
+
+// checkTopology 检查节点数量是否符合集群拓扑
+func checkTopology(cfg *ClusterConfig, r *ValidationResult) {
+	topology := cfg.ClusterInfo.Topology
+	if topology != "" && !IsValidTopology(topology) {
+		r.addError("cluster_info.topology", fmt.Sprintf("不支持的集群拓扑: %s", topology), "可选值: "+strings.Join(Topologies(), ", "))
+		return
+	}
+
+	masters, workers := len(cfg.Cluster.ControlPlane), len(cfg.Cluster.Worker)
+	if masters == 0 {
+		return // 已由节点检查报告
+	}
+	switch cfg.Topology() {
+	case TopologySNO:
+		if masters != 1 {
+			r.addError("cluster.control_plane", fmt.Sprintf("sno 拓扑需要 1 个Control Plane节点，当前为 %d 个", masters), "")
+		}
+		if workers != 0 {
+			r.addError("cluster.worker", "sno 拓扑不能配置Worker节点", "删除 [[cluster.worker]] 配置段，或使用 standard 拓扑")
+		}
+	case TopologyCompact:
+		if masters != 3 {
+			r.addError("cluster.control_plane", fmt.Sprintf("compact 拓扑需要 3 个Control Plane节点，当前为 %d 个", masters), "")
+		}
+		if workers != 0 {
+			r.addError("cluster.worker", "compact 拓扑不能配置Worker节点", "删除 [[cluster.worker]] 配置段，或使用 standard 拓扑")
+		}
+	case TopologyStandard:
+		if !slices.Contains(standardControlPlaneCounts, masters) {
+			r.addError("cluster.control_plane", fmt.Sprintf("standard 拓扑需要 3 或 5 个Control Plane节点，当前为 %d 个", masters), "单节点集群请使用 sno 拓扑")
+		}
+		switch {
+		case workers == 0:
+			r.addError("cluster.worker", "standard 拓扑至少需要一个Worker节点", "没有Worker节点时请使用 compact 拓扑")
+		case workers == 1:
+			r.addWarning("cluster.worker", "只有 1 个Worker节点，Ingress Router 无法满足默认的 2 个副本", "至少配置 2 个Worker节点")
+		}
+	}
+}
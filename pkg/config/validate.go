@@ -0,0 +1,591 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"ocpack/pkg/utils"
+)
+
+// Severity 表示校验问题的严重程度
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Stage 表示需要校验配置的命令阶段
+type Stage string
+
+const (
+	StageDownload Stage = "download"
+	StageBastion  Stage = "bastion"
+	StageRegistry Stage = "registry"
+	StageISO      Stage = "iso"
+	StagePXE      Stage = "pxe"
+)
+
+// ValidationIssue 描述配置中的一个问题
+type ValidationIssue struct {
+	Path     string   `json:"path"`
+	Message  string   `json:"message"`
+	Hint     string   `json:"hint,omitempty"`
+	Severity Severity `json:"severity"`
+}
+
+// String 返回便于终端展示的问题描述
+func (i ValidationIssue) String() string {
+	s := fmt.Sprintf("%s: %s", i.Path, i.Message)
+	if i.Hint != "" {
+		s += fmt.Sprintf(" (提示: %s)", i.Hint)
+	}
+	return s
+}
+
+// ValidationResult 汇总一次校验发现的全部问题
+type ValidationResult struct {
+	Stage  Stage             `json:"stage"`
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// ValidationError 包含校验发现的全部错误
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Issues) == 1 {
+		return e.Issues[0].String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "共发现 %d 个配置错误:", len(e.Issues))
+	for _, issue := range e.Issues {
+		b.WriteString("\n  - ")
+		b.WriteString(issue.String())
+	}
+	return b.String()
+}
+
+// validationRule 对配置的某一部分进行检查，并把问题记录到结果中
+type validationRule func(cfg *ClusterConfig, r *ValidationResult)
+
+// stageRules 声明每个命令阶段需要的校验规则
+var stageRules = map[Stage][]validationRule{
+	StageDownload: {checkOpenShiftVersion, checkDownload, checkProxy, checkArch, checkDownloadArch},
+	StageRegistry: {checkClusterName, checkOpenShiftVersion, checkRegistryHost, checkSSHPolicy, checkSecurityMode, checkHostTools},
+	StageBastion: {
+		checkClusterName, checkClusterDomain, checkClusterID, checkOpenShiftVersion,
+		checkBastionHost, checkRegistryIP, checkNodesWithoutMAC, checkTopology, checkNetwork, checkLoadBalancer, checkSSHPolicy, checkSecurityMode, checkHostTools,
+	},
+	StageISO: {
+		checkClusterName, checkClusterDomain, checkClusterID, checkOpenShiftVersion,
+		checkBastionHost, checkRegistryHost, checkNodes, checkTopology, checkNetwork, checkArch, checkVersionResolved,
+	},
+	StagePXE: {
+		checkClusterName, checkClusterDomain, checkClusterID, checkOpenShiftVersion,
+		checkBastionHost, checkRegistryHost, checkNodes, checkTopology, checkNetwork, checkPXENetwork, checkSSHPolicy, checkSecurityMode, checkArch, checkVersionResolved,
+	},
+}
+
+// Stages 返回所有可校验的阶段，按部署流程排序
+func Stages() []Stage {
+	return []Stage{StageDownload, StageBastion, StageRegistry, StageISO, StagePXE}
+}
+
+// Validate 使用指定阶段的规则集校验配置，返回发现的全部问题
+func Validate(cfg *ClusterConfig, stage Stage) *ValidationResult {
+	r := &ValidationResult{Stage: stage}
+	rules, ok := stageRules[stage]
+	if !ok {
+		r.addError("", fmt.Sprintf("未知的校验阶段: %s", stage), "")
+		return r
+	}
+	checkSchemaVersion(cfg, r)
+	for _, rule := range rules {
+		rule(cfg, r)
+	}
+	return r
+}
+
+// checkSchemaVersion 提示旧版本配置文件执行迁移
+func checkSchemaVersion(cfg *ClusterConfig, r *ValidationResult) {
+	if v := cfg.LoadedSchemaVersion(); v != 0 && v < CurrentSchemaVersion {
+		r.addWarning("schema_version", fmt.Sprintf("配置文件使用旧版 schema v%d，已在内存中升级到 v%d", v, CurrentSchemaVersion), "运行 'ocpack config migrate' 更新配置文件")
+	}
+}
+
+// Errors 返回所有错误级别的问题
+func (r *ValidationResult) Errors() []ValidationIssue {
+	return r.filter(SeverityError)
+}
+
+// Warnings 返回所有警告级别的问题
+func (r *ValidationResult) Warnings() []ValidationIssue {
+	return r.filter(SeverityWarning)
+}
+
+// HasErrors 判断是否存在错误级别的问题
+func (r *ValidationResult) HasErrors() bool {
+	return len(r.Errors()) > 0
+}
+
+// Err 在存在错误时返回 *ValidationError，否则返回 nil
+func (r *ValidationResult) Err() error {
+	if errs := r.Errors(); len(errs) > 0 {
+		return &ValidationError{Issues: errs}
+	}
+	return nil
+}
+
+func (r *ValidationResult) filter(severity Severity) []ValidationIssue {
+	var issues []ValidationIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func (r *ValidationResult) addError(path, message, hint string) {
+	r.Issues = append(r.Issues, ValidationIssue{Path: path, Message: message, Hint: hint, Severity: SeverityError})
+}
+
+func (r *ValidationResult) addWarning(path, message, hint string) {
+	r.Issues = append(r.Issues, ValidationIssue{Path: path, Message: message, Hint: hint, Severity: SeverityWarning})
+}
+
+// --- 集群基本信息 ---
+
+func checkClusterName(cfg *ClusterConfig, r *ValidationResult) {
+	if cfg.ClusterInfo.Name == "" {
+		r.addError("cluster_info.name", "集群名称不能为空", "")
+	}
+}
+
+func checkClusterDomain(cfg *ClusterConfig, r *ValidationResult) {
+	if cfg.ClusterInfo.Domain == "" {
+		r.addError("cluster_info.domain", "集群域名不能为空", "例如 example.com")
+	}
+}
+
+func checkClusterID(cfg *ClusterConfig, r *ValidationResult) {
+	if cfg.ClusterInfo.ClusterID == "" {
+		r.addError("cluster_info.cluster_id", "集群ID不能为空", "通常与集群名称相同")
+	}
+}
+
+func checkOpenShiftVersion(cfg *ClusterConfig, r *ValidationResult) {
+	version := cfg.ClusterInfo.OpenShiftVersion
+	if version == "" {
+		r.addError("cluster_info.openshift_version", "OpenShift版本不能为空", "例如 4.14.0")
+		return
+	}
+	if _, ok := VersionChannel(cfg.RequestedVersion()); ok {
+		return
+	}
+	if !utils.IsValidVersionFormat(strings.TrimPrefix(version, "v")) {
+		r.addError("cluster_info.openshift_version", fmt.Sprintf("无效的OpenShift版本: %s", version), "应为 x.y.z 格式，例如 4.14.0，或者 stable-4.14 等版本通道")
+	}
+}
+
+func checkDownload(cfg *ClusterConfig, r *ValidationResult) {
+	if cfg.Download.Retries < 0 {
+		r.addError("download.retries", fmt.Sprintf("重试次数不能为负数: %d", cfg.Download.Retries), "设置为 0 表示不重试")
+	}
+	if cfg.Download.Concurrency < 0 {
+		r.addError("download.concurrency", fmt.Sprintf("并发数不能为负数: %d", cfg.Download.Concurrency), "不设置时默认为 3")
+	}
+}
+
+// --- Bastion / Registry 节点 ---
+
+func checkBastionHost(cfg *ClusterConfig, r *ValidationResult) {
+	checkSSHHost(r, "bastion", "Bastion", cfg.BastionSSH(""))
+	checkIPv6Field(r, "bastion.ipv6", "Bastion", cfg.Bastion.IP, cfg.Bastion.IPv6)
+}
+
+func checkRegistryHost(cfg *ClusterConfig, r *ValidationResult) {
+	checkSSHHost(r, "registry", "Registry", cfg.RegistrySSH(""))
+	checkIPv6Field(r, "registry.ipv6", "Registry", cfg.Registry.IP, cfg.Registry.IPv6)
+	if cfg.Registry.StoragePath == "" {
+		r.addError("registry.storage_path", "Registry节点存储路径不能为空", "例如 /var/lib/registry")
+	} else if !strings.HasPrefix(cfg.Registry.StoragePath, "/") {
+		r.addError("registry.storage_path", fmt.Sprintf("存储路径必须为绝对路径: %s", cfg.Registry.StoragePath), "")
+	}
+}
+
+// checkRegistryIP 仅检查 Registry IP，Bastion 需要它来配置 DNS 解析
+func checkRegistryIP(cfg *ClusterConfig, r *ValidationResult) {
+	checkIPField(r, "registry.ip", "Registry节点IP", cfg.Registry.IP, "Bastion需要配置Registry的DNS解析")
+	checkIPv6Field(r, "registry.ipv6", "Registry", cfg.Registry.IP, cfg.Registry.IPv6)
+}
+
+func checkSSHHost(r *ValidationResult, section, label string, opts utils.SSHOptions) {
+	checkIPField(r, section+".ip", label+"节点IP", opts.Host, "")
+	if opts.User == "" {
+		r.addError(section+".username", label+"节点用户名不能为空", "通常为 root")
+	}
+	if opts.KeyPath == "" && opts.Password == "" && !opts.UseAgent {
+		r.addError(section+".ssh_key_path", label+"节点必须提供SSH密钥或密码", "设置 ssh_key_path 或 password 其中之一，或启用 ssh.use_agent")
+	} else if opts.KeyPath != "" {
+		if _, err := os.Stat(opts.KeyPath); os.IsNotExist(err) {
+			r.addWarning(section+".ssh_key_path", fmt.Sprintf("SSH私钥文件不存在: %s", opts.KeyPath), "确认路径在执行 ocpack 的机器上可访问")
+		}
+	}
+	if opts.Port < 0 || opts.Port > 65535 {
+		r.addError(section+".ssh_port", fmt.Sprintf("%s节点SSH端口无效: %d", label, opts.Port), "")
+	}
+}
+
+// checkIPv6Field 检查双栈环境中主机的可选 IPv6 地址
+func checkIPv6Field(r *ValidationResult, path, label, ip, ipv6 string) {
+	switch {
+	case ipv6 == "":
+	case !utils.IsIPv6(ipv6):
+		r.addError(path, fmt.Sprintf("%s节点的 ipv6 不是 IPv6 地址: %s", label, ipv6), "")
+	case utils.IsIPv6(ip):
+		r.addError(path, fmt.Sprintf("%s节点的主地址已是 IPv6，无需设置 ipv6", label), "")
+	}
+}
+
+func checkIPField(r *ValidationResult, path, label, ip, hint string) {
+	if ip == "" {
+		r.addError(path, label+"不能为空", hint)
+		return
+	}
+	if !utils.IsValidIP(ip) {
+		r.addError(path, fmt.Sprintf("%s格式无效: %s", label, ip), "")
+	}
+}
+
+// --- 集群节点 ---
+
+// nodeRef 统一描述 control_plane 与 worker 节点，便于交叉检查
+type nodeRef struct {
+	path  string
+	label string
+	name  string
+	ip    string
+	mac   string
+	node  Node
+}
+
+func collectNodes(cfg *ClusterConfig) []nodeRef {
+	var nodes []nodeRef
+	for i, cp := range cfg.Cluster.ControlPlane {
+		nodes = append(nodes, nodeRef{
+			path:  fmt.Sprintf("cluster.control_plane[%d]", i),
+			label: fmt.Sprintf("Control Plane节点[%d]", i),
+			name:  cp.Name, ip: cp.IP, mac: cp.MAC,
+			node: cp,
+		})
+	}
+	for i, worker := range cfg.Cluster.Worker {
+		nodes = append(nodes, nodeRef{
+			path:  fmt.Sprintf("cluster.worker[%d]", i),
+			label: fmt.Sprintf("Worker节点[%d]", i),
+			name:  worker.Name, ip: worker.IP, mac: worker.MAC,
+			node: worker,
+		})
+	}
+	return nodes
+}
+
+func checkNodes(cfg *ClusterConfig, r *ValidationResult) {
+	checkClusterNodes(cfg, r, true)
+}
+
+// checkNodesWithoutMAC 用于 Bastion 部署，MAC 地址对于 DNS 和 HAProxy 不是必需的
+func checkNodesWithoutMAC(cfg *ClusterConfig, r *ValidationResult) {
+	checkClusterNodes(cfg, r, false)
+}
+
+func checkClusterNodes(cfg *ClusterConfig, r *ValidationResult, forInstall bool) {
+	if len(cfg.Cluster.ControlPlane) == 0 {
+		r.addError("cluster.control_plane", "至少需要配置一个Control Plane节点", "添加 [[cluster.control_plane]] 配置段")
+	}
+
+	machineNetworks := cfg.Cluster.Network.MachineNetwork
+	dualStack := cfg.IsDualStack()
+
+	names := map[string]string{}
+	ips := map[string]string{}
+	macs := map[string]string{}
+
+	// checkAddress 检查地址格式、唯一性以及是否位于对应地址族的机器网络内
+	checkAddress := func(node nodeRef, path, ip string) {
+		if !utils.IsValidIP(ip) {
+			r.addError(path, fmt.Sprintf("%s %s 的IP格式无效: %s", node.label, node.name, ip), "")
+			return
+		}
+		if prev, ok := ips[ip]; ok {
+			r.addError(path, fmt.Sprintf("IP %s 与 %s 重复", ip, prev), "每个节点的IP必须唯一")
+		} else {
+			ips[ip] = path
+		}
+		if len(machineNetworks) == 0 {
+			return
+		}
+		cidr := machineNetworkFor(machineNetworks, utils.IsIPv6(ip))
+		if cidr == "" {
+			r.addError(path, fmt.Sprintf("机器网络中没有与 IP %s 相同地址族的网段", ip), "在 cluster.network.machine_network 中添加对应的网段")
+		} else if in, _ := utils.CIDRContainsIP(cidr, ip); !in {
+			r.addError(path, fmt.Sprintf("IP %s 不在机器网络 %s 内", ip, cidr), "修改节点IP或 cluster.network.machine_network")
+		}
+	}
+
+	for _, node := range collectNodes(cfg) {
+		checkNodeNetwork(node, machineNetworks, macs, r)
+
+		if node.name == "" {
+			r.addError(node.path+".name", node.label+"名称不能为空", "")
+		} else if prev, ok := names[node.name]; ok {
+			r.addError(node.path+".name", fmt.Sprintf("节点名称 %s 与 %s 重复", node.name, prev), "每个节点的名称必须唯一")
+		} else {
+			names[node.name] = node.path
+		}
+
+		if node.ip == "" {
+			r.addError(node.path+".ip", fmt.Sprintf("%s %s 的IP不能为空", node.label, node.name), "")
+		} else {
+			checkAddress(node, node.path+".ip", node.ip)
+		}
+
+		switch ipv6 := node.node.IPv6; {
+		case ipv6 == "":
+			if forInstall && dualStack && !utils.IsIPv6(node.ip) {
+				r.addError(node.path+".ipv6", fmt.Sprintf("双栈集群中 %s %s 必须配置 IPv6 地址", node.label, node.name), "")
+			}
+		case !utils.IsIPv6(ipv6):
+			r.addError(node.path+".ipv6", fmt.Sprintf("%s %s 的 ipv6 不是 IPv6 地址: %s", node.label, node.name, ipv6), "")
+		case utils.IsIPv6(node.ip):
+			r.addError(node.path+".ipv6", fmt.Sprintf("%s %s 的主地址已是 IPv6，无需设置 ipv6", node.label, node.name), "双栈集群的 ip 填写 IPv4 地址")
+		default:
+			checkAddress(node, node.path+".ipv6", ipv6)
+		}
+
+		if node.mac == "" {
+			if forInstall {
+				r.addError(node.path+".mac", fmt.Sprintf("%s %s 的MAC地址不能为空", node.label, node.name), "")
+			}
+			continue
+		}
+		if !utils.IsValidMAC(node.mac) {
+			r.addError(node.path+".mac", fmt.Sprintf("%s %s 的MAC地址格式无效: %s", node.label, node.name, node.mac), "应为 52:54:00:aa:bb:cc 格式")
+			continue
+		}
+		key := strings.ToLower(node.mac)
+		if prev, ok := macs[key]; ok {
+			r.addError(node.path+".mac", fmt.Sprintf("MAC地址 %s 与 %s 重复", node.mac, prev), "每个节点的MAC地址必须唯一")
+		} else {
+			macs[key] = node.path
+		}
+	}
+}
+
+// checkNodeNetwork 校验节点的网卡、bond、VLAN、网关和静态路由配置
+func checkNodeNetwork(ref nodeRef, machineNetworks []string, macs map[string]string, r *ValidationResult) {
+	node := ref.node
+	names := map[string]bool{}
+
+	if node.Interface != "" && !interfaceNamePattern.MatchString(node.Interface) {
+		r.addError(ref.path+".interface", fmt.Sprintf("网卡名称无效: %s", node.Interface), "最多 15 个字符，如 eno1、ens192")
+	}
+	names[node.Network(machineNetworks).Interfaces[0].Name] = true
+
+	for i, iface := range node.Interfaces {
+		path := fmt.Sprintf("%s.interfaces[%d]", ref.path, i)
+		switch {
+		case !interfaceNamePattern.MatchString(iface.Name):
+			r.addError(path+".name", fmt.Sprintf("网卡名称无效: %q", iface.Name), "最多 15 个字符，如 eno2、ens224")
+		case names[iface.Name]:
+			r.addError(path+".name", fmt.Sprintf("%s 的网卡 %s 重复", ref.name, iface.Name), "")
+		default:
+			names[iface.Name] = true
+		}
+
+		if !utils.IsValidMAC(iface.MAC) {
+			r.addError(path+".mac", fmt.Sprintf("%s 的网卡 %s MAC地址格式无效: %q", ref.name, iface.Name, iface.MAC), "应为 52:54:00:aa:bb:cc 格式")
+		} else if prev, ok := macs[strings.ToLower(iface.MAC)]; ok {
+			r.addError(path+".mac", fmt.Sprintf("MAC地址 %s 与 %s 重复", iface.MAC, prev), "每个网卡的MAC地址必须唯一")
+		} else {
+			macs[strings.ToLower(iface.MAC)] = path
+		}
+	}
+
+	if bond := node.Bond; bond != nil {
+		if bond.Name != "" && !interfaceNamePattern.MatchString(bond.Name) {
+			r.addError(ref.path+".bond.name", fmt.Sprintf("bond 名称无效: %s", bond.Name), "如 bond0")
+		}
+		if bond.Mode != "" && !contains(bondModes, bond.Mode) {
+			r.addError(ref.path+".bond.mode", fmt.Sprintf("不支持的 bond 模式: %s", bond.Mode), "可选: "+strings.Join(bondModes, ", "))
+		}
+		for i, port := range bond.Ports {
+			if !names[port] {
+				r.addError(fmt.Sprintf("%s.bond.ports[%d]", ref.path, i), fmt.Sprintf("bond 成员 %s 不是 %s 的网卡", port, ref.name), "在 interface 或 interfaces 中声明该网卡")
+			}
+		}
+	}
+
+	if node.VLAN < 0 || node.VLAN > 4094 {
+		r.addError(ref.path+".vlan", fmt.Sprintf("VLAN ID 超出范围: %d", node.VLAN), "有效范围为 1-4094")
+	}
+
+	// gateway 与主地址同族，gateway_v6 仅用于双栈节点的 IPv6 地址
+	checkGateway := func(path, gateway string, ipv6 bool) {
+		if gateway == "" {
+			return
+		}
+		if !utils.IsValidIP(gateway) || utils.IsIPv6(gateway) != ipv6 {
+			r.addError(path, fmt.Sprintf("网关格式无效或地址族不匹配: %s", gateway), "")
+			return
+		}
+		if cidr := machineNetworkFor(machineNetworks, ipv6); cidr != "" {
+			if in, _ := utils.CIDRContainsIP(cidr, gateway); !in {
+				r.addError(path, fmt.Sprintf("网关 %s 不在机器网络 %s 内", gateway, cidr), "")
+			}
+		}
+	}
+	checkGateway(ref.path+".gateway", node.Gateway, utils.IsIPv6(node.IP))
+	checkGateway(ref.path+".gateway_v6", node.GatewayV6, true)
+
+	for i, route := range node.Routes {
+		path := fmt.Sprintf("%s.routes[%d]", ref.path, i)
+		if !utils.IsValidCIDR(route.Destination) {
+			r.addError(path+".destination", fmt.Sprintf("路由目标格式无效: %q", route.Destination), "应为 10.10.0.0/16 格式")
+		}
+		if route.Gateway != "" && !utils.IsValidIP(route.Gateway) {
+			r.addError(path+".gateway", fmt.Sprintf("路由网关格式无效: %s", route.Gateway), "")
+		}
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// --- 网络 ---
+
+func checkNetwork(cfg *ClusterConfig, r *ValidationResult) {
+	networks := []struct {
+		path  string
+		label string
+		cidrs []string
+	}{
+		{"cluster.network.cluster_network", "集群网络CIDR", cfg.Cluster.Network.ClusterNetwork},
+		{"cluster.network.service_network", "服务网络CIDR", cfg.Cluster.Network.ServiceNetwork},
+		{"cluster.network.machine_network", "机器网络CIDR", cfg.Cluster.Network.MachineNetwork},
+	}
+
+	type entry struct {
+		path  string
+		label string
+		cidr  string
+	}
+	var valid []entry
+	families := make([][]string, len(networks))
+
+	for i, n := range networks {
+		if len(n.cidrs) == 0 {
+			r.addError(n.path, n.label+"不能为空", "")
+			continue
+		}
+		for j, cidr := range n.cidrs {
+			path := fmt.Sprintf("%s[%d]", n.path, j)
+			if !utils.IsValidCIDR(cidr) {
+				r.addError(path, fmt.Sprintf("%s格式无效: %q", n.label, cidr), "应为 10.128.0.0/14 或 fd01::/48 格式")
+				continue
+			}
+			if base := utils.ExtractNetworkBase(cidr); base != networkAddress(cidr) {
+				r.addWarning(path, fmt.Sprintf("%s %s 包含主机位", n.label, cidr), fmt.Sprintf("网络地址应为 %s", networkAddress(cidr)))
+			}
+			family := addressFamily(cidr)
+			if contains(families[i], family) {
+				r.addError(path, fmt.Sprintf("%s中有多个 %s 网段", n.label, family), "每个地址族只能配置一个网段")
+				continue
+			}
+			families[i] = append(families[i], family)
+			valid = append(valid, entry{path, n.label, cidr})
+		}
+	}
+
+	// 双栈集群中三个网络的地址族及主地址族顺序必须一致
+	machine := families[len(networks)-1]
+	for i, n := range networks[:len(networks)-1] {
+		if len(families[i]) == 0 || len(machine) == 0 {
+			continue
+		}
+		if !sameFamilies(families[i], machine) {
+			r.addError(n.path, fmt.Sprintf("%s的地址族 (%s) 与机器网络 (%s) 不一致", n.label, strings.Join(families[i], "+"), strings.Join(machine, "+")),
+				"双栈集群的三个网络都需要同时包含 IPv4 和 IPv6 网段")
+		} else if families[i][0] != machine[0] {
+			r.addError(n.path, fmt.Sprintf("%s的主地址族为 %s，与机器网络的 %s 不一致", n.label, families[i][0], machine[0]), "调整网段顺序，使第一个网段的地址族一致")
+		}
+	}
+
+	for x := 0; x < len(valid); x++ {
+		for y := x + 1; y < len(valid); y++ {
+			a, b := valid[x], valid[y]
+			if overlap, _ := utils.CIDRsOverlap(a.cidr, b.cidr); overlap {
+				r.addError(b.path, fmt.Sprintf("%s %s 与%s %s 重叠", b.label, b.cidr, a.label, a.cidr), "集群网络、服务网络和机器网络不能重叠")
+			}
+		}
+	}
+
+	for _, cidr := range cfg.Cluster.Network.ClusterNetwork {
+		if !utils.IsValidCIDR(cidr) {
+			continue
+		}
+		path, maxBits := "cluster.network.host_prefix", 32
+		if utils.IsIPv6CIDR(cidr) {
+			path, maxBits = "cluster.network.host_prefix_v6", 128
+		}
+		hostPrefix := cfg.hostPrefixFor(cidr)
+		if hostPrefix <= utils.ExtractPrefixLength(cidr) || hostPrefix > maxBits {
+			r.addError(path, fmt.Sprintf("hostPrefix %d 必须大于集群网络 %s 的前缀长度且不超过 %d", hostPrefix, cidr, maxBits), "")
+		}
+	}
+}
+
+// checkPXENetwork PXE 使用 dhcpd 分配 IPv4 地址，需要 IPv4 机器网络和 Bastion IPv4 地址
+func checkPXENetwork(cfg *ClusterConfig, r *ValidationResult) {
+	if len(cfg.Cluster.Network.MachineNetwork) > 0 && machineNetworkFor(cfg.Cluster.Network.MachineNetwork, false) == "" {
+		r.addError("cluster.network.machine_network", "PXE 模式需要 IPv4 机器网络", "IPv6-only 集群请使用 ISO 模式")
+	}
+	if utils.IsIPv6(cfg.Bastion.IP) {
+		r.addError("bastion.ip", "PXE 模式需要 Bastion 的 IPv4 地址", "")
+	}
+}
+
+// addressFamily 返回 CIDR 的地址族名称
+func addressFamily(cidr string) string {
+	if utils.IsIPv6CIDR(cidr) {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+func sameFamilies(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, family := range a {
+		if !contains(b, family) {
+			return false
+		}
+	}
+	return true
+}
+
+// networkAddress 返回 CIDR 的网络地址（去掉主机位）
+func networkAddress(cidr string) string {
+	return utils.ExtractNetworkBase(utils.NormalizeCIDR(cidr))
+}
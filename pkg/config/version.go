@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"ocpack/pkg/utils"
+)
+
+// VersionLockFilename 集群目录中记录版本通道解析结果的文件
+const VersionLockFilename = "version.lock"
+
+// versionChannelPattern 匹配 stable-4.16、fast-4.16、candidate-4.16、eus-4.16 和 latest-4.16
+var versionChannelPattern = regexp.MustCompile(`^(stable|fast|candidate|eus|latest)-(\d+\.\d+)$`)
+
+// VersionLock openshift_version 为版本通道时解析出的 z-stream 版本
+type VersionLock struct {
+	Requested  string    `toml:"requested"` // config.toml 中的取值，如 stable-4.16
+	Channel    string    `toml:"channel"`   // 查询的 Cincinnati 通道
+	Version    string    `toml:"version"`
+	Arch       string    `toml:"arch"`
+	ResolvedAt time.Time `toml:"resolved_at"`
+}
+
+// VersionChannel 解析版本通道写法，返回对应的 Cincinnati 通道。
+// latest-4.x 与 mirror.openshift.com 上的 latest-4.x 目录一致，取 fast 通道中的最新版本。
+func VersionChannel(version string) (string, bool) {
+	m := versionChannelPattern.FindStringSubmatch(strings.ToLower(version))
+	if m == nil {
+		return "", false
+	}
+	if m[1] == "latest" {
+		return "fast-" + m[2], true
+	}
+	return m[1] + "-" + m[2], true
+}
+
+// RequestedVersion 返回 config.toml 中的 openshift_version，版本通道解析后仍返回通道
+func (c *ClusterConfig) RequestedVersion() string {
+	if c.requestedVersion != "" {
+		return c.requestedVersion
+	}
+	return c.ClusterInfo.OpenShiftVersion
+}
+
+// VersionChannel 返回 openshift_version 对应的 Cincinnati 通道，openshift_version 为具体版本时返回 false
+func (c *ClusterConfig) VersionChannel() (string, bool) {
+	return VersionChannel(c.RequestedVersion())
+}
+
+// ChannelType 返回 openshift_version 版本通道的类型 (stable、fast、candidate 或 eus)，
+// openshift_version 为具体版本时返回空字符串
+func (c *ClusterConfig) ChannelType() string {
+	channel, ok := c.VersionChannel()
+	if !ok {
+		return ""
+	}
+	return channel[:strings.LastIndex(channel, "-")]
+}
+
+// VersionResolved 判断 openshift_version 是具体版本，或者版本通道已经解析
+func (c *ClusterConfig) VersionResolved() bool {
+	_, isChannel := VersionChannel(c.ClusterInfo.OpenShiftVersion)
+	return !isChannel
+}
+
+// PinVersion 使用解析出的版本，openshift_version 写回配置文件时仍保留版本通道
+func (c *ClusterConfig) PinVersion(lock *VersionLock) {
+	if c.requestedVersion == "" {
+		c.requestedVersion = c.ClusterInfo.OpenShiftVersion
+	}
+	c.ClusterInfo.OpenShiftVersion = lock.Version
+}
+
+// NewVersionLock 创建当前配置的版本锁
+func (c *ClusterConfig) NewVersionLock(channel, version string) *VersionLock {
+	return &VersionLock{
+		Requested:  c.RequestedVersion(),
+		Channel:    channel,
+		Version:    version,
+		Arch:       c.TargetArch(),
+		ResolvedAt: time.Now().UTC(),
+	}
+}
+
+// LoadVersionLock 读取集群目录中的版本锁，文件不存在时返回 nil
+func LoadVersionLock(clusterDir string) (*VersionLock, error) {
+	data, err := os.ReadFile(filepath.Join(clusterDir, VersionLockFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", VersionLockFilename, err)
+	}
+	var lock VersionLock
+	if err := toml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", VersionLockFilename, err)
+	}
+	return &lock, nil
+}
+
+// Save 把版本锁写入集群目录
+func (l *VersionLock) Save(clusterDir string) error {
+	data, err := toml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("序列化 %s 失败: %w", VersionLockFilename, err)
+	}
+	header := "# 由 ocpack 生成，记录 openshift_version 版本通道解析出的版本。删除此文件后重新运行 ocpack download 可更新版本\n"
+	if err := os.WriteFile(filepath.Join(clusterDir, VersionLockFilename), append([]byte(header), data...), 0644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", VersionLockFilename, err)
+	}
+	return nil
+}
+
+// applyVersionLock 在 openshift_version 为版本通道时使用版本锁中的版本。
+// 版本锁与当前通道或集群架构不一致时忽略，等待重新解析。
+func (c *ClusterConfig) applyVersionLock(clusterDir string) error {
+	if _, ok := c.VersionChannel(); !ok {
+		return nil
+	}
+	lock, err := LoadVersionLock(clusterDir)
+	if err != nil || lock == nil {
+		return err
+	}
+	if lock.Requested != c.RequestedVersion() || lock.Arch != c.TargetArch() || !utils.IsValidVersionFormat(lock.Version) {
+		return nil
+	}
+	c.PinVersion(lock)
+	return nil
+}
+
+// checkVersionResolved 生成安装介质前版本通道必须已经解析
+func checkVersionResolved(cfg *ClusterConfig, r *ValidationResult) {
+	if cfg.VersionResolved() {
+		return
+	}
+	r.addError("cluster_info.openshift_version",
+		fmt.Sprintf("版本通道 %s 尚未解析为具体版本", cfg.ClusterInfo.OpenShiftVersion),
+		fmt.Sprintf("运行 'ocpack download' 解析并写入 %s，或者改为具体版本，例如 4.16.0", VersionLockFilename))
+}
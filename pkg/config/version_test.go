@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVersionChannel(t *testing.T) {
+	tests := map[string]string{
+		"stable-4.16":    "stable-4.16",
+		"fast-4.15":      "fast-4.15",
+		"candidate-4.17": "candidate-4.17",
+		"eus-4.16":       "eus-4.16",
+		"latest-4.16":    "fast-4.16",
+		"4.16.0":         "",
+		"stable-4.16.1":  "",
+		"stable":         "",
+	}
+	for version, want := range tests {
+		got, ok := VersionChannel(version)
+		if got != want || ok != (want != "") {
+			t.Errorf("VersionChannel(%q) = %q, %v; want %q", version, got, ok, want)
+		}
+	}
+}
+
+func TestLoadConfigAppliesVersionLock(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	if err := GenerateDefaultConfig(configPath, "demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConfigValue(configPath, "cluster_info.openshift_version", "stable-4.16"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有版本锁时保持版本通道，ISO 阶段校验失败
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.VersionResolved() {
+		t.Fatal("VersionResolved() = true without a lock file")
+	}
+	if err := Validate(cfg, StageDownload).Err(); err != nil {
+		t.Errorf("download stage should accept version channels: %v", err)
+	}
+	if !strings.Contains(Validate(cfg, StageISO).Err().Error(), VersionLockFilename) {
+		t.Error("ISO stage should require a resolved version")
+	}
+
+	if err := cfg.NewVersionLock("stable-4.16", "4.16.21").Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClusterInfo.OpenShiftVersion != "4.16.21" || cfg.RequestedVersion() != "stable-4.16" || cfg.ChannelType() != "stable" {
+		t.Errorf("version = %s, requested = %s, channel type = %s", cfg.ClusterInfo.OpenShiftVersion, cfg.RequestedVersion(), cfg.ChannelType())
+	}
+
+	// 保存时写回版本通道
+	if err := SaveConfig(cfg, configPath); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(data), "stable-4.16") || strings.Contains(string(data), "4.16.21") {
+		t.Errorf("SaveConfig() wrote:\n%s", data)
+	}
+
+	// 改变通道后忽略旧的版本锁
+	if err := SetConfigValue(configPath, "cluster_info.openshift_version", "fast-4.16"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.VersionResolved() {
+		t.Errorf("lock for another channel applied: %s", cfg.ClusterInfo.OpenShiftVersion)
+	}
+}
@@ -0,0 +1,177 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ocpack/pkg/secrets"
+)
+
+// --- Constants ---
+const (
+	registryDirName     = "registry"
+	credentialsFilename = "credentials.json"
+	passwordLength      = 24
+	// RegistrySecretName 启用密钥库时 Registry 凭据在密钥库中的条目名称
+	RegistrySecretName = "registry-credentials"
+	// 只使用字母和数字，避免 mirror-registry 和 shell 对特殊字符的处理问题
+	passwordAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// Registry 集群私有镜像仓库 (Quay) 的凭据
+type Registry struct {
+	Username string    `json:"username"`
+	Password string    `json:"password"`
+	Updated  time.Time `json:"updated"`
+	// 轮换过程中保存旧密码，Quay 更新失败时用于恢复
+	PreviousPassword string `json:"previous_password,omitempty"`
+}
+
+// RegistryFile 返回集群 Registry 凭据文件路径
+func RegistryFile(clusterDir string) string {
+	return filepath.Join(clusterDir, registryDirName, credentialsFilename)
+}
+
+// RegistryLocation 返回 Registry 凭据的保存位置，用于提示信息
+func RegistryLocation(clusterDir string) string {
+	if secrets.Exists(clusterDir) {
+		return fmt.Sprintf("%s (%s)", secrets.StorePath(clusterDir), RegistrySecretName)
+	}
+	return RegistryFile(clusterDir)
+}
+
+// LoadRegistry 读取集群的 Registry 凭据，凭据不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)。
+// 凭据文件不存在时从密钥库中读取。
+func LoadRegistry(clusterDir string) (*Registry, error) {
+	path := RegistryFile(clusterDir)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && secrets.Has(clusterDir, RegistrySecretName) {
+		path = RegistryLocation(clusterDir)
+		data, err = readSecret(clusterDir, RegistrySecretName)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("未找到 Registry 凭据 %s，请先运行 'ocpack deploy-registry': %w", path, err)
+		}
+		return nil, fmt.Errorf("读取 Registry 凭据失败: %w", err)
+	}
+
+	var creds Registry
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("解析 Registry 凭据 %s 失败: %w", path, err)
+	}
+	if creds.Password == "" {
+		return nil, fmt.Errorf("Registry 凭据 %s 中缺少密码", path)
+	}
+	return &creds, nil
+}
+
+// SaveRegistry 以 0600 权限保存 Registry 凭据，启用密钥库时保存到密钥库
+func SaveRegistry(clusterDir string, creds *Registry) error {
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 Registry 凭据失败: %w", err)
+	}
+
+	path := RegistryFile(clusterDir)
+	if secrets.Exists(clusterDir) {
+		if err := writeSecret(clusterDir, RegistrySecretName, string(data)); err != nil {
+			return fmt.Errorf("保存 Registry 凭据失败: %w", err)
+		}
+		// 密钥库中的凭据生效后删除旧的明文文件
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("删除明文凭据文件 %s 失败: %w", path, err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建 registry 目录失败: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免中断时留下不完整的凭据文件
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("保存 Registry 凭据失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("保存 Registry 凭据失败: %w", err)
+	}
+	return nil
+}
+
+// EnsureRegistry 返回集群的 Registry 凭据，不存在时生成新的随机密码并保存。
+// 用户名始终以配置文件中的 registry.registry_user 为准。
+func EnsureRegistry(clusterDir, username string) (*Registry, error) {
+	creds, err := LoadRegistry(clusterDir)
+	if err == nil {
+		if creds.Username != username {
+			creds.Username = username
+			if err := SaveRegistry(clusterDir, creds); err != nil {
+				return nil, err
+			}
+		}
+		return creds, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	password, err := GeneratePassword()
+	if err != nil {
+		return nil, err
+	}
+	creds = &Registry{Username: username, Password: password, Updated: time.Now().UTC()}
+	if err := SaveRegistry(clusterDir, creds); err != nil {
+		return nil, err
+	}
+	fmt.Printf("🔐 已生成 Registry 密码并保存到 %s\n", RegistryLocation(clusterDir))
+	return creds, nil
+}
+
+// GeneratePassword 使用 crypto/rand 生成随机密码
+func GeneratePassword() (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	password := make([]byte, passwordLength)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("生成随机密码失败: %w", err)
+		}
+		password[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// MergeAuth 将 Registry 凭据合并到 pull-secret (或已合并的认证文件) 的 auths 中
+func MergeAuth(pullSecret []byte, registryURL string, creds *Registry) ([]byte, error) {
+	var pullSecretData map[string]interface{}
+	if err := json.Unmarshal(pullSecret, &pullSecretData); err != nil {
+		return nil, fmt.Errorf("解析 pull-secret JSON 失败: %w", err)
+	}
+
+	auths, ok := pullSecretData["auths"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("pull-secret 格式无效: 缺少 'auths' 字段")
+	}
+
+	authString := fmt.Sprintf("%s:%s", creds.Username, creds.Password)
+	auths[registryURL] = map[string]interface{}{
+		"auth":  base64.StdEncoding.EncodeToString([]byte(authString)),
+		"email": "user@example.com", // 部分工具要求 email 字段
+	}
+
+	merged, err := json.MarshalIndent(pullSecretData, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化合并后的认证配置失败: %w", err)
+	}
+	return merged, nil
+}
@@ -0,0 +1,165 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ocpack/pkg/secrets"
+)
+
+func TestEnsureRegistry(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadRegistry(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("LoadRegistry() error = %v, expected os.ErrNotExist", err)
+	}
+
+	creds, err := EnsureRegistry(dir, "ocp4")
+	if err != nil {
+		t.Fatalf("EnsureRegistry() error = %v", err)
+	}
+	if creds.Username != "ocp4" || len(creds.Password) != passwordLength {
+		t.Errorf("EnsureRegistry() = %+v", creds)
+	}
+	info, err := os.Stat(RegistryFile(dir))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("credentials file mode = %v (%v), expected 0600", info.Mode().Perm(), err)
+	}
+
+	// 再次调用应返回已保存的密码
+	again, err := EnsureRegistry(dir, "ocp4")
+	if err != nil || again.Password != creds.Password {
+		t.Errorf("second EnsureRegistry() = %+v, %v; expected password to be reused", again, err)
+	}
+
+	other, _ := GeneratePassword()
+	if other == creds.Password {
+		t.Error("GeneratePassword() returned the same password twice")
+	}
+}
+
+func TestSecretsStore(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, pullSecretFilename), []byte(`{"auths":{}}`+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EnsureRegistry(dir, "ocp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(secrets.PassphraseEnv, "passphrase")
+	store, err := secrets.Create(dir, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportToStore(dir, store)
+	if err != nil || len(imported) != 2 {
+		t.Fatalf("ImportToStore() = %v, %v", imported, err)
+	}
+	for _, path := range []string{filepath.Join(dir, pullSecretFilename), RegistryFile(dir)} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("plaintext file %s was not removed", path)
+		}
+	}
+
+	if !HasPullSecret(dir) {
+		t.Error("HasPullSecret() = false for a pull-secret in the store")
+	}
+	pullSecret, err := ReadPullSecret(dir)
+	if err != nil || string(pullSecret) != `{"auths":{}}` {
+		t.Errorf("ReadPullSecret() = %s, %v", pullSecret, err)
+	}
+
+	// 启用密钥库后新保存的凭据不再写入明文文件
+	creds, err := LoadRegistry(dir)
+	if err != nil {
+		t.Fatalf("LoadRegistry() error = %v", err)
+	}
+	creds.Password = "rotated"
+	if err := SaveRegistry(dir, creds); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(RegistryFile(dir)); !errors.Is(err, os.ErrNotExist) {
+		t.Error("SaveRegistry() wrote a plaintext credentials file")
+	}
+	if again, err := LoadRegistry(dir); err != nil || again.Password != "rotated" {
+		t.Errorf("LoadRegistry() = %+v, %v", again, err)
+	}
+}
+
+func TestMergeAuth(t *testing.T) {
+	pullSecret := []byte(`{"auths":{"quay.io":{"auth":"eDp5"}}}`)
+	merged, err := MergeAuth(pullSecret, "registry.demo.example.com:8443", &Registry{Username: "ocp4", Password: "secret"})
+	if err != nil {
+		t.Fatalf("MergeAuth() error = %v", err)
+	}
+
+	var data struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(merged, &data); err != nil {
+		t.Fatal(err)
+	}
+	want := base64.StdEncoding.EncodeToString([]byte("ocp4:secret"))
+	if data.Auths["registry.demo.example.com:8443"].Auth != want || data.Auths["quay.io"].Auth != "eDp5" {
+		t.Errorf("MergeAuth() = %s", merged)
+	}
+
+	if _, err := MergeAuth([]byte(`{}`), "registry", &Registry{}); err == nil {
+		t.Error("MergeAuth() expected error for pull-secret without auths")
+	}
+}
+
+func TestQuayClientChangePassword(t *testing.T) {
+	password := "old-password"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/csrf_token", func(w http.ResponseWriter, r *http.Request) {
+		token := "anonymous"
+		if _, err := r.Cookie("session"); err == nil {
+			token = "signed-in"
+		}
+		json.NewEncoder(w).Encode(map[string]string{"csrf_token": token})
+	})
+	mux.HandleFunc("/api/v1/signin", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("X-CSRF-Token") != "anonymous" || body["username"] != "ocp4" || body["password"] != password {
+			http.Error(w, `{"message":"invalid credentials"}`, http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "ok", Path: "/"})
+	})
+	mux.HandleFunc("/api/v1/user/", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if _, err := r.Cookie("session"); err != nil || r.Method != http.MethodPut || r.Header.Get("X-CSRF-Token") != "signed-in" {
+			http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		password = body["password"]
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	httpClient := server.Client()
+	httpClient.Jar, _ = cookiejar.New(nil)
+	client := &quayClient{baseURL: server.URL, http: httpClient}
+
+	if err := client.changePassword("ocp4", "wrong", "new-password"); err == nil {
+		t.Error("changePassword() expected error for a wrong current password")
+	}
+	if err := client.changePassword("ocp4", "old-password", "new-password"); err != nil {
+		t.Fatalf("changePassword() error = %v", err)
+	}
+	if password != "new-password" {
+		t.Errorf("Quay password = %s, expected new-password", password)
+	}
+}
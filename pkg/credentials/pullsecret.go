@@ -0,0 +1,127 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/secrets"
+)
+
+// --- Constants ---
+const (
+	pullSecretFilename = "pull-secret.txt"
+	// PullSecretName 密钥库中 Red Hat pull-secret 的条目名称
+	PullSecretName = "pull-secret"
+)
+
+// UseSecretsStore 判断集群是否启用了密钥库。启用后不再在集群目录中写入明文认证文件
+func UseSecretsStore(clusterDir string) bool {
+	return secrets.Exists(clusterDir)
+}
+
+// HasPullSecret 不解密地检查集群是否提供了 pull-secret
+func HasPullSecret(clusterDir string) bool {
+	if _, err := os.Stat(filepath.Join(clusterDir, pullSecretFilename)); err == nil {
+		return true
+	}
+	return secrets.Has(clusterDir, PullSecretName)
+}
+
+// ReadPullSecret 读取 pull-secret.txt，文件不存在时从密钥库读取，
+// 两者都不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)
+func ReadPullSecret(clusterDir string) ([]byte, error) {
+	path := filepath.Join(clusterDir, pullSecretFilename)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && secrets.Has(clusterDir, PullSecretName) {
+		data, err = readSecret(clusterDir, PullSecretName)
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("未找到 pull-secret: %s 不存在，密钥库中也没有 %s 条目: %w", path, PullSecretName, err)
+		}
+		return nil, fmt.Errorf("读取 pull-secret 失败: %w", err)
+	}
+	return bytes.TrimSpace(data), nil
+}
+
+// MergedPullSecret 返回合并了 Registry 凭据的 pull-secret，只在内存中生成
+func MergedPullSecret(cfg *config.ClusterConfig, clusterDir string) ([]byte, error) {
+	pullSecret, err := ReadPullSecret(clusterDir)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := LoadRegistry(clusterDir)
+	if err != nil {
+		return nil, err
+	}
+	return MergeAuth(pullSecret, RegistryHost(cfg), creds)
+}
+
+// ImportToStore 将集群目录中的明文 pull-secret.txt 和 Registry 凭据导入密钥库并删除明文文件
+func ImportToStore(clusterDir string, store *secrets.Store) ([]string, error) {
+	files := map[string]string{
+		PullSecretName:     filepath.Join(clusterDir, pullSecretFilename),
+		RegistrySecretName: RegistryFile(clusterDir),
+	}
+
+	var imported []string
+	for _, name := range []string{PullSecretName, RegistrySecretName} {
+		data, err := os.ReadFile(files[name])
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", files[name], err)
+		}
+		if err := store.Set(name, strings.TrimSpace(string(data))); err != nil {
+			return nil, err
+		}
+		imported = append(imported, name)
+	}
+	if len(imported) == 0 {
+		return nil, nil
+	}
+	if err := store.Save(); err != nil {
+		return nil, err
+	}
+
+	// 密钥库保存成功后再删除明文文件，包括已合并的认证文件
+	for _, path := range []string{files[PullSecretName], files[RegistrySecretName], mergedAuthPath(clusterDir)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return imported, fmt.Errorf("删除明文文件 %s 失败: %w", path, err)
+		}
+	}
+	return imported, nil
+}
+
+func mergedAuthPath(clusterDir string) string {
+	return filepath.Join(clusterDir, registryDirName, mergedAuthFilename)
+}
+
+func readSecret(clusterDir, name string) ([]byte, error) {
+	store, err := secrets.Open(clusterDir)
+	if err != nil {
+		return nil, err
+	}
+	value, err := store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+func writeSecret(clusterDir, name, value string) error {
+	store, err := secrets.Open(clusterDir)
+	if err != nil {
+		return err
+	}
+	if err := store.Set(name, value); err != nil {
+		return err
+	}
+	return store.Save()
+}
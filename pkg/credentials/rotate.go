@@ -0,0 +1,230 @@
+package credentials
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ocpack/pkg/config"
+)
+
+// --- Constants ---
+const (
+	registryPort       = "8443"
+	mergedAuthFilename = "merged-auth.json"
+	rootCACertFilename = "rootCA.pem"
+	quayRequestTimeout = 30 * time.Second
+)
+
+// RotateOptions Registry 密码轮换选项
+type RotateOptions struct {
+	// CurrentPassword 凭据文件不存在时使用的当前密码，用于旧版本 ocpack 部署的 Registry
+	CurrentPassword string
+}
+
+// RegistryHost 返回 Registry 的主机名和端口，即 auths 中使用的键
+func RegistryHost(cfg *config.ClusterConfig) string {
+	return fmt.Sprintf("registry.%s.%s:%s", cfg.ClusterInfo.Name, cfg.ClusterInfo.Domain, registryPort)
+}
+
+// RotateRegistryPassword 生成新密码，同时更新 Quay、凭据文件和 merged-auth.json
+func RotateRegistryPassword(cfg *config.ClusterConfig, clusterDir string, opts RotateOptions) error {
+	fmt.Println("▶️  开始轮换 Registry 密码...")
+
+	current, err := LoadRegistry(clusterDir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) || opts.CurrentPassword == "" {
+			return err
+		}
+		current = &Registry{Password: opts.CurrentPassword}
+	}
+	current.Username = cfg.Registry.RegistryUser
+
+	newPassword, err := GeneratePassword()
+	if err != nil {
+		return err
+	}
+
+	// 1. 先保存新密码并保留旧密码，保证 Quay 更新后新密码不会丢失
+	rotated := &Registry{
+		Username:         current.Username,
+		Password:         newPassword,
+		Updated:          time.Now().UTC(),
+		PreviousPassword: current.Password,
+	}
+	if err := SaveRegistry(clusterDir, rotated); err != nil {
+		return err
+	}
+
+	// 2. 更新 Quay 中的用户密码，失败时恢复凭据文件
+	client, err := newQuayClient(cfg, clusterDir)
+	if err == nil {
+		err = client.changePassword(current.Username, current.Password, newPassword)
+	}
+	if err != nil {
+		current.PreviousPassword = ""
+		if restoreErr := SaveRegistry(clusterDir, current); restoreErr != nil {
+			return fmt.Errorf("更新 Quay 密码失败: %v; 恢复凭据文件也失败: %w", err, restoreErr)
+		}
+		return fmt.Errorf("更新 Quay 密码失败: %w", err)
+	}
+	fmt.Println("✅ Quay 密码已更新")
+
+	// 3. 更新合并后的认证文件
+	if err := UpdateMergedAuth(cfg, clusterDir, rotated); err != nil {
+		return err
+	}
+
+	rotated.PreviousPassword = ""
+	if err := SaveRegistry(clusterDir, rotated); err != nil {
+		return err
+	}
+	fmt.Printf("🎉 Registry 密码轮换完成，新密码保存在 %s\n", RegistryLocation(clusterDir))
+	fmt.Println("   如已使用 podman login 登录 Registry，请重新登录。")
+	return nil
+}
+
+// UpdateMergedAuth 更新 merged-auth.json 中的 Registry 凭据，文件不存在时跳过
+func UpdateMergedAuth(cfg *config.ClusterConfig, clusterDir string, creds *Registry) error {
+	mergedAuthPath := mergedAuthPath(clusterDir)
+	content, err := os.ReadFile(mergedAuthPath)
+	if errors.Is(err, os.ErrNotExist) {
+		if UseSecretsStore(clusterDir) {
+			fmt.Println("ℹ️  已启用密钥库，合并后的认证配置在使用时于内存中生成")
+		} else {
+			fmt.Printf("ℹ️  %s 不存在，将在下次 load-image 或 generate-iso 时生成\n", mergedAuthFilename)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取 %s 失败: %w", mergedAuthFilename, err)
+	}
+
+	merged, err := MergeAuth(content, RegistryHost(cfg), creds)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(mergedAuthPath, merged, 0600); err != nil {
+		return fmt.Errorf("保存 %s 失败: %w", mergedAuthFilename, err)
+	}
+	fmt.Printf("✅ 认证配置已更新: %s\n", mergedAuthPath)
+	return nil
+}
+
+// --- Quay API ---
+
+// quayClient 通过 Quay Web API 修改用户密码 (与 Quay 控制台使用相同的 session + CSRF 流程)
+type quayClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newQuayClient(cfg *config.ClusterConfig, clusterDir string) (*quayClient, error) {
+	hostname := fmt.Sprintf("registry.%s.%s", cfg.ClusterInfo.Name, cfg.ClusterInfo.Domain)
+
+	// 直接连接 Registry IP，证书按主机名校验，不依赖 Bastion DNS
+	tlsConfig := &tls.Config{ServerName: hostname}
+	for _, candidate := range []string{
+		filepath.Join(clusterDir, registryDirName, cfg.Registry.IP, rootCACertFilename),
+		filepath.Join(clusterDir, registryDirName, hostname, rootCACertFilename),
+		filepath.Join(clusterDir, registryDirName, rootCACertFilename),
+	} {
+		pem, err := os.ReadFile(candidate)
+		if err != nil {
+			continue
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("解析 CA 证书 %s 失败", candidate)
+		}
+		tlsConfig.RootCAs = pool
+		break
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建 cookie jar 失败: %w", err)
+	}
+	return &quayClient{
+		baseURL: "https://" + net.JoinHostPort(cfg.Registry.IP, registryPort),
+		http: &http.Client{
+			Timeout:   quayRequestTimeout,
+			Jar:       jar,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// changePassword 使用旧密码登录后修改当前用户的密码
+func (c *quayClient) changePassword(username, oldPassword, newPassword string) error {
+	token, err := c.csrfToken()
+	if err != nil {
+		return err
+	}
+	signin := map[string]string{"username": username, "password": oldPassword}
+	if err := c.send(http.MethodPost, "/api/v1/signin", token, signin); err != nil {
+		return fmt.Errorf("登录 Quay 失败: %w", err)
+	}
+
+	// 登录后 session 中的 CSRF token 会更新
+	if token, err = c.csrfToken(); err != nil {
+		return err
+	}
+	if err := c.send(http.MethodPut, "/api/v1/user/", token, map[string]string{"password": newPassword}); err != nil {
+		return fmt.Errorf("修改密码失败: %w", err)
+	}
+	return nil
+}
+
+func (c *quayClient) csrfToken() (string, error) {
+	resp, err := c.http.Get(c.baseURL + "/csrf_token")
+	if err != nil {
+		return "", fmt.Errorf("获取 CSRF token 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("获取 CSRF token 失败: HTTP %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Token string `json:"csrf_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Token == "" {
+		return "", fmt.Errorf("解析 CSRF token 失败: %v", err)
+	}
+	return body.Token, nil
+}
+
+func (c *quayClient) send(method, path, token string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s 返回 HTTP %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+# -*- coding: utf-8 -*-
+# ocpack 的 stdout 回调插件: 每个事件在 stdout 输出一行 JSON，由 ocpack 解析后显示任务进度和失败摘要
+from __future__ import absolute_import, division, print_function
+__metaclass__ = type
+
+DOCUMENTATION = '''
+    name: ocpack_jsonl
+    type: stdout
+    short_description: one JSON object per playbook event
+    description:
+      - Emits play, task and result events as JSON Lines for ocpack.
+'''
+
+import json
+import sys
+import time
+
+from ansible.plugins.callback import CallbackBase
+
+
+def _text(value):
+    if value is None:
+        return ''
+    if isinstance(value, str):
+        return value
+    return json.dumps(value, default=str)
+
+
+class CallbackModule(CallbackBase):
+    CALLBACK_VERSION = 2.0
+    CALLBACK_TYPE = 'stdout'
+    CALLBACK_NAME = 'ocpack_jsonl'
+
+    def _emit(self, event, **data):
+        data['event'] = event
+        data['time'] = time.time()
+        sys.stdout.write(json.dumps(data, default=str) + '\n')
+        sys.stdout.flush()
+
+    def _result(self, event, result, **extra):
+        r = result._result
+        data = dict(
+            task=result._task.get_name(),
+            host=result._host.get_name(),
+            changed=bool(r.get('changed', False)),
+            msg=_text(r.get('msg')),
+            stdout=_text(r.get('stdout')),
+            stderr=_text(r.get('stderr')),
+            rc=r.get('rc'),
+            diff=self._diff_text(r),
+        )
+        data.update(extra)
+        self._emit(event, **data)
+
+    def _diff_text(self, r):
+        # --diff 时 template/copy/lineinfile 等模块返回修改前后的内容，循环任务的 diff 在每个 item 中
+        diffs = [r['diff']] if r.get('diff') else []
+        for item in r.get('results') or []:
+            if isinstance(item, dict) and item.get('diff'):
+                diffs.append(item['diff'])
+        return ''.join(self._get_diff(d) for d in diffs)
+
+    def v2_playbook_on_play_start(self, play):
+        self._emit('play_start', play=play.get_name())
+
+    def v2_playbook_on_task_start(self, task, is_conditional):
+        self._emit('task_start', task=task.get_name())
+
+    def v2_playbook_on_handler_task_start(self, task):
+        self._emit('task_start', task=task.get_name(), handler=True)
+
+    def v2_runner_on_ok(self, result):
+        self._result('ok', result)
+
+    def v2_runner_on_failed(self, result, ignore_errors=False):
+        self._result('failed', result, ignore_errors=bool(ignore_errors))
+
+    def v2_runner_on_skipped(self, result):
+        self._result('skipped', result)
+
+    def v2_runner_on_unreachable(self, result):
+        self._result('unreachable', result)
+
+    def v2_playbook_on_stats(self, stats):
+        hosts = {}
+        for host in sorted(stats.processed.keys()):
+            hosts[host] = stats.summarize(host)
+        self._emit('stats', stats=hosts)
@@ -0,0 +1,272 @@
+package deploy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 任务在一台主机上的执行状态
+const (
+	TaskOK          = "ok"
+	TaskChanged     = "changed"
+	TaskSkipped     = "skipped"
+	TaskFailed      = "failed"
+	TaskIgnored     = "ignored" // 失败但设置了 ignore_errors
+	TaskUnreachable = "unreachable"
+)
+
+// maxSummaryLines 失败摘要中 stdout/stderr 保留的最后几行
+const maxSummaryLines = 10
+
+// ansibleEvent ocpack_jsonl 回调插件输出的一行事件
+type ansibleEvent struct {
+	Event        string               `json:"event"`
+	Play         string               `json:"play"`
+	Task         string               `json:"task"`
+	Handler      bool                 `json:"handler"`
+	Host         string               `json:"host"`
+	Changed      bool                 `json:"changed"`
+	IgnoreErrors bool                 `json:"ignore_errors"`
+	Msg          string               `json:"msg"`
+	Stdout       string               `json:"stdout"`
+	Stderr       string               `json:"stderr"`
+	RC           *int                 `json:"rc"`
+	Diff         string               `json:"diff"`
+	Stats        map[string]HostStats `json:"stats"`
+}
+
+// HostStats playbook 结束时每台主机的统计
+type HostStats struct {
+	OK          int `json:"ok"`
+	Changed     int `json:"changed"`
+	Failures    int `json:"failures"`
+	Unreachable int `json:"unreachable"`
+	Skipped     int `json:"skipped"`
+	Rescued     int `json:"rescued"`
+	Ignored     int `json:"ignored"`
+}
+
+// TaskResult 一个任务在一台主机上的执行结果
+type TaskResult struct {
+	Index  int // 任务序号，从 1 开始
+	Task   string
+	Host   string
+	Status string
+	Msg    string
+	Stdout string
+	Stderr string
+	RC     *int
+	Diff   string // 使用 --diff 时修改的内容
+}
+
+// PlaybookReport 一次 playbook 执行的结果
+type PlaybookReport struct {
+	Results []TaskResult
+	Stats   map[string]HostStats
+}
+
+// Failed 返回失败 (不含 ignore_errors) 和主机不可达的任务
+func (r *PlaybookReport) Failed() []TaskResult {
+	var failed []TaskResult
+	for _, result := range r.Results {
+		if result.Status == TaskFailed || result.Status == TaskUnreachable {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Summary 返回失败任务的摘要，每个任务包含主机、返回码、消息和输出的最后几行
+func (r *PlaybookReport) Summary() string {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "❌ %d 个任务失败:\n", len(failed))
+	for _, result := range failed {
+		fmt.Fprintf(&b, "   [%d] %s (%s", result.Index, result.Task, result.Host)
+		if result.Status == TaskUnreachable {
+			b.WriteString(", 主机不可达")
+		}
+		if result.RC != nil {
+			fmt.Fprintf(&b, ", rc=%d", *result.RC)
+		}
+		b.WriteString(")\n")
+		for _, field := range []struct{ name, value string }{
+			{"msg", result.Msg}, {"stderr", result.Stderr}, {"stdout", result.Stdout},
+		} {
+			lines := tailLines(field.value, maxSummaryLines)
+			if len(lines) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "       %s: %s\n", field.name, lines[0])
+			for _, line := range lines[1:] {
+				fmt.Fprintf(&b, "       %s  %s\n", strings.Repeat(" ", len(field.name)), line)
+			}
+		}
+	}
+	return b.String()
+}
+
+// Err 有失败任务时返回错误
+func (r *PlaybookReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	first := failed[0]
+	msg := first.Msg
+	if msg == "" {
+		msg = first.Status
+	}
+	if len(failed) == 1 {
+		return fmt.Errorf("任务 %q 失败: %s", first.Task, firstLine(msg))
+	}
+	return fmt.Errorf("%d 个任务失败，第一个为 %q: %s", len(failed), first.Task, firstLine(msg))
+}
+
+// readAnsibleEvents 解析回调插件的事件流，在 out 中显示每个任务的进度。
+// 每一行原样写入 transcript；不是 JSON 的行 (如 Ansible 的警告) 直接显示。
+func readAnsibleEvents(r io.Reader, out, transcript io.Writer) (*PlaybookReport, error) {
+	report := &PlaybookReport{}
+	index := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(transcript, line)
+
+		var event ansibleEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil {
+			if strings.TrimSpace(line) != "" {
+				fmt.Fprintln(out, line)
+			}
+			continue
+		}
+
+		switch event.Event {
+		case "play_start":
+			fmt.Fprintf(out, "▶️  %s\n", event.Play)
+		case "task_start":
+			index++
+		case "ok", "failed", "skipped", "unreachable":
+			result := TaskResult{
+				Index:  index,
+				Task:   event.Task,
+				Host:   event.Host,
+				Status: resultStatus(event),
+				Msg:    event.Msg,
+				Stdout: event.Stdout,
+				Stderr: event.Stderr,
+				RC:     event.RC,
+				Diff:   event.Diff,
+			}
+			report.Results = append(report.Results, result)
+			printTaskResult(out, result)
+		case "stats":
+			report.Stats = event.Stats
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return report, fmt.Errorf("读取 Ansible 输出失败: %w", err)
+	}
+	return report, nil
+}
+
+func resultStatus(event ansibleEvent) string {
+	switch event.Event {
+	case "ok":
+		if event.Changed {
+			return TaskChanged
+		}
+		return TaskOK
+	case "failed":
+		if event.IgnoreErrors {
+			return TaskIgnored
+		}
+		return TaskFailed
+	}
+	return event.Event
+}
+
+// printTaskResult 每个结果显示一行
+func printTaskResult(out io.Writer, result TaskResult) {
+	icon := map[string]string{
+		TaskOK:          "✔️ ",
+		TaskChanged:     "✅",
+		TaskSkipped:     "⏭️ ",
+		TaskIgnored:     "⚠️ ",
+		TaskFailed:      "❌",
+		TaskUnreachable: "❌",
+	}[result.Status]
+	line := fmt.Sprintf("   %s [%d] %s (%s, %s)", icon, result.Index, result.Task, result.Host, result.Status)
+	if result.Status == TaskIgnored && result.Msg != "" {
+		line += ": " + firstLine(result.Msg)
+	}
+	fmt.Fprintln(out, line)
+	if result.Diff != "" {
+		for _, diffLine := range strings.Split(strings.TrimSuffix(result.Diff, "\n"), "\n") {
+			fmt.Fprintf(out, "      %s\n", diffLine)
+		}
+	}
+}
+
+// Changed 返回修改了主机 (检查模式下为将要修改) 的任务
+func (r *PlaybookReport) Changed() []TaskResult {
+	var changed []TaskResult
+	for _, result := range r.Results {
+		if result.Status == TaskChanged {
+			changed = append(changed, result)
+		}
+	}
+	return changed
+}
+
+// PrintStats 显示每台主机的统计
+func (r *PlaybookReport) PrintStats(out io.Writer) {
+	hosts := make([]string, 0, len(r.Stats))
+	for host := range r.Stats {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		s := r.Stats[host]
+		fmt.Fprintf(out, "ℹ️  %s: ok=%d changed=%d failed=%d unreachable=%d skipped=%d ignored=%d\n",
+			host, s.OK, s.Changed, s.Failures, s.Unreachable, s.Skipped, s.Ignored)
+	}
+}
+
+func tailLines(s string, n int) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if len(lines) > n {
+		lines = append([]string{fmt.Sprintf("... (省略 %d 行)", len(lines)-n)}, lines[len(lines)-n:]...)
+	}
+	return lines
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// lockedWriter 允许 stdout 和 stderr 同时写入执行记录
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
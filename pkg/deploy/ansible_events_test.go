@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const sampleAnsibleEvents = `{"event": "play_start", "play": "Setup bastion"}
+{"event": "task_start", "task": "Install packages", "handler": false}
+{"event": "ok", "task": "Install packages", "host": "192.168.1.10", "changed": true, "msg": "", "stdout": "", "stderr": "", "rc": null, "ignore_errors": false, "diff": "--- before\n+++ after\n@@ -1 +1 @@\n-old\n+new\n"}
+{"event": "task_start", "task": "Check named", "handler": false}
+{"event": "failed", "task": "Check named", "host": "192.168.1.10", "changed": false, "msg": "non-zero return code", "stdout": "", "stderr": "", "rc": 1, "ignore_errors": true}
+[WARNING]: Platform linux on host 192.168.1.10 is using the discovered Python interpreter
+{"event": "task_start", "task": "Start haproxy", "handler": false}
+{"event": "failed", "task": "Start haproxy", "host": "192.168.1.10", "changed": false, "msg": "Unable to start service haproxy", "stdout": "", "stderr": "line1\nline2\nbind 0.0.0.0:6443 failed", "rc": 1, "ignore_errors": false}
+{"event": "stats", "stats": {"192.168.1.10": {"ok": 1, "changed": 1, "failures": 1, "unreachable": 0, "skipped": 0, "rescued": 0, "ignored": 1}}}
+`
+
+func TestReadAnsibleEvents(t *testing.T) {
+	var out, transcript bytes.Buffer
+	report, err := readAnsibleEvents(strings.NewReader(sampleAnsibleEvents), &out, &transcript)
+	if err != nil {
+		t.Fatalf("readAnsibleEvents() error = %v", err)
+	}
+	if transcript.String() != sampleAnsibleEvents {
+		t.Errorf("transcript does not contain the raw stream:\n%s", transcript.String())
+	}
+
+	if len(report.Results) != 3 {
+		t.Fatalf("results = %+v", report.Results)
+	}
+	wantStatus := []string{TaskChanged, TaskIgnored, TaskFailed}
+	for i, result := range report.Results {
+		if result.Status != wantStatus[i] || result.Index != i+1 {
+			t.Errorf("result %d = %+v, want status %s", i, result, wantStatus[i])
+		}
+	}
+	if changed := report.Changed(); len(changed) != 1 || changed[0].Diff == "" {
+		t.Errorf("Changed() = %+v", changed)
+	}
+	if report.Stats["192.168.1.10"].Failures != 1 {
+		t.Errorf("stats = %+v", report.Stats)
+	}
+
+	printed := out.String()
+	for _, want := range []string{"▶️  Setup bastion", "[1] Install packages (192.168.1.10, changed)", "      +new\n", "[2] Check named (192.168.1.10, ignored): non-zero return code", "[WARNING]: Platform linux"} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("output missing %q:\n%s", want, printed)
+		}
+	}
+	if strings.Contains(printed, `"event"`) {
+		t.Errorf("raw events printed:\n%s", printed)
+	}
+}
+
+func TestPlaybookReportFailures(t *testing.T) {
+	report, err := readAnsibleEvents(strings.NewReader(sampleAnsibleEvents), &bytes.Buffer{}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ignore_errors 的任务不算失败
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].Task != "Start haproxy" {
+		t.Fatalf("Failed() = %+v", failed)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), `"Start haproxy"`) || !strings.Contains(err.Error(), "Unable to start service haproxy") {
+		t.Errorf("Err() = %v", err)
+	}
+	summary := report.Summary()
+	for _, want := range []string{"1 个任务失败", "[3] Start haproxy (192.168.1.10, rc=1)", "stderr: line1", "bind 0.0.0.0:6443 failed"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Summary() missing %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "Check named") {
+		t.Errorf("Summary() includes ignored task:\n%s", summary)
+	}
+
+	ok := &PlaybookReport{Results: []TaskResult{{Task: "a", Status: TaskOK}, {Task: "b", Status: TaskSkipped}}}
+	if ok.Err() != nil || ok.Summary() != "" {
+		t.Errorf("successful report: Err() = %v, Summary() = %q", ok.Err(), ok.Summary())
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	var lines []string
+	for i := 0; i < maxSummaryLines+5; i++ {
+		lines = append(lines, "x")
+	}
+	got := tailLines(strings.Join(lines, "\n"), maxSummaryLines)
+	if len(got) != maxSummaryLines+1 || got[0] != "... (省略 5 行)" {
+		t.Errorf("tailLines() = %q", got)
+	}
+	if tailLines("  \n", maxSummaryLines) != nil {
+		t.Error("tailLines() of blank output should be empty")
+	}
+}
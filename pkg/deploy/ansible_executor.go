@@ -3,13 +3,20 @@ package deploy
 import (
 	"embed"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"text/template"
+	"time"
 
 	"ocpack/pkg/config"
+	"ocpack/pkg/credentials"
+	"ocpack/pkg/utils"
+
+	"gopkg.in/yaml.v3"
 )
 
 //go:embed ansible/bastion/*
@@ -21,12 +28,25 @@ var registryAnsibleFiles embed.FS
 //go:embed ansible/pxe/*
 var pxeAnsibleFiles embed.FS
 
+//go:embed ansible/callback_plugins/ocpack_jsonl.py
+var ansibleCallbackPlugin []byte
+
+// ansibleCallbackName 输出 JSON 事件的 stdout 回调插件
+const ansibleCallbackName = "ocpack_jsonl"
+
 // AnsibleExecutor 处理 Ansible playbook 的执行
 type AnsibleExecutor struct {
 	config         *config.ClusterConfig
 	workDir        string
 	inventory      string
 	ConfigFilePath string // 配置文件路径
+
+	// LogFile 保存完整的执行记录 (JSON 事件和 stderr)，相对路径相对于集群目录，为空时不保存
+	LogFile string
+	// Check 以 --check --diff 执行 playbook，只显示将要做的修改，不修改主机
+	Check bool
+	// Report 最近一次执行 playbook 的结果
+	Report *PlaybookReport
 }
 
 // NewAnsibleExecutor 创建新的 Ansible 执行器
@@ -44,19 +64,155 @@ func NewAnsibleExecutor(cfg *config.ClusterConfig, configFilePath string) (*Ansi
 	}, nil
 }
 
+// inventoryData inventory 模板数据
+type inventoryData struct {
+	*config.ClusterConfig
+	BastionSSH  inventorySSH
+	RegistrySSH inventorySSH
+	// LoadBalancerNodes haproxy-ha 模式的 HAProxy 节点，其他模式为空
+	LoadBalancerNodes []config.LoadBalancerNode
+}
+
+// inventorySSH 节点的 Ansible SSH 连接参数
+type inventorySSH struct {
+	Port       int
+	CommonArgs string // ansible_ssh_common_args
+}
+
+func newInventorySSH(opts utils.SSHOptions) inventorySSH {
+	var args []string
+	for _, opt := range opts.HostKeyOptions() {
+		args = append(args, "-o "+opt)
+	}
+	return inventorySSH{Port: opts.Port, CommonArgs: strings.Join(args, " ")}
+}
+
+// inventoryData 返回 inventory 模板数据，known_hosts 使用绝对路径
+func (ae *AnsibleExecutor) inventoryData() inventoryData {
+	clusterDir := filepath.Dir(ae.ConfigFilePath)
+	if abs, err := filepath.Abs(clusterDir); err == nil {
+		clusterDir = abs
+	}
+	data := inventoryData{
+		ClusterConfig: ae.config,
+		BastionSSH:    newInventorySSH(ae.config.BastionSSH(clusterDir)),
+		RegistrySSH:   newInventorySSH(ae.config.RegistrySSH(clusterDir)),
+	}
+	if ae.config.LoadBalancerMode() == config.LoadBalancerHAProxyHA {
+		data.LoadBalancerNodes = ae.config.LoadBalancer.Nodes
+	}
+	return data
+}
+
 // getAnsibleEnv 获取 Ansible 执行环境变量
 func (ae *AnsibleExecutor) getAnsibleEnv() []string {
 	env := os.Environ()
 
-	// 设置基本的 Ansible 环境变量以获得清洁的输出
-	env = append(env, "ANSIBLE_STDOUT_CALLBACK=default")     // 使用默认回调插件
-	env = append(env, "ANSIBLE_HOST_KEY_CHECKING=false")     // 禁用主机密钥检查
+	// 使用内置的回调插件输出 JSON 事件，由 readAnsibleEvents 显示进度
+	env = append(env, "ANSIBLE_STDOUT_CALLBACK="+ansibleCallbackName)
+	env = append(env, "ANSIBLE_CALLBACK_PLUGINS="+ae.callbackPluginDir())
 	env = append(env, "ANSIBLE_DISPLAY_SKIPPED_HOSTS=false") // 不显示跳过的主机
 	env = append(env, "ANSIBLE_VERBOSITY=0")                 // 设置最小详细程度
 
+	// 主机密钥由 inventory 中的 ssh 参数按 [ssh] 配置校验；为 false 时 Ansible 会强制添加 StrictHostKeyChecking=no
+	env = append(env, fmt.Sprintf("ANSIBLE_HOST_KEY_CHECKING=%t", ae.config.SSH.HostKeyPolicy != utils.HostKeyPolicyInsecure))
+
 	return env
 }
 
+func (ae *AnsibleExecutor) callbackPluginDir() string {
+	return filepath.Join(ae.workDir, "callback_plugins")
+}
+
+// runPlaybook 执行 playbook，显示每个任务的进度，失败时列出失败的任务和原因
+func (ae *AnsibleExecutor) runPlaybook(playbookPath string, varFiles ...string) error {
+	if err := os.MkdirAll(ae.callbackPluginDir(), 0755); err != nil {
+		return fmt.Errorf("创建回调插件目录失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(ae.callbackPluginDir(), ansibleCallbackName+".py"), ansibleCallbackPlugin, 0644); err != nil {
+		return fmt.Errorf("写入回调插件失败: %w", err)
+	}
+
+	args := []string{"-i", ae.inventory}
+	for _, varFile := range varFiles {
+		args = append(args, "-e", "@"+varFile)
+	}
+	if ae.Check {
+		args = append(args, "--check", "--diff")
+	}
+	args = append(args, playbookPath)
+	cmd := exec.Command("ansible-playbook", args...)
+	cmd.Dir = ae.workDir
+	cmd.Env = ae.getAnsibleEnv()
+
+	transcript, closeLog, err := ae.openLog(playbookPath)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	cmd.Stderr = io.MultiWriter(os.Stderr, transcript)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("获取 Ansible 输出失败: %w", err)
+	}
+
+	fmt.Printf("执行 Ansible playbook: %s\n", playbookPath)
+	fmt.Printf("使用 inventory: %s\n", ae.inventory)
+	fmt.Printf("工作目录: %s\n", ae.workDir)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("执行 Ansible playbook 失败: %w", err)
+	}
+	report, readErr := readAnsibleEvents(stdout, os.Stdout, transcript)
+	waitErr := cmd.Wait()
+	ae.Report = report
+
+	report.PrintStats(os.Stdout)
+	if summary := report.Summary(); summary != "" {
+		fmt.Print(summary)
+	}
+	if ae.LogFile != "" {
+		fmt.Printf("ℹ️  完整的执行记录: %s\n", ae.logPath())
+	}
+	if err := report.Err(); err != nil {
+		return fmt.Errorf("执行 Ansible playbook 失败: %w", err)
+	}
+	if waitErr != nil {
+		return fmt.Errorf("执行 Ansible playbook 失败: %w", waitErr)
+	}
+	if ae.Check {
+		fmt.Printf("ℹ️  检查模式: %d 个任务将修改主机，未做任何修改\n", len(report.Changed()))
+	}
+	return readErr
+}
+
+// logPath 返回执行记录的路径
+func (ae *AnsibleExecutor) logPath() string {
+	if filepath.IsAbs(ae.LogFile) {
+		return ae.LogFile
+	}
+	return filepath.Join(filepath.Dir(ae.ConfigFilePath), ae.LogFile)
+}
+
+// openLog 打开执行记录文件，多次执行追加到同一个文件；未设置 LogFile 时丢弃
+func (ae *AnsibleExecutor) openLog(playbookPath string) (io.Writer, func(), error) {
+	if ae.LogFile == "" {
+		return io.Discard, func() {}, nil
+	}
+	path := ae.logPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	// 执行记录可能包含主机信息和命令输出，只允许当前用户读取
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	w := &lockedWriter{w: f}
+	fmt.Fprintf(w, "# %s ansible-playbook %s\n", time.Now().Format(time.RFC3339), filepath.Base(filepath.Dir(playbookPath)))
+	return w, func() { f.Close() }, nil
+}
+
 // ExtractBastionFiles 提取 bastion 相关的 Ansible 文件到临时目录
 func (ae *AnsibleExecutor) ExtractBastionFiles() error {
 	// 提取所有嵌入的文件
@@ -122,14 +278,14 @@ func (ae *AnsibleExecutor) GenerateInventory() error {
 
 	// 生成 inventory 文件
 	inventoryPath := filepath.Join(ae.workDir, "inventory")
-	inventoryFile, err := os.Create(inventoryPath)
+	inventoryFile, err := os.OpenFile(inventoryPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // 可能包含 SSH 密码
 	if err != nil {
 		return fmt.Errorf("创建 inventory 文件失败: %w", err)
 	}
 	defer inventoryFile.Close()
 
 	// 执行模板
-	if err := tmpl.Execute(inventoryFile, ae.config); err != nil {
+	if err := tmpl.Execute(inventoryFile, ae.inventoryData()); err != nil {
 		return fmt.Errorf("生成 inventory 文件失败: %w", err)
 	}
 
@@ -151,51 +307,11 @@ func (ae *AnsibleExecutor) GenerateVarsFile() error {
 	configDir := filepath.Dir(filepath.Join(currentDir, ae.ConfigFilePath))
 	clusterDir := filepath.Base(configDir)
 
-	varsContent := fmt.Sprintf(`---
-cluster_info:
-  name: "%s"
-  domain: "%s"
-  cluster_id: "%s"
-
-bastion:
-  ip: "%s"
-
-registry:
-  ip: "%s"
-  storage_path: "%s"
-  registry_user: "%s"
-
-project_root: "%s"
-cluster_dir: "%s"
-
-cluster:
-  control_plane:
-`, ae.config.ClusterInfo.Name, ae.config.ClusterInfo.Domain, ae.config.ClusterInfo.ClusterID, ae.config.Bastion.IP, ae.config.Registry.IP, ae.config.Registry.StoragePath, ae.config.Registry.RegistryUser, currentDir, clusterDir)
-
-	// 添加 Control Plane 节点
-	for _, cp := range ae.config.Cluster.ControlPlane {
-		varsContent += fmt.Sprintf(`    - name: "%s"
-      ip: "%s"
-      mac: "%s"
-`, cp.Name, cp.IP, cp.MAC)
-	}
-
-	varsContent += "  worker:\n"
-	// 添加 Worker 节点
-	for _, worker := range ae.config.Cluster.Worker {
-		varsContent += fmt.Sprintf(`    - name: "%s"
-      ip: "%s"
-      mac: "%s"
-`, worker.Name, worker.IP, worker.MAC)
+	varsContent, err := buildAnsibleVars(ae.config, currentDir, clusterDir)
+	if err != nil {
+		return err
 	}
 
-	// 添加网络配置
-	varsContent += fmt.Sprintf(`  network:
-    cluster_network: "%s"
-    service_network: "%s"
-    machine_network: "%s"
-`, ae.config.Cluster.Network.ClusterNetwork, ae.config.Cluster.Network.ServiceNetwork, ae.config.Cluster.Network.MachineNetwork)
-
 	if err := os.WriteFile(varsPath, []byte(varsContent), 0644); err != nil {
 		return fmt.Errorf("创建变量文件失败: %w", err)
 	}
@@ -203,6 +319,25 @@ cluster:
 	return nil
 }
 
+// GenerateRegistrySecretsFile 生成只包含 Registry 密码的变量文件 (0600)，密码不出现在命令行参数中
+func (ae *AnsibleExecutor) GenerateRegistrySecretsFile() (string, error) {
+	clusterDir := filepath.Dir(ae.ConfigFilePath)
+	creds, err := credentials.EnsureRegistry(clusterDir, ae.config.Registry.RegistryUser)
+	if err != nil {
+		return "", fmt.Errorf("获取 Registry 凭据失败: %w", err)
+	}
+
+	content, err := yaml.Marshal(map[string]string{"registry_password": creds.Password})
+	if err != nil {
+		return "", fmt.Errorf("生成 Registry 密码变量失败: %w", err)
+	}
+	secretsPath := filepath.Join(ae.workDir, "registry-secrets.yml")
+	if err := os.WriteFile(secretsPath, content, 0600); err != nil {
+		return "", fmt.Errorf("创建 Registry 密码变量文件失败: %w", err)
+	}
+	return secretsPath, nil
+}
+
 // CheckAnsibleInstalled 检查 Ansible 是否已安装
 func (ae *AnsibleExecutor) CheckAnsibleInstalled() error {
 	_, err := exec.LookPath("ansible-playbook")
@@ -238,31 +373,7 @@ func (ae *AnsibleExecutor) RunBastionPlaybook() error {
 	playbookPath := filepath.Join(ae.workDir, "ansible/bastion/playbook.yml")
 	varsPath := filepath.Join(ae.workDir, "vars.yml")
 
-	cmd := exec.Command("ansible-playbook",
-		"-i", ae.inventory,
-		"-e", fmt.Sprintf("@%s", varsPath),
-		playbookPath,
-	)
-
-	// 设置工作目录
-	cmd.Dir = ae.workDir
-
-	// 设置环境变量（包括 Ansible 回调插件配置）
-	cmd.Env = ae.getAnsibleEnv()
-
-	// 设置输出
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	fmt.Printf("执行 Ansible playbook: %s\n", playbookPath)
-	fmt.Printf("使用 inventory: %s\n", ae.inventory)
-	fmt.Printf("工作目录: %s\n", ae.workDir)
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("执行 Ansible playbook 失败: %w", err)
-	}
-
-	return nil
+	return ae.runPlaybook(playbookPath, varsPath)
 }
 
 // Cleanup 清理临时文件
@@ -343,14 +454,14 @@ func (ae *AnsibleExecutor) GenerateRegistryInventory() error {
 
 	// 生成 inventory 文件
 	inventoryPath := filepath.Join(ae.workDir, "registry_inventory")
-	inventoryFile, err := os.Create(inventoryPath)
+	inventoryFile, err := os.OpenFile(inventoryPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // 可能包含 SSH 密码
 	if err != nil {
 		return fmt.Errorf("创建 inventory 文件失败: %w", err)
 	}
 	defer inventoryFile.Close()
 
 	// 执行模板
-	if err := tmpl.Execute(inventoryFile, ae.config); err != nil {
+	if err := tmpl.Execute(inventoryFile, ae.inventoryData()); err != nil {
 		return fmt.Errorf("生成 inventory 文件失败: %w", err)
 	}
 
@@ -380,35 +491,17 @@ func (ae *AnsibleExecutor) RunRegistryPlaybook() error {
 		return err
 	}
 
+	// 生成 Registry 密码变量文件
+	secretsPath, err := ae.GenerateRegistrySecretsFile()
+	if err != nil {
+		return err
+	}
+
 	// 执行 playbook
 	playbookPath := filepath.Join(ae.workDir, "ansible/registry/playbook.yml")
 	varsPath := filepath.Join(ae.workDir, "vars.yml")
 
-	cmd := exec.Command("ansible-playbook",
-		"-i", ae.inventory,
-		"-e", fmt.Sprintf("@%s", varsPath),
-		playbookPath,
-	)
-
-	// 设置工作目录
-	cmd.Dir = ae.workDir
-
-	// 设置环境变量（包括 Ansible 回调插件配置）
-	cmd.Env = ae.getAnsibleEnv()
-
-	// 设置输出
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	fmt.Printf("执行 Ansible playbook: %s\n", playbookPath)
-	fmt.Printf("使用 inventory: %s\n", ae.inventory)
-	fmt.Printf("工作目录: %s\n", ae.workDir)
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("执行 Ansible playbook 失败: %w", err)
-	}
-
-	return nil
+	return ae.runPlaybook(playbookPath, varsPath, secretsPath)
 }
 
 // ExtractPXEFiles 提取 PXE 相关的 Ansible 文件到临时目录
@@ -476,14 +569,14 @@ func (ae *AnsibleExecutor) GeneratePXEInventory() error {
 
 	// 生成 inventory 文件
 	inventoryPath := filepath.Join(ae.workDir, "pxe_inventory")
-	inventoryFile, err := os.Create(inventoryPath)
+	inventoryFile, err := os.OpenFile(inventoryPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // 可能包含 SSH 密码
 	if err != nil {
 		return fmt.Errorf("创建 inventory 文件失败: %w", err)
 	}
 	defer inventoryFile.Close()
 
 	// 执行模板
-	if err := tmpl.Execute(inventoryFile, ae.config); err != nil {
+	if err := tmpl.Execute(inventoryFile, ae.inventoryData()); err != nil {
 		return fmt.Errorf("生成 inventory 文件失败: %w", err)
 	}
 
@@ -517,29 +610,5 @@ func (ae *AnsibleExecutor) RunPXEPlaybook() error {
 	playbookPath := filepath.Join(ae.workDir, "ansible/pxe/playbook.yml")
 	varsPath := filepath.Join(ae.workDir, "vars.yml")
 
-	cmd := exec.Command("ansible-playbook",
-		"-i", ae.inventory,
-		"-e", fmt.Sprintf("@%s", varsPath),
-		playbookPath,
-	)
-
-	// 设置工作目录
-	cmd.Dir = ae.workDir
-
-	// 设置环境变量（包括 Ansible 回调插件配置）
-	cmd.Env = ae.getAnsibleEnv()
-
-	// 设置输出
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	fmt.Printf("执行 Ansible playbook: %s\n", playbookPath)
-	fmt.Printf("使用 inventory: %s\n", ae.inventory)
-	fmt.Printf("工作目录: %s\n", ae.workDir)
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("执行 Ansible playbook 失败: %w", err)
-	}
-
-	return nil
+	return ae.runPlaybook(playbookPath, varsPath)
 }
@@ -0,0 +1,75 @@
+package deploy
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/utils"
+)
+
+func TestInventoryHostKeyPolicy(t *testing.T) {
+	tmplContent, err := bastionAnsibleFiles.ReadFile("ansible/bastion/inventory.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := template.Must(template.New("inventory").Parse(string(tmplContent)))
+
+	clusterDir := t.TempDir()
+	tests := []struct {
+		policy   string
+		port     int
+		expected []string
+	}{
+		{"", 0, []string{"StrictHostKeyChecking=accept-new", "UserKnownHostsFile=" + filepath.Join(clusterDir, "known_hosts")}},
+		{utils.HostKeyPolicyStrict, 2222, []string{"ansible_port=2222", "StrictHostKeyChecking=yes"}},
+		{utils.HostKeyPolicyInsecure, 0, []string{"StrictHostKeyChecking=no", "UserKnownHostsFile=/dev/null"}},
+	}
+
+	for _, test := range tests {
+		cfg := config.NewDefaultConfig("demo")
+		cfg.Bastion.IP = "192.168.1.10"
+		cfg.Bastion.SSHPort = test.port
+		cfg.SSH.HostKeyPolicy = test.policy
+		ae := &AnsibleExecutor{config: cfg, ConfigFilePath: filepath.Join(clusterDir, "config.toml")}
+
+		var b strings.Builder
+		if err := tmpl.Execute(&b, ae.inventoryData()); err != nil {
+			t.Fatalf("inventory template error = %v", err)
+		}
+		for _, want := range test.expected {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("policy %q: inventory missing %q:\n%s", test.policy, want, b.String())
+			}
+		}
+		if strings.Contains(b.String(), "ansible_ssh_pass") {
+			t.Errorf("policy %q: inventory contains an empty ansible_ssh_pass:\n%s", test.policy, b.String())
+		}
+	}
+}
+
+func TestInventoryLoadBalancerNodes(t *testing.T) {
+	tmplContent, err := bastionAnsibleFiles.ReadFile("ansible/bastion/inventory.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := template.Must(template.New("inventory").Parse(string(tmplContent)))
+
+	cfg := testHAConfig()
+	cfg.Bastion.Password = "secret"
+	for _, mode := range []string{config.LoadBalancerHAProxyHA, config.LoadBalancerExternal} {
+		cfg.LoadBalancer.Mode = mode
+		ae := &AnsibleExecutor{config: cfg, ConfigFilePath: filepath.Join(t.TempDir(), "config.toml")}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, ae.inventoryData()); err != nil {
+			t.Fatalf("inventory template error = %v", err)
+		}
+		_, group, _ := strings.Cut(b.String(), "[loadbalancer]\n")
+		hasNodes := strings.Contains(group, "192.168.1.8 ansible_user=root ansible_ssh_pass=secret\n")
+		if hasNodes != (mode == config.LoadBalancerHAProxyHA) {
+			t.Errorf("%s: loadbalancer group has nodes = %v:\n%s", mode, hasNodes, b.String())
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package deploy
+
+import (
+	"fmt"
+	"net/netip"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ansibleVars vars.yml 的内容，供 bastion、registry 和 pxe playbook 使用
+type ansibleVars struct {
+	ClusterInfo  clusterInfoVars  `yaml:"cluster_info"`
+	Bastion      hostVars         `yaml:"bastion"`
+	Registry     registryVars     `yaml:"registry"`
+	ProjectRoot  string           `yaml:"project_root"`
+	ClusterDir   string           `yaml:"cluster_dir"`
+	Cluster      clusterVars      `yaml:"cluster"`
+	DNS          dnsVars          `yaml:"dns"`
+	Security     securityVars     `yaml:"security"`
+	LoadBalancer loadBalancerVars `yaml:"load_balancer"`
+}
+
+// loadBalancerVars API 和 Ingress 的负载均衡方式，haproxy.cfg.j2 和 keepalived.conf.j2 使用
+type loadBalancerVars struct {
+	Mode     string                 `yaml:"mode"`
+	BindIPv6 bool                   `yaml:"bind_ipv6"` // HAProxy 同时监听 IPv6
+	Nodes    []loadBalancerNodeVars `yaml:"nodes"`     // haproxy-ha 模式的 HAProxy 节点
+	// haproxy-ha 模式的 VIP 和 VRRP 路由 ID，Ingress VIP 与 API VIP 相同时只使用一个 VRRP 实例
+	APIVIP          hostVars `yaml:"api_vip"`
+	IngressVIP      hostVars `yaml:"ingress_vip"`
+	APIRouterID     int      `yaml:"api_router_id"`
+	IngressRouterID int      `yaml:"ingress_router_id"`
+}
+
+type loadBalancerNodeVars struct {
+	hostVars  `yaml:",inline"`
+	Interface string `yaml:"interface"` // 为空时使用默认路由所在的网卡
+	State     string `yaml:"state"`     // keepalived 初始状态，第一个节点为 MASTER
+	Priority  int    `yaml:"priority"`
+}
+
+// securityVars firewalld 和 SELinux 的处理方式，hardened 模式下开放的端口与原生引擎相同
+type securityVars struct {
+	Mode              string   `yaml:"mode"`
+	BastionPorts      []string `yaml:"bastion_ports"`
+	PXEPorts          []string `yaml:"pxe_ports"`
+	RegistryPorts     []string `yaml:"registry_ports"`
+	LoadBalancerPorts []string `yaml:"load_balancer_ports"`
+	// 需要恢复默认 SELinux 上下文的文件和目录
+	BastionSELinuxPaths      []string `yaml:"bastion_selinux_paths"`
+	PXESELinuxPaths          []string `yaml:"pxe_selinux_paths"`
+	LoadBalancerSELinuxPaths []string `yaml:"load_balancer_selinux_paths"`
+}
+
+type clusterInfoVars struct {
+	Name      string `yaml:"name"`
+	Domain    string `yaml:"domain"`
+	ClusterID string `yaml:"cluster_id"`
+	Arch      string `yaml:"arch"` // 集群节点架构，决定 PXE 启动文件名 agent.<arch>-*
+}
+
+// hostVars 主机地址。ip 为主地址，ipv4/ipv6 为空表示未配置该地址族，
+// address 可直接用于 "地址:端口" 形式 (IPv6 带方括号)
+type hostVars struct {
+	Name    string `yaml:"name,omitempty"`
+	IP      string `yaml:"ip"`
+	IPv4    string `yaml:"ipv4"`
+	IPv6    string `yaml:"ipv6"`
+	Address string `yaml:"address"`
+	MAC     string `yaml:"mac,omitempty"`
+}
+
+type registryVars struct {
+	hostVars     `yaml:",inline"`
+	StoragePath  string `yaml:"storage_path"`
+	RegistryUser string `yaml:"registry_user"`
+	Archive      string `yaml:"archive"` // downloads 目录中的 mirror-registry 安装包
+}
+
+type clusterVars struct {
+	Topology     string      `yaml:"topology"`
+	ControlPlane []hostVars  `yaml:"control_plane"`
+	Worker       []hostVars  `yaml:"worker"`
+	IngressNodes []hostVars  `yaml:"ingress_nodes"` // HAProxy 80/443 后端
+	Network      networkVars `yaml:"network"`
+}
+
+type networkVars struct {
+	ClusterNetwork   []string `yaml:"cluster_network"`
+	ServiceNetwork   []string `yaml:"service_network"`
+	MachineNetwork   []string `yaml:"machine_network"`
+	MachineNetworkV4 string   `yaml:"machine_network_v4"` // DHCP (dhcpd) 只支持 IPv4
+	MachineNetworkV6 string   `yaml:"machine_network_v6"`
+}
+
+type dnsVars struct {
+	API          hostVars          `yaml:"api"`  // api 和 api-int 记录指向的地址
+	Apps         hostVars          `yaml:"apps"` // *.apps 记录指向的地址
+	ReverseZones []reverseZoneVars `yaml:"reverse_zones"`
+}
+
+// reverseZoneVars 一个反向解析区域，每个机器网络对应一个
+type reverseZoneVars struct {
+	Name    string         `yaml:"name"`
+	File    string         `yaml:"file"`
+	Records []ptrRecordVar `yaml:"records"`
+}
+
+type ptrRecordVar struct {
+	Name string `yaml:"name"` // 完整的 PTR 名称，不含末尾的点
+	Host string `yaml:"host"` // 主机名 (不含集群域名)
+}
+
+// buildAnsibleVars 根据集群配置生成 vars.yml 内容
+func buildAnsibleVars(cfg *config.ClusterConfig, projectRoot, clusterDir string) ([]byte, error) {
+	vars := ansibleVars{
+		ClusterInfo: clusterInfoVars{
+			Name:      cfg.ClusterInfo.Name,
+			Domain:    cfg.ClusterInfo.Domain,
+			ClusterID: cfg.ClusterInfo.ClusterID,
+			Arch:      cfg.TargetArch(),
+		},
+		Bastion: newHostVars("", cfg.Bastion.IP, cfg.Bastion.IPv6, ""),
+		Registry: registryVars{
+			hostVars:     newHostVars("", cfg.Registry.IP, cfg.Registry.IPv6, ""),
+			StoragePath:  cfg.Registry.StoragePath,
+			RegistryUser: cfg.Registry.RegistryUser,
+			Archive:      cfg.MirrorRegistryArchive(),
+		},
+		ProjectRoot: projectRoot,
+		ClusterDir:  clusterDir,
+		Security: securityVars{
+			Mode:              cfg.SecurityMode(),
+			BastionPorts:      bastionFirewallPorts(cfg),
+			PXEPorts:          pxeFirewallPorts,
+			RegistryPorts:     registryFirewallPorts,
+			LoadBalancerPorts: haproxyFirewallPorts,
+
+			BastionSELinuxPaths:      bastionSELinuxPaths,
+			PXESELinuxPaths:          pxeSELinuxPaths,
+			LoadBalancerSELinuxPaths: loadBalancerSELinuxPaths,
+		},
+		LoadBalancer: newLoadBalancerVars(cfg),
+		Cluster: clusterVars{
+			Topology:     cfg.Topology(),
+			ControlPlane: []hostVars{},
+			Worker:       []hostVars{},
+			IngressNodes: []hostVars{},
+			Network: networkVars{
+				ClusterNetwork:   cfg.Cluster.Network.ClusterNetwork,
+				ServiceNetwork:   cfg.Cluster.Network.ServiceNetwork,
+				MachineNetwork:   utils.NormalizeCIDRs(cfg.Cluster.Network.MachineNetwork),
+				MachineNetworkV4: firstNetwork(cfg.Cluster.Network.MachineNetwork, false),
+				MachineNetworkV6: firstNetwork(cfg.Cluster.Network.MachineNetwork, true),
+			},
+		},
+	}
+	for _, cp := range cfg.Cluster.ControlPlane {
+		vars.Cluster.ControlPlane = append(vars.Cluster.ControlPlane, newHostVars(cp.Name, cp.IP, cp.IPv6, cp.MAC))
+	}
+	for _, worker := range cfg.Cluster.Worker {
+		vars.Cluster.Worker = append(vars.Cluster.Worker, newHostVars(worker.Name, worker.IP, worker.IPv6, worker.MAC))
+	}
+	for _, node := range cfg.IngressNodes() {
+		vars.Cluster.IngressNodes = append(vars.Cluster.IngressNodes, newHostVars(node.Name, node.IP, node.IPv6, node.MAC))
+	}
+
+	// API 和应用入口默认经过 Bastion 上的 HAProxy，haproxy-ha 和 external 模式指向 VIP；单节点集群直接指向节点
+	switch {
+	case vars.Cluster.Topology == config.TopologySNO && len(vars.Cluster.ControlPlane) == 1:
+		vars.DNS.API, vars.DNS.Apps = vars.Cluster.ControlPlane[0], vars.Cluster.ControlPlane[0]
+	case vars.LoadBalancer.Mode != config.LoadBalancerBastionHAProxy:
+		vars.DNS.API = newHostVars("", cfg.LoadBalancer.APIVIP, cfg.LoadBalancer.APIVIPv6, "")
+		vars.DNS.Apps = newHostVars("", cfg.LoadBalancer.IngressVIP, cfg.LoadBalancer.IngressVIPv6, "")
+	default:
+		vars.DNS.API, vars.DNS.Apps = vars.Bastion, vars.Bastion
+	}
+
+	// 反向解析记录: bastion、registry、HAProxy 节点和所有节点
+	hosts := []hostVars{newHostVars("bastion", cfg.Bastion.IP, cfg.Bastion.IPv6, ""), newHostVars("registry", cfg.Registry.IP, cfg.Registry.IPv6, "")}
+	for _, node := range vars.LoadBalancer.Nodes {
+		if node.IP != cfg.Bastion.IP { // Bastion 也可以作为 HAProxy 节点
+			hosts = append(hosts, node.hostVars)
+		}
+	}
+	hosts = append(hosts, vars.Cluster.ControlPlane...)
+	hosts = append(hosts, vars.Cluster.Worker...)
+
+	zones, err := reverseZones(vars.Cluster.Network.MachineNetwork, hosts)
+	if err != nil {
+		return nil, err
+	}
+	vars.DNS.ReverseZones = zones
+
+	data, err := yaml.Marshal(&vars)
+	if err != nil {
+		return nil, fmt.Errorf("生成变量文件内容失败: %w", err)
+	}
+	return append([]byte("---\n"), data...), nil
+}
+
+// newLoadBalancerVars 生成负载均衡变量，只有 haproxy-ha 模式包含节点和 VIP
+func newLoadBalancerVars(cfg *config.ClusterConfig) loadBalancerVars {
+	lb := loadBalancerVars{Mode: cfg.LoadBalancerMode(), Nodes: []loadBalancerNodeVars{}}
+	if lb.Mode != config.LoadBalancerHAProxyHA {
+		lb.BindIPv6 = newHostVars("", cfg.Bastion.IP, cfg.Bastion.IPv6, "").IPv6 != ""
+		return lb
+	}
+	lb.APIVIP = newHostVars("", cfg.LoadBalancer.APIVIP, cfg.LoadBalancer.APIVIPv6, "")
+	lb.IngressVIP = newHostVars("", cfg.LoadBalancer.IngressVIP, cfg.LoadBalancer.IngressVIPv6, "")
+	lb.BindIPv6 = lb.APIVIP.IPv6 != "" || lb.IngressVIP.IPv6 != ""
+	lb.APIRouterID = cfg.VirtualRouterID()
+	lb.IngressRouterID = lb.APIRouterID + 1
+	for i, node := range cfg.LoadBalancer.Nodes {
+		n := loadBalancerNodeVars{hostVars: newHostVars(node.Name, node.IP, node.IPv6, ""), Interface: node.Interface, State: "BACKUP", Priority: 100}
+		if i == 0 {
+			n.State, n.Priority = "MASTER", 150
+		}
+		lb.Nodes = append(lb.Nodes, n)
+	}
+	return lb
+}
+
+func newHostVars(name, ip, ipv6, mac string) hostVars {
+	ipv4, ipv6 := config.HostAddresses(ip, ipv6)
+	address := ipv4
+	if address == "" {
+		address = "[" + ipv6 + "]"
+	}
+	return hostVars{Name: name, IP: ip, IPv4: ipv4, IPv6: ipv6, Address: address, MAC: mac}
+}
+
+// firstNetwork 返回指定地址族的第一个机器网络，不存在时返回空字符串
+func firstNetwork(cidrs []string, ipv6 bool) string {
+	for _, cidr := range cidrs {
+		if utils.IsValidCIDR(cidr) && utils.IsIPv6CIDR(cidr) == ipv6 {
+			return utils.NormalizeCIDR(cidr)
+		}
+	}
+	return ""
+}
+
+// reverseZones 为每个机器网络生成反向解析区域，记录只包含位于该网络内的主机地址
+func reverseZones(machineNetworks []string, hosts []hostVars) ([]reverseZoneVars, error) {
+	zones := []reverseZoneVars{}
+	for _, cidr := range machineNetworks {
+		name, err := utils.ReverseZone(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的机器网络 %s: %w", cidr, err)
+		}
+
+		zone := reverseZoneVars{Name: name, File: name + ".zone", Records: []ptrRecordVar{}}
+		for _, host := range hosts {
+			for _, ip := range []string{host.IPv4, host.IPv6} {
+				addr, err := netip.ParseAddr(ip)
+				if err != nil || !prefix.Contains(addr) {
+					continue
+				}
+				pointer, err := utils.ReversePointer(ip)
+				if err != nil {
+					return nil, err
+				}
+				zone.Records = append(zone.Records, ptrRecordVar{Name: pointer, Host: host.Name})
+			}
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
@@ -0,0 +1,94 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"ocpack/pkg/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildAnsibleVarsReverseZones(t *testing.T) {
+	cfg := config.NewDefaultConfig("demo")
+	cfg.Bastion.IP = "192.168.1.10"
+	cfg.Bastion.IPv6 = "fd00:1::10"
+	cfg.Registry.IP = "192.168.1.11"
+	cfg.Cluster.Network.MachineNetwork = []string{"192.168.1.0/24", "fd00:1::/64"}
+	cfg.Cluster.Worker = nil
+	cfg.Cluster.ControlPlane = []config.Node{{Name: "master-0", IP: "192.168.1.21", IPv6: "fd00:1::21"}}
+
+	data, err := buildAnsibleVars(cfg, "/work", "demo")
+	if err != nil {
+		t.Fatalf("buildAnsibleVars() error = %v", err)
+	}
+	var vars ansibleVars
+	if err := yaml.Unmarshal(data, &vars); err != nil {
+		t.Fatalf("vars.yml is not valid YAML: %v\n%s", err, data)
+	}
+
+	if vars.Bastion.IPv6 != "fd00:1::10" || vars.Registry.IP != "192.168.1.11" || vars.Registry.IPv6 != "" {
+		t.Errorf("bastion = %+v, registry = %+v", vars.Bastion, vars.Registry)
+	}
+	if vars.Cluster.Network.MachineNetworkV4 != "192.168.1.0/24" {
+		t.Errorf("machine_network_v4 = %s", vars.Cluster.Network.MachineNetworkV4)
+	}
+
+	zones := vars.DNS.ReverseZones
+	if len(zones) != 2 {
+		t.Fatalf("expected 2 reverse zones, got %+v", zones)
+	}
+	if zones[0].Name != "1.168.192.in-addr.arpa" || len(zones[0].Records) != 3 {
+		t.Errorf("IPv4 reverse zone = %+v", zones[0])
+	}
+	if len(zones[1].Records) != 2 || zones[1].Records[1].Host != "master-0" ||
+		zones[1].Records[1].Name != "1.2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.1.0.0.0.0.0.d.f.ip6.arpa" {
+		t.Errorf("IPv6 reverse zone = %+v", zones[1])
+	}
+}
+
+func TestBuildAnsibleVarsTopology(t *testing.T) {
+	tests := []struct {
+		topology string
+		ingress  []string
+		apiIP    string
+	}{
+		{config.TopologySNO, []string{"master-0"}, "192.168.1.21"},
+		{config.TopologyCompact, []string{"master-0", "master-1", "master-2"}, "192.168.1.10"},
+		{config.TopologyStandard, []string{"worker-0", "worker-1"}, "192.168.1.10"},
+	}
+
+	for _, tt := range tests {
+		cfg, err := config.NewTopologyConfig("demo", tt.topology)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Bastion.IP = "192.168.1.10"
+		for i := range cfg.Cluster.ControlPlane {
+			cfg.Cluster.ControlPlane[i].IP = fmt.Sprintf("192.168.1.%d", 21+i)
+		}
+		for i := range cfg.Cluster.Worker {
+			cfg.Cluster.Worker[i].IP = fmt.Sprintf("192.168.1.%d", 31+i)
+		}
+
+		data, err := buildAnsibleVars(cfg, "/work", "demo")
+		if err != nil {
+			t.Fatalf("buildAnsibleVars(%s) error = %v", tt.topology, err)
+		}
+		var vars ansibleVars
+		if err := yaml.Unmarshal(data, &vars); err != nil {
+			t.Fatal(err)
+		}
+		var ingress []string
+		for _, node := range vars.Cluster.IngressNodes {
+			ingress = append(ingress, node.Name)
+		}
+		if strings.Join(ingress, ",") != strings.Join(tt.ingress, ",") {
+			t.Errorf("%s ingress nodes = %v, expected %v", tt.topology, ingress, tt.ingress)
+		}
+		if vars.DNS.API.IPv4 != tt.apiIP || vars.DNS.Apps.IPv4 != tt.apiIP {
+			t.Errorf("%s api/apps records point to %s/%s, expected %s", tt.topology, vars.DNS.API.IPv4, vars.DNS.Apps.IPv4, tt.apiIP)
+		}
+	}
+}
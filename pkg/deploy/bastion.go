@@ -2,6 +2,7 @@ package deploy
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"ocpack/pkg/config"
 )
@@ -18,6 +19,10 @@ const (
 type BastionDeployer struct {
 	config      *config.ClusterConfig
 	downloadDir string
+
+	// Engine 部署引擎: native、ansible 或 auto (默认，安装了 Ansible 时使用 Ansible)
+	Engine string
+	DeployOptions
 }
 
 // NewBastionDeployer 创建一个新的 Bastion 部署器
@@ -33,6 +38,41 @@ func NewBastionDeployer(cfg *config.ClusterConfig, downloadDir string) *BastionD
 func (d *BastionDeployer) Deploy(configFilePath string) error {
 	fmt.Printf("▶️  开始部署 Bastion 节点 (%s)...\n", d.config.Bastion.IP)
 
+	// 0. 验证配置
+	if err := config.ValidateBastionConfig(d.config); err != nil {
+		return fmt.Errorf("配置验证失败: %w", err)
+	}
+
+	engine, err := ResolveEngine(d.Engine)
+	if err != nil {
+		return err
+	}
+	if engine == EngineNative {
+		provisioner := NewNativeBastionProvisioner(d.config, filepath.Dir(configFilePath), d.downloadDir)
+		if d.Check {
+			fmt.Println("➡️  检查模式: 在本地渲染配置文件并与 Bastion 节点上的比较...")
+			if err := provisioner.Check(); err != nil {
+				return fmt.Errorf("检查 Bastion 节点失败: %w", err)
+			}
+			return nil
+		}
+		fmt.Println("🚀 正在使用原生引擎配置 Bastion 节点...")
+		if err := provisioner.Provision(); err != nil {
+			return fmt.Errorf("Bastion 节点部署失败: %w", err)
+		}
+		d.printSuccessMessage()
+		return nil
+	}
+
+	if d.Check {
+		// 与原生引擎相同，先在本地渲染配置文件并通过 SSH 显示 diff，再以 --check 执行 playbook
+		fmt.Println("➡️  检查模式: 在本地渲染配置文件并与 Bastion 节点上的比较...")
+		provisioner := NewNativeBastionProvisioner(d.config, filepath.Dir(configFilePath), d.downloadDir)
+		if err := provisioner.Check(); err != nil {
+			return fmt.Errorf("检查 Bastion 节点失败: %w", err)
+		}
+	}
+
 	// 1. 创建 Ansible 执行器
 	fmt.Println("➡️  正在初始化部署环境...")
 	executor, err := NewAnsibleExecutor(d.config, configFilePath)
@@ -40,12 +80,22 @@ func (d *BastionDeployer) Deploy(configFilePath string) error {
 		return fmt.Errorf("创建 Ansible 执行器失败: %w", err)
 	}
 	defer executor.Cleanup()
+	executor.LogFile = d.LogFile
+	executor.Check = d.Check
 
 	// 2. 执行 Bastion playbook
 	fmt.Println("🚀 正在执行 Bastion 部署 playbook (此过程可能需要几分钟)...")
 	if err := executor.RunBastionPlaybook(); err != nil {
 		return fmt.Errorf("Bastion 节点部署失败: %w", err)
 	}
+	if d.Check {
+		return nil
+	}
+	if d.config.SecurityMode() == config.SecurityModeHardened {
+		if err := VerifyBastionServices(d.config); err != nil {
+			return fmt.Errorf("Bastion 节点部署后验证失败: %w", err)
+		}
+	}
 
 	// 3. 打印成功信息
 	// 优化：调用独立的函数来打印最终的成功信息
@@ -59,7 +109,17 @@ func (d *BastionDeployer) Deploy(configFilePath string) error {
 func (d *BastionDeployer) printSuccessMessage() {
 	fmt.Println("\n✅ Bastion 节点部署完成！")
 	fmt.Printf("   DNS 服务器: %s:%d\n", d.config.Bastion.IP, dnsPort)
-	fmt.Printf("   HAProxy 统计页面: http://%s:%d/stats\n", d.config.Bastion.IP, haproxyPort)
+	switch d.config.LoadBalancerMode() {
+	case config.LoadBalancerHAProxyHA:
+		fmt.Printf("   API VIP: %s，Ingress VIP: %s\n", d.config.LoadBalancer.APIVIP, d.config.LoadBalancer.IngressVIP)
+		for _, node := range d.config.LoadBalancer.Nodes {
+			fmt.Printf("   HAProxy 统计页面 (%s): http://%s:%d/stats\n", node.Name, node.IP, haproxyPort)
+		}
+	case config.LoadBalancerExternal:
+		fmt.Printf("   API 和 Ingress 指向外部负载均衡: %s、%s\n", d.config.LoadBalancer.APIVIP, d.config.LoadBalancer.IngressVIP)
+	default:
+		fmt.Printf("   HAProxy 统计页面: http://%s:%d/stats\n", d.config.Bastion.IP, haproxyPort)
+	}
 }
 
 /*
@@ -0,0 +1,363 @@
+package deploy
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/pipeline"
+	"ocpack/pkg/utils"
+)
+
+// 可以删除的组件
+const (
+	DestroyBastion      = "bastion"
+	DestroyRegistry     = "registry"
+	DestroyPXE          = "pxe"
+	DestroyLoadBalancer = "load-balancer" // haproxy-ha 模式的 HAProxy 节点
+)
+
+// securityStateFile 部署前 firewalld 和 SELinux 的状态，第一次部署时写入，重复部署不覆盖
+const securityStateFile = "/etc/ocpack/security-state"
+
+// recordSecurityStateCommand 记录部署前的 firewalld 和 SELinux 状态。
+// Bastion 和 Registry 的 playbook 中有相同的任务
+var recordSecurityStateCommand = "if [ ! -e " + securityStateFile + " ]; then mkdir -p " + filepath.Dir(securityStateFile) + " && { " +
+	"echo firewalld_enabled=$(systemctl is-enabled firewalld 2>/dev/null); " +
+	"echo firewalld_active=$(systemctl is-active firewalld 2>/dev/null); " +
+	"echo selinux_config=$(sed -n 's/^SELINUX=//p' /etc/selinux/config 2>/dev/null); " +
+	"echo selinux_mode=$(getenforce 2>/dev/null); " +
+	"} > " + securityStateFile + "; fi"
+
+// DestroyTargets 返回可以删除的组件
+func DestroyTargets() []string {
+	return []string{DestroyBastion, DestroyRegistry, DestroyPXE, DestroyLoadBalancer}
+}
+
+// DestroyOptions 删除组件的选项
+type DestroyOptions struct {
+	// CleanStorage 同时删除 Registry 的存储目录 (registry.storage_path)
+	CleanStorage bool
+}
+
+// 删除每个组件时停止的服务、卸载的软件包和删除的文件
+var (
+	bastionServices = []string{"haproxy", "named"}
+	// 只卸载提供服务的软件包，bind-utils 和 firewalld 保留
+	bastionRemovePackages = []string{"bind", "haproxy"}
+
+	pxeServices       = []string{"dhcpd", "tftp.socket", "tftp.service", "httpd"}
+	pxeRemovePackages = []string{"dhcp-server", "tftp-server", "syslinux-tftpboot", "ipxe-bootimgs", "httpd"}
+	pxeFiles          = []string{
+		"/etc/systemd/system/tftp.service",
+		"/etc/systemd/system/tftp.socket",
+		"/var/lib/tftpboot",
+		"/var/www/html/pxe",
+		"/usr/local/bin/upload-pxe-files.sh",
+	}
+)
+
+// Destroy 删除节点上部署的组件: 停止服务、卸载软件包、删除 ocpack 生成的文件，
+// 并把 firewalld 和 SELinux 恢复到部署前的状态
+func Destroy(cfg *config.ClusterConfig, configFilePath, target string, opts DestroyOptions) error {
+	clusterDir := filepath.Dir(configFilePath)
+
+	var hosts []utils.SSHOptions
+	var stages []string
+	switch target {
+	case DestroyBastion:
+		hosts, stages = []utils.SSHOptions{cfg.BastionSSH(clusterDir)}, []string{pipeline.StageBastion}
+	case DestroyPXE:
+		hosts, stages = []utils.SSHOptions{cfg.BastionSSH(clusterDir)}, []string{pipeline.StagePXE}
+	case DestroyRegistry:
+		// Registry 中的镜像随 Registry 一起删除，load 阶段也需要重新执行
+		hosts, stages = []utils.SSHOptions{cfg.RegistrySSH(clusterDir)}, []string{pipeline.StageRegistry, pipeline.StageLoad}
+	case DestroyLoadBalancer:
+		if cfg.LoadBalancerMode() != config.LoadBalancerHAProxyHA || len(cfg.LoadBalancer.Nodes) == 0 {
+			return fmt.Errorf("load_balancer.mode 不是 %s，没有 HAProxy 节点", config.LoadBalancerHAProxyHA)
+		}
+		for _, node := range cfg.LoadBalancer.Nodes {
+			hosts = append(hosts, cfg.LoadBalancerSSH(clusterDir, node))
+		}
+		stages = []string{pipeline.StageBastion}
+	default:
+		return fmt.Errorf("不支持删除 %s (可选: %s)", target, strings.Join(DestroyTargets(), ", "))
+	}
+
+	for _, sshOpts := range hosts {
+		if err := destroyHost(cfg, clusterDir, target, sshOpts, opts); err != nil {
+			return fmt.Errorf("删除 %s 失败: %w", target, err)
+		}
+	}
+
+	if err := resetStages(clusterDir, stages...); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	fmt.Printf("✅ %s 已删除\n", target)
+	return nil
+}
+
+// destroyHost 删除一个节点上的组件
+func destroyHost(cfg *config.ClusterConfig, clusterDir, target string, sshOpts utils.SSHOptions, opts DestroyOptions) error {
+	client, err := utils.NewSSHClient(sshOpts)
+	if err != nil {
+		return fmt.Errorf("连接 %s 失败: %w", sshOpts.Host, err)
+	}
+	defer client.Close()
+	host := &nativeHost{shell: client, sudo: sshOpts.User != "root"}
+
+	fmt.Printf("▶️  开始删除 %s (%s)...\n", target, sshOpts.Host)
+	switch target {
+	case DestroyBastion:
+		return destroyBastion(host, cfg, clusterDir)
+	case DestroyPXE:
+		return destroyPXE(host)
+	case DestroyRegistry:
+		return destroyRegistry(host, cfg, clusterDir, opts)
+	case DestroyLoadBalancer:
+		return destroyLoadBalancer(host)
+	}
+	return nil
+}
+
+func destroyBastion(host *nativeHost, cfg *config.ClusterConfig, clusterDir string) error {
+	vars, err := bastionTemplateVars(cfg, clusterDir)
+	if err != nil {
+		return err
+	}
+	files, err := renderBastionFiles(vars)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("➡️  停止 named 和 haproxy...")
+	stopServices(host, bastionServices...)
+
+	fmt.Println("➡️  卸载软件包并删除配置文件...")
+	if err := removePackages(host, bastionRemovePackages...); err != nil {
+		return err
+	}
+	// 卸载后 named.conf 和 haproxy.cfg 可能保留为 .rpmsave，区域文件由 ocpack 生成。
+	// 部署后切换过负载均衡方式时 haproxy.cfg 不在 files 中，同样删除
+	paths := []string{"/etc/named"}
+	for _, f := range files {
+		paths = append(paths, f.Path, f.Path+".rpmsave")
+	}
+	if !slices.Contains(paths, haproxyConfigPath) {
+		paths = append(paths, haproxyConfigPath, haproxyConfigPath+".rpmsave")
+	}
+	if err := removePaths(host, paths...); err != nil {
+		return err
+	}
+
+	fmt.Println("➡️  恢复 firewalld 和 SELinux...")
+	closeFirewallPorts(host, slices.Concat(dnsFirewallPorts, haproxyFirewallPorts))
+	return restoreSecurity(host)
+}
+
+func destroyPXE(host *nativeHost) error {
+	fmt.Println("➡️  停止 dhcpd、tftp 和 httpd...")
+	stopServices(host, pxeServices...)
+
+	fmt.Println("➡️  卸载软件包并删除 PXE 文件...")
+	if err := removePackages(host, pxeRemovePackages...); err != nil {
+		return err
+	}
+	if err := removePaths(host, pxeFiles...); err != nil {
+		return err
+	}
+	closeFirewallPorts(host, pxeFirewallPorts)
+	_, err := host.run("systemctl daemon-reload")
+	// PXE 与 Bastion 在同一个节点上，firewalld 和 SELinux 在删除 Bastion 时恢复
+	return err
+}
+
+func destroyRegistry(host *nativeHost, cfg *config.ClusterConfig, clusterDir string, opts DestroyOptions) error {
+	archive := cfg.MirrorRegistryArchive()
+	localPath := filepath.Join(clusterDir, cfg.Download.LocalPath, archive)
+	if !utils.FileExists(localPath) {
+		return fmt.Errorf("本地没有 %s，请先运行 'ocpack download'", localPath)
+	}
+
+	// 每次都把 downloads 中的安装包上传到 mktemp 新建的目录，不以 root 执行 /tmp 中来源不明的 mirror-registry
+	tmpDir, err := host.makeTemp(true)
+	if err != nil {
+		return err
+	}
+	dir := shellQuote(tmpDir)
+	defer host.run("rm -rf -- " + dir)
+
+	fmt.Printf("➡️  上传 %s...\n", archive)
+	remoteArchive := tmpDir + "/" + archive
+	if err := host.shell.UploadFile(localPath, remoteArchive); err != nil {
+		return err
+	}
+	if _, err := host.run(fmt.Sprintf("tar -xzf %s -C %s", shellQuote(remoteArchive), dir)); err != nil {
+		return fmt.Errorf("解压 %s 失败: %w", archive, err)
+	}
+
+	fmt.Println("➡️  执行 mirror-registry uninstall...")
+	uninstall := fmt.Sprintf("cd %s && ./mirror-registry uninstall --autoApprove --quayRoot %s", dir, shellQuote(cfg.Registry.StoragePath))
+	if !opts.CleanStorage {
+		uninstall = keepStorageCommand(cfg.Registry.StoragePath, uninstall)
+	}
+	if output, err := host.run(uninstall); err != nil {
+		return fmt.Errorf("mirror-registry uninstall 失败: %w\n%s", err, output)
+	}
+
+	if opts.CleanStorage {
+		fmt.Printf("➡️  删除存储目录 %s...\n", cfg.Registry.StoragePath)
+		if err := removePaths(host, cfg.Registry.StoragePath); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("ℹ️  保留存储目录 %s，使用 --clean-storage 删除\n", cfg.Registry.StoragePath)
+	}
+
+	fmt.Println("➡️  恢复 firewalld 和 SELinux...")
+	closeFirewallPorts(host, registryFirewallPorts)
+	return restoreSecurity(host)
+}
+
+// keepStorageCommand 包装 uninstall 命令。mirror-registry uninstall --autoApprove 会删除 quayRoot，
+// 卸载前把存储目录移到 <storage>.ocpack-keep，卸载后 (包括卸载失败时) 再移回原处
+func keepStorageCommand(storage, uninstall string) string {
+	kept := shellQuote(strings.TrimSuffix(storage, "/") + ".ocpack-keep")
+	return fmt.Sprintf("if [ -e %[1]s ]; then mv -T %[1]s %[2]s || exit 1; fi; (%[3]s); rc=$?; "+
+		"if [ -e %[2]s ]; then mv -T %[2]s %[1]s || exit 1; fi; exit $rc", shellQuote(storage), kept, uninstall)
+}
+
+// destroyLoadBalancer 删除 HAProxy 节点上的 HAProxy 和 keepalived
+func destroyLoadBalancer(host *nativeHost) error {
+	fmt.Println("➡️  停止 haproxy 和 keepalived...")
+	stopServices(host, loadBalancerServices...)
+
+	fmt.Println("➡️  卸载软件包并删除配置文件...")
+	if err := removePackages(host, loadBalancerServices...); err != nil {
+		return err
+	}
+	var paths []string
+	for _, p := range []string{haproxyConfigPath, keepalivedConfigPath} {
+		paths = append(paths, p, p+".rpmsave")
+	}
+	if err := removePaths(host, paths...); err != nil {
+		return err
+	}
+
+	fmt.Println("➡️  恢复 firewalld 和 SELinux...")
+	closeFirewallPorts(host, haproxyFirewallPorts)
+	host.run(closeVRRPCommand)
+	return restoreSecurity(host)
+}
+
+// stopServices 停止并禁用服务，服务不存在时忽略
+func stopServices(host *nativeHost, services ...string) {
+	for _, service := range services {
+		host.run(fmt.Sprintf("systemctl disable --now %s 2>/dev/null || true", service))
+	}
+}
+
+// removePackages 卸载已安装的软件包
+func removePackages(host *nativeHost, packages ...string) error {
+	var installed []string
+	for _, pkg := range packages {
+		if _, err := host.run("rpm -q --quiet " + shellQuote(pkg)); err == nil {
+			installed = append(installed, pkg)
+		}
+	}
+	if len(installed) == 0 {
+		return nil
+	}
+	list := strings.Join(installed, " ")
+	if _, err := host.run(fmt.Sprintf("if command -v dnf >/dev/null 2>&1; then dnf -y remove %s; else yum -y remove %s; fi", list, list)); err != nil {
+		return fmt.Errorf("卸载软件包 %s 失败: %w", list, err)
+	}
+	fmt.Printf("✅ 已卸载 %s\n", strings.Join(installed, "、"))
+	return nil
+}
+
+func removePaths(host *nativeHost, paths ...string) error {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		if p == "" || p == "/" {
+			return fmt.Errorf("拒绝删除路径 %q", p)
+		}
+		quoted[i] = shellQuote(p)
+	}
+	if _, err := host.run("rm -rf -- " + strings.Join(quoted, " ")); err != nil {
+		return fmt.Errorf("删除文件失败: %w", err)
+	}
+	return nil
+}
+
+// restoreSecurity 按 securityStateFile 恢复 firewalld 和 SELinux，恢复后删除记录
+func restoreSecurity(host *nativeHost) error {
+	content, exists, err := host.readFile(securityStateFile)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		fmt.Printf("⚠️  没有找到部署前的状态记录 (%s)，firewalld 和 SELinux 保持当前状态\n", securityStateFile)
+		return nil
+	}
+	state := parseSecurityState(content)
+
+	commands := []string{}
+	switch state["selinux_config"] {
+	case "enforcing", "permissive", "disabled":
+		commands = append(commands, fmt.Sprintf("sed -i 's/^SELINUX=.*/SELINUX=%s/' /etc/selinux/config", state["selinux_config"]))
+	}
+	if state["selinux_mode"] == "Enforcing" {
+		// 部署时只切换到 permissive，未重启时可以直接恢复
+		commands = append(commands, `if [ "$(getenforce)" = Permissive ]; then setenforce 1; fi`)
+	}
+	if state["firewalld_enabled"] == "enabled" {
+		commands = append(commands, "systemctl enable firewalld")
+	}
+	if state["firewalld_active"] == "active" {
+		commands = append(commands, "systemctl start firewalld")
+	}
+	for _, command := range commands {
+		if _, err := host.run(command); err != nil {
+			return fmt.Errorf("恢复 firewalld 和 SELinux 失败: %w", err)
+		}
+	}
+	if state["selinux_mode"] == "Enforcing" {
+		if mode, _ := host.run("getenforce"); strings.TrimSpace(mode) != "Enforcing" {
+			fmt.Println("⚠️  SELinux 部署后被禁用，重启节点后才会恢复为 Enforcing")
+		}
+	}
+	_, err = host.run("rm -f " + securityStateFile)
+	return err
+}
+
+func parseSecurityState(content string) map[string]string {
+	state := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			state[key] = strings.TrimSpace(value)
+		}
+	}
+	return state
+}
+
+// resetStages 删除组件后清除 ocpack all 中对应阶段的记录，下次会重新部署
+func resetStages(clusterDir string, stages ...string) error {
+	state, err := pipeline.LoadState(clusterDir)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, stage := range stages {
+		if _, ok := state.Stages[stage]; ok {
+			delete(state.Stages, stage)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return state.Save()
+}
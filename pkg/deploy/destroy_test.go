@@ -0,0 +1,135 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ocpack/pkg/utils"
+)
+
+func TestRestoreSecurity(t *testing.T) {
+	shell := &fakeShell{files: map[string]string{
+		securityStateFile: "firewalld_enabled=enabled\nfirewalld_active=active\nselinux_config=enforcing\nselinux_mode=Enforcing\n",
+	}}
+	host := &nativeHost{shell: shell}
+	if err := restoreSecurity(host); err != nil {
+		t.Fatalf("restoreSecurity() error = %v", err)
+	}
+	commands := strings.Join(shell.commands, "\n")
+	for _, want := range []string{"SELINUX=enforcing/", "setenforce 1", "systemctl enable firewalld", "systemctl start firewalld", "rm -f " + securityStateFile} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("missing %q in commands:\n%s", want, commands)
+		}
+	}
+
+	// 部署前 firewalld 未启用、SELinux 已关闭时不做修改
+	shell = &fakeShell{files: map[string]string{
+		securityStateFile: "firewalld_enabled=disabled\nfirewalld_active=inactive\nselinux_config=disabled\nselinux_mode=Disabled\n",
+	}}
+	if err := restoreSecurity(&nativeHost{shell: shell}); err != nil {
+		t.Fatal(err)
+	}
+	commands = strings.Join(shell.commands, "\n")
+	if strings.Contains(commands, "firewalld") || strings.Contains(commands, "setenforce") {
+		t.Errorf("unexpected commands:\n%s", commands)
+	}
+
+	// 没有记录时保持当前状态
+	shell = &fakeShell{files: map[string]string{}}
+	if err := restoreSecurity(&nativeHost{shell: shell}); err != nil || len(shell.commands) != 1 {
+		t.Errorf("restoreSecurity() without state = %v, commands %v", err, shell.commands)
+	}
+}
+
+func TestDestroyBastion(t *testing.T) {
+	shell := &fakeShell{files: map[string]string{}}
+	host := &nativeHost{shell: shell, sudo: true}
+	if err := destroyBastion(host, testBastionConfig(), t.TempDir()); err != nil {
+		t.Fatalf("destroyBastion() error = %v", err)
+	}
+	commands := strings.Join(shell.commands, "\n")
+	for _, want := range []string{"systemctl disable --now haproxy", "dnf -y remove bind haproxy", `'\''/var/named/demo.example.com.zone'\''`, `'\''/etc/haproxy/haproxy.cfg.rpmsave'\''`} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("missing %q in commands:\n%s", want, commands)
+		}
+	}
+}
+
+func TestDestroyRegistryKeepsStorage(t *testing.T) {
+	cfg := testBastionConfig()
+	cfg.Registry.StoragePath = "/opt/quay"
+	clusterDir := t.TempDir()
+	archive := filepath.Join(clusterDir, cfg.Download.LocalPath, cfg.MirrorRegistryArchive())
+	if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archive, []byte("archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, clean := range []bool{false, true} {
+		shell := &fakeShell{files: map[string]string{}}
+		if err := destroyRegistry(&nativeHost{shell: shell}, cfg, clusterDir, DestroyOptions{CleanStorage: clean}); err != nil {
+			t.Fatalf("destroyRegistry() error = %v", err)
+		}
+		commands := strings.ReplaceAll(strings.Join(shell.commands, "\n"), `'\''`, "'")
+		// 安装包上传到 mktemp -d 新建的目录，从该目录执行 mirror-registry，结束后删除
+		if !strings.HasPrefix(shell.commands[0], "mktemp -d ") {
+			t.Errorf("first command = %s", shell.commands[0])
+		}
+		tmpDir := fmt.Sprintf("%s%010d", tempFilePrefix, 1)
+		if !strings.Contains(commands, "tar -xzf '"+tmpDir+"/"+cfg.MirrorRegistryArchive()+"' -C '"+tmpDir+"'") {
+			t.Errorf("archive not extracted into %s:\n%s", tmpDir, commands)
+		}
+		uninstall := "cd '" + tmpDir + "' && ./mirror-registry uninstall --autoApprove --quayRoot '/opt/quay'"
+		if !strings.Contains(commands, uninstall) {
+			t.Errorf("mirror-registry uninstall not run from %s:\n%s", tmpDir, commands)
+		}
+		if strings.Contains(commands, "/tmp/mirror-registry") {
+			t.Errorf("mirror-registry run from /tmp:\n%s", commands)
+		}
+		// --autoApprove 会删除 quayRoot，保留存储时卸载前移开、卸载后移回
+		kept := strings.Contains(commands, "mv -T '/opt/quay' '/opt/quay.ocpack-keep'") &&
+			strings.Contains(commands, "mv -T '/opt/quay.ocpack-keep' '/opt/quay'")
+		if kept == clean {
+			t.Errorf("CleanStorage=%v: storage moved aside = %v", clean, kept)
+		}
+		if removed := strings.Contains(commands, "rm -rf -- '/opt/quay'"); removed != clean {
+			t.Errorf("CleanStorage=%v: storage removed = %v", clean, removed)
+		}
+		assertNoTempFiles(t, shell)
+	}
+
+	shell := &fakeShell{files: map[string]string{}}
+	if err := destroyRegistry(&nativeHost{shell: shell}, cfg, t.TempDir(), DestroyOptions{}); err == nil || !strings.Contains(err.Error(), cfg.MirrorRegistryArchive()) || len(shell.commands) != 0 {
+		t.Errorf("destroyRegistry() without local archive = %v, commands %v", err, shell.commands)
+	}
+}
+
+func TestKeepStorageCommandRestoresOnFailure(t *testing.T) {
+	root := t.TempDir()
+	storage := filepath.Join(root, "quay")
+	if err := os.MkdirAll(filepath.Join(storage, "quay-storage"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, uninstall := range []string{"rm -rf " + storage, "rm -rf " + storage + "; false"} {
+		output, err := exec.Command("sh", "-c", keepStorageCommand(storage, uninstall)).CombinedOutput()
+		if failed := strings.HasSuffix(uninstall, "false"); (err != nil) != failed {
+			t.Errorf("%s: error = %v %s", uninstall, err, output)
+		}
+		if !utils.FileExists(filepath.Join(storage, "quay-storage")) {
+			t.Fatalf("%s: storage not restored", uninstall)
+		}
+	}
+}
+
+func TestRemovePathsRefusesRoot(t *testing.T) {
+	shell := &fakeShell{files: map[string]string{}}
+	if err := removePaths(&nativeHost{shell: shell}, "/var/lib/tftpboot", "/"); err == nil || len(shell.commands) != 0 {
+		t.Errorf("removePaths(/) = %v, commands %v", err, shell.commands)
+	}
+}
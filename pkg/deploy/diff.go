@@ -0,0 +1,142 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext unified diff 中每个修改前后保留的上下文行数
+const diffContext = 3
+
+// diffOp 逐行比较的一步: ' ' 相同，'-' 只在旧文件中，'+' 只在新文件中
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff 返回 a 到 b 的 unified diff，内容相同时返回空字符串
+func unifiedDiff(oldName, newName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// 找到下一处修改，向前保留上下文
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		hunkStart := max(first-diffContext, start)
+
+		// 两处修改之间的相同行不超过 2*diffContext 时合并为一个 hunk
+		end, same := first, 0
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind == ' ' {
+				same++
+				if same > 2*diffContext {
+					break
+				}
+				continue
+			}
+			same = 0
+			end = i + 1
+		}
+		hunkEnd := min(end+diffContext, len(ops))
+
+		oldLine, newLine := 1, 1
+		for _, op := range ops[:hunkStart] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for _, op := range ops[hunkStart:hunkEnd] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		start = hunkEnd
+	}
+	return out.String()
+}
+
+// hunkRange 与 diff -u 相同，空范围的起始行号为前一行
+func hunkRange(line, count int) string {
+	if count == 0 {
+		line--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if !strings.HasSuffix(s, "\n") {
+		lines[len(lines)-1] += "\n\\ No newline at end of file"
+	}
+	return lines
+}
+
+// diffLines 按最长公共子序列逐行比较。配置文件只有几百行，O(n*m) 足够
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
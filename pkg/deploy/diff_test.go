@@ -0,0 +1,25 @@
+package deploy
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"unchanged", old, old, ""},
+		{
+			"two hunks",
+			old,
+			"a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n",
+			"--- old\n+++ new\n@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -11,3 +11,4 @@\n k\n l\n m\n+n\n",
+		},
+		{"new file", "", "x\ny\n", "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+x\n+y\n"},
+		{"no newline", "x\n", "x", "--- old\n+++ new\n@@ -1 +1 @@\n-x\n+x\n\\ No newline at end of file\n"},
+	}
+	for _, tt := range tests {
+		if got := unifiedDiff("old", "new", tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: unifiedDiff() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package deploy
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocpack/pkg/config"
+)
+
+// hardened 模式下在 firewalld 中开放的端口
+var (
+	dnsFirewallPorts = []string{"53/tcp", "53/udp"}
+	// API、Machine Config Server、Ingress 和 HAProxy 统计页面
+	haproxyFirewallPorts = []string{"6443/tcp", "22623/tcp", "80/tcp", "443/tcp", "9000/tcp"}
+	// DHCP、TFTP 和提供 PXE 文件的 httpd
+	pxeFirewallPorts = []string{"67/udp", "69/udp", "8080/tcp"}
+	// Quay
+	registryFirewallPorts = []string{"8443/tcp"}
+)
+
+// SELinux 上下文需要恢复为默认值的目录
+var (
+	bastionSELinuxPaths = []string{"/etc/named.conf", "/etc/named", "/var/named", "/etc/haproxy"}
+	pxeSELinuxPaths     = []string{"/var/lib/tftpboot", "/var/www/html/pxe"}
+	// haproxy-ha 模式的 HAProxy 节点
+	loadBalancerSELinuxPaths = []string{"/etc/haproxy", "/etc/keepalived"}
+)
+
+// bastionFirewallPorts 返回 Bastion 需要开放的端口，只有 bastion-haproxy 模式在 Bastion 上运行 HAProxy
+func bastionFirewallPorts(cfg *config.ClusterConfig) []string {
+	if cfg.LoadBalancerMode() != config.LoadBalancerBastionHAProxy {
+		return dnsFirewallPorts
+	}
+	return slices.Concat(dnsFirewallPorts, haproxyFirewallPorts)
+}
+
+// verifyTimeout 验证每个服务的超时时间
+const verifyTimeout = 5 * time.Second
+
+// hardenSecurity 保持 firewalld 和 SELinux 启用: 开放 ports 并启用 SELinux 布尔值。
+// SELinux 已被禁用时只配置 firewalld
+func hardenSecurity(host *nativeHost, ports []string, booleans ...string) error {
+	commands := []string{recordSecurityStateCommand, "systemctl enable --now firewalld"}
+	for _, port := range ports {
+		commands = append(commands, "firewall-cmd --permanent --add-port="+port)
+	}
+	commands = append(commands, "firewall-cmd --reload")
+	for _, boolean := range booleans {
+		commands = append(commands, fmt.Sprintf(`if [ "$(getenforce)" != Disabled ]; then setsebool -P %s 1; fi`, boolean))
+	}
+	for _, command := range commands {
+		if output, err := host.run(command); err != nil {
+			return fmt.Errorf("配置 firewalld 和 SELinux 失败: %w %s", err, output)
+		}
+	}
+	if mode, _ := host.run("getenforce"); strings.TrimSpace(mode) == "Disabled" {
+		fmt.Println("⚠️  节点上的 SELinux 已被禁用，启用需要重新标记文件系统并重启，本次只配置了 firewalld")
+	}
+	return nil
+}
+
+// restoreContexts 把 paths 的 SELinux 上下文恢复为策略中的默认值，不存在的路径跳过。
+// 例如通过 /tmp 安装的 named.conf 需要 named_conf_t 才能被 named 读取
+func restoreContexts(host *nativeHost, paths ...string) error {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	command := `if [ "$(getenforce)" != Disabled ]; then for p in %s; do if [ -e "$p" ]; then restorecon -R "$p"; fi; done; fi`
+	if _, err := host.run(fmt.Sprintf(command, strings.Join(quoted, " "))); err != nil {
+		return fmt.Errorf("恢复 SELinux 上下文失败: %w", err)
+	}
+	return nil
+}
+
+// closeFirewallPorts 删除组件时关闭 hardened 模式开放的端口，firewalld 未运行时跳过
+func closeFirewallPorts(host *nativeHost, ports []string) {
+	var commands []string
+	for _, port := range ports {
+		commands = append(commands, "firewall-cmd --permanent --remove-port="+port+" >/dev/null 2>&1")
+	}
+	host.run(fmt.Sprintf("if firewall-cmd --state >/dev/null 2>&1; then %s; firewall-cmd --reload; fi", strings.Join(commands, "; ")))
+}
+
+// VerifyBastionServices 从 ocpack 所在的机器访问 Bastion 上的 DNS 和负载均衡，
+// 确认 hardened 模式下 firewalld 和 SELinux 没有阻止它们。
+// haproxy-ha 模式通过 VIP 访问 HAProxy，external 模式的负载均衡不由 ocpack 部署，不做验证
+func VerifyBastionServices(cfg *config.ClusterConfig) error {
+	fmt.Println("➡️  验证 Bastion 上的服务...")
+	name := fmt.Sprintf("api.%s.%s", cfg.ClusterInfo.ClusterID, cfg.ClusterInfo.Domain)
+	if err := checkDNS(cfg.Bastion.IP, name); err != nil {
+		return err
+	}
+
+	apiHost, ingressHost, statsHosts := cfg.Bastion.IP, cfg.Bastion.IP, []string{cfg.Bastion.IP}
+	switch cfg.LoadBalancerMode() {
+	case config.LoadBalancerExternal:
+		fmt.Println("✅ DNS (53) 可以访问")
+		fmt.Println("ℹ️  external 模式的负载均衡不由 ocpack 部署，请确认它已转发 6443、22623、80 和 443")
+		return nil
+	case config.LoadBalancerHAProxyHA:
+		apiHost, ingressHost, statsHosts = cfg.LoadBalancer.APIVIP, cfg.LoadBalancer.IngressVIP, nil
+		for _, node := range cfg.LoadBalancer.Nodes {
+			statsHosts = append(statsHosts, node.IP)
+		}
+	}
+
+	for _, check := range []struct {
+		host string
+		port int
+	}{{apiHost, apiServerPort}, {apiHost, 22623}, {ingressHost, 80}, {ingressHost, 443}} {
+		if err := checkTCP(check.host, check.port); err != nil {
+			return err
+		}
+	}
+	for _, host := range statsHosts {
+		if err := checkHTTP(fmt.Sprintf("http://%s/stats", net.JoinHostPort(host, strconv.Itoa(haproxyPort)))); err != nil {
+			return err
+		}
+	}
+	fmt.Println("✅ DNS (53)、HAProxy (6443、22623、80、443) 和统计页面 (9000) 可以访问")
+	return nil
+}
+
+// VerifyPXEServices 确认 Bastion 上的 TFTP 和 PXE 文件服务可以访问。DHCP 需要广播，不在此验证
+func VerifyPXEServices(cfg *config.ClusterConfig) error {
+	fmt.Println("➡️  验证 PXE 服务...")
+	host := cfg.Bastion.IP
+	if err := checkTFTP(host, "pxelinux.0"); err != nil {
+		return err
+	}
+	if err := checkTCP(host, 8080); err != nil {
+		return err
+	}
+	fmt.Println("✅ TFTP (69) 和 HTTP (8080) 可以访问")
+	return nil
+}
+
+func checkTCP(host string, port int) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", addr, verifyTimeout)
+	if err != nil {
+		return fmt.Errorf("无法连接 %s，请检查 firewalld 和服务状态: %w", addr, err)
+	}
+	return conn.Close()
+}
+
+func checkHTTP(url string) error {
+	client := &http.Client{Timeout: verifyTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("访问 %s 失败: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("访问 %s 返回 %s", url, resp.Status)
+	}
+	return nil
+}
+
+// checkDNS 直接向 server 的 53 端口查询 name
+func checkDNS(server, name string) error {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: verifyTimeout}
+			return d.DialContext(ctx, network, net.JoinHostPort(server, "53"))
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+	defer cancel()
+	addrs, err := resolver.LookupHost(ctx, name)
+	if err != nil {
+		return fmt.Errorf("通过 %s 解析 %s 失败，请检查 firewalld 和 named: %w", server, name, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("通过 %s 解析 %s 没有返回地址", server, name)
+	}
+	return nil
+}
+
+// checkTFTP 发送 TFTP 读请求，收到数据或错误报文都说明服务可以访问
+func checkTFTP(host, filename string) error {
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, "69"))
+	if err != nil {
+		return fmt.Errorf("解析 TFTP 地址失败: %w", err)
+	}
+	// tftpd 从新的端口回复，不能使用只接收 69 端口报文的已连接 socket
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return fmt.Errorf("创建 UDP socket 失败: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP(tftpReadRequest(filename), addr); err != nil {
+		return fmt.Errorf("发送 TFTP 请求失败: %w", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(verifyTimeout))
+	buf := make([]byte, 516)
+	n, from, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		return fmt.Errorf("TFTP %s 没有回复，请检查 firewalld 和 tftp.socket: %w", addr, err)
+	}
+	if !from.IP.Equal(addr.IP) {
+		return fmt.Errorf("收到来自 %s 的 TFTP 回复，预期为 %s", from.IP, addr.IP)
+	}
+	if opcode := tftpOpcode(buf[:n]); opcode != tftpData && opcode != tftpError {
+		return fmt.Errorf("TFTP %s 返回了无效的报文", addr)
+	}
+	return nil
+}
+
+// TFTP 报文类型 (RFC 1350)
+const (
+	tftpRRQ   = 1
+	tftpData  = 3
+	tftpError = 5
+)
+
+func tftpReadRequest(filename string) []byte {
+	packet := binary.BigEndian.AppendUint16(nil, tftpRRQ)
+	packet = append(packet, filename...)
+	packet = append(packet, 0)
+	packet = append(packet, "octet"...)
+	return append(packet, 0)
+}
+
+func tftpOpcode(packet []byte) uint16 {
+	if len(packet) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(packet)
+}
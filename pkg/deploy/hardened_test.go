@@ -0,0 +1,73 @@
+package deploy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHardenSecurity(t *testing.T) {
+	shell := &fakeShell{files: map[string]string{}}
+	host := &nativeHost{shell: shell, sudo: true}
+	if err := hardenSecurity(host, pxeFirewallPorts, "haproxy_connect_any"); err != nil {
+		t.Fatalf("hardenSecurity() error = %v", err)
+	}
+	commands := strings.Join(shell.commands, "\n")
+	for _, want := range []string{
+		securityStateFile,
+		"systemctl enable --now firewalld",
+		"firewall-cmd --permanent --add-port=67/udp",
+		"firewall-cmd --permanent --add-port=69/udp",
+		"firewall-cmd --permanent --add-port=8080/tcp",
+		"firewall-cmd --reload",
+		"setsebool -P haproxy_connect_any 1",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("missing %q in commands:\n%s", want, commands)
+		}
+	}
+	// hardened 模式不能关闭 firewalld 和 SELinux
+	for _, unwanted := range []string{"systemctl disable", "setenforce 0", "SELINUX=disabled"} {
+		if strings.Contains(commands, unwanted) {
+			t.Errorf("unexpected %q in commands:\n%s", unwanted, commands)
+		}
+	}
+}
+
+func TestVerifyHelpers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stats" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	if err := checkHTTP(server.URL + "/stats"); err != nil {
+		t.Errorf("checkHTTP(/stats) error = %v", err)
+	}
+	if err := checkHTTP(server.URL + "/missing"); err == nil {
+		t.Error("checkHTTP() should fail on 404")
+	}
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	if err := checkTCP(host, p); err != nil {
+		t.Errorf("checkTCP() error = %v", err)
+	}
+	server.Close()
+	if err := checkTCP(host, p); err == nil {
+		t.Error("checkTCP() should fail after the listener is closed")
+	}
+}
+
+func TestTFTPReadRequest(t *testing.T) {
+	packet := tftpReadRequest("pxelinux.0")
+	if want := "\x00\x01pxelinux.0\x00octet\x00"; string(packet) != want {
+		t.Errorf("tftpReadRequest() = %q, want %q", packet, want)
+	}
+	if tftpOpcode(packet) != tftpRRQ || tftpOpcode([]byte{0}) != 0 {
+		t.Error("tftpOpcode() returned a wrong opcode")
+	}
+}
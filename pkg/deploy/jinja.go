@@ -0,0 +1,924 @@
+package deploy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 原生部署使用的 Jinja2 子集，渲染 ansible/*/templates 中的模板，保证与 Ansible 生成的文件一致。
+// 支持 {{ 表达式 }}、{% if/elif/else/endif %}、{% for x in 列表 %}/{% endfor %}、{% set x = 表达式 %}、
+// {# 注释 #} 和 {%- -%} 空白控制。与 Ansible template 模块相同，块标签后的第一个换行会被删除 (trim_blocks)。
+// 表达式支持变量路径 (a.b、a[0])、字符串、整数、true/false、not、and、or、==、!= 和 in，
+// 字符串的 split 方法，以及 default、int、join 和 regex_replace 过滤器。
+
+// jinjaNode 模板语法树节点
+type jinjaNode interface {
+	render(b *strings.Builder, ctx *jinjaContext) error
+}
+
+type jinjaText string
+
+type jinjaOutput struct {
+	expr jinjaExpr
+	line int
+}
+
+type jinjaBranch struct {
+	cond jinjaExpr // else 分支为 nil
+	body []jinjaNode
+}
+
+type jinjaIf struct {
+	branches []jinjaBranch
+}
+
+type jinjaFor struct {
+	name     string
+	iterable jinjaExpr
+	body     []jinjaNode
+	line     int
+}
+
+type jinjaSet struct {
+	name string
+	expr jinjaExpr
+	line int
+}
+
+// jinjaContext 渲染时的变量作用域，for 循环变量覆盖外层变量
+type jinjaContext struct {
+	vars   map[string]interface{}
+	parent *jinjaContext
+}
+
+func (c *jinjaContext) lookup(name string) (interface{}, bool) {
+	for ctx := c; ctx != nil; ctx = ctx.parent {
+		if v, ok := ctx.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// jinjaUndefined 未定义的变量，在条件中为假，输出时报错
+type jinjaUndefined struct{ name string }
+
+// renderJinja 使用 vars 渲染模板
+func renderJinja(name, src string, vars map[string]interface{}) (string, error) {
+	nodes, err := parseJinja(src)
+	if err != nil {
+		return "", fmt.Errorf("解析模板 %s 失败: %w", name, err)
+	}
+	var b strings.Builder
+	// set 定义的变量写入模板自己的作用域，不修改 vars
+	ctx := &jinjaContext{vars: map[string]interface{}{}, parent: &jinjaContext{vars: vars}}
+	if err := renderNodes(nodes, &b, ctx); err != nil {
+		return "", fmt.Errorf("渲染模板 %s 失败: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+func renderNodes(nodes []jinjaNode, b *strings.Builder, ctx *jinjaContext) error {
+	for _, node := range nodes {
+		if err := node.render(b, ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t jinjaText) render(b *strings.Builder, _ *jinjaContext) error {
+	b.WriteString(string(t))
+	return nil
+}
+
+func (o *jinjaOutput) render(b *strings.Builder, ctx *jinjaContext) error {
+	v, err := o.expr.eval(ctx)
+	if err != nil {
+		return fmt.Errorf("第 %d 行: %w", o.line, err)
+	}
+	s, err := jinjaString(v)
+	if err != nil {
+		return fmt.Errorf("第 %d 行: %w", o.line, err)
+	}
+	b.WriteString(s)
+	return nil
+}
+
+func (n *jinjaIf) render(b *strings.Builder, ctx *jinjaContext) error {
+	for _, branch := range n.branches {
+		if branch.cond != nil {
+			v, err := branch.cond.eval(ctx)
+			if err != nil {
+				return err
+			}
+			if !jinjaTruthy(v) {
+				continue
+			}
+		}
+		return renderNodes(branch.body, b, ctx)
+	}
+	return nil
+}
+
+func (n *jinjaFor) render(b *strings.Builder, ctx *jinjaContext) error {
+	v, err := n.iterable.eval(ctx)
+	if err != nil {
+		return fmt.Errorf("第 %d 行: %w", n.line, err)
+	}
+	var items []interface{}
+	switch list := v.(type) {
+	case nil:
+	case []interface{}:
+		items = list
+	case jinjaUndefined:
+		return fmt.Errorf("第 %d 行: 变量 %s 未定义", n.line, list.name)
+	default:
+		return fmt.Errorf("第 %d 行: %v 不是列表", n.line, v)
+	}
+	for i, item := range items {
+		loop := map[string]interface{}{
+			"index":  i + 1,
+			"index0": i,
+			"first":  i == 0,
+			"last":   i == len(items)-1,
+			"length": len(items),
+		}
+		inner := &jinjaContext{vars: map[string]interface{}{n.name: item, "loop": loop}, parent: ctx}
+		if err := renderNodes(n.body, b, inner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *jinjaSet) render(_ *strings.Builder, ctx *jinjaContext) error {
+	v, err := n.expr.eval(ctx)
+	if err != nil {
+		return fmt.Errorf("第 %d 行: %w", n.line, err)
+	}
+	ctx.vars[n.name] = v
+	return nil
+}
+
+// --- 解析 ---
+
+// jinjaTag 模板中的标签或文本片段
+type jinjaTag struct {
+	kind    byte // 0 文本、'{' 输出、'%' 语句
+	content string
+	line    int
+}
+
+// lexJinja 把模板拆分为文本和标签，处理注释、空白控制和 trim_blocks
+func lexJinja(src string) ([]jinjaTag, error) {
+	var tags []jinjaTag
+	line := 1
+	trimNext := false
+	for len(src) > 0 {
+		start := indexJinjaTag(src)
+		text := src
+		if start >= 0 {
+			text = src[:start]
+		}
+		if trimNext {
+			text = strings.TrimLeftFunc(text, unicode.IsSpace)
+			trimNext = false
+		}
+		if start < 0 {
+			tags = append(tags, jinjaTag{content: text, line: line})
+			break
+		}
+
+		open := src[start+1]
+		closer := map[byte]string{'{': "}}", '%': "%}", '#': "#}"}[open]
+		end := strings.Index(src[start+2:], closer)
+		if end < 0 {
+			return nil, fmt.Errorf("第 %d 行: 标签没有结束", line+strings.Count(src[:start], "\n"))
+		}
+		inner := src[start+2 : start+2+end]
+		rest := src[start+2+end+2:]
+
+		if strings.HasPrefix(inner, "-") {
+			text = strings.TrimRightFunc(text, unicode.IsSpace)
+			inner = inner[1:]
+		}
+		if strings.HasSuffix(inner, "-") {
+			inner = inner[:len(inner)-1]
+			trimNext = true
+		}
+		tags = append(tags, jinjaTag{content: text, line: line})
+		line += strings.Count(src[:start], "\n")
+		if open != '#' {
+			tags = append(tags, jinjaTag{kind: open, content: strings.TrimSpace(inner), line: line})
+		}
+		line += strings.Count(src[start:start+2+end+2], "\n")
+
+		// trim_blocks: 删除语句和注释后的第一个换行
+		if open != '{' && strings.HasPrefix(rest, "\n") {
+			rest = rest[1:]
+			line++
+		}
+		src = rest
+	}
+	return tags, nil
+}
+
+func indexJinjaTag(src string) int {
+	for i := 0; i+1 < len(src); i++ {
+		if src[i] == '{' && (src[i+1] == '{' || src[i+1] == '%' || src[i+1] == '#') {
+			return i
+		}
+	}
+	return -1
+}
+
+// jinjaParser 把标签序列解析为语法树
+type jinjaParser struct {
+	tags []jinjaTag
+	pos  int
+}
+
+func parseJinja(src string) ([]jinjaNode, error) {
+	tags, err := lexJinja(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &jinjaParser{tags: tags}
+	nodes, end, err := p.parseUntil()
+	if err != nil {
+		return nil, err
+	}
+	if end != nil {
+		return nil, fmt.Errorf("第 %d 行: 多余的 {%% %s %%}", end.line, end.content)
+	}
+	return nodes, nil
+}
+
+// parseUntil 解析到 endif/elif/else/endfor 等结束标签，返回该标签
+func (p *jinjaParser) parseUntil() ([]jinjaNode, *jinjaTag, error) {
+	var nodes []jinjaNode
+	for p.pos < len(p.tags) {
+		tag := p.tags[p.pos]
+		p.pos++
+		switch tag.kind {
+		case 0:
+			if tag.content != "" {
+				nodes = append(nodes, jinjaText(tag.content))
+			}
+		case '{':
+			expr, err := parseJinjaExpr(tag.content)
+			if err != nil {
+				return nil, nil, fmt.Errorf("第 %d 行: %w", tag.line, err)
+			}
+			nodes = append(nodes, &jinjaOutput{expr: expr, line: tag.line})
+		case '%':
+			keyword, args := splitKeyword(tag.content)
+			switch keyword {
+			case "if":
+				node, err := p.parseIf(args, tag.line)
+				if err != nil {
+					return nil, nil, err
+				}
+				nodes = append(nodes, node)
+			case "for":
+				node, err := p.parseFor(args, tag.line)
+				if err != nil {
+					return nil, nil, err
+				}
+				nodes = append(nodes, node)
+			case "set":
+				node, err := parseSet(args, tag.line)
+				if err != nil {
+					return nil, nil, err
+				}
+				nodes = append(nodes, node)
+			case "elif", "else", "endif", "endfor":
+				return nodes, &p.tags[p.pos-1], nil
+			default:
+				return nil, nil, fmt.Errorf("第 %d 行: 不支持的语句 {%% %s %%}", tag.line, keyword)
+			}
+		}
+	}
+	return nodes, nil, nil
+}
+
+func (p *jinjaParser) parseIf(cond string, line int) (jinjaNode, error) {
+	node := &jinjaIf{}
+	for {
+		expr, err := parseJinjaExpr(cond)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		body, end, err := p.parseUntil()
+		if err != nil {
+			return nil, err
+		}
+		node.branches = append(node.branches, jinjaBranch{cond: expr, body: body})
+		if end == nil {
+			return nil, fmt.Errorf("第 %d 行: if 没有对应的 endif", line)
+		}
+		keyword, args := splitKeyword(end.content)
+		switch keyword {
+		case "elif":
+			cond, line = args, end.line
+			continue
+		case "else":
+			body, end, err := p.parseUntil()
+			if err != nil {
+				return nil, err
+			}
+			if end == nil || end.content != "endif" {
+				return nil, fmt.Errorf("第 %d 行: else 没有对应的 endif", line)
+			}
+			node.branches = append(node.branches, jinjaBranch{body: body})
+			return node, nil
+		case "endif":
+			return node, nil
+		}
+		return nil, fmt.Errorf("第 %d 行: if 中出现 {%% %s %%}", end.line, end.content)
+	}
+}
+
+func (p *jinjaParser) parseFor(args string, line int) (jinjaNode, error) {
+	name, iterable, ok := strings.Cut(args, " in ")
+	name = strings.TrimSpace(name)
+	if !ok || !isJinjaIdent(name) {
+		return nil, fmt.Errorf("第 %d 行: 无效的 for 语句 %q", line, args)
+	}
+	expr, err := parseJinjaExpr(iterable)
+	if err != nil {
+		return nil, fmt.Errorf("第 %d 行: %w", line, err)
+	}
+	body, end, err := p.parseUntil()
+	if err != nil {
+		return nil, err
+	}
+	if end == nil || end.content != "endfor" {
+		return nil, fmt.Errorf("第 %d 行: for 没有对应的 endfor", line)
+	}
+	return &jinjaFor{name: name, iterable: expr, body: body, line: line}, nil
+}
+
+func parseSet(args string, line int) (jinjaNode, error) {
+	name, src, ok := strings.Cut(args, "=")
+	name = strings.TrimSpace(name)
+	if !ok || !isJinjaIdent(name) {
+		return nil, fmt.Errorf("第 %d 行: 无效的 set 语句 %q", line, args)
+	}
+	expr, err := parseJinjaExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("第 %d 行: %w", line, err)
+	}
+	return &jinjaSet{name: name, expr: expr, line: line}, nil
+}
+
+func splitKeyword(content string) (string, string) {
+	keyword, args, _ := strings.Cut(content, " ")
+	return keyword, strings.TrimSpace(args)
+}
+
+func isJinjaIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// --- 表达式 ---
+
+type jinjaExpr interface {
+	eval(ctx *jinjaContext) (interface{}, error)
+}
+
+type jinjaLiteral struct{ value interface{} }
+
+type jinjaPath struct {
+	name  string
+	steps []interface{} // string 为属性，int 为下标
+}
+
+type jinjaNot struct{ expr jinjaExpr }
+
+type jinjaBinary struct {
+	op          string
+	left, right jinjaExpr
+}
+
+// jinjaCall 方法调用，如 a.split('/')
+type jinjaCall struct {
+	target jinjaExpr
+	method string
+	args   []jinjaExpr
+}
+
+// jinjaFilter 过滤器，如 a | default('x')
+type jinjaFilter struct {
+	name string
+	expr jinjaExpr
+	args []jinjaExpr
+}
+
+func (l jinjaLiteral) eval(*jinjaContext) (interface{}, error) { return l.value, nil }
+
+func (p *jinjaPath) eval(ctx *jinjaContext) (interface{}, error) {
+	v, ok := ctx.lookup(p.name)
+	if !ok {
+		return jinjaUndefined{p.name}, nil
+	}
+	path := p.name
+	for _, step := range p.steps {
+		switch key := step.(type) {
+		case string:
+			path += "." + key
+			m, isMap := v.(map[string]interface{})
+			if !isMap {
+				return jinjaUndefined{path}, nil
+			}
+			if v, ok = m[key]; !ok {
+				return jinjaUndefined{path}, nil
+			}
+		case int:
+			path += fmt.Sprintf("[%d]", key)
+			list, isList := v.([]interface{})
+			if !isList || key < 0 || key >= len(list) {
+				return jinjaUndefined{path}, nil
+			}
+			v = list[key]
+		}
+	}
+	return v, nil
+}
+
+func (n jinjaNot) eval(ctx *jinjaContext) (interface{}, error) {
+	v, err := n.expr.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return !jinjaTruthy(v), nil
+}
+
+func (n *jinjaBinary) eval(ctx *jinjaContext) (interface{}, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "and":
+		if !jinjaTruthy(left) {
+			return false, nil
+		}
+	case "or":
+		if jinjaTruthy(left) {
+			return true, nil
+		}
+	}
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "and", "or":
+		return jinjaTruthy(right), nil
+	case "==":
+		return jinjaEqual(left, right), nil
+	case "!=":
+		return !jinjaEqual(left, right), nil
+	case "in":
+		switch container := right.(type) {
+		case []interface{}:
+			for _, item := range container {
+				if jinjaEqual(left, item) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case string:
+			s, err := jinjaString(left)
+			if err != nil {
+				return nil, err
+			}
+			return strings.Contains(container, s), nil
+		case map[string]interface{}:
+			s, _ := left.(string)
+			_, ok := container[s]
+			return ok, nil
+		}
+		return false, nil
+	}
+	return nil, fmt.Errorf("不支持的运算符 %s", n.op)
+}
+
+func (c *jinjaCall) eval(ctx *jinjaContext) (interface{}, error) {
+	v, err := c.target.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	args, err := evalJinjaArgs(c.args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch c.method {
+	case "split":
+		s, err := jinjaString(v)
+		if err != nil {
+			return nil, err
+		}
+		var parts []string
+		if len(args) == 0 {
+			parts = strings.Fields(s)
+		} else {
+			sep, err := jinjaString(args[0])
+			if err != nil {
+				return nil, err
+			}
+			parts = strings.Split(s, sep)
+		}
+		list := make([]interface{}, len(parts))
+		for i, part := range parts {
+			list[i] = part
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("不支持的方法 %s", c.method)
+}
+
+// jinjaBackref 匹配 Python 替换字符串中的 \1
+var jinjaBackref = regexp.MustCompile(`\\(\d+)`)
+
+func (f *jinjaFilter) eval(ctx *jinjaContext) (interface{}, error) {
+	v, err := f.expr.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	args, err := evalJinjaArgs(f.args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch f.name {
+	case "default":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("default 缺少参数")
+		}
+		_, undefined := v.(jinjaUndefined)
+		if undefined || (len(args) > 1 && jinjaTruthy(args[1]) && !jinjaTruthy(v)) {
+			return args[0], nil
+		}
+		return v, nil
+	case "int":
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case float64:
+			return int(n), nil
+		case string:
+			i, _ := strconv.Atoi(strings.TrimSpace(n))
+			return i, nil
+		}
+		return 0, nil
+	case "join":
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("join 的参数 %v 不是列表", v)
+		}
+		sep := ""
+		if len(args) > 0 {
+			if sep, err = jinjaString(args[0]); err != nil {
+				return nil, err
+			}
+		}
+		items := make([]string, len(list))
+		for i, item := range list {
+			if items[i], err = jinjaString(item); err != nil {
+				return nil, err
+			}
+		}
+		return strings.Join(items, sep), nil
+	case "regex_replace":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("regex_replace 需要 2 个参数")
+		}
+		s, err := jinjaString(v)
+		if err != nil {
+			return nil, err
+		}
+		pattern, _ := args[0].(string)
+		replacement, _ := args[1].(string)
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("regex_replace 的正则表达式无效: %w", err)
+		}
+		return re.ReplaceAllString(s, jinjaBackref.ReplaceAllString(replacement, "$${$1}")), nil
+	}
+	return nil, fmt.Errorf("不支持的过滤器 %s", f.name)
+}
+
+func evalJinjaArgs(exprs []jinjaExpr, ctx *jinjaContext) ([]interface{}, error) {
+	args := make([]interface{}, len(exprs))
+	for i, expr := range exprs {
+		v, err := expr.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+// jinjaExprParser 表达式的递归下降解析器
+type jinjaExprParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseJinjaExpr(src string) (jinjaExpr, error) {
+	tokens, err := tokenizeJinjaExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("表达式为空")
+	}
+	p := &jinjaExprParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("表达式 %q 中有多余的 %q", src, p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+func tokenizeJinjaExpr(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("字符串没有结束: %s", src[i:])
+			}
+			tokens = append(tokens, src[i:i+end+2])
+			i += end + 2
+		case strings.HasPrefix(src[i:], "==") || strings.HasPrefix(src[i:], "!="):
+			tokens = append(tokens, src[i:i+2])
+			i += 2
+		case strings.ContainsRune(".[]()|,", rune(c)):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '_' || c == '-' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("表达式 %q 中有不支持的字符 %q", src, c)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *jinjaExprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *jinjaExprParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *jinjaExprParser) parseOr() (jinjaExpr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "or" {
+		p.next()
+		var right jinjaExpr
+		if right, err = p.parseAnd(); err == nil {
+			left = &jinjaBinary{op: "or", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *jinjaExprParser) parseAnd() (jinjaExpr, error) {
+	left, err := p.parseNot()
+	for err == nil && p.peek() == "and" {
+		p.next()
+		var right jinjaExpr
+		if right, err = p.parseNot(); err == nil {
+			left = &jinjaBinary{op: "and", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *jinjaExprParser) parseNot() (jinjaExpr, error) {
+	if p.peek() == "not" {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return jinjaNot{expr}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *jinjaExprParser) parseCompare() (jinjaExpr, error) {
+	left, err := p.parseFiltered()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	negate := false
+	if op == "not" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1] == "in" {
+		p.next()
+		negate = true
+		op = "in"
+	}
+	if op != "==" && op != "!=" && op != "in" {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseFiltered()
+	if err != nil {
+		return nil, err
+	}
+	var expr jinjaExpr = &jinjaBinary{op: op, left: left, right: right}
+	if negate {
+		expr = jinjaNot{expr}
+	}
+	return expr, nil
+}
+
+// parseFiltered 解析带过滤器的表达式，过滤器比比较运算符优先
+func (p *jinjaExprParser) parseFiltered() (jinjaExpr, error) {
+	expr, err := p.parsePrimary()
+	for err == nil && p.peek() == "|" {
+		p.next()
+		filter := &jinjaFilter{name: p.next(), expr: expr}
+		if !isJinjaIdent(filter.name) {
+			return nil, fmt.Errorf("无效的过滤器 %q", filter.name)
+		}
+		if p.peek() == "(" {
+			filter.args, err = p.parseArgs()
+		}
+		expr = filter
+	}
+	return expr, err
+}
+
+// parseArgs 解析 (a, b) 形式的参数列表
+func (p *jinjaExprParser) parseArgs() ([]jinjaExpr, error) {
+	p.next()
+	var args []jinjaExpr
+	for p.peek() != ")" {
+		if len(args) > 0 && p.next() != "," {
+			return nil, fmt.Errorf("参数之间缺少 ,")
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+	return args, nil
+}
+
+func (p *jinjaExprParser) parsePrimary() (jinjaExpr, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("表达式不完整")
+	case t == "(":
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("缺少 )")
+		}
+		return expr, nil
+	case t[0] == '\'' || t[0] == '"':
+		return jinjaLiteral{t[1 : len(t)-1]}, nil
+	case t == "true" || t == "True":
+		return jinjaLiteral{true}, nil
+	case t == "false" || t == "False":
+		return jinjaLiteral{false}, nil
+	case t == "none" || t == "None":
+		return jinjaLiteral{nil}, nil
+	}
+	if n, err := strconv.Atoi(t); err == nil {
+		return jinjaLiteral{n}, nil
+	}
+	if !isJinjaIdent(t) {
+		return nil, fmt.Errorf("无效的变量名 %q", t)
+	}
+
+	path := &jinjaPath{name: t}
+	for {
+		switch p.peek() {
+		case ".":
+			p.next()
+			attr := p.next()
+			if !isJinjaIdent(attr) {
+				return nil, fmt.Errorf("无效的属性 %q", attr)
+			}
+			if p.peek() == "(" {
+				args, err := p.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+				return &jinjaCall{target: path, method: attr, args: args}, nil
+			}
+			path.steps = append(path.steps, attr)
+		case "[":
+			p.next()
+			index := p.next()
+			var step interface{}
+			if n, err := strconv.Atoi(index); err == nil {
+				step = n
+			} else if len(index) >= 2 && (index[0] == '\'' || index[0] == '"') {
+				step = index[1 : len(index)-1]
+			} else {
+				return nil, fmt.Errorf("无效的下标 %q", index)
+			}
+			if p.next() != "]" {
+				return nil, fmt.Errorf("缺少 ]")
+			}
+			path.steps = append(path.steps, step)
+		default:
+			return path, nil
+		}
+	}
+}
+
+// --- 取值 ---
+
+func jinjaTruthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil, jinjaUndefined:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+func jinjaEqual(a, b interface{}) bool {
+	if _, ok := a.(jinjaUndefined); ok {
+		return false
+	}
+	if _, ok := b.(jinjaUndefined); ok {
+		return false
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// jinjaString 按 Python 的习惯输出值
+func jinjaString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case jinjaUndefined:
+		return "", fmt.Errorf("变量 %s 未定义", v.name)
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		if v {
+			return "True", nil
+		}
+		return "False", nil
+	case int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("无法输出 %T 类型的值", v)
+}
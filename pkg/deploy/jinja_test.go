@@ -0,0 +1,67 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderJinja(t *testing.T) {
+	vars := map[string]interface{}{
+		"bastion": map[string]interface{}{"ipv4": "192.168.1.10", "ipv6": ""},
+		"nodes": []interface{}{
+			map[string]interface{}{"name": "master-0", "ip": "192.168.1.21"},
+			map[string]interface{}{"name": "master-1", "ip": "192.168.1.22"},
+		},
+		"mode": "bastion-haproxy",
+		"port": 6443,
+	}
+	tests := []struct {
+		name, src, want string
+	}{
+		{"output", "api {{ bastion.ipv4 }}:{{ port }}", "api 192.168.1.10:6443"},
+		{"trim blocks", "a\n{% if bastion.ipv4 %}\nv4\n{% endif %}\n{% if bastion.ipv6 %}\nv6\n{% endif %}\nb\n", "a\nv4\nb\n"},
+		{"else", "{% if bastion.ipv6 %}v6{% elif bastion.ipv4 %}v4{% else %}none{% endif %}", "v4"},
+		{"for", "{% for n in nodes %}\nserver {{ n.name }} {{ n.ip }}\n{% endfor %}\n", "server master-0 192.168.1.21\nserver master-1 192.168.1.22\n"},
+		{"loop", "{% for n in nodes %}{{ n.name }}{% if not loop.last %},{% endif %}{% endfor %}", "master-0,master-1"},
+		{"index", "{{ nodes[1].name }}", "master-1"},
+		{"compare", "{% if mode == 'bastion-haproxy' and port != 80 %}yes{% endif %}", "yes"},
+		{"in", "{% if 'haproxy' in mode %}yes{% endif %}{% if 'z' not in mode %}!{% endif %}", "yes!"},
+		{"comment and whitespace control", "a {#- note -#} b\n  {%- if true %} c{% endif %}", "ab c"},
+		{"undefined in condition", "{% if missing.value %}x{% endif %}ok", "ok"},
+		{"set and split", "{% set parts = bastion.ipv4.split('.') %}{{ parts[3] | int }}{% if parts[3] | int == 10 %}!{% endif %}", "10!"},
+		{"default", "{{ missing | default('x86_64') }} {{ bastion.ipv6 | default('none', true) }}", "x86_64 none"},
+		{"regex_replace", "{{ bastion.ipv4 | regex_replace('\\.\\d+$', '.0') }} {{ mode | regex_replace('(\\w+)-(\\w+)', '\\2-\\1') }}", "192.168.1.0 haproxy-bastion"},
+		{"join", "{{ bastion.ipv4.split('.') | join('-') }}", "192-168-1-10"},
+	}
+	for _, tt := range tests {
+		got, err := renderJinja(tt.name, tt.src, vars)
+		if err != nil {
+			t.Errorf("%s: renderJinja() error = %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: renderJinja() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderJinjaErrors(t *testing.T) {
+	tests := map[string]string{
+		"{{ missing }}":                 "未定义",
+		"{% if true %}x":                "endif",
+		"{% for x in nodes %}":          "endfor",
+		"{% endif %}":                   "多余",
+		"{% include 'other.j2' %}":      "不支持",
+		"{{ a + 1 }}":                   "不支持的字符",
+		"{{ bastion | upper }}":         "不支持的过滤器",
+		"{% set = 1 %}":                 "set",
+		"line\n\n{{ bastion.missing }}": "第 3 行",
+	}
+	vars := map[string]interface{}{"bastion": map[string]interface{}{}, "nodes": []interface{}{}}
+	for src, want := range tests {
+		_, err := renderJinja("test", src, vars)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("renderJinja(%q) error = %v, want %q", src, err, want)
+		}
+	}
+}
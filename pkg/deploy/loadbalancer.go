@@ -0,0 +1,152 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/utils"
+)
+
+// haproxy-ha 模式的 HAProxy 节点，与 bastion playbook 中 loadbalancer 组的 play 一致
+const (
+	haproxyConfigPath    = "/etc/haproxy/haproxy.cfg"
+	keepalivedConfigPath = "/etc/keepalived/keepalived.conf"
+)
+
+var (
+	loadBalancerPackages = []string{"haproxy", "keepalived", "firewalld"}
+	loadBalancerServices = []string{"haproxy", "keepalived"}
+)
+
+// defaultInterfaceCommand 输出默认路由所在的网卡，节点未设置 interface 时 keepalived 使用它
+const defaultInterfaceCommand = "ip -o route show default | awk '{print $5; exit}'; ip -o -6 route show default | awk '{print $5; exit}'"
+
+// firewalld 中 keepalived 需要的 VRRP 协议
+const (
+	openVRRPCommand  = "firewall-cmd --permanent --add-protocol=vrrp && firewall-cmd --reload"
+	closeVRRPCommand = "if firewall-cmd --state >/dev/null 2>&1; then firewall-cmd --permanent --remove-protocol=vrrp >/dev/null 2>&1; firewall-cmd --reload; fi"
+)
+
+// connectLoadBalancer 连接 HAProxy 节点，使用 Bastion 的 SSH 用户和认证方式
+func (p *NativeBastionProvisioner) connectLoadBalancer(node config.LoadBalancerNode) (*nativeHost, func(), error) {
+	client, err := utils.NewSSHClient(p.config.LoadBalancerSSH(p.clusterDir, node))
+	if err != nil {
+		return nil, nil, fmt.Errorf("连接 HAProxy 节点失败: %w", err)
+	}
+	host := &nativeHost{shell: client, sudo: p.config.Bastion.Username != "root"}
+	return host, func() { client.Close() }, nil
+}
+
+// provisionLoadBalancer 在 HAProxy 节点上安装并配置 HAProxy 和 keepalived
+func (p *NativeBastionProvisioner) provisionLoadBalancer(vars map[string]interface{}, node config.LoadBalancerNode) error {
+	host, closeHost, err := p.connectLoadBalancer(node)
+	if err != nil {
+		return err
+	}
+	defer closeHost()
+
+	fmt.Printf("➡️  %s: 安装 haproxy 和 keepalived...\n", node.Name)
+	installed, err := host.installPackages(loadBalancerPackages...)
+	if err != nil {
+		return err
+	}
+	if len(installed) > 0 {
+		fmt.Printf("✅ 已安装 %s\n", strings.Join(installed, "、"))
+	}
+
+	hardened := p.config.SecurityMode() == config.SecurityModeHardened
+	if hardened {
+		if err := hardenSecurity(host, haproxyFirewallPorts, "haproxy_connect_any"); err != nil {
+			return err
+		}
+		if _, err := host.run(openVRRPCommand); err != nil {
+			return fmt.Errorf("在 firewalld 中允许 VRRP 失败: %w", err)
+		}
+	} else if err := configureSecurity(host); err != nil {
+		return err
+	}
+
+	files, err := renderLoadBalancerFiles(vars, node, loadBalancerInterface(host, node))
+	if err != nil {
+		return err
+	}
+	restart := map[string]bool{}
+	for _, f := range files {
+		changed, err := host.syncFile(f)
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmt.Printf("✅ 已更新 %s:%s\n", node.Name, f.Path)
+			restart[f.Service] = true
+		}
+	}
+	if hardened {
+		if err := restoreContexts(host, loadBalancerSELinuxPaths...); err != nil {
+			return err
+		}
+	}
+	for _, service := range loadBalancerServices {
+		if err := host.ensureService(service, restart[service]); err != nil {
+			return err
+		}
+	}
+	return verifyHAProxy(host)
+}
+
+// checkLoadBalancer 显示 HAProxy 节点上将要做的修改，不修改节点
+func (p *NativeBastionProvisioner) checkLoadBalancer(vars map[string]interface{}, node config.LoadBalancerNode) error {
+	host, closeHost, err := p.connectLoadBalancer(node)
+	if err != nil {
+		return err
+	}
+	defer closeHost()
+
+	files, err := renderLoadBalancerFiles(vars, node, loadBalancerInterface(host, node))
+	if err != nil {
+		return err
+	}
+	return checkHost(host, loadBalancerPackages, files)
+}
+
+// loadBalancerInterface 返回 keepalived 绑定 VIP 的网卡
+func loadBalancerInterface(host *nativeHost, node config.LoadBalancerNode) string {
+	if node.Interface != "" {
+		return node.Interface
+	}
+	output, _ := host.run(defaultInterfaceCommand)
+	if fields := strings.Fields(output); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// renderLoadBalancerFiles 渲染 HAProxy 节点的 haproxy.cfg 和 keepalived.conf，
+// lb_node 和 lb_interface 与 playbook 中 set_fact 设置的变量相同
+func renderLoadBalancerFiles(vars map[string]interface{}, node config.LoadBalancerNode, iface string) ([]managedFile, error) {
+	if iface == "" {
+		return nil, fmt.Errorf("无法确定 %s 上 keepalived 使用的网卡，请设置 load_balancer.nodes 的 interface", node.Name)
+	}
+	lb, _ := vars["load_balancer"].(map[string]interface{})
+	nodes, _ := lb["nodes"].([]interface{})
+	var lbNode map[string]interface{}
+	for _, n := range nodes {
+		if m, _ := n.(map[string]interface{}); m["ip"] == node.IP {
+			lbNode = m
+		}
+	}
+	if lbNode == nil {
+		return nil, fmt.Errorf("变量中没有 HAProxy 节点 %s", node.IP)
+	}
+
+	t := &templateFiles{vars: vars}
+	extra := map[string]interface{}{"lb_node": lbNode, "lb_interface": iface}
+	if err := t.add("haproxy.cfg.j2", haproxyConfigPath, "root", "0644", "haproxy", "haproxy -c -f %s", extra); err != nil {
+		return nil, err
+	}
+	if err := t.add("keepalived.conf.j2", keepalivedConfigPath, "root", "0644", "keepalived", "keepalived -t -f %s", extra); err != nil {
+		return nil, err
+	}
+	return t.files, nil
+}
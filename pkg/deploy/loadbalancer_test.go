@@ -0,0 +1,122 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+
+	"ocpack/pkg/config"
+)
+
+func testHAConfig() *config.ClusterConfig {
+	cfg := testBastionConfig()
+	cfg.LoadBalancer.Mode = config.LoadBalancerHAProxyHA
+	cfg.LoadBalancer.APIVIP = "192.168.1.5"
+	cfg.LoadBalancer.IngressVIP = "192.168.1.6"
+	cfg.LoadBalancer.Nodes = []config.LoadBalancerNode{{Name: "lb-0", IP: "192.168.1.7"}, {Name: "lb-1", IP: "192.168.1.8", Interface: "eth1"}}
+	return cfg
+}
+
+func TestRenderBastionFilesLoadBalancerModes(t *testing.T) {
+	for _, mode := range []string{config.LoadBalancerHAProxyHA, config.LoadBalancerExternal} {
+		cfg := testHAConfig()
+		cfg.LoadBalancer.Mode = mode
+		vars, err := bastionTemplateVars(cfg, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := renderBastionFiles(vars)
+		if err != nil {
+			t.Fatalf("%s: renderBastionFiles() error = %v", mode, err)
+		}
+		var forward string
+		for _, f := range files {
+			if f.Path == haproxyConfigPath {
+				t.Errorf("%s: haproxy.cfg rendered for the bastion", mode)
+			}
+			if f.Path == "/var/named/demo.example.com.zone" {
+				forward = f.Content
+			}
+		}
+		for _, want := range []string{"api IN  A   192.168.1.5\n", "api-int IN  A   192.168.1.5\n", "*.apps IN  A   192.168.1.6\n"} {
+			if !strings.Contains(forward, want) {
+				t.Errorf("%s: forward zone missing %q:\n%s", mode, want, forward)
+			}
+		}
+		if hasNodes := strings.Contains(forward, "lb-0 IN  A   192.168.1.7\n"); hasNodes != (mode == config.LoadBalancerHAProxyHA) {
+			t.Errorf("%s: forward zone HAProxy node records = %v:\n%s", mode, hasNodes, forward)
+		}
+	}
+}
+
+func TestRenderLoadBalancerFiles(t *testing.T) {
+	cfg := testHAConfig()
+	vars, err := bastionTemplateVars(cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := renderLoadBalancerFiles(vars, cfg.LoadBalancer.Nodes[0], "ens3")
+	if err != nil {
+		t.Fatalf("renderLoadBalancerFiles() error = %v", err)
+	}
+	if len(files) != 2 || files[0].Path != haproxyConfigPath || files[1].Path != keepalivedConfigPath {
+		t.Fatalf("files = %+v", files)
+	}
+	if !strings.Contains(files[0].Content, "    server master-0 192.168.1.21:6443 check\n") {
+		t.Errorf("haproxy.cfg:\n%s", files[0].Content)
+	}
+	keepalived := files[1].Content
+	for _, want := range []string{
+		"vrrp_instance demo_api {\n    state MASTER\n    interface ens3\n    virtual_router_id 51\n    priority 150\n",
+		"    unicast_src_ip 192.168.1.7\n    unicast_peer {\n        192.168.1.8\n    }\n",
+		"    virtual_ipaddress {\n        192.168.1.5\n    }\n",
+		"vrrp_instance demo_ingress {",
+		"    virtual_router_id 52\n",
+		"        192.168.1.6\n",
+	} {
+		if !strings.Contains(keepalived, want) {
+			t.Errorf("keepalived.conf missing %q:\n%s", want, keepalived)
+		}
+	}
+	if strings.Contains(keepalived, "virtual_ipaddress_excluded") || strings.Contains(keepalived, "{%") {
+		t.Errorf("keepalived.conf:\n%s", keepalived)
+	}
+
+	// API 和 Ingress 使用同一个 VIP 时只有一个 VRRP 实例
+	cfg.LoadBalancer.IngressVIP = cfg.LoadBalancer.APIVIP
+	vars, _ = bastionTemplateVars(cfg, t.TempDir())
+	files, err = renderLoadBalancerFiles(vars, cfg.LoadBalancer.Nodes[1], "eth1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keepalived := files[1].Content; strings.Contains(keepalived, "demo_ingress") || !strings.Contains(keepalived, "state BACKUP\n    interface eth1\n") {
+		t.Errorf("keepalived.conf with a shared VIP:\n%s", keepalived)
+	}
+}
+
+func TestLoadBalancerInterface(t *testing.T) {
+	cfg := testHAConfig()
+	host := &nativeHost{shell: &fakeShell{files: map[string]string{}}}
+	if iface := loadBalancerInterface(host, cfg.LoadBalancer.Nodes[1]); iface != "eth1" {
+		t.Errorf("loadBalancerInterface() = %q, want eth1", iface)
+	}
+	// 没有默认路由时要求配置 interface
+	vars, _ := bastionTemplateVars(cfg, t.TempDir())
+	iface := loadBalancerInterface(host, cfg.LoadBalancer.Nodes[0])
+	if _, err := renderLoadBalancerFiles(vars, cfg.LoadBalancer.Nodes[0], iface); err == nil || !strings.Contains(err.Error(), "interface") {
+		t.Errorf("renderLoadBalancerFiles() without interface = %v", err)
+	}
+}
+
+func TestDestroyLoadBalancer(t *testing.T) {
+	shell := &fakeShell{files: map[string]string{}}
+	if err := destroyLoadBalancer(&nativeHost{shell: shell}); err != nil {
+		t.Fatalf("destroyLoadBalancer() error = %v", err)
+	}
+	commands := strings.Join(shell.commands, "\n")
+	for _, want := range []string{"systemctl disable --now keepalived", "dnf -y remove haproxy keepalived", "'/etc/keepalived/keepalived.conf'", "--remove-protocol=vrrp"} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("missing %q in commands:\n%s", want, commands)
+		}
+	}
+}
@@ -0,0 +1,649 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 部署引擎
+const (
+	// EngineAuto 安装了 ansible-playbook 时使用 Ansible，否则使用原生引擎
+	EngineAuto = "auto"
+	// EngineNative 通过 SSH 直接配置节点，不需要 Ansible
+	EngineNative = "native"
+	// EngineAnsible 执行内置的 Ansible playbook
+	EngineAnsible = "ansible"
+)
+
+// Engines 返回所有支持的部署引擎
+func Engines() []string {
+	return []string{EngineAuto, EngineNative, EngineAnsible}
+}
+
+// ResolveEngine 把 auto (或空) 解析为实际使用的部署引擎
+func ResolveEngine(engine string) (string, error) {
+	switch engine {
+	case "", EngineAuto:
+		if _, err := exec.LookPath("ansible-playbook"); err == nil {
+			return EngineAnsible, nil
+		}
+		fmt.Println("ℹ️  未找到 ansible-playbook，使用原生部署引擎")
+		return EngineNative, nil
+	case EngineNative, EngineAnsible:
+		return engine, nil
+	}
+	return "", fmt.Errorf("不支持的部署引擎 %q，可选值: %s", engine, strings.Join(Engines(), "、"))
+}
+
+// remoteShell 在远程主机上执行命令和写入文件，由 utils.SSHClient 实现，测试中替换
+type remoteShell interface {
+	RunCommand(command string) (string, error)
+	UploadString(content, remotePath string) error
+	UploadFile(localPath, remotePath string) error
+}
+
+// nativeHost 远程主机，非 root 用户通过 sudo 执行命令 (与 playbook 的 become 相同，需要免密 sudo)
+type nativeHost struct {
+	shell remoteShell
+	sudo  bool
+}
+
+// managedFile 原生引擎管理的配置文件
+type managedFile struct {
+	Path     string
+	Content  string
+	Owner    string
+	Group    string
+	Mode     string
+	Service  string // 文件变化后需要重启的服务
+	Validate string // 安装前校验临时文件的命令，%s 为临时文件路径
+	// Edit 根据节点上的内容计算新内容，用于检查模式预览 lineinfile 和 blockinfile 修改的文件，设置时忽略 Content
+	Edit func(current string) (string, error)
+}
+
+// contentEdit 返回根据节点上的内容计算文件新内容的函数
+func (f managedFile) contentEdit() func(string) (string, error) {
+	if f.Edit != nil {
+		return f.Edit
+	}
+	return func(string) (string, error) { return f.Content, nil }
+}
+
+// run 以 root 身份执行命令
+func (h *nativeHost) run(command string) (string, error) {
+	if h.sudo {
+		command = "sudo -n sh -c " + shellQuote(command)
+	}
+	return h.shell.RunCommand(command)
+}
+
+// readFile 读取远程文件，文件不存在时返回 false
+func (h *nativeHost) readFile(filePath string) (string, bool, error) {
+	q := shellQuote(filePath)
+	output, err := h.run(fmt.Sprintf("if [ -e %s ]; then echo present; cat -- %s; else echo missing; fi", q, q))
+	if err != nil {
+		return "", false, fmt.Errorf("读取 %s 失败: %w", filePath, err)
+	}
+	status, content, _ := strings.Cut(output, "\n")
+	return content, status == "present", nil
+}
+
+// tempFilePrefix 上传文件使用的临时文件前缀，由 mktemp 生成随机后缀
+const tempFilePrefix = "/tmp/.ocpack-"
+
+// makeTemp 以 SSH 用户身份通过 mktemp 新建临时文件 (0600) 或临时目录 (dir 为 true 时，0700)，返回路径
+func (h *nativeHost) makeTemp(dir bool) (string, error) {
+	command := "mktemp "
+	if dir {
+		command += "-d "
+	}
+	output, err := h.shell.RunCommand(command + tempFilePrefix + "XXXXXXXXXX")
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpPath := strings.TrimSpace(output)
+	if !strings.HasPrefix(tmpPath, tempFilePrefix) || strings.ContainsAny(tmpPath, " \n'") {
+		return "", fmt.Errorf("mktemp 返回了无效的路径: %q", output)
+	}
+	return tmpPath, nil
+}
+
+// uploadTemp 通过 upload 把内容写入 mktemp 新建的临时文件 (属主为 SSH 用户、权限 0600)，
+// 返回临时文件路径。上传由 SSH 用户完成，临时文件不能由 root 创建，失败时删除临时文件
+func (h *nativeHost) uploadTemp(upload func(remotePath string) error) (string, error) {
+	tmpPath, err := h.makeTemp(false)
+	if err != nil {
+		return "", err
+	}
+	if err := upload(tmpPath); err != nil {
+		h.run("rm -f " + shellQuote(tmpPath))
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+// diffFile 返回远程文件到新内容的 unified diff，只读取不修改，内容相同时返回空字符串
+func (h *nativeHost) diffFile(f managedFile) (string, error) {
+	current, exists, err := h.readFile(f.Path)
+	if err != nil {
+		return "", err
+	}
+	content, err := f.contentEdit()(current)
+	if err != nil {
+		return "", fmt.Errorf("计算 %s 的新内容失败: %w", f.Path, err)
+	}
+	oldName := f.Path
+	if !exists {
+		oldName = "/dev/null"
+	}
+	return unifiedDiff(oldName, f.Path, current, content), nil
+}
+
+// syncFile 内容不同时备份并替换远程文件，返回文件是否变化。属主和权限每次都会校正。
+func (h *nativeHost) syncFile(f managedFile) (bool, error) {
+	current, exists, err := h.readFile(f.Path)
+	if err != nil {
+		return false, err
+	}
+	dest := shellQuote(f.Path)
+	if exists && current == f.Content {
+		_, err := h.run(fmt.Sprintf("chown %s:%s %s && chmod %s %s", f.Owner, f.Group, dest, f.Mode, dest))
+		return false, err
+	}
+
+	tmpPath, err := h.uploadTemp(func(uploadPath string) error {
+		return h.shell.UploadString(f.Content, uploadPath)
+	})
+	if err != nil {
+		return false, err
+	}
+	tmp := shellQuote(tmpPath)
+	defer h.run("rm -f " + tmp)
+	if f.Validate != "" {
+		if output, err := h.run(fmt.Sprintf(f.Validate, tmp)); err != nil {
+			return false, fmt.Errorf("%s 校验失败: %w %s", f.Path, err, output)
+		}
+	}
+	var commands []string
+	if exists {
+		// 与 Ansible template 模块的 backup: yes 相同，保留旧文件
+		commands = append(commands, fmt.Sprintf("cp -p %s %s", dest, shellQuote(f.Path+"."+time.Now().Format("2006-01-02@15:04:05")+"~")))
+	}
+	commands = append(commands, fmt.Sprintf("install -o %s -g %s -m %s %s %s", f.Owner, f.Group, f.Mode, tmp, dest))
+	if _, err := h.run(strings.Join(commands, " && ")); err != nil {
+		return false, fmt.Errorf("写入 %s 失败: %w", f.Path, err)
+	}
+	return true, nil
+}
+
+// missingPackages 返回未安装的软件包
+func (h *nativeHost) missingPackages(packages ...string) []string {
+	var missing []string
+	for _, pkg := range packages {
+		if _, err := h.run("rpm -q --quiet " + shellQuote(pkg)); err != nil {
+			missing = append(missing, pkg)
+		}
+	}
+	return missing
+}
+
+// installPackages 安装缺少的软件包，返回新安装的软件包
+func (h *nativeHost) installPackages(packages ...string) ([]string, error) {
+	missing := h.missingPackages(packages...)
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	list := strings.Join(missing, " ")
+	if _, err := h.run(fmt.Sprintf("if command -v dnf >/dev/null 2>&1; then dnf -y install %s; else yum -y install %s; fi", list, list)); err != nil {
+		return nil, fmt.Errorf("安装软件包 %s 失败: %w", list, err)
+	}
+	return missing, nil
+}
+
+// ensureService 启用并启动服务，restart 为 true 时重启
+func (h *nativeHost) ensureService(name string, restart bool) error {
+	action := "start"
+	if restart {
+		action = "restart"
+	}
+	if _, err := h.run(fmt.Sprintf("systemctl enable %s && systemctl %s %s", name, action, name)); err != nil {
+		status, _ := h.run(fmt.Sprintf("systemctl status %s --no-pager; journalctl -u %s --no-pager -n 30", name, name))
+		return fmt.Errorf("启动 %s 服务失败: %w\n%s", name, err, status)
+	}
+	return nil
+}
+
+// installBinary 上传本地可执行文件，远程文件的 SHA256 相同时跳过
+func (h *nativeHost) installBinary(localPath, remotePath string) (bool, error) {
+	sum, err := fileSHA256(localPath)
+	if err != nil {
+		return false, err
+	}
+	output, _ := h.run(fmt.Sprintf("sha256sum %s 2>/dev/null || true", shellQuote(remotePath)))
+	if strings.HasPrefix(output, sum+" ") {
+		return false, nil
+	}
+	tmpPath, err := h.uploadTemp(func(uploadPath string) error {
+		return h.shell.UploadFile(localPath, uploadPath)
+	})
+	if err != nil {
+		return false, err
+	}
+	tmp := shellQuote(tmpPath)
+	defer h.run("rm -f " + tmp)
+	if _, err := h.run(fmt.Sprintf("install -o root -g root -m 0755 %s %s", tmp, shellQuote(remotePath))); err != nil {
+		return false, fmt.Errorf("安装 %s 失败: %w", remotePath, err)
+	}
+	return true, nil
+}
+
+// NativeBastionProvisioner 不依赖 Ansible，通过 SSH 完成与 bastion playbook 相同的配置
+type NativeBastionProvisioner struct {
+	config      *config.ClusterConfig
+	clusterDir  string
+	downloadDir string
+}
+
+// NewNativeBastionProvisioner 创建原生 Bastion 部署器
+func NewNativeBastionProvisioner(cfg *config.ClusterConfig, clusterDir, downloadDir string) *NativeBastionProvisioner {
+	return &NativeBastionProvisioner{config: cfg, clusterDir: clusterDir, downloadDir: downloadDir}
+}
+
+// bastionPackages 返回 Bastion 节点需要的软件包，与 playbook 一致
+func bastionPackages(cfg *config.ClusterConfig) []string {
+	packages := []string{"bind", "bind-utils", "firewalld"}
+	if cfg.LoadBalancerMode() == config.LoadBalancerBastionHAProxy {
+		packages = append(packages, "haproxy")
+	}
+	return packages
+}
+
+// bastionServiceNames 返回 Bastion 节点上运行的服务
+func bastionServiceNames(cfg *config.ClusterConfig) []string {
+	if cfg.LoadBalancerMode() == config.LoadBalancerBastionHAProxy {
+		return []string{"named", "haproxy"}
+	}
+	return []string{"named"}
+}
+
+// Provision 配置 Bastion 节点，haproxy-ha 模式同时配置 HAProxy 节点，重复执行时只修改变化的部分
+func (p *NativeBastionProvisioner) Provision() error {
+	ha := p.config.LoadBalancerMode() == config.LoadBalancerHAProxyHA
+	steps := 6
+	if ha {
+		steps = 7
+	}
+	services := bastionServiceNames(p.config)
+
+	// 先在本地渲染所有配置文件，模板错误不会留下修改了一半的节点
+	vars, err := bastionTemplateVars(p.config, p.clusterDir)
+	if err != nil {
+		return err
+	}
+	files, err := renderBastionFiles(vars)
+	if err != nil {
+		return err
+	}
+
+	client, err := utils.NewSSHClient(p.config.BastionSSH(p.clusterDir))
+	if err != nil {
+		return fmt.Errorf("连接 Bastion 节点失败: %w", err)
+	}
+	defer client.Close()
+	host := &nativeHost{shell: client, sudo: p.config.Bastion.Username != "root"}
+
+	fmt.Printf("➡️  步骤 1/%d: 安装软件包...\n", steps)
+	installed, err := host.installPackages(bastionPackages(p.config)...)
+	if err != nil {
+		return err
+	}
+	if len(installed) > 0 {
+		fmt.Printf("✅ 已安装 %s\n", strings.Join(installed, "、"))
+	}
+
+	fmt.Printf("➡️  步骤 2/%d: 配置 firewalld 和 SELinux...\n", steps)
+	hardened := p.config.SecurityMode() == config.SecurityModeHardened
+	if hardened {
+		var booleans []string
+		if slices.Contains(services, "haproxy") {
+			booleans = append(booleans, "haproxy_connect_any")
+		}
+		err = hardenSecurity(host, bastionFirewallPorts(p.config), booleans...)
+	} else {
+		err = configureSecurity(host)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("➡️  步骤 3/%d: 生成 %s 配置...\n", steps, strings.Join(services, " 和 "))
+	if _, err := host.run("install -d -o named -g named -m 0755 /etc/named"); err != nil {
+		return fmt.Errorf("创建 /etc/named 失败: %w", err)
+	}
+	restart := map[string]bool{}
+	for _, f := range files {
+		changed, err := host.syncFile(f)
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmt.Printf("✅ 已更新 %s\n", f.Path)
+			restart[f.Service] = true
+		}
+	}
+	if hardened {
+		if err := restoreContexts(host, bastionSELinuxPaths...); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("➡️  步骤 4/%d: 启动 %s...\n", steps, strings.Join(services, " 和 "))
+	for _, service := range services {
+		if err := host.ensureService(service, restart[service]); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("➡️  步骤 5/%d: 安装 oc 和 kubectl...\n", steps)
+	p.installClients(host)
+
+	if ha {
+		fmt.Printf("➡️  步骤 6/%d: 配置 HAProxy 节点...\n", steps)
+		for _, node := range p.config.LoadBalancer.Nodes {
+			if err := p.provisionLoadBalancer(vars, node); err != nil {
+				return fmt.Errorf("配置 HAProxy 节点 %s (%s) 失败: %w", node.Name, node.IP, err)
+			}
+		}
+	}
+
+	fmt.Printf("➡️  步骤 %d/%d: 验证服务...\n", steps, steps)
+	if err := p.verify(host); err != nil {
+		return err
+	}
+	if hardened {
+		return VerifyBastionServices(p.config)
+	}
+	return nil
+}
+
+// Check 显示部署会对 Bastion (以及 haproxy-ha 模式的 HAProxy 节点) 做的修改: 缺少的软件包、
+// 每个配置文件的 unified diff 以及需要重启的服务。只通过 SSH 读取节点的状态，不修改任何文件。
+func (p *NativeBastionProvisioner) Check() error {
+	vars, err := bastionTemplateVars(p.config, p.clusterDir)
+	if err != nil {
+		return err
+	}
+	files, err := renderBastionFiles(vars)
+	if err != nil {
+		return err
+	}
+
+	client, err := utils.NewSSHClient(p.config.BastionSSH(p.clusterDir))
+	if err != nil {
+		return fmt.Errorf("连接 Bastion 节点失败: %w", err)
+	}
+	defer client.Close()
+	host := &nativeHost{shell: client, sudo: p.config.Bastion.Username != "root"}
+
+	if err := checkHost(host, bastionPackages(p.config), files); err != nil {
+		return err
+	}
+	if p.config.LoadBalancerMode() == config.LoadBalancerHAProxyHA {
+		for _, node := range p.config.LoadBalancer.Nodes {
+			fmt.Printf("ℹ️  HAProxy 节点 %s (%s):\n", node.Name, node.IP)
+			if err := p.checkLoadBalancer(vars, node); err != nil {
+				return fmt.Errorf("检查 HAProxy 节点 %s 失败: %w", node.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkHost 显示节点缺少的软件包、配置文件的 diff 和需要重启的服务
+func checkHost(host *nativeHost, packages []string, files []managedFile) error {
+	if missing := host.missingPackages(packages...); len(missing) > 0 {
+		fmt.Printf("➡️  将安装软件包: %s\n", strings.Join(missing, "、"))
+	}
+
+	changed, err := previewFiles(host, files, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		fmt.Println("✅ 所有配置文件与节点上的一致")
+		return nil
+	}
+	var restart []string
+	for _, f := range changed {
+		if f.Service != "" && !slices.Contains(restart, f.Service) {
+			restart = append(restart, f.Service)
+		}
+	}
+	if len(restart) == 0 {
+		fmt.Printf("ℹ️  %d 个配置文件将被修改\n", len(changed))
+		return nil
+	}
+	fmt.Printf("ℹ️  %d 个配置文件将被修改，将重启 %s\n", len(changed), strings.Join(restart, "、"))
+	return nil
+}
+
+// previewFiles 在 out 中显示每个会被修改的文件的 diff，返回这些文件
+func previewFiles(host *nativeHost, files []managedFile, out io.Writer) ([]managedFile, error) {
+	var changed []managedFile
+	for _, f := range files {
+		diff, err := host.diffFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if diff == "" {
+			fmt.Fprintf(out, "✔️  %s 无变化\n", f.Path)
+			continue
+		}
+		fmt.Fprintf(out, "➡️  %s 将被修改:\n%s", f.Path, diff)
+		changed = append(changed, f)
+	}
+	return changed, nil
+}
+
+// configureSecurity 与 playbook 相同，停用 firewalld 并关闭 SELinux
+func configureSecurity(host *nativeHost) error {
+	commands := []string{
+		recordSecurityStateCommand,
+		"if systemctl is-active --quiet firewalld; then systemctl stop firewalld; fi",
+		"if systemctl is-enabled --quiet firewalld 2>/dev/null; then systemctl disable firewalld; fi",
+		"if [ -f /etc/selinux/config ] && ! grep -q '^SELINUX=disabled' /etc/selinux/config; then sed -i 's/^SELINUX=.*/SELINUX=disabled/' /etc/selinux/config; fi",
+		"if [ \"$(getenforce 2>/dev/null)\" = Enforcing ]; then setenforce 0; fi",
+	}
+	for _, command := range commands {
+		if _, err := host.run(command); err != nil {
+			return fmt.Errorf("配置 firewalld 和 SELinux 失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// installClients 复制 downloads/bin 中的 oc 和 kubectl，只复制 Linux 版本
+func (p *NativeBastionProvisioner) installClients(host *nativeHost) {
+	if p.config.ClientOS() != "linux" {
+		fmt.Printf("ℹ️  downloads/bin 中的客户端工具为 %s 版本，跳过安装到 Bastion\n", p.config.ClientOS())
+		return
+	}
+	for _, name := range []string{"oc", "kubectl"} {
+		localPath := filepath.Join(p.downloadDir, "bin", name)
+		if !utils.FileExists(localPath) {
+			continue
+		}
+		changed, err := host.installBinary(localPath, "/usr/bin/"+name)
+		if err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+			continue
+		}
+		if changed {
+			fmt.Printf("✅ 已安装 /usr/bin/%s\n", name)
+		}
+	}
+}
+
+// verify 检查 HAProxy 统计页面和 API 记录的解析，Bastion 上没有 HAProxy 时只检查解析
+func (p *NativeBastionProvisioner) verify(host *nativeHost) error {
+	if p.config.LoadBalancerMode() == config.LoadBalancerBastionHAProxy {
+		if err := verifyHAProxy(host); err != nil {
+			return err
+		}
+	}
+	name := fmt.Sprintf("api.%s.%s", p.config.ClusterInfo.ClusterID, p.config.ClusterInfo.Domain)
+	output, err := host.run(fmt.Sprintf("dig +short @127.0.0.1 %s", name))
+	if err != nil || strings.TrimSpace(output) == "" {
+		fmt.Printf("⚠️  Bastion 上的 DNS 无法解析 %s，请检查 named 服务\n", name)
+	}
+	return nil
+}
+
+// verifyHAProxy 在节点上访问 HAProxy 统计页面
+func verifyHAProxy(host *nativeHost) error {
+	if _, err := host.run(fmt.Sprintf("curl -fsS -o /dev/null http://127.0.0.1:%d/stats", haproxyPort)); err != nil {
+		return fmt.Errorf("HAProxy 统计页面无法访问: %w", err)
+	}
+	return nil
+}
+
+// bastionTemplateVars 生成渲染 bastion 模板使用的变量
+func bastionTemplateVars(cfg *config.ClusterConfig, clusterDir string) (map[string]interface{}, error) {
+	return playbookTemplateVars(cfg, clusterDir, bastionAnsibleFiles, "ansible/bastion/playbook.yml")
+}
+
+// addPlaybookVars 按定义的顺序渲染 playbook 中 vars 定义的变量并加入 vars，后面的变量可以引用前面的
+func addPlaybookVars(files fs.FS, playbook string, vars map[string]interface{}) error {
+	data, err := fs.ReadFile(files, playbook)
+	if err != nil {
+		return fmt.Errorf("读取 %s 失败: %w", playbook, err)
+	}
+	var plays []struct {
+		Vars yaml.Node `yaml:"vars"`
+	}
+	if err := yaml.Unmarshal(data, &plays); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", playbook, err)
+	}
+	for _, play := range plays {
+		for i := 0; i+1 < len(play.Vars.Content); i += 2 {
+			name, value := play.Vars.Content[i].Value, play.Vars.Content[i+1].Value
+			rendered, err := renderJinja(name, value, vars)
+			if err != nil {
+				return err
+			}
+			vars[name] = rendered
+		}
+	}
+	return nil
+}
+
+// renderBastionTemplate 渲染 ansible/bastion/templates 中的模板，extra 中的变量覆盖 vars
+func renderBastionTemplate(name string, vars, extra map[string]interface{}) (string, error) {
+	return renderTemplate(bastionAnsibleFiles, "ansible/bastion/templates/"+name, vars, extra)
+}
+
+// renderTemplate 渲染 files 中的模板，extra 中的变量覆盖 vars
+func renderTemplate(files fs.FS, templatePath string, vars, extra map[string]interface{}) (string, error) {
+	name := path.Base(templatePath)
+	src, err := fs.ReadFile(files, templatePath)
+	if err != nil {
+		return "", fmt.Errorf("读取模板 %s 失败: %w", name, err)
+	}
+	scope := vars
+	if extra != nil {
+		scope = make(map[string]interface{}, len(vars)+len(extra))
+		for k, v := range vars {
+			scope[k] = v
+		}
+		for k, v := range extra {
+			scope[k] = v
+		}
+	}
+	content, err := renderJinja(name, string(src), scope)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content, nil
+}
+
+// templateFiles 收集渲染后的配置文件
+type templateFiles struct {
+	vars  map[string]interface{}
+	files []managedFile
+}
+
+func (t *templateFiles) add(template, dest, group, mode, service, validate string, extra map[string]interface{}) error {
+	content, err := renderBastionTemplate(template, t.vars, extra)
+	if err != nil {
+		return err
+	}
+	t.files = append(t.files, managedFile{Path: dest, Content: content, Owner: "root", Group: group, Mode: mode, Service: service, Validate: validate})
+	return nil
+}
+
+// renderBastionFiles 渲染 bastion playbook 管理的配置文件，只有 bastion-haproxy 模式包含 haproxy.cfg
+func renderBastionFiles(vars map[string]interface{}) ([]managedFile, error) {
+	t := &templateFiles{vars: vars}
+	if err := t.add("named.conf.j2", "/etc/named.conf", "named", "0640", "named", "named-checkconf %s", nil); err != nil {
+		return nil, err
+	}
+	zone := fmt.Sprintf("/var/named/%s.%s.zone", vars["cluster_id"], vars["cluster_domain"])
+	if err := t.add("forward.zone.j2", zone, "named", "0640", "named", "", nil); err != nil {
+		return nil, err
+	}
+	dns, _ := vars["dns"].(map[string]interface{})
+	reverseZones, _ := dns["reverse_zones"].([]interface{})
+	for _, rz := range reverseZones {
+		m, _ := rz.(map[string]interface{})
+		file, _ := m["file"].(string)
+		if file == "" {
+			return nil, errors.New("反向解析区域缺少文件名")
+		}
+		if err := t.add("reverse.zone.j2", "/var/named/"+file, "named", "0640", "named", "", map[string]interface{}{"reverse_zone": rz}); err != nil {
+			return nil, err
+		}
+	}
+	if lb, _ := vars["load_balancer"].(map[string]interface{}); lb["mode"] == config.LoadBalancerBastionHAProxy {
+		if err := t.add("haproxy.cfg.j2", haproxyConfigPath, "root", "0644", "haproxy", "haproxy -c -f %s", nil); err != nil {
+			return nil, err
+		}
+	}
+	return t.files, nil
+}
+
+// shellQuote 用单引号包裹参数
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func fileSHA256(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("打开 %s 失败: %w", filePath, err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("计算 %s 的 SHA256 失败: %w", filePath, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
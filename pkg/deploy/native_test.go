@@ -0,0 +1,270 @@
+package deploy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"ocpack/pkg/config"
+)
+
+// fakeShell 模拟远程主机的文件，记录执行的命令
+type fakeShell struct {
+	files    map[string]string
+	commands []string
+	failures map[string]error // 命令包含 key 时返回错误
+}
+
+var (
+	fakeReadPattern    = regexp.MustCompile(`if \[ -e '([^']+)' \]`)
+	fakeInstallPattern = regexp.MustCompile(`install -o \S+ -g \S+ -m \S+ '([^']+)' '([^']+)'`)
+	fakeRemovePattern  = regexp.MustCompile(`^rm -(?:f|rf --) '([^']+)'$`)
+)
+
+func (s *fakeShell) RunCommand(command string) (string, error) {
+	s.commands = append(s.commands, command)
+	if quoted, ok := strings.CutPrefix(command, "sudo -n sh -c "); ok {
+		command = strings.ReplaceAll(quoted[1:len(quoted)-1], `'\''`, "'")
+	}
+	for key, err := range s.failures {
+		if strings.Contains(command, key) {
+			return "", err
+		}
+	}
+	if m := fakeReadPattern.FindStringSubmatch(command); m != nil {
+		if content, ok := s.files[m[1]]; ok {
+			return "present\n" + content, nil
+		}
+		return "missing\n", nil
+	}
+	if strings.HasPrefix(command, "mktemp ") {
+		tmpPath := fmt.Sprintf("%s%010d", tempFilePrefix, len(s.commands))
+		s.files[tmpPath] = ""
+		return tmpPath + "\n", nil
+	}
+	if m := fakeRemovePattern.FindStringSubmatch(command); m != nil {
+		for p := range s.files {
+			if p == m[1] || strings.HasPrefix(p, m[1]+"/") {
+				delete(s.files, p)
+			}
+		}
+	}
+	if m := fakeInstallPattern.FindStringSubmatch(command); m != nil {
+		s.files[m[2]] = s.files[m[1]]
+		delete(s.files, m[1])
+	}
+	return "", nil
+}
+
+func (s *fakeShell) UploadString(content, remotePath string) error {
+	s.files[remotePath] = content
+	return nil
+}
+
+func (s *fakeShell) UploadFile(localPath, remotePath string) error {
+	s.files[remotePath] = "binary:" + localPath
+	return nil
+}
+
+func testBastionConfig() *config.ClusterConfig {
+	cfg := config.NewDefaultConfig("demo")
+	cfg.ClusterInfo.Domain = "example.com"
+	cfg.ClusterInfo.ClusterID = "demo"
+	cfg.Bastion.IP = "192.168.1.10"
+	cfg.Registry.IP = "192.168.1.11"
+	cfg.Cluster.Network.MachineNetwork = []string{"192.168.1.0/24"}
+	for i := range cfg.Cluster.ControlPlane {
+		cfg.Cluster.ControlPlane[i].IP = fmt.Sprintf("192.168.1.%d", 21+i)
+	}
+	for i := range cfg.Cluster.Worker {
+		cfg.Cluster.Worker[i].IP = fmt.Sprintf("192.168.1.%d", 31+i)
+	}
+	return cfg
+}
+
+func TestRenderBastionFiles(t *testing.T) {
+	vars, err := bastionTemplateVars(testBastionConfig(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["cluster_id"] != "demo" || vars["bastion_ip"] != "192.168.1.10" {
+		t.Errorf("playbook vars not rendered: cluster_id=%v bastion_ip=%v", vars["cluster_id"], vars["bastion_ip"])
+	}
+
+	files, err := renderBastionFiles(vars)
+	if err != nil {
+		t.Fatalf("renderBastionFiles() error = %v", err)
+	}
+	byPath := map[string]managedFile{}
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+
+	forward := byPath["/var/named/demo.example.com.zone"].Content
+	for _, want := range []string{"api IN  A   192.168.1.10\napi-int IN  A   192.168.1.10\n", "master-0 IN  A   192.168.1.21\n", "_etcd-server-ssl._tcp   IN  SRV 0 10 2380 master-0.demo.example.com.\n"} {
+		if !strings.Contains(forward, want) {
+			t.Errorf("forward zone missing %q:\n%s", want, forward)
+		}
+	}
+	if strings.Contains(forward, "AAAA") || strings.Contains(forward, "{%") {
+		t.Errorf("forward zone contains IPv6 records or template tags:\n%s", forward)
+	}
+	if reverse := byPath["/var/named/1.168.192.in-addr.arpa.zone"].Content; !strings.Contains(reverse, "21.1.168.192.in-addr.arpa.   IN  PTR master-0.demo.example.com.") {
+		t.Errorf("reverse zone:\n%s", reverse)
+	}
+	if named := byPath["/etc/named.conf"].Content; !strings.Contains(named, `zone "1.168.192.in-addr.arpa" IN {`) {
+		t.Errorf("named.conf missing reverse zone:\n%s", named)
+	}
+	haproxy := byPath["/etc/haproxy/haproxy.cfg"]
+	if !strings.Contains(haproxy.Content, "    server master-2 192.168.1.23:6443 check\n") || !strings.HasSuffix(haproxy.Content, "\n") {
+		t.Errorf("haproxy.cfg:\n%s", haproxy.Content)
+	}
+	if haproxy.Service != "haproxy" || haproxy.Validate == "" {
+		t.Errorf("haproxy.cfg managed file = %+v", haproxy)
+	}
+}
+
+func TestSyncFileIsIdempotent(t *testing.T) {
+	shell := &fakeShell{files: map[string]string{}}
+	host := &nativeHost{shell: shell, sudo: true}
+	f := managedFile{Path: "/etc/haproxy/haproxy.cfg", Content: "global\n", Owner: "root", Group: "root", Mode: "0644", Validate: "haproxy -c -f %s"}
+
+	changed, err := host.syncFile(f)
+	if err != nil || !changed {
+		t.Fatalf("first syncFile() = %v, %v", changed, err)
+	}
+	if shell.files[f.Path] != "global\n" {
+		t.Errorf("remote file = %q", shell.files[f.Path])
+	}
+	for _, command := range shell.commands {
+		// 临时文件由 SSH 用户创建，上传后才能写入
+		if !strings.HasPrefix(command, "sudo -n sh -c ") && !strings.HasPrefix(command, "mktemp ") {
+			t.Errorf("command not run through sudo: %s", command)
+		}
+	}
+	assertNoTempFiles(t, shell)
+
+	shell.commands = nil
+	changed, err = host.syncFile(f)
+	if err != nil || changed {
+		t.Errorf("second syncFile() = %v, %v", changed, err)
+	}
+	for _, command := range shell.commands {
+		if strings.Contains(command, "install ") {
+			t.Errorf("unchanged file rewritten: %s", command)
+		}
+	}
+
+	// 内容变化时先备份
+	f.Content = "global\n    daemon\n"
+	shell.commands = nil
+	if changed, err := host.syncFile(f); err != nil || !changed {
+		t.Fatalf("third syncFile() = %v, %v", changed, err)
+	}
+	if !strings.Contains(strings.Join(shell.commands, "\n"), `cp -p '\''/etc/haproxy/haproxy.cfg'\''`) {
+		t.Errorf("existing file not backed up: %v", shell.commands)
+	}
+}
+
+func TestSyncFileValidationFailure(t *testing.T) {
+	shell := &fakeShell{
+		files:    map[string]string{"/etc/haproxy/haproxy.cfg": "old\n"},
+		failures: map[string]error{"haproxy -c": fmt.Errorf("exit status 1")},
+	}
+	host := &nativeHost{shell: shell}
+	_, err := host.syncFile(managedFile{Path: "/etc/haproxy/haproxy.cfg", Content: "broken\n", Owner: "root", Group: "root", Mode: "0644", Validate: "haproxy -c -f %s"})
+	if err == nil {
+		t.Fatal("syncFile() should fail when validation fails")
+	}
+	if shell.files["/etc/haproxy/haproxy.cfg"] != "old\n" {
+		t.Errorf("invalid config installed: %q", shell.files["/etc/haproxy/haproxy.cfg"])
+	}
+	assertNoTempFiles(t, shell)
+}
+
+func TestSyncFileUniqueTempFiles(t *testing.T) {
+	// 同名的配置文件不能共用临时文件
+	shell := &fakeShell{files: map[string]string{}}
+	host := &nativeHost{shell: shell}
+	for _, dir := range []string{"/etc/haproxy", "/etc/haproxy-ingress"} {
+		f := managedFile{Path: dir + "/haproxy.cfg", Content: dir + "\n", Owner: "root", Group: "root", Mode: "0644"}
+		if _, err := host.syncFile(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var uploads []string
+	for _, command := range shell.commands {
+		if m := fakeInstallPattern.FindStringSubmatch(command); m != nil {
+			uploads = append(uploads, m[1])
+		}
+	}
+	if len(uploads) != 2 || uploads[0] == uploads[1] || !strings.HasPrefix(uploads[0], tempFilePrefix) {
+		t.Errorf("temp files = %v", uploads)
+	}
+	if shell.files["/etc/haproxy/haproxy.cfg"] != "/etc/haproxy\n" {
+		t.Errorf("haproxy.cfg = %q", shell.files["/etc/haproxy/haproxy.cfg"])
+	}
+
+	// 安装失败时也删除临时文件
+	shell = &fakeShell{files: map[string]string{}, failures: map[string]error{"install -o": fmt.Errorf("exit status 1")}}
+	if _, err := (&nativeHost{shell: shell}).syncFile(managedFile{Path: "/etc/named.conf", Content: "x", Owner: "root", Group: "named", Mode: "0640"}); err == nil {
+		t.Fatal("syncFile() should fail when install fails")
+	}
+	assertNoTempFiles(t, shell)
+}
+
+func assertNoTempFiles(t *testing.T, shell *fakeShell) {
+	t.Helper()
+	for p := range shell.files {
+		if strings.HasPrefix(p, tempFilePrefix) {
+			t.Errorf("temp file %s not removed", p)
+		}
+	}
+}
+
+func TestResolveEngine(t *testing.T) {
+	for _, engine := range []string{EngineNative, EngineAnsible} {
+		if got, err := ResolveEngine(engine); err != nil || got != engine {
+			t.Errorf("ResolveEngine(%s) = %s, %v", engine, got, err)
+		}
+	}
+	if _, err := ResolveEngine("puppet"); err == nil {
+		t.Error("ResolveEngine(puppet) should fail")
+	}
+}
+
+func TestPreviewFilesDoesNotModifyHost(t *testing.T) {
+	shell := &fakeShell{files: map[string]string{
+		"/etc/haproxy/haproxy.cfg": "global\n    daemon\n",
+		"/etc/named.conf":          "options {};\n",
+	}}
+	host := &nativeHost{shell: shell, sudo: true}
+	files := []managedFile{
+		{Path: "/etc/haproxy/haproxy.cfg", Content: "global\n    daemon\n    maxconn 4000\n", Service: "haproxy"},
+		{Path: "/etc/named.conf", Content: "options {};\n", Service: "named"},
+		{Path: "/var/named/demo.example.com.zone", Content: "$TTL 1W\n", Service: "named"},
+	}
+
+	var out strings.Builder
+	changed, err := previewFiles(host, files, &out)
+	if err != nil {
+		t.Fatalf("previewFiles() error = %v", err)
+	}
+	if len(changed) != 2 || changed[0].Path != "/etc/haproxy/haproxy.cfg" || changed[1].Path != "/var/named/demo.example.com.zone" {
+		t.Errorf("changed = %+v", changed)
+	}
+	for _, want := range []string{"+    maxconn 4000\n", "/etc/named.conf 无变化", "--- /dev/null\n+++ /var/named/demo.example.com.zone\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("preview missing %q:\n%s", want, out.String())
+		}
+	}
+	if len(shell.files) != 2 || shell.files["/etc/haproxy/haproxy.cfg"] != "global\n    daemon\n" {
+		t.Errorf("remote files modified: %v", shell.files)
+	}
+	for _, command := range shell.commands {
+		if !strings.Contains(command, "if [ -e ") {
+			t.Errorf("unexpected command in check mode: %s", command)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package deploy
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// playbookTask playbook 中写入文件的任务，检查模式按它们在本地计算节点上的配置文件
+type playbookTask struct {
+	Name        string        `yaml:"name"`
+	When        string        `yaml:"when"`
+	Notify      string        `yaml:"notify"`
+	Loop        interface{}   `yaml:"loop"`
+	Template    *fileTaskArgs `yaml:"template"`
+	Copy        *fileTaskArgs `yaml:"copy"`
+	LineInFile  *fileTaskArgs `yaml:"lineinfile"`
+	BlockInFile *fileTaskArgs `yaml:"blockinfile"`
+}
+
+// fileTaskArgs template、copy、lineinfile 和 blockinfile 模块的参数
+type fileTaskArgs struct {
+	Src     string `yaml:"src"`
+	Dest    string `yaml:"dest"`
+	Path    string `yaml:"path"`
+	Content string `yaml:"content"`
+	Owner   string `yaml:"owner"`
+	Group   string `yaml:"group"`
+	Mode    string `yaml:"mode"`
+	Regexp  string `yaml:"regexp"`
+	Line    string `yaml:"line"`
+	Block   string `yaml:"block"`
+	Marker  string `yaml:"marker"`
+}
+
+// playbookTemplateVars 生成渲染 playbook 模板使用的变量: vars.yml 的内容加上 playbook 中定义的变量
+func playbookTemplateVars(cfg *config.ClusterConfig, clusterDir string, files fs.FS, playbook string) (map[string]interface{}, error) {
+	absDir, err := filepath.Abs(clusterDir)
+	if err != nil {
+		return nil, fmt.Errorf("获取集群目录失败: %w", err)
+	}
+	data, err := buildAnsibleVars(cfg, filepath.Dir(absDir), filepath.Base(absDir))
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("解析变量失败: %w", err)
+	}
+	if err := addPlaybookVars(files, playbook, vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// playbookFiles 返回 dir/playbook.yml 写入的配置文件。template 和带 content 的 copy 在本地渲染，
+// lineinfile 和 blockinfile 在读取节点上的文件后按 Ansible 的规则修改。when 无法在本地求值的任务
+// (依赖 register 或 facts) 和从节点或本地复制文件的任务不包含在内
+func playbookFiles(files fs.FS, dir string, vars map[string]interface{}) ([]managedFile, error) {
+	playbook := dir + "/playbook.yml"
+	data, err := fs.ReadFile(files, playbook)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", playbook, err)
+	}
+	var plays []struct {
+		Tasks []playbookTask `yaml:"tasks"`
+	}
+	if err := yaml.Unmarshal(data, &plays); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", playbook, err)
+	}
+
+	var result []managedFile
+	index := map[string]int{}
+	for _, play := range plays {
+		for _, task := range play.Tasks {
+			if task.Loop != nil || !taskEnabled(task.When, vars) {
+				continue
+			}
+			f, ok, err := taskFile(files, dir, task, vars)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", task.Name, err)
+			}
+			if !ok {
+				continue
+			}
+			// 同一个文件的多个任务依次修改
+			if i, seen := index[f.Path]; seen {
+				result[i] = mergeFileTasks(result[i], f)
+				continue
+			}
+			index[f.Path] = len(result)
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+// taskEnabled 在本地求值 when 条件，无法求值或引用了未定义的变量时视为不执行
+func taskEnabled(when string, vars map[string]interface{}) bool {
+	if when == "" {
+		return true
+	}
+	expr, err := parseJinjaExpr(when)
+	if err != nil {
+		return false
+	}
+	v, err := expr.eval(&jinjaContext{vars: vars})
+	if err != nil {
+		return false
+	}
+	return jinjaTruthy(v)
+}
+
+// taskFile 把一个任务转换为 managedFile，任务不写入文件内容时返回 false
+func taskFile(files fs.FS, dir string, task playbookTask, vars map[string]interface{}) (managedFile, bool, error) {
+	var args fileTaskArgs
+	switch {
+	case task.Template != nil:
+		args = *task.Template
+	case task.Copy != nil && task.Copy.Content != "":
+		args = *task.Copy
+	case task.LineInFile != nil:
+		args = *task.LineInFile
+	case task.BlockInFile != nil:
+		args = *task.BlockInFile
+	default:
+		return managedFile{}, false, nil
+	}
+	rendered, err := renderTaskArgs(args, vars)
+	if err != nil {
+		return managedFile{}, false, err
+	}
+	f := managedFile{Owner: rendered.Owner, Group: rendered.Group, Mode: rendered.Mode, Service: strings.TrimPrefix(task.Notify, "restart ")}
+
+	switch {
+	case task.Template != nil:
+		f.Path = rendered.Dest
+		f.Content, err = renderTemplate(files, dir+"/templates/"+rendered.Src, vars, nil)
+	case task.Copy != nil:
+		f.Path, f.Content = rendered.Dest, rendered.Content
+	case task.LineInFile != nil:
+		f.Path = rendered.Path
+		f.Edit, err = lineInFile(rendered.Regexp, rendered.Line)
+	default:
+		f.Path = rendered.Path
+		f.Edit = blockInFile(rendered.Marker, rendered.Block)
+	}
+	return f, err == nil, err
+}
+
+// renderTaskArgs 渲染任务参数中的 Jinja 表达式
+func renderTaskArgs(args fileTaskArgs, vars map[string]interface{}) (fileTaskArgs, error) {
+	fields := []*string{&args.Src, &args.Dest, &args.Path, &args.Content, &args.Owner, &args.Group, &args.Mode, &args.Regexp, &args.Line, &args.Block, &args.Marker}
+	for _, field := range fields {
+		rendered, err := renderJinja("task", *field, vars)
+		if err != nil {
+			return args, err
+		}
+		*field = rendered
+	}
+	return args, nil
+}
+
+// mergeFileTasks 合并修改同一个文件的两个任务，next 在 prev 的结果上修改
+func mergeFileTasks(prev, next managedFile) managedFile {
+	first, second := prev.contentEdit(), next.contentEdit()
+	merged := prev
+	merged.Content = ""
+	merged.Edit = func(current string) (string, error) {
+		content, err := first(current)
+		if err != nil {
+			return "", err
+		}
+		return second(content)
+	}
+	if merged.Service == "" {
+		merged.Service = next.Service
+	}
+	return merged
+}
+
+// lineInFile 与 Ansible lineinfile 模块相同: 替换最后一个匹配 regexp 的行，
+// 没有匹配且文件中没有 line 时把 line 追加到文件末尾
+func lineInFile(pattern, line string) (func(string) (string, error), error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("lineinfile 的正则表达式 %q 无效: %w", pattern, err)
+	}
+	return func(current string) (string, error) {
+		lines := fileLines(current)
+		match := -1
+		for i, l := range lines {
+			if re.MatchString(l) {
+				match = i
+			}
+		}
+		switch {
+		case match >= 0:
+			lines[match] = line
+		case !slices.Contains(lines, line):
+			lines = append(lines, line)
+		}
+		return joinFileLines(lines), nil
+	}, nil
+}
+
+// blockInFile 与 Ansible blockinfile 模块相同: 替换 BEGIN 和 END 标记之间的内容，
+// 文件中没有标记时把标记和 block 追加到文件末尾
+func blockInFile(marker, block string) func(string) (string, error) {
+	if marker == "" {
+		marker = "# {mark} ANSIBLE MANAGED BLOCK"
+	}
+	begin := strings.ReplaceAll(marker, "{mark}", "BEGIN")
+	end := strings.ReplaceAll(marker, "{mark}", "END")
+	managed := append(append([]string{begin}, fileLines(block)...), end)
+	return func(current string) (string, error) {
+		lines := fileLines(current)
+		first, last := -1, -1
+		for i, l := range lines {
+			switch strings.TrimRight(l, " \t") {
+			case begin:
+				first = i
+			case end:
+				last = i
+			}
+		}
+		if first >= 0 && last > first {
+			lines = append(append(lines[:first:first], managed...), lines[last+1:]...)
+		} else {
+			lines = append(lines, managed...)
+		}
+		return joinFileLines(lines), nil
+	}
+}
+
+// fileLines 按行拆分文件内容，与 Ansible 相同，最后一行没有换行时按有换行处理
+func fileLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func joinFileLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// checkPlaybook 在本地渲染 playbook 写入的配置文件，通过 SSH 显示它们与节点上的文件的 diff，不修改节点
+func checkPlaybook(cfg *config.ClusterConfig, clusterDir string, files fs.FS, dir string, sshOpts utils.SSHOptions, sudo bool) error {
+	vars, err := playbookTemplateVars(cfg, clusterDir, files, dir+"/playbook.yml")
+	if err != nil {
+		return err
+	}
+	managed, err := playbookFiles(files, dir, vars)
+	if err != nil {
+		return err
+	}
+
+	client, err := utils.NewSSHClient(sshOpts)
+	if err != nil {
+		return fmt.Errorf("连接 %s 失败: %w", sshOpts.Host, err)
+	}
+	defer client.Close()
+	return checkHost(&nativeHost{shell: client, sudo: sudo}, nil, managed)
+}
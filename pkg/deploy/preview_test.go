@@ -0,0 +1,149 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"ocpack/pkg/config"
+)
+
+func TestPlaybookFilesPXE(t *testing.T) {
+	cfg := testBastionConfig()
+	for i := range cfg.Cluster.ControlPlane {
+		cfg.Cluster.ControlPlane[i].MAC = fmt.Sprintf("52:54:00:00:00:%02d", 21+i)
+	}
+	for i := range cfg.Cluster.Worker {
+		cfg.Cluster.Worker[i].MAC = fmt.Sprintf("52:54:00:00:00:%02d", 31+i)
+	}
+	vars, err := playbookTemplateVars(cfg, t.TempDir(), pxeAnsibleFiles, "ansible/pxe/playbook.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := playbookFiles(pxeAnsibleFiles, "ansible/pxe", vars)
+	if err != nil {
+		t.Fatalf("playbookFiles() error = %v", err)
+	}
+	byPath := map[string]managedFile{}
+	var paths []string
+	for _, f := range files {
+		byPath[f.Path] = f
+		paths = append(paths, f.Path)
+	}
+	want := []string{
+		"/etc/httpd/conf/httpd.conf",
+		"/etc/systemd/system/tftp.service",
+		"/etc/systemd/system/tftp.socket",
+		"/var/lib/tftpboot/pxelinux.cfg/default",
+		"/etc/dhcp/dhcpd.conf",
+		"/usr/local/bin/upload-pxe-files.sh",
+	}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", paths, want)
+	}
+
+	dhcpd := byPath["/etc/dhcp/dhcpd.conf"]
+	if dhcpd.Service != "dhcpd" || dhcpd.Mode != "0644" {
+		t.Errorf("dhcpd.conf service=%q mode=%q", dhcpd.Service, dhcpd.Mode)
+	}
+	for _, want := range []string{"subnet 192.168.1.0 netmask 255.255.255.0 {\n", "range 192.168.1.100 192.168.1.200;\n", "option routers 192.168.1.1;\n", "hardware ethernet 52:54:00:00:00:21;\n    fixed-address 192.168.1.21;\n"} {
+		if !strings.Contains(dhcpd.Content, want) {
+			t.Errorf("dhcpd.conf missing %q:\n%s", want, dhcpd.Content)
+		}
+	}
+	if byPath["/usr/local/bin/upload-pxe-files.sh"].Mode != "0755" {
+		t.Errorf("upload script mode = %q", byPath["/usr/local/bin/upload-pxe-files.sh"].Mode)
+	}
+	if !strings.Contains(byPath["/etc/systemd/system/tftp.socket"].Content, "ListenDatagram=69\n") {
+		t.Errorf("tftp.socket = %q", byPath["/etc/systemd/system/tftp.socket"].Content)
+	}
+
+	// lineinfile 和 blockinfile 依次修改 httpd.conf，再次执行时没有变化
+	httpd := byPath["/etc/httpd/conf/httpd.conf"]
+	got, err := httpd.contentEdit()("ServerRoot \"/etc/httpd\"\nListen 80\nInclude conf.modules.d/*.conf\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHTTPD := "ServerRoot \"/etc/httpd\"\nListen 8080\nInclude conf.modules.d/*.conf\n" +
+		"# BEGIN ANSIBLE MANAGED BLOCK - PXE Directory\n<Directory \"/var/www/html/pxe\">\n    Options Indexes FollowSymLinks\n    AllowOverride None\n    Require all granted\n</Directory>\n# END ANSIBLE MANAGED BLOCK - PXE Directory\n"
+	if got != wantHTTPD || httpd.Service != "httpd" {
+		t.Errorf("httpd.conf = %q (service %q), want %q", got, httpd.Service, wantHTTPD)
+	}
+	if again, _ := httpd.contentEdit()(got); again != got {
+		t.Errorf("httpd.conf edit is not idempotent:\n%s", again)
+	}
+}
+
+func TestPlaybookFilesRegistry(t *testing.T) {
+	for _, mode := range []string{config.SecurityModeDisabled, config.SecurityModeHardened} {
+		cfg := testBastionConfig()
+		cfg.Security.Mode = mode
+		vars, err := playbookTemplateVars(cfg, t.TempDir(), registryAnsibleFiles, "ansible/registry/playbook.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := playbookFiles(registryAnsibleFiles, "ansible/registry", vars)
+		if err != nil {
+			t.Fatalf("%s: playbookFiles() error = %v", mode, err)
+		}
+		shell := &fakeShell{files: map[string]string{
+			"/etc/resolv.conf":    "search example.com\nnameserver 8.8.8.8\n",
+			"/etc/hosts":          "127.0.0.1 localhost\n192.168.1.11 old-name\n",
+			"/etc/selinux/config": "SELINUX=enforcing\nSELINUXTYPE=targeted\n",
+		}}
+		var out strings.Builder
+		changed, err := previewFiles(&nativeHost{shell: shell}, files, &out)
+		if err != nil {
+			t.Fatalf("%s: previewFiles() error = %v", mode, err)
+		}
+		wantChanged := 3
+		if mode == config.SecurityModeHardened {
+			wantChanged = 2
+		}
+		if len(changed) != wantChanged {
+			t.Errorf("%s: %d files changed, want %d:\n%s", mode, len(changed), wantChanged, out.String())
+		}
+		for _, want := range []string{"-nameserver 8.8.8.8\n+nameserver 192.168.1.10\n", "+192.168.1.11 registry.demo.example.com registry\n"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s: preview missing %q:\n%s", mode, want, out.String())
+			}
+		}
+		if selinux := strings.Contains(out.String(), "+SELINUX=disabled\n"); selinux != (mode == config.SecurityModeDisabled) {
+			t.Errorf("%s: SELinux diff shown = %v:\n%s", mode, selinux, out.String())
+		}
+		if shell.files["/etc/hosts"] != "127.0.0.1 localhost\n192.168.1.11 old-name\n" {
+			t.Errorf("%s: remote files modified: %v", mode, shell.files)
+		}
+	}
+}
+
+func TestLineInFile(t *testing.T) {
+	tests := []struct {
+		name, current, want string
+	}{
+		{"replace last match", "nameserver 1.1.1.1\nnameserver 8.8.8.8\n", "nameserver 1.1.1.1\nnameserver 10.0.0.1\n"},
+		{"append", "search example.com", "search example.com\nnameserver 10.0.0.1\n"},
+		{"missing file", "", "nameserver 10.0.0.1\n"},
+	}
+	edit, err := lineInFile("^nameserver", "nameserver 10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if got, _ := edit(tt.current); got != tt.want {
+			t.Errorf("%s: lineInFile() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if _, err := lineInFile("(", "x"); err == nil {
+		t.Error("lineInFile() with invalid regexp succeeded")
+	}
+}
+
+func TestBlockInFile(t *testing.T) {
+	edit := blockInFile("", "a\nb\n")
+	current := "before\n# BEGIN ANSIBLE MANAGED BLOCK\nold\n# END ANSIBLE MANAGED BLOCK\nafter\n"
+	want := "before\n# BEGIN ANSIBLE MANAGED BLOCK\na\nb\n# END ANSIBLE MANAGED BLOCK\nafter\n"
+	if got, _ := edit(current); got != want {
+		t.Errorf("blockInFile() = %q, want %q", got, want)
+	}
+}
@@ -2,6 +2,7 @@ package deploy
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"ocpack/pkg/config"
 )
@@ -10,6 +11,7 @@ import (
 type PXEDeployer struct {
 	config      *config.ClusterConfig
 	downloadDir string
+	DeployOptions
 }
 
 // NewPXEDeployer 创建一个新的 PXE 部署器
@@ -24,17 +26,35 @@ func NewPXEDeployer(cfg *config.ClusterConfig, downloadDir string) *PXEDeployer
 func (d *PXEDeployer) Deploy(configFilePath string) error {
 	fmt.Printf("开始在 Bastion 节点 (%s) 上部署 PXE 服务...\n", d.config.Bastion.IP)
 
+	if d.Check {
+		fmt.Println("➡️  检查模式: 在本地渲染 PXE 配置文件并与 Bastion 节点上的比较...")
+		clusterDir := filepath.Dir(configFilePath)
+		if err := checkPlaybook(d.config, clusterDir, pxeAnsibleFiles, "ansible/pxe", d.config.BastionSSH(clusterDir), d.config.Bastion.Username != "root"); err != nil {
+			return fmt.Errorf("检查 PXE 配置文件失败: %w", err)
+		}
+	}
+
 	// 使用 Ansible 执行器进行部署
 	executor, err := NewAnsibleExecutor(d.config, configFilePath)
 	if err != nil {
 		return fmt.Errorf("创建 Ansible 执行器失败: %w", err)
 	}
 	defer executor.Cleanup()
+	executor.LogFile = d.LogFile
+	executor.Check = d.Check
 
 	// 执行 PXE playbook
 	if err := executor.RunPXEPlaybook(); err != nil {
 		return fmt.Errorf("PXE 服务部署失败: %w", err)
 	}
+	if d.Check {
+		return nil
+	}
+	if d.config.SecurityMode() == config.SecurityModeHardened {
+		if err := VerifyPXEServices(d.config); err != nil {
+			return fmt.Errorf("PXE 服务部署后验证失败: %w", err)
+		}
+	}
 
 	fmt.Println("PXE 服务部署完成！")
 	fmt.Printf("PXE 服务器: %s\n", d.config.Bastion.IP)
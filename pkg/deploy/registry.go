@@ -4,25 +4,43 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"time"
 
 	"ocpack/pkg/config"
+	"ocpack/pkg/credentials"
+	"ocpack/pkg/preflight"
 )
 
 // --- Constants ---
 // 优化: 将硬编码的值定义为常量
 const (
-	registryPort            = "8443"
-	registryHealthEndpoint  = "/health/instance"
-	registryDefaultPassword = "ztesoft123"
+	registryPort           = "8443"
+	registryHealthEndpoint = "/health/instance"
 )
 
+// DeployOptions 部署命令的通用选项
+type DeployOptions struct {
+	// LogFile 保存 Ansible 完整的执行记录，相对路径相对于集群目录
+	LogFile string
+	// Check 只显示部署会做的修改 (配置文件的 diff)，不修改节点
+	Check bool
+}
+
 // DeployRegistry 部署 Registry 节点，如果它尚未部署。
-func DeployRegistry(cfg *config.ClusterConfig, configFilePath string) error {
+func DeployRegistry(cfg *config.ClusterConfig, configFilePath string, opts ...DeployOptions) error {
+	var opt DeployOptions
+	for _, o := range opts {
+		opt = o
+	}
 	fmt.Println("▶️  开始部署 Registry 节点...")
 
-	// 1. 验证配置
-	if err := config.ValidateRegistryConfig(cfg); err != nil {
+	// 1. 验证配置和下载文件
+	result := preflight.Check(cfg, filepath.Dir(configFilePath), config.StageRegistry)
+	for _, warning := range result.Warnings() {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	if err := result.Err(); err != nil {
 		return fmt.Errorf("配置验证失败: %w", err)
 	}
 
@@ -33,7 +51,7 @@ func DeployRegistry(cfg *config.ClusterConfig, configFilePath string) error {
 	deployed, err := checkRegistryDeployed(cfg)
 	if err == nil && deployed {
 		fmt.Println("🔄 Registry 节点已经部署并运行。跳过重复部署。")
-		printSuccessMessage(cfg) // 优化: 调用统一的成功消息函数
+		printSuccessMessage(cfg, configFilePath) // 优化: 调用统一的成功消息函数
 		return nil
 	}
 
@@ -45,19 +63,41 @@ func DeployRegistry(cfg *config.ClusterConfig, configFilePath string) error {
 	// 3. 执行部署
 	fmt.Printf("🚀 Registry 未部署或不可访问，开始执行部署 playbook (%s)...\n", cfg.Registry.IP)
 
+	if opt.Check {
+		fmt.Println("➡️  检查模式: 在本地计算配置文件的修改并与 Registry 节点上的比较...")
+		clusterDir := filepath.Dir(configFilePath)
+		if err := checkPlaybook(cfg, clusterDir, registryAnsibleFiles, "ansible/registry", cfg.RegistrySSH(clusterDir), cfg.Registry.Username != "root"); err != nil {
+			return fmt.Errorf("检查 Registry 配置文件失败: %w", err)
+		}
+	}
+
 	// 创建 Ansible 执行器
 	executor, err := NewAnsibleExecutor(cfg, configFilePath)
 	if err != nil {
 		return fmt.Errorf("创建 Ansible 执行器失败: %w", err)
 	}
 	defer executor.Cleanup()
+	executor.LogFile = opt.LogFile
+	executor.Check = opt.Check
 
 	// 执行 Registry playbook
 	if err := executor.RunRegistryPlaybook(); err != nil {
 		return fmt.Errorf("Registry 节点部署失败: %w", err)
 	}
+	if opt.Check {
+		return nil
+	}
+	if cfg.SecurityMode() == config.SecurityModeHardened {
+		deployed, err := checkRegistryDeployed(cfg)
+		if err == nil && !deployed {
+			err = fmt.Errorf("health check 未返回 200")
+		}
+		if err != nil {
+			return fmt.Errorf("Registry 部署后验证失败，请检查 firewalld: %w", err)
+		}
+	}
 
-	printSuccessMessage(cfg) // 优化: 调用统一的成功消息函数
+	printSuccessMessage(cfg, configFilePath) // 优化: 调用统一的成功消息函数
 	return nil
 }
 
@@ -88,12 +128,12 @@ func checkRegistryDeployed(cfg *config.ClusterConfig) (bool, error) {
 
 // printSuccessMessage 打印部署成功后的信息。
 // 优化: 提取重复代码到此函数中。
-func printSuccessMessage(cfg *config.ClusterConfig) {
+func printSuccessMessage(cfg *config.ClusterConfig, configFilePath string) {
 	registryURL := fmt.Sprintf("https://%s:%s", cfg.Registry.IP, registryPort)
 	fmt.Println("✅ Registry 部署完成！")
 	fmt.Printf("   Quay 镜像仓库: %s\n", registryURL)
 	fmt.Printf("   用户名: %s\n", cfg.Registry.RegistryUser)
-	fmt.Printf("   密码: %s\n", registryDefaultPassword)
+	fmt.Printf("   密码: 保存在 %s\n", credentials.RegistryLocation(filepath.Dir(configFilePath)))
 }
 
 /*
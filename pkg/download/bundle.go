@@ -0,0 +1,435 @@
+package download
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/utils"
+)
+
+// --- Constants ---
+const (
+	bundleFormat       = 1
+	bundleManifestName = "bundle.json"
+	bundleConfigName   = "config.toml"
+	bundleDigestSuffix = ".sha256"
+)
+
+// BundleManifest 离线下载包的自描述信息，是归档中的第一个文件
+type BundleManifest struct {
+	Format           int          `json:"format"`
+	Cluster          string       `json:"cluster"`
+	OpenShiftVersion string       `json:"openshift_version"`
+	TargetArch       string       `json:"target_arch"`
+	ClientOS         string       `json:"client_os"`
+	ClientArch       string       `json:"client_arch"`
+	CreatedAt        time.Time    `json:"created_at"`
+	Files            []BundleFile `json:"files"`
+}
+
+// BundleFile 离线下载包中的一个文件，路径相对于集群目录
+type BundleFile struct {
+	Path     string `json:"path"`
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Mode     uint32 `json:"mode"`
+	Verified string `json:"verified,omitempty"` // 下载时的校验方式，只有下载的文件才有
+}
+
+// ImportOptions 导入离线下载包的选项
+type ImportOptions struct {
+	Force bool // 覆盖集群目录中已存在的文件
+}
+
+// ExportBundle 把下载的文件、downloads/bin 中的工具、下载清单、config.toml 和 version.lock 打包为一个 tar 归档 (ocpack download --bundle)。
+// 下载的文件在打包前按清单中已校验的摘要重新校验；同时在归档旁写入 <归档>.sha256，用于在隔离网络中核对整个归档。
+func ExportBundle(cfg *config.ClusterConfig, clusterDir, bundlePath string) error {
+	fmt.Printf("📦 正在创建离线下载包 %s...\n", bundlePath)
+
+	downloadDir := filepath.Join(clusterDir, cfg.Download.LocalPath)
+	manifest, err := LoadManifest(downloadDir)
+	if err != nil {
+		return err
+	}
+	if len(manifest.Files) == 0 {
+		return fmt.Errorf("下载清单 %s 为空，请先运行 'ocpack download'", ManifestPath(downloadDir))
+	}
+
+	bundle, err := newBundleManifest(cfg, clusterDir, downloadDir, manifest)
+	if err != nil {
+		return err
+	}
+	if err := writeBundle(bundle, clusterDir, bundlePath); err != nil {
+		os.Remove(bundlePath)
+		return err
+	}
+
+	digest, size, err := fileSHA256(bundlePath)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%s  %s\n", digest, filepath.Base(bundlePath))
+	if err := os.WriteFile(bundlePath+bundleDigestSuffix, []byte(line), 0644); err != nil {
+		return fmt.Errorf("写入摘要文件失败: %w", err)
+	}
+
+	fmt.Printf("✅ 离线下载包已创建: %s (%d 个文件，%s)\n", bundlePath, len(bundle.Files), formatBytes(size))
+	fmt.Printf("   sha256: %s\n", digest)
+	fmt.Printf("   请将 %s 和 %s 一起传输，在隔离网络中运行 'ocpack import-bundle'\n", filepath.Base(bundlePath), filepath.Base(bundlePath)+bundleDigestSuffix)
+	return nil
+}
+
+// newBundleManifest 收集需要打包的文件并计算摘要
+func newBundleManifest(cfg *config.ClusterConfig, clusterDir, downloadDir string, manifest *Manifest) (*BundleManifest, error) {
+	p, err := newPlatform(cfg)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &BundleManifest{
+		Format:           bundleFormat,
+		Cluster:          cfg.ClusterInfo.Name,
+		OpenShiftVersion: cfg.ClusterInfo.OpenShiftVersion,
+		TargetArch:       p.targetArch,
+		ClientOS:         p.os,
+		ClientArch:       p.arch,
+		CreatedAt:        time.Now().UTC(),
+	}
+
+	add := func(file, verified string) error {
+		digest, size, err := fileSHA256(file)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(clusterDir, file)
+		if err != nil {
+			return err
+		}
+		bundle.Files = append(bundle.Files, BundleFile{
+			Path:     filepath.ToSlash(rel),
+			SHA256:   digest,
+			Size:     size,
+			Mode:     uint32(info.Mode().Perm()),
+			Verified: verified,
+		})
+		return nil
+	}
+
+	if err := add(filepath.Join(clusterDir, bundleConfigName), ""); err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", bundleConfigName, err)
+	}
+	if lockPath := filepath.Join(clusterDir, config.VersionLockFilename); utils.FileExists(lockPath) {
+		if err := add(lockPath, ""); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(ManifestPath(downloadDir), ""); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(manifest.Files))
+	for name := range manifest.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		entry := manifest.Files[name]
+		if err := add(filepath.Join(downloadDir, name), entry.Verified); err != nil {
+			return nil, fmt.Errorf("打包 %s 失败: %w", name, err)
+		}
+		if got := bundle.Files[len(bundle.Files)-1].SHA256; got != entry.SHA256 {
+			return nil, fmt.Errorf("%s 的摘要与下载时记录的不一致 (期望 %s，实际 %s)，请重新运行 'ocpack download'", name, entry.SHA256, got)
+		}
+	}
+
+	binDir := filepath.Join(downloadDir, "bin")
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败，请先运行 'ocpack download': %w", binDir, err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			if err := add(filepath.Join(binDir, entry.Name()), ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return bundle, nil
+}
+
+// writeBundle 写入归档: bundle.json 在最前面，之后是清单中的文件
+func writeBundle(bundle *BundleManifest, clusterDir, bundlePath string) error {
+	out, err := os.Create(bundlePath)
+	if err != nil {
+		return fmt.Errorf("创建离线下载包失败: %w", err)
+	}
+	defer out.Close()
+
+	buffered := bufio.NewWriterSize(out, 1<<20)
+	tw := tar.NewWriter(buffered)
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 %s 失败: %w", bundleManifestName, err)
+	}
+	header := &tar.Header{Name: bundleManifestName, Mode: 0644, Size: int64(len(data)), ModTime: bundle.CreatedAt, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", bundleManifestName, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", bundleManifestName, err)
+	}
+
+	for _, file := range bundle.Files {
+		if err := addBundleFile(tw, clusterDir, file); err != nil {
+			return err
+		}
+		fmt.Printf("   + %s (%s)\n", file.Path, formatBytes(file.Size))
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("写入离线下载包失败: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("写入离线下载包失败: %w", err)
+	}
+	return out.Close()
+}
+
+func addBundleFile(tw *tar.Writer, clusterDir string, file BundleFile) error {
+	in, err := os.Open(filepath.Join(clusterDir, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return fmt.Errorf("打开 %s 失败: %w", file.Path, err)
+	}
+	defer in.Close()
+
+	header := &tar.Header{Name: file.Path, Mode: int64(file.Mode), Size: file.Size, ModTime: time.Now(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", file.Path, err)
+	}
+	// 文件在计算摘要后发生变化时大小不一致，tar.Writer 会报错
+	if _, err := io.Copy(tw, in); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", file.Path, err)
+	}
+	return nil
+}
+
+// ImportBundle 在隔离网络中解包 ocpack download --bundle 生成的归档 (ocpack import-bundle)。
+// 归档旁存在 <归档>.sha256 时先核对整个归档；每个文件按 bundle.json 中的摘要校验后才写入
+// <projectRoot>/<集群名>，下载的文件还要与下载清单中已校验的摘要一致。返回集群目录。
+func ImportBundle(bundlePath, projectRoot string, opts ImportOptions) (string, error) {
+	fmt.Printf("📦 正在导入离线下载包 %s...\n", bundlePath)
+
+	if err := verifyBundleDigest(bundlePath); err != nil {
+		return "", err
+	}
+
+	in, err := os.Open(bundlePath)
+	if err != nil {
+		return "", fmt.Errorf("打开离线下载包失败: %w", err)
+	}
+	defer in.Close()
+	tr := tar.NewReader(bufio.NewReaderSize(in, 1<<20))
+
+	bundle, err := readBundleManifest(tr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("ℹ️  集群 %s，OpenShift %s，集群架构 %s，客户端 %s/%s，创建于 %s\n",
+		bundle.Cluster, bundle.OpenShiftVersion, bundle.TargetArch, bundle.ClientOS, bundle.ClientArch, bundle.CreatedAt.Format(time.RFC3339))
+
+	clusterDir := filepath.Join(projectRoot, bundle.Cluster)
+	files := map[string]BundleFile{}
+	for _, file := range bundle.Files {
+		if _, err := bundleDest(clusterDir, file.Path); err != nil {
+			return "", err
+		}
+		files[file.Path] = file
+		if !opts.Force {
+			if _, err := os.Stat(filepath.Join(clusterDir, filepath.FromSlash(file.Path))); err == nil {
+				return "", fmt.Errorf("%s 已存在，使用 --force 覆盖", filepath.Join(clusterDir, filepath.FromSlash(file.Path)))
+			}
+		}
+	}
+
+	extracted := map[string]bool{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("读取离线下载包失败: %w", err)
+		}
+		file, ok := files[header.Name]
+		if !ok || header.Typeflag != tar.TypeReg || extracted[header.Name] {
+			return "", fmt.Errorf("离线下载包中包含 %s 中未列出的文件: %s", bundleManifestName, header.Name)
+		}
+		if err := extractBundleFile(tr, clusterDir, file); err != nil {
+			return "", err
+		}
+		extracted[header.Name] = true
+		fmt.Printf("   ✓ %s\n", file.Path)
+	}
+	for _, file := range bundle.Files {
+		if !extracted[file.Path] {
+			return "", fmt.Errorf("离线下载包不完整，缺少 %s", file.Path)
+		}
+	}
+
+	if err := verifyImportedDownloads(bundle, clusterDir); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("✅ 已导入 %d 个文件到 %s\n", len(bundle.Files), clusterDir)
+	return clusterDir, nil
+}
+
+// verifyBundleDigest 按归档旁的 .sha256 文件核对整个归档，文件不存在时跳过
+func verifyBundleDigest(bundlePath string) error {
+	data, err := os.ReadFile(bundlePath + bundleDigestSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("⚠️  未找到 %s，跳过整个归档的摘要校验，仅校验每个文件\n", filepath.Base(bundlePath)+bundleDigestSuffix)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取摘要文件失败: %w", err)
+	}
+	sums, err := parseChecksums(data)
+	if err != nil {
+		return err
+	}
+	expected, ok := sums[filepath.Base(bundlePath)]
+	if !ok {
+		// 归档传输后改过名时使用唯一的摘要
+		if len(sums) != 1 {
+			return fmt.Errorf("%s 中没有 %s 的摘要", bundlePath+bundleDigestSuffix, filepath.Base(bundlePath))
+		}
+		for _, digest := range sums {
+			expected = digest
+		}
+	}
+
+	fmt.Println("🔒 正在校验离线下载包摘要...")
+	digest, _, err := fileSHA256(bundlePath)
+	if err != nil {
+		return err
+	}
+	if digest != expected {
+		return fmt.Errorf("离线下载包的 sha256 摘要不匹配 (期望 %s，实际 %s)，文件可能在传输中损坏", expected, digest)
+	}
+	return nil
+}
+
+// readBundleManifest 读取归档中的第一个文件 bundle.json
+func readBundleManifest(tr *tar.Reader) (*BundleManifest, error) {
+	header, err := tr.Next()
+	if err != nil || header.Name != bundleManifestName {
+		return nil, fmt.Errorf("不是 ocpack 离线下载包: 第一个文件应为 %s", bundleManifestName)
+	}
+	var bundle BundleManifest
+	if err := json.NewDecoder(io.LimitReader(tr, maxChecksumsBytes)).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", bundleManifestName, err)
+	}
+	if bundle.Format != bundleFormat {
+		return nil, fmt.Errorf("不支持的离线下载包格式版本 %d (当前支持 %d)，请使用相同版本的 ocpack", bundle.Format, bundleFormat)
+	}
+	if bundle.Cluster == "" || strings.ContainsAny(bundle.Cluster, `/\`) || bundle.Cluster == "." || bundle.Cluster == ".." {
+		return nil, fmt.Errorf("%s 中的集群名称无效: %q", bundleManifestName, bundle.Cluster)
+	}
+	return &bundle, nil
+}
+
+// bundleDest 返回归档内路径在集群目录中的位置，拒绝绝对路径和指向集群目录之外的路径
+func bundleDest(clusterDir, name string) (string, error) {
+	clean := path.Clean(name)
+	if name == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || clean != name {
+		return "", fmt.Errorf("离线下载包中的路径无效: %s", name)
+	}
+	return filepath.Join(clusterDir, filepath.FromSlash(clean)), nil
+}
+
+// extractBundleFile 把文件写入临时文件并校验摘要，通过后再移动到最终位置
+func extractBundleFile(tr *tar.Reader, clusterDir string, file BundleFile) error {
+	dest, err := bundleDest(clusterDir, file.Path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	partPath := dest + partSuffix
+	out, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(file.Mode)&0777)
+	if err != nil {
+		return fmt.Errorf("创建 %s 失败: %w", file.Path, err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), tr)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("解包 %s 失败: %w", file.Path, err)
+	}
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if digest != file.SHA256 || size != file.Size {
+		os.Remove(partPath)
+		return fmt.Errorf("%s 校验失败 (期望 sha256 %s，实际 %s)", file.Path, file.SHA256, digest)
+	}
+	if err := os.Chmod(partPath, os.FileMode(file.Mode)&0777); err != nil {
+		return fmt.Errorf("设置 %s 的权限失败: %w", file.Path, err)
+	}
+	return os.Rename(partPath, dest)
+}
+
+// verifyImportedDownloads 检查下载的文件与下载清单中已校验的摘要一致，并汇总校验方式
+func verifyImportedDownloads(bundle *BundleManifest, clusterDir string) error {
+	var manifestDir string
+	for _, file := range bundle.Files {
+		if path.Base(file.Path) == manifestFilename {
+			manifestDir = filepath.Join(clusterDir, filepath.FromSlash(path.Dir(file.Path)))
+			break
+		}
+	}
+	if manifestDir == "" {
+		return fmt.Errorf("离线下载包中缺少下载清单 %s", manifestFilename)
+	}
+	manifest, err := LoadManifest(manifestDir)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, file := range bundle.Files {
+		if file.Verified == "" {
+			continue
+		}
+		entry, ok := manifest.Files[path.Base(file.Path)]
+		if !ok || entry.SHA256 != file.SHA256 {
+			return fmt.Errorf("%s 与下载清单中记录的摘要不一致", file.Path)
+		}
+		counts[entry.Verified]++
+	}
+	fmt.Printf("🔒 下载文件校验: %d 个经 GPG 签名校验，%d 个经摘要校验，%d 个只记录了摘要\n",
+		counts[VerifiedSignature], counts[VerifiedChecksum], counts[VerifiedNone])
+	return nil
+}
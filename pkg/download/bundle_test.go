@@ -0,0 +1,153 @@
+package download
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ocpack/pkg/config"
+)
+
+// newBundleCluster 创建一个已完成下载的集群目录
+func newBundleCluster(t *testing.T) (*config.ClusterConfig, string) {
+	t.Helper()
+	clusterDir := filepath.Join(t.TempDir(), "demo")
+	downloadDir := filepath.Join(clusterDir, "downloads")
+	if err := os.MkdirAll(filepath.Join(downloadDir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.ClusterConfig{}
+	cfg.ClusterInfo.Name = "demo"
+	cfg.ClusterInfo.OpenShiftVersion = "4.16.0"
+	cfg.Download.LocalPath = "downloads"
+	cfg.Download.ClientOS = "linux"
+	cfg.Download.ClientArch = "x86_64"
+
+	files := map[string]string{
+		filepath.Join(clusterDir, "config.toml"):                         "[cluster_info]\nname = \"demo\"\n",
+		filepath.Join(downloadDir, "openshift-client-linux.tar.gz"):      "client",
+		filepath.Join(downloadDir, "openshift-install-linux.tar.gz"):     "install-payload",
+		filepath.Join(downloadDir, "bin", "oc"):                          "#!/bin/sh\n",
+		filepath.Join(downloadDir, "openshift-client-linux.tar.gz.part"): "partial",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest := &Manifest{Files: map[string]ManifestEntry{}}
+	for _, name := range []string{"openshift-client-linux.tar.gz", "openshift-install-linux.tar.gz"} {
+		digest, size, err := fileSHA256(filepath.Join(downloadDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest.Files[name] = ManifestEntry{SHA256: digest, Size: size, Verified: VerifiedSignature}
+	}
+	if err := manifest.Save(downloadDir); err != nil {
+		t.Fatal(err)
+	}
+	return cfg, clusterDir
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	cfg, clusterDir := newBundleCluster(t)
+	bundlePath := filepath.Join(t.TempDir(), "demo.tar")
+	if err := ExportBundle(cfg, clusterDir, bundlePath); err != nil {
+		t.Fatalf("ExportBundle: %v", err)
+	}
+
+	projectRoot := t.TempDir()
+	imported, err := ImportBundle(bundlePath, projectRoot, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportBundle: %v", err)
+	}
+	if imported != filepath.Join(projectRoot, "demo") {
+		t.Errorf("cluster dir = %s", imported)
+	}
+
+	for _, name := range []string{"config.toml", "downloads/manifest.json", "downloads/openshift-install-linux.tar.gz", "downloads/bin/oc"} {
+		want, err := os.ReadFile(filepath.Join(clusterDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(imported, name))
+		if err != nil {
+			t.Fatalf("%s not imported: %v", name, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s content mismatch", name)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(imported, "downloads", "bin", "oc")); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("bin/oc should stay executable: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(imported, "downloads", "openshift-client-linux.tar.gz.part")); !os.IsNotExist(err) {
+		t.Errorf(".part files must not be bundled")
+	}
+
+	// 再次导入需要 --force
+	if _, err := ImportBundle(bundlePath, projectRoot, ImportOptions{}); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("expected overwrite refusal, got %v", err)
+	}
+	if _, err := ImportBundle(bundlePath, projectRoot, ImportOptions{Force: true}); err != nil {
+		t.Errorf("forced import: %v", err)
+	}
+}
+
+func TestImportBundleRejectsCorruption(t *testing.T) {
+	cfg, clusterDir := newBundleCluster(t)
+	bundlePath := filepath.Join(t.TempDir(), "demo.tar")
+	if err := ExportBundle(cfg, clusterDir, bundlePath); err != nil {
+		t.Fatalf("ExportBundle: %v", err)
+	}
+
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := strings.Index(string(data), "install-payload")
+	data[index] = 'X'
+	if err := os.WriteFile(bundlePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 整个归档的摘要不匹配
+	if _, err := ImportBundle(bundlePath, t.TempDir(), ImportOptions{}); err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("expected bundle digest mismatch, got %v", err)
+	}
+
+	// 没有 .sha256 文件时逐个文件校验
+	os.Remove(bundlePath + bundleDigestSuffix)
+	projectRoot := t.TempDir()
+	if _, err := ImportBundle(bundlePath, projectRoot, ImportOptions{}); err == nil || !strings.Contains(err.Error(), "校验失败") {
+		t.Errorf("expected file digest mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(projectRoot, "demo", "downloads", "openshift-install-linux.tar.gz")); !os.IsNotExist(err) {
+		t.Errorf("corrupted file must not be written")
+	}
+}
+
+func TestExportBundleDetectsChangedDownload(t *testing.T) {
+	cfg, clusterDir := newBundleCluster(t)
+	if err := os.WriteFile(filepath.Join(clusterDir, "downloads", "openshift-client-linux.tar.gz"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := ExportBundle(cfg, clusterDir, filepath.Join(t.TempDir(), "demo.tar"))
+	if err == nil || !strings.Contains(err.Error(), "摘要") {
+		t.Errorf("expected digest mismatch, got %v", err)
+	}
+}
+
+func TestBundleDestRejectsEscapes(t *testing.T) {
+	for _, name := range []string{"../etc/passwd", "/etc/passwd", "downloads/../../x", "", "./config.toml"} {
+		if _, err := bundleDest("/tmp/demo", name); err == nil {
+			t.Errorf("%q should be rejected", name)
+		}
+	}
+	if _, err := bundleDest("/tmp/demo", "downloads/bin/oc"); err != nil {
+		t.Errorf("valid path rejected: %v", err)
+	}
+}
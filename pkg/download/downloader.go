@@ -1,29 +1,46 @@
 package download
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
-	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"ocpack/pkg/config"
 	"ocpack/pkg/utils"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
 )
 
 // --- Constants ---
 const (
-	baseURL              = "https://mirror.openshift.com/pub/openshift-v4"
-	ocpClientsURLPattern = baseURL + "/clients/ocp/%s/%s"
-	ocMirrorURLPattern   = baseURL + "/%s/clients/ocp/%s/oc-mirror.tar.gz"
-	butaneURLPattern     = baseURL + "/clients/butane/latest/butane-%s"
-	quayReleaseURL       = "https://mirror.openshift.com/pub/cgw/mirror-registry/latest/mirror-registry-amd64.tar.gz"
-	progressBarWidth     = 30
-	progressUpdateFreq   = 100 * time.Millisecond
+	defaultBaseURL        = "https://mirror.openshift.com/pub/openshift-v4"
+	ocMirrorURLPattern    = "%s/%s/clients/ocp/%s/oc-mirror.tar.gz"
+	butaneURLPattern      = "%s/clients/butane/latest/%s"
+	ocMirrorChecksumURL   = "%s/%s/clients/ocp/%s/" + checksumFilename
+	butaneChecksumURL     = "%s/clients/butane/latest/" + checksumFilename
+	quayReleaseURLPattern = "https://mirror.openshift.com/pub/cgw/mirror-registry/latest/%s"
+	progressBarWidth      = 30
+	progressUpdateFreq    = 100 * time.Millisecond
+	partSuffix            = ".part"
+
+	defaultRetries     = 3
+	defaultConcurrency = 3
+	retryBaseDelay     = 2 * time.Second
+	retryMaxDelay      = time.Minute
+	connectTimeout     = 30 * time.Second
+	stallTimeout       = 2 * time.Minute // 超过该时间没有收到数据时中断连接并重试
 )
 
 // --- Struct Definitions ---
@@ -32,6 +49,17 @@ const (
 type Downloader struct {
 	config      *config.ClusterConfig
 	downloadDir string
+	baseURL     string
+	client      *http.Client
+	retries     int
+	concurrency int
+	retryDelay  time.Duration
+	board       *progressBoard
+
+	mu        sync.Mutex // 保护 manifest 和 checksums
+	manifest  *Manifest
+	keyring   openpgp.EntityList
+	checksums map[string]*checksumSet // 按 sha256sum.txt URL 缓存，nil 表示未发布
 }
 
 // ProgressReader is an io.Reader that displays download progress.
@@ -39,59 +67,164 @@ type ProgressReader struct {
 	io.Reader
 	total      int64
 	downloaded int64
+	resumed    int64 // bytes already on disk before this attempt
 	fileName   string
 	startTime  time.Time
 	lastUpdate time.Time
+	board      *progressBoard
+	line       int
+	onRead     func() // called after each read that returned data
 }
 
+// permanentError marks a download failure that retrying cannot fix.
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
 // DownloadTask defines a file to be downloaded.
 type DownloadTask struct {
-	Name       string
-	URL        string
-	FileName   string
-	Required   bool
-	VersionDep bool // Does this depend on a specific OCP version?
+	Name        string
+	URL         string
+	FileName    string
+	ChecksumURL string // sha256sum.txt published next to the file, empty if none
+	Required    bool
+	VersionDep  bool // Does this depend on a specific OCP version?
 }
 
 // --- Main Logic ---
 
 // NewDownloader creates a new downloader instance.
+// Retries, concurrency and the mirror URLs come from the [download] section
+// of the cluster config; the proxy and CA bundle are applied by DownloadAll.
 func NewDownloader(cfg *config.ClusterConfig, downloadDir string) *Downloader {
-	return &Downloader{
+	client, _ := newHTTPClient(nil)
+	d := &Downloader{
 		config:      cfg,
 		downloadDir: downloadDir,
+		baseURL:     defaultBaseURL,
+		client:      client,
+		retries:     defaultRetries,
+		concurrency: defaultConcurrency,
+		retryDelay:  retryBaseDelay,
+		board:       newProgressBoard(),
+		checksums:   map[string]*checksumSet{},
+	}
+	if cfg != nil {
+		if cfg.Download.Retries > 0 {
+			d.retries = cfg.Download.Retries
+		}
+		if cfg.Download.Concurrency > 0 {
+			d.concurrency = cfg.Download.Concurrency
+		}
+		if cfg.Download.BaseURL != "" {
+			d.baseURL = strings.TrimRight(cfg.Download.BaseURL, "/")
+		}
+	}
+	return d
+}
+
+// newHTTPClient returns a client with connection timeouts but no overall
+// timeout, since multi-GB downloads legitimately take hours; stalled
+// transfers are detected per read instead. The proxy and extra CA bundle
+// come from the [download] section; without a configured proxy the
+// standard HTTP(S)_PROXY environment variables apply.
+func newHTTPClient(cfg *config.ClusterConfig) (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: connectTimeout * 2,
+	}
+	if cfg != nil {
+		if cfg.UseProxy() {
+			proxy, err := newProxyFunc(cfg.HTTPProxyURL(), cfg.HTTPSProxyURL(), cfg.Download.NoProxy)
+			if err != nil {
+				return nil, err
+			}
+			transport.Proxy = proxy
+		}
+		if cfg.Download.CABundle != "" {
+			pool, err := loadCABundle(cfg.Download.CABundle)
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		}
 	}
+	return &http.Client{Transport: transport}, nil
 }
 
 // DownloadAll orchestrates the download of all necessary files.
 func (d *Downloader) DownloadAll() error {
 	fmt.Println("▶️  开始下载所需工具和文件...")
 
+	if !d.config.VersionResolved() {
+		return fmt.Errorf("版本通道 %s 尚未解析为具体版本，需要先查询 Cincinnati 并写入 %s", d.config.ClusterInfo.OpenShiftVersion, config.VersionLockFilename)
+	}
+
 	if err := os.MkdirAll(d.downloadDir, 0755); err != nil {
 		return fmt.Errorf("创建下载目录失败: %w", err)
 	}
 
+	var err error
+	if d.client, err = newHTTPClient(d.config); err != nil {
+		return err
+	}
+	if d.config.UseProxy() {
+		fmt.Printf("ℹ️  通过代理下载: %s\n", redactProxy(d.config.HTTPSProxyURL()))
+	}
+	if d.manifest, err = LoadManifest(d.downloadDir); err != nil {
+		return err
+	}
+	if d.keyring, err = releaseKeyring(); err != nil {
+		return err
+	}
+
+	p, err := newPlatform(d.config)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ℹ️  客户端工具: %s/%s，集群架构: %s\n", p.os, p.arch, p.targetArch)
+	if !p.hasOcMirror() {
+		fmt.Printf("⚠️  %s 上没有 oc-mirror，跳过下载\n", p.os)
+	}
+
 	version := d.config.ClusterInfo.OpenShiftVersion
-	tasks := d.buildDownloadTasks(version)
+	tasks := d.buildDownloadTasks(version, p)
 
+	// Download tasks in parallel, at most d.concurrency at a time.
+	fmt.Printf("ℹ️  共 %d 个下载任务，并发数 %d，失败重试 %d 次\n\n", len(tasks), d.concurrency, d.retries)
+	errs := make([]error, len(tasks))
+	sem := make(chan struct{}, d.concurrency)
+	var wg sync.WaitGroup
 	for i, task := range tasks {
-		fmt.Printf("\n➡️  任务 %d/%d: %s\n", i+1, len(tasks), task.Name)
-
 		if task.VersionDep && !utils.SupportsOcMirror(version) {
-			fmt.Printf("⚠️  跳过 %s: OpenShift 版本 %s 不支持 (需要 4.14.0 及以上版本)\n", task.Name, version)
-		} else {
-			filePath := filepath.Join(d.downloadDir, task.FileName)
-			if err := d.downloadFile(task.URL, filePath); err != nil {
-				if task.Required {
-					return fmt.Errorf("下载必需文件 '%s' 失败: %w", task.Name, err)
-				}
-				fmt.Printf("⚠️  下载可选文件 '%s' 失败，已跳过: %v\n", task.Name, err)
-			}
+			d.printf("⚠️  跳过 %s: OpenShift 版本 %s 不支持 (需要 4.14.0 及以上版本)\n", task.Name, version)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, task DownloadTask) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			d.printf("➡️  任务 %d/%d: %s\n", i+1, len(tasks), task.Name)
+			errs[i] = d.downloadTask(task)
+		}(i, task)
+	}
+	wg.Wait()
+
+	for i, task := range tasks {
+		if errs[i] == nil {
+			continue
+		}
+		if task.Required {
+			return fmt.Errorf("下载必需文件 '%s' 失败: %w", task.Name, errs[i])
 		}
+		fmt.Printf("⚠️  下载可选文件 '%s' 失败，已跳过: %v\n", task.Name, errs[i])
 	}
 
 	fmt.Println("\n➡️  正在提取工具...")
-	if err := d.extractTools(version); err != nil {
+	if err := d.extractTools(version, p); err != nil {
 		return fmt.Errorf("提取工具失败: %w", err)
 	}
 
@@ -101,107 +234,337 @@ func (d *Downloader) DownloadAll() error {
 
 // --- Task Building ---
 
-// buildDownloadTasks constructs the list of files to download.
-func (d *Downloader) buildDownloadTasks(version string) []DownloadTask {
-	arch := getSystemArch()
-	butaneArch := getSystemArchForButane()
+// buildDownloadTasks constructs the list of files to download for the
+// client platform and cluster architecture.
+func (d *Downloader) buildDownloadTasks(version string, p platform) []DownloadTask {
+	clientDir := p.clientsDir(d.baseURL, version)
+	clientTarball := p.clientTarball("openshift-client", version)
+	installTarball := p.clientTarball("openshift-install", version)
+	butaneName := p.butaneName()
 
-	return []DownloadTask{
-		{
-			Name:     "OpenShift 客户端 (oc, kubectl)",
-			URL:      fmt.Sprintf(ocpClientsURLPattern, version, fmt.Sprintf("openshift-client-linux-%s.tar.gz", version)),
-			FileName: fmt.Sprintf("openshift-client-linux-%s.tar.gz", version),
-			Required: true,
-		},
+	tasks := []DownloadTask{
 		{
-			Name:     "OpenShift 安装程序 (openshift-install)",
-			URL:      fmt.Sprintf(ocpClientsURLPattern, version, fmt.Sprintf("openshift-install-linux-%s.tar.gz", version)),
-			FileName: fmt.Sprintf("openshift-install-linux-%s.tar.gz", version),
-			Required: true,
+			Name:        "OpenShift 客户端 (oc, kubectl)",
+			URL:         clientDir + "/" + clientTarball,
+			FileName:    clientTarball,
+			ChecksumURL: clientDir + "/" + checksumFilename,
+			Required:    true,
 		},
 		{
-			Name:       "oc-mirror 工具",
-			URL:        fmt.Sprintf(ocMirrorURLPattern, arch, version),
-			FileName:   fmt.Sprintf("oc-mirror-%s.tar.gz", version),
-			Required:   false, // Not required if version is too old
-			VersionDep: true,
+			Name:        "OpenShift 安装程序 (openshift-install)",
+			URL:         clientDir + "/" + installTarball,
+			FileName:    installTarball,
+			ChecksumURL: clientDir + "/" + checksumFilename,
+			Required:    true,
 		},
-		{
-			Name:     "Butane 工具",
-			URL:      fmt.Sprintf(butaneURLPattern, butaneArch),
-			FileName: fmt.Sprintf("butane-%s", butaneArch),
-			Required: true,
+	}
+	if p.hasOcMirror() {
+		tasks = append(tasks, DownloadTask{
+			Name:        "oc-mirror 工具",
+			URL:         fmt.Sprintf(ocMirrorURLPattern, d.baseURL, p.arch, version),
+			FileName:    fmt.Sprintf("oc-mirror-%s.tar.gz", version),
+			ChecksumURL: fmt.Sprintf(ocMirrorChecksumURL, d.baseURL, p.arch, version),
+			Required:    false, // Not required if version is too old
+			VersionDep:  true,
+		})
+	}
+	return append(tasks,
+		DownloadTask{
+			Name:        "Butane 工具",
+			URL:         fmt.Sprintf(butaneURLPattern, d.baseURL, butaneName),
+			FileName:    butaneName,
+			ChecksumURL: fmt.Sprintf(butaneChecksumURL, d.baseURL),
+			Required:    true,
 		},
-		{
+		DownloadTask{
+			// mirror-registry 未在下载目录发布 sha256sum.txt，只在清单中记录摘要
 			Name:     "Quay 镜像仓库安装包",
-			URL:      quayReleaseURL,
-			FileName: "mirror-registry-amd64.tar.gz",
+			URL:      d.quayReleaseURL(p),
+			FileName: p.mirrorRegistry,
 			Required: true,
 		},
+	)
+}
+
+// quayReleaseURL returns the mirror-registry URL, honouring mirror_registry_url.
+func (d *Downloader) quayReleaseURL(p platform) string {
+	if d.config != nil && d.config.Download.MirrorRegistryURL != "" {
+		return d.config.Download.MirrorRegistryURL
 	}
+	return fmt.Sprintf(quayReleaseURLPattern, p.mirrorRegistry)
 }
 
 // --- Download and Extraction ---
 
-// downloadFile downloads a single file to a destination path with progress.
-func (d *Downloader) downloadFile(url, destPath string) error {
+// downloadTask downloads a file and verifies its digest.
+// An existing file is kept only if it still matches the published or recorded digest.
+func (d *Downloader) downloadTask(task DownloadTask) error {
+	destPath := filepath.Join(d.downloadDir, task.FileName)
+	expected, verified, err := d.expectedChecksum(task)
+	if err != nil {
+		return err
+	}
+
 	if _, err := os.Stat(destPath); err == nil {
-		fmt.Printf("✅ 文件已存在，跳过下载: %s\n", filepath.Base(destPath))
-		return nil
+		digest, size, err := fileSHA256(destPath)
+		if err != nil {
+			return err
+		}
+		if d.existingFileValid(task, digest, size, expected) {
+			d.printf("✅ 文件已存在且校验通过，跳过下载: %s\n", task.FileName)
+			return d.record(task, digest, size, verified)
+		}
+		d.printf("⚠️  已存在的文件 %s 校验未通过，重新下载\n", task.FileName)
+		if err := os.Remove(destPath); err != nil {
+			return fmt.Errorf("删除损坏的文件失败: %w", err)
+		}
 	}
 
-	fileName := filepath.Base(destPath)
-	tmpPath := destPath + ".tmp"
-	defer os.Remove(tmpPath)
+	digest, size, err := d.downloadFile(task.URL, destPath, expected)
+	if err != nil {
+		return err
+	}
+	if expected != "" {
+		d.printf("🔒 %s sha256 校验通过\n", task.FileName)
+	}
+	return d.record(task, digest, size, verified)
+}
 
-	headResp, err := http.Head(url)
-	var contentLength int64
-	if err == nil {
-		defer headResp.Body.Close()
-		contentLength = headResp.ContentLength
+// expectedChecksum returns the published sha256 of a task, or "" if none is available.
+// Tasks sharing a sha256sum.txt wait for the first one to fetch it.
+func (d *Downloader) expectedChecksum(task DownloadTask) (string, string, error) {
+	if task.ChecksumURL == "" {
+		return "", VerifiedNone, nil
 	}
 
-	resp, err := http.Get(url)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	set, cached := d.checksums[task.ChecksumURL]
+	if !cached {
+		var err error
+		if set, err = d.fetchChecksums(task.ChecksumURL); err != nil {
+			return "", "", err
+		}
+		if set == nil {
+			d.printf("⚠️  %s 不存在，无法校验摘要\n", task.ChecksumURL)
+		}
+		d.checksums[task.ChecksumURL] = set
+	}
+	if set == nil {
+		return "", VerifiedNone, nil
+	}
+
+	expected, ok := set.sums[path.Base(task.URL)]
+	if !ok {
+		d.printf("⚠️  %s 中没有 %s 的摘要\n", checksumFilename, path.Base(task.URL))
+		return "", VerifiedNone, nil
+	}
+	return expected, set.verified, nil
+}
+
+// existingFileValid checks an existing file against the published digest,
+// falling back to the digest recorded in the manifest. Without any digest the
+// file may be a partial download from a run that could not resume, so its size
+// must match the Content-Length reported by the server.
+func (d *Downloader) existingFileValid(task DownloadTask, digest string, size int64, expected string) bool {
+	if expected != "" {
+		return digest == expected
+	}
+	d.mu.Lock()
+	entry, recorded := d.manifest.Files[task.FileName]
+	d.mu.Unlock()
+	if recorded {
+		return entry.SHA256 == digest
+	}
+
+	length, err := d.contentLength(task.URL)
 	if err != nil {
-		return fmt.Errorf("HTTP GET 请求失败: %w", err)
+		d.printf("⚠️  无法确认 %s 是否完整: %v\n", task.FileName, err)
+		return false
 	}
-	defer resp.Body.Close()
+	return length == size
+}
 
+// contentLength returns the size of a remote file from a HEAD request.
+func (d *Downloader) contentLength(url string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("HTTP HEAD 请求失败: %w", err)
+	}
+	resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("下载失败，HTTP 状态码: %d", resp.StatusCode)
+		return 0, fmt.Errorf("HTTP HEAD 请求失败，HTTP 状态码: %d", resp.StatusCode)
 	}
-	if contentLength <= 0 {
-		contentLength = resp.ContentLength
+	if resp.ContentLength < 0 {
+		return 0, errors.New("服务端没有返回文件大小")
 	}
+	return resp.ContentLength, nil
+}
+
+// record saves the verified digest of a file to the manifest.
+func (d *Downloader) record(task DownloadTask, digest string, size int64, verified string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.manifest.Files[task.FileName] = ManifestEntry{
+		URL:        task.URL,
+		SHA256:     digest,
+		Size:       size,
+		Verified:   verified,
+		VerifiedAt: time.Now().UTC(),
+	}
+	return d.manifest.Save(d.downloadDir)
+}
 
-	out, err := os.Create(tmpPath)
+// printf prints a message above the progress bars of running downloads.
+func (d *Downloader) printf(format string, args ...interface{}) {
+	d.board.printf(format, args...)
+}
+
+// downloadFile downloads a single file to a destination path with progress,
+// retrying with exponential backoff. Data is written to a .part file that is
+// kept between attempts (and between runs) so the download can resume.
+func (d *Downloader) downloadFile(url, destPath, expected string) (string, int64, error) {
+	fileName := filepath.Base(destPath)
+	line := d.board.add(fmt.Sprintf("⏳ %s 等待下载...", fileName))
+
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			delay := d.backoff(attempt)
+			d.printf("🔄 %s 下载失败: %v，%s 后进行第 %d/%d 次重试\n", fileName, err, delay, attempt, d.retries)
+			time.Sleep(delay)
+		}
+
+		var digest string
+		var size int64
+		digest, size, err = d.fetch(url, destPath, expected, line)
+		if err == nil {
+			return digest, size, nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			break
+		}
+	}
+	d.board.update(line, fmt.Sprintf("❌ %s 下载失败", fileName))
+	return "", 0, err
+}
+
+// backoff returns the delay before the given retry attempt.
+func (d *Downloader) backoff(attempt int) time.Duration {
+	delay := d.retryDelay << (attempt - 1)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// fetch performs a single download attempt, resuming from the .part file
+// with an HTTP Range request when one exists. The sha256 covers the whole
+// file, including the resumed part; on mismatch the .part file is discarded.
+func (d *Downloader) fetch(url, destPath, expected string, line int) (string, int64, error) {
+	fileName := filepath.Base(destPath)
+	partPath := destPath + partSuffix
+
+	// 计算已下载部分的摘要，续传的数据接在后面继续计算
+	hash := sha256.New()
+	var offset int64
+	if part, err := os.Open(partPath); err == nil {
+		offset, err = io.Copy(hash, part)
+		part.Close()
+		if err != nil {
+			return "", 0, fmt.Errorf("读取未完成的文件失败: %w", err)
+		}
+	}
+
+	// 长时间没有收到数据时取消请求，由外层重试
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchdog := time.AfterFunc(stallTimeout, cancel)
+	defer watchdog.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return fmt.Errorf("创建临时文件失败: %w", err)
+		return "", 0, permanentError{fmt.Errorf("创建请求失败: %w", err)}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("HTTP GET 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+		d.printf("⏩ 从 %s 处继续下载 %s\n", formatBytes(offset), fileName)
+	case http.StatusOK:
+		// 服务端不支持 Range，从头开始
+		flags |= os.O_TRUNC
+		hash.Reset()
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 未完成的文件比服务端的文件还大，丢弃后重新下载
+		os.Remove(partPath)
+		return "", 0, fmt.Errorf("未完成的文件 %s 无效，已删除", fileName+partSuffix)
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return "", 0, permanentError{fmt.Errorf("下载失败，HTTP 状态码: %d", resp.StatusCode)}
+	default:
+		return "", 0, fmt.Errorf("下载失败，HTTP 状态码: %d", resp.StatusCode)
+	}
+
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return "", 0, permanentError{fmt.Errorf("创建临时文件失败: %w", err)}
 	}
 	defer out.Close()
 
+	var total int64
+	if resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
 	progressReader := &ProgressReader{
-		Reader:    resp.Body,
-		total:     contentLength,
-		fileName:  fileName,
-		startTime: time.Now(),
+		Reader:     resp.Body,
+		total:      total,
+		downloaded: offset,
+		resumed:    offset,
+		fileName:   fileName,
+		startTime:  time.Now(),
+		board:      d.board,
+		line:       line,
+		onRead:     func() { watchdog.Reset(stallTimeout) },
+	}
+
+	written, err := io.Copy(io.MultiWriter(out, hash), progressReader)
+	if err != nil {
+		return "", 0, fmt.Errorf("保存文件时出错 (已下载 %s，将续传): %w", formatBytes(offset+written), err)
 	}
-
-	if _, err = io.Copy(out, progressReader); err != nil {
-		fmt.Println()
-		return fmt.Errorf("保存文件时出错: %w", err)
+	if err := out.Close(); err != nil {
+		return "", 0, fmt.Errorf("保存文件时出错: %w", err)
 	}
-	fmt.Println()
 
-	if err := os.Rename(tmpPath, destPath); err != nil {
-		return fmt.Errorf("重命名临时文件失败: %w", err)
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if expected != "" && digest != expected {
+		os.Remove(partPath)
+		return "", 0, fmt.Errorf("%s 的 sha256 摘要不匹配 (期望 %s，实际 %s)，文件可能不完整或已被篡改", fileName, expected, digest)
 	}
-
-	return nil
+	if err := os.Rename(partPath, destPath); err != nil {
+		return "", 0, fmt.Errorf("重命名临时文件失败: %w", err)
+	}
+	return digest, offset + written, nil
 }
 
 // extractTools extracts binaries from downloaded tarballs.
-func (d *Downloader) extractTools(version string) error {
+func (d *Downloader) extractTools(version string, p platform) error {
 	binDir := filepath.Join(d.downloadDir, "bin")
 	if err := os.MkdirAll(binDir, 0755); err != nil {
 		return fmt.Errorf("创建 bin 目录失败: %w", err)
@@ -217,9 +580,9 @@ func (d *Downloader) extractTools(version string) error {
 		files     []string
 		condition bool
 	}{
-		{"OpenShift 客户端", fmt.Sprintf("openshift-client-linux-%s.tar.gz", version), []string{"oc", "kubectl"}, true},
-		{"OpenShift 安装程序", fmt.Sprintf("openshift-install-linux-%s.tar.gz", version), []string{"openshift-install"}, true},
-		{"oc-mirror 工具", fmt.Sprintf("oc-mirror-%s.tar.gz", version), []string{"oc-mirror"}, utils.SupportsOcMirror(version)},
+		{"OpenShift 客户端", p.clientTarball("openshift-client", version), []string{"oc", "kubectl"}, true},
+		{"OpenShift 安装程序", p.clientTarball("openshift-install", version), []string{"openshift-install"}, true},
+		{"oc-mirror 工具", fmt.Sprintf("oc-mirror-%s.tar.gz", version), []string{"oc-mirror"}, p.hasOcMirror() && utils.SupportsOcMirror(version)},
 	}
 
 	for _, task := range extractTasks {
@@ -236,7 +599,7 @@ func (d *Downloader) extractTools(version string) error {
 		}
 	}
 
-	if err := d.copyButaneTool(binDir); err != nil {
+	if err := d.copyButaneTool(binDir, p); err != nil {
 		return fmt.Errorf("复制 butane 工具失败: %w", err)
 	}
 	if err := utils.MakeExecutable(binDir); err != nil {
@@ -262,9 +625,8 @@ func (d *Downloader) cleanupBinDir(binDir string) error {
 	return nil
 }
 
-func (d *Downloader) copyButaneTool(binDir string) error {
-	arch := getSystemArchForButane()
-	srcPath := filepath.Join(d.downloadDir, fmt.Sprintf("butane-%s", arch))
+func (d *Downloader) copyButaneTool(binDir string, p platform) error {
+	srcPath := filepath.Join(d.downloadDir, p.butaneName())
 	dstPath := filepath.Join(binDir, "butane")
 
 	if _, err := os.Stat(srcPath); os.IsNotExist(err) {
@@ -278,6 +640,9 @@ func (d *Downloader) copyButaneTool(binDir string) error {
 func (pr *ProgressReader) Read(p []byte) (int, error) {
 	n, err := pr.Reader.Read(p)
 	pr.downloaded += int64(n)
+	if n > 0 && pr.onRead != nil {
+		pr.onRead()
+	}
 	now := time.Now()
 	if now.Sub(pr.lastUpdate) >= progressUpdateFreq || err == io.EOF {
 		pr.lastUpdate = now
@@ -288,22 +653,33 @@ func (pr *ProgressReader) Read(p []byte) (int, error) {
 
 func (pr *ProgressReader) printProgress() {
 	if pr.total <= 0 {
-		fmt.Printf("\rDownloading %s: %s", pr.fileName, formatBytes(pr.downloaded))
+		pr.show(fmt.Sprintf("⬇️  %s: %s", pr.fileName, formatBytes(pr.downloaded)))
 		return
 	}
 	percent := float64(pr.downloaded) * 100 / float64(pr.total)
 	filled := int(percent * float64(progressBarWidth) / 100)
 	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+	// 续传前已有的部分不计入速度
 	elapsed := time.Since(pr.startTime)
-	speed := float64(pr.downloaded) / elapsed.Seconds()
+	speed := float64(pr.downloaded-pr.resumed) / elapsed.Seconds()
 	var eta time.Duration
 	if speed > 0 && pr.downloaded < pr.total {
 		eta = time.Duration(float64(pr.total-pr.downloaded)/speed) * time.Second
 	}
-	fmt.Printf("\r⬇️  %s [%s] %.1f%% (%s/%s) %s/s ETA: %s ",
+	pr.show(fmt.Sprintf("⬇️  %s [%s] %.1f%% (%s/%s) %s/s ETA: %s",
 		pr.fileName, bar, percent,
 		formatBytes(pr.downloaded), formatBytes(pr.total),
-		formatBytes(int64(speed)), formatDuration(eta))
+		formatBytes(int64(speed)), formatDuration(eta)))
+}
+
+// show renders the progress line on the board, or directly on stdout
+// when the reader is used on its own.
+func (pr *ProgressReader) show(line string) {
+	if pr.board != nil {
+		pr.board.update(pr.line, line)
+		return
+	}
+	fmt.Printf("\r%s ", line)
 }
 
 func formatBytes(b int64) string {
@@ -331,41 +707,3 @@ func formatDuration(d time.Duration) string {
 	}
 	return fmt.Sprintf("%02d:%02d", m, s)
 }
-
-// --- System Architecture Helpers ---
-
-func getSystemArch() string {
-	archMap := map[string]string{
-		"amd64": "x86_64",
-		"arm64": "aarch64",
-	}
-	if mapped, exists := archMap[runtime.GOARCH]; exists {
-		return mapped
-	}
-	return "x86_64"
-}
-
-// **FIX:** Reverted this function to the original logic to match the mirror's specific naming scheme.
-func getSystemArchForButane() string {
-	osArchMap := map[string]map[string]string{
-		"linux": {
-			"amd64":   "amd64",
-			"arm64":   "aarch64",
-			"ppc64le": "ppc64le",
-			"s390x":   "s390x",
-		},
-		"darwin": {
-			"amd64": "darwin-amd64",
-			"arm64": "darwin-amd64", // Fallback for M1/M2 macs
-		},
-		"windows": {
-			"amd64": "windows-amd64.exe",
-		},
-	}
-	if osMap, ok := osArchMap[runtime.GOOS]; ok {
-		if arch, ok := osMap[runtime.GOARCH]; ok {
-			return arch
-		}
-	}
-	return "amd64" // Default to amd64 for linux
-}
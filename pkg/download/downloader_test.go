@@ -0,0 +1,117 @@
+package download
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloadFileResumes(t *testing.T) {
+	content := bytes.Repeat([]byte("openshift"), 1000)
+	sum := sha256.Sum256(content)
+	expected := hex.EncodeToString(sum[:])
+
+	var requests int32
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		// 第一次请求返回服务端错误，验证重试
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	destPath := filepath.Join(dir, "file.tar.gz")
+	if err := os.WriteFile(destPath+partSuffix, content[:4000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDownloader(nil, dir)
+	d.retryDelay = time.Millisecond
+	digest, size, err := d.downloadFile(server.URL+"/file.tar.gz", destPath, expected)
+	if err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	if digest != expected || size != int64(len(content)) {
+		t.Errorf("downloadFile() = %s, %d; expected %s, %d", digest, size, expected, len(content))
+	}
+	if len(ranges) != 2 || ranges[1] != "bytes=4000-" {
+		t.Errorf("Range headers = %q, expected a retry resuming from byte 4000", ranges)
+	}
+	if data, _ := os.ReadFile(destPath); !bytes.Equal(data, content) {
+		t.Error("resumed file content does not match")
+	}
+	if _, err := os.Stat(destPath + partSuffix); !os.IsNotExist(err) {
+		t.Error("downloadFile() left the .part file behind")
+	}
+}
+
+func TestDownloadFilePermanentError(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	d := NewDownloader(nil, t.TempDir())
+	d.retryDelay = time.Millisecond
+	_, _, err := d.downloadFile(server.URL+"/missing", filepath.Join(t.TempDir(), "missing"), "")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("downloadFile() error = %v, expected HTTP 404", err)
+	}
+	if requests != 1 {
+		t.Errorf("server received %d requests, expected no retries for HTTP 404", requests)
+	}
+}
+
+func TestDownloadTaskWithoutChecksum(t *testing.T) {
+	content := bytes.Repeat([]byte("mirror-registry"), 100)
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&downloads, 1)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	d := NewDownloader(nil, dir)
+	d.manifest = &Manifest{Files: map[string]ManifestEntry{}}
+	d.retryDelay = time.Millisecond
+	task := DownloadTask{URL: server.URL + "/mirror-registry.tar.gz", FileName: "mirror-registry.tar.gz"}
+	destPath := filepath.Join(dir, task.FileName)
+
+	// 没有摘要时，中断的下载留下的不完整文件会重新下载
+	if err := os.WriteFile(destPath, content[:100], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.downloadTask(task); err != nil {
+		t.Fatalf("downloadTask() error = %v", err)
+	}
+	if data, _ := os.ReadFile(destPath); !bytes.Equal(data, content) || downloads != 1 {
+		t.Errorf("partial file not re-downloaded: %d bytes, %d downloads", len(data), downloads)
+	}
+
+	// 大小与服务端一致的文件跳过下载
+	d.manifest = &Manifest{Files: map[string]ManifestEntry{}}
+	if err := d.downloadTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if downloads != 1 {
+		t.Errorf("complete file downloaded again (%d downloads)", downloads)
+	}
+}
@@ -0,0 +1,75 @@
+package download
+
+import (
+	"fmt"
+
+	"ocpack/pkg/config"
+)
+
+// platform 客户端工具运行的系统和架构，以及集群节点的架构
+type platform struct {
+	os             string // linux 或 darwin
+	arch           string // 客户端架构，使用 x86_64、aarch64 写法
+	targetArch     string // 集群架构
+	mirrorRegistry string // mirror-registry 安装包文件名
+}
+
+// newPlatform 根据 [download] client_os/client_arch 和 cluster_info.target_arch 确定下载的工具版本
+func newPlatform(cfg *config.ClusterConfig) (platform, error) {
+	if cfg == nil {
+		cfg = &config.ClusterConfig{}
+	}
+	arch, err := cfg.ClientArch()
+	if err != nil {
+		return platform{}, err
+	}
+	p := platform{
+		os:             cfg.ClientOS(),
+		arch:           arch,
+		targetArch:     cfg.TargetArch(),
+		mirrorRegistry: cfg.MirrorRegistryArchive(),
+	}
+	if p.os != config.ClientOSLinux && p.os != config.ClientOSDarwin {
+		return platform{}, fmt.Errorf("不支持的客户端操作系统: %s，请在 [download] 中设置 client_os (可选值: linux, darwin)", p.os)
+	}
+	return p, nil
+}
+
+// clientsDir 返回 oc 和 openshift-install 所在的目录。集群架构决定 openshift-install
+// 安装的 release，x86_64 使用 mirror.openshift.com 原有的 clients/ocp 路径。
+func (p platform) clientsDir(baseURL, version string) string {
+	if p.targetArch == config.ArchX86_64 {
+		return fmt.Sprintf("%s/clients/ocp/%s", baseURL, version)
+	}
+	return fmt.Sprintf("%s/%s/clients/ocp/%s", baseURL, p.targetArch, version)
+}
+
+// clientTarball 返回 openshift-client 或 openshift-install 的归档名称。
+// 集群架构目录下与目录架构相同的版本不带架构后缀，其他客户端架构带 -amd64、-arm64 等后缀，
+// 如 x86_64 目录下的 openshift-install-mac-arm64-4.16.0.tar.gz。
+func (p platform) clientTarball(tool, version string) string {
+	name := tool + "-linux"
+	if p.os == config.ClientOSDarwin {
+		name = tool + "-mac"
+	}
+	if p.arch != p.targetArch {
+		name += "-" + config.GoArch(p.arch)
+	}
+	return fmt.Sprintf("%s-%s.tar.gz", name, version)
+}
+
+// hasOcMirror 判断客户端系统上是否有 oc-mirror，oc-mirror 只发布了 Linux 版本
+func (p platform) hasOcMirror() bool {
+	return p.os == config.ClientOSLinux
+}
+
+// butaneName 返回 butane 在 mirror.openshift.com 上的文件名
+func (p platform) butaneName() string {
+	if p.os == config.ClientOSDarwin {
+		return "butane-darwin-amd64" // Apple Silicon 通过 Rosetta 运行
+	}
+	if p.arch == config.ArchX86_64 {
+		return "butane-amd64"
+	}
+	return "butane-" + p.arch
+}
@@ -0,0 +1,68 @@
+package download
+
+import (
+	"testing"
+
+	"ocpack/pkg/config"
+)
+
+func TestBuildDownloadTasksPlatforms(t *testing.T) {
+	tests := []struct {
+		clientOS, clientArch, targetArch string
+		expected                         []string
+	}{
+		{"linux", "amd64", "", []string{
+			"https://mirror.openshift.com/pub/openshift-v4/clients/ocp/4.16.0/openshift-client-linux-4.16.0.tar.gz",
+			"https://mirror.openshift.com/pub/openshift-v4/clients/ocp/4.16.0/openshift-install-linux-4.16.0.tar.gz",
+			"https://mirror.openshift.com/pub/openshift-v4/x86_64/clients/ocp/4.16.0/oc-mirror.tar.gz",
+			"https://mirror.openshift.com/pub/openshift-v4/clients/butane/latest/butane-amd64",
+			"https://mirror.openshift.com/pub/cgw/mirror-registry/latest/mirror-registry-amd64.tar.gz",
+		}},
+		{"linux", "x86_64", "arm64", []string{
+			"https://mirror.openshift.com/pub/openshift-v4/aarch64/clients/ocp/4.16.0/openshift-client-linux-amd64-4.16.0.tar.gz",
+			"https://mirror.openshift.com/pub/openshift-v4/aarch64/clients/ocp/4.16.0/openshift-install-linux-amd64-4.16.0.tar.gz",
+			"https://mirror.openshift.com/pub/openshift-v4/x86_64/clients/ocp/4.16.0/oc-mirror.tar.gz",
+			"https://mirror.openshift.com/pub/openshift-v4/clients/butane/latest/butane-amd64",
+			"https://mirror.openshift.com/pub/cgw/mirror-registry/latest/mirror-registry-aarch64.tar.gz",
+		}},
+		{"darwin", "arm64", "ppc64le", []string{
+			"https://mirror.openshift.com/pub/openshift-v4/ppc64le/clients/ocp/4.16.0/openshift-client-mac-arm64-4.16.0.tar.gz",
+			"https://mirror.openshift.com/pub/openshift-v4/ppc64le/clients/ocp/4.16.0/openshift-install-mac-arm64-4.16.0.tar.gz",
+			"https://mirror.openshift.com/pub/openshift-v4/clients/butane/latest/butane-darwin-amd64",
+			"https://mirror.openshift.com/pub/cgw/mirror-registry/latest/mirror-registry-amd64.tar.gz",
+		}},
+	}
+	for _, tt := range tests {
+		cfg := config.NewDefaultConfig("demo")
+		cfg.Download.ClientOS = tt.clientOS
+		cfg.Download.ClientArch = tt.clientArch
+		cfg.ClusterInfo.TargetArch = tt.targetArch
+
+		p, err := newPlatform(cfg)
+		if err != nil {
+			t.Fatalf("newPlatform(%s/%s) error = %v", tt.clientOS, tt.clientArch, err)
+		}
+		tasks := NewDownloader(cfg, t.TempDir()).buildDownloadTasks("4.16.0", p)
+		if len(tasks) != len(tt.expected) {
+			t.Fatalf("%s/%s -> %s: got %d tasks, expected %d", tt.clientOS, tt.clientArch, tt.targetArch, len(tasks), len(tt.expected))
+		}
+		for i, task := range tasks {
+			if task.URL != tt.expected[i] {
+				t.Errorf("%s/%s -> %s: task %d URL = %s, expected %s", tt.clientOS, tt.clientArch, tt.targetArch, i, task.URL, tt.expected[i])
+			}
+		}
+	}
+}
+
+func TestNewPlatformRejectsUnsupported(t *testing.T) {
+	cfg := config.NewDefaultConfig("demo")
+	cfg.Download.ClientOS = "windows"
+	if _, err := newPlatform(cfg); err == nil {
+		t.Error("newPlatform() expected error for windows")
+	}
+	cfg.Download.ClientOS = "linux"
+	cfg.Download.ClientArch = "386"
+	if _, err := newPlatform(cfg); err == nil {
+		t.Error("newPlatform() expected error for an unsupported client_arch")
+	}
+}
@@ -0,0 +1,72 @@
+package download
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// progressBoard 并行下载时的多行进度显示，每个下载任务占一行。
+// 输出不是终端时不显示进度条，只打印普通消息。
+type progressBoard struct {
+	mu    sync.Mutex
+	tty   bool
+	lines []string
+	drawn int // 当前屏幕上已绘制的进度行数
+}
+
+func newProgressBoard() *progressBoard {
+	return &progressBoard{tty: term.IsTerminal(int(os.Stdout.Fd()))}
+}
+
+// add 添加一行进度，返回行号
+func (b *progressBoard) add(line string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, line)
+	b.redraw()
+	return len(b.lines) - 1
+}
+
+// update 更新指定行的进度
+func (b *progressBoard) update(index int, line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines[index] = line
+	b.redraw()
+}
+
+// printf 在进度条上方打印消息
+func (b *progressBoard) printf(format string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clear()
+	fmt.Printf(format, args...)
+	b.redraw()
+}
+
+// clear 清除已绘制的进度行，光标回到第一行进度的位置
+func (b *progressBoard) clear() {
+	if b.drawn > 0 {
+		fmt.Printf("\033[%dA\033[J", b.drawn)
+		b.drawn = 0
+	}
+}
+
+func (b *progressBoard) redraw() {
+	if !b.tty {
+		return
+	}
+	b.clear()
+	var out strings.Builder
+	for _, line := range b.lines {
+		out.WriteString("\033[2K")
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	fmt.Print(out.String())
+	b.drawn = len(b.lines)
+}
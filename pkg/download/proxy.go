@@ -0,0 +1,87 @@
+package download
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// newProxyFunc 返回按 [download] 代理配置选择代理的函数，no_proxy 支持
+// 域名后缀 (example.com 或 .example.com)、IP、CIDR 和 "*"。
+func newProxyFunc(httpProxy, httpsProxy string, noProxy []string) (func(*http.Request) (*url.URL, error), error) {
+	proxies := map[string]*url.URL{}
+	for scheme, raw := range map[string]string{"http": httpProxy, "https": httpsProxy} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("无效的代理地址: %s", redactProxy(raw))
+		}
+		proxies[scheme] = u
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return proxies[req.URL.Scheme], nil
+	}, nil
+}
+
+// bypassProxy 判断主机是否匹配 no_proxy 中的条目
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, network, err := net.ParseCIDR(entry); err == nil && ip != nil && network.Contains(ip) {
+				return true
+			}
+		case ip != nil:
+			if entryIP := net.ParseIP(entry); entryIP != nil && entryIP.Equal(ip) {
+				return true
+			}
+		default:
+			domain := strings.TrimPrefix(entry, ".")
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadCABundle 返回系统根证书加上 ca_bundle 中的证书
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 CA 证书 %s 失败: %w", path, err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s 中没有 PEM 格式的证书", path)
+	}
+	return pool, nil
+}
+
+// redactProxy 隐藏代理地址中的密码，用于输出
+func redactProxy(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
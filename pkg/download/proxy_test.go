@@ -0,0 +1,107 @@
+package download
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ocpack/pkg/config"
+)
+
+func TestBypassProxy(t *testing.T) {
+	noProxy := []string{".corp.example.com", "mirror.local", "10.0.0.0/8", "192.168.1.5"}
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{"artifacts.corp.example.com", true},
+		{"corp.example.com", true},
+		{"mirror.local", true},
+		{"other.mirror.local", true},
+		{"mirror.openshift.com", false},
+		{"10.1.2.3", true},
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+	}
+	for _, tt := range tests {
+		if got := bypassProxy(tt.host, noProxy); got != tt.expected {
+			t.Errorf("bypassProxy(%s) = %v, expected %v", tt.host, got, tt.expected)
+		}
+	}
+	if !bypassProxy("mirror.openshift.com", []string{"*"}) {
+		t.Error("bypassProxy() expected * to match every host")
+	}
+}
+
+func TestDownloadThroughProxy(t *testing.T) {
+	var proxyAuth, requestURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyAuth = r.Header.Get("Proxy-Authorization")
+		requestURL = r.URL.String()
+		w.Write([]byte("content"))
+	}))
+	defer proxy.Close()
+
+	cfg := config.NewDefaultConfig("demo")
+	cfg.Download.BaseURL = "http://mirror.internal.example.com/pub/openshift-v4/"
+	cfg.Download.HTTPProxy = proxy.URL
+	cfg.Download.ProxyUsername = "user"
+	cfg.Download.ProxyPassword = "password"
+	cfg.Download.ClientOS = "linux"
+	cfg.Download.ClientArch = "x86_64"
+
+	dir := t.TempDir()
+	d := NewDownloader(cfg, dir)
+	var err error
+	if d.client, err = newHTTPClient(cfg); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newPlatform(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := d.buildDownloadTasks("4.16.0", p)[0].URL
+	if _, _, err := d.downloadFile(url, filepath.Join(dir, "client.tar.gz"), ""); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	if requestURL != "http://mirror.internal.example.com/pub/openshift-v4/clients/ocp/4.16.0/openshift-client-linux-4.16.0.tar.gz" {
+		t.Errorf("proxy received %s, expected a request for the configured base_url", requestURL)
+	}
+	if proxyAuth != "Basic dXNlcjpwYXNzd29yZA==" {
+		t.Errorf("Proxy-Authorization = %q, expected the configured credentials", proxyAuth)
+	}
+}
+
+func TestLoadCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCABundle(invalid); err == nil {
+		t.Error("loadCABundle() expected error for a file without PEM certificates")
+	}
+
+	bundle := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.NewDefaultConfig("demo")
+	cfg.Download.CABundle = bundle
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET with ca_bundle error = %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,228 @@
+package download
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// --- Constants ---
+const (
+	checksumFilename  = "sha256sum.txt"
+	signatureSuffix   = ".gpg"
+	manifestFilename  = "manifest.json"
+	maxChecksumsBytes = 1 << 20
+)
+
+// 校验方式，记录在清单中
+const (
+	VerifiedSignature = "signature" // sha256sum.txt 的 GPG 签名和文件摘要均已校验
+	VerifiedChecksum  = "checksum"  // 已按 sha256sum.txt 校验摘要，签名不可用
+	VerifiedNone      = "none"      // 未发布校验文件，只记录摘要用于之后检测损坏
+)
+
+// redHatReleaseKey 与 pkg/mirror/release 中校验 release 签名使用的 Red Hat release key 2 相同
+//
+//go:embed redhat-release-key.asc
+var redHatReleaseKey []byte
+
+// Manifest 下载目录中已校验文件的清单
+type Manifest struct {
+	Files map[string]ManifestEntry `json:"files"`
+}
+
+// ManifestEntry 清单中的一个文件
+type ManifestEntry struct {
+	URL        string    `json:"url"`
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
+	Verified   string    `json:"verified"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// ManifestPath 返回下载目录中的清单文件路径
+func ManifestPath(downloadDir string) string {
+	return filepath.Join(downloadDir, manifestFilename)
+}
+
+// LoadManifest 读取下载清单，文件不存在时返回空清单
+func LoadManifest(downloadDir string) (*Manifest, error) {
+	manifest := &Manifest{Files: map[string]ManifestEntry{}}
+	data, err := os.ReadFile(ManifestPath(downloadDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取下载清单失败: %w", err)
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("解析下载清单 %s 失败: %w", ManifestPath(downloadDir), err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = map[string]ManifestEntry{}
+	}
+	return manifest, nil
+}
+
+// Save 保存下载清单
+func (m *Manifest) Save(downloadDir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化下载清单失败: %w", err)
+	}
+	if err := os.WriteFile(ManifestPath(downloadDir), data, 0644); err != nil {
+		return fmt.Errorf("保存下载清单失败: %w", err)
+	}
+	return nil
+}
+
+// checksumSet 一个 sha256sum.txt 中的摘要
+type checksumSet struct {
+	sums     map[string]string // 文件名 -> sha256
+	verified string            // VerifiedSignature 或 VerifiedChecksum
+}
+
+// fetchChecksums 下载 sha256sum.txt 及其签名。签名存在时必须校验通过，
+// 签名未发布 (HTTP 404) 时只使用摘要，获取签名的其他错误都会使下载失败；sha256sum.txt 不存在时返回 nil
+func (d *Downloader) fetchChecksums(checksumURL string) (*checksumSet, error) {
+	data, err := d.fetchSmallFile(checksumURL)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	sums, err := parseChecksums(data)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &checksumSet{sums: sums, verified: VerifiedChecksum}
+	signature, err := d.fetchSmallFile(checksumURL + signatureSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s 的 GPG 签名失败: %w", checksumURL, err)
+	}
+	if signature == nil {
+		d.printf("ℹ️  %s 未发布 GPG 签名，仅校验摘要\n", checksumURL)
+		return set, nil
+	}
+	if err := verifySignature(d.keyring, data, signature); err != nil {
+		return nil, fmt.Errorf("%s 的 GPG 签名校验失败: %w", checksumURL, err)
+	}
+	set.verified = VerifiedSignature
+	return set, nil
+}
+
+// fetchSmallFile 下载校验文件，只有 HTTP 404 表示文件未发布，此时返回 nil。
+// 网络错误、429 和 5xx 按下载的重试次数重试，其他状态码直接返回错误
+func (d *Downloader) fetchSmallFile(url string) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			delay := d.backoff(attempt)
+			d.printf("🔄 获取 %s 失败: %v，%s 后进行第 %d/%d 次重试\n", url, err, delay, attempt, d.retries)
+			time.Sleep(delay)
+		}
+
+		var data []byte
+		data, err = d.fetchSmallFileOnce(url)
+		if err == nil {
+			return data, nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			break
+		}
+	}
+	return nil, err
+}
+
+func (d *Downloader) fetchSmallFileOnce(url string) ([]byte, error) {
+	resp, err := d.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP GET 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("下载 %s 失败，HTTP 状态码: %d", url, resp.StatusCode)
+	default:
+		return nil, permanentError{fmt.Errorf("下载 %s 失败，HTTP 状态码: %d", url, resp.StatusCode)}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxChecksumsBytes))
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", url, err)
+	}
+	return data, nil
+}
+
+// parseChecksums 解析 sha256sum 格式的 "<摘要>  <文件名>" 行
+func parseChecksums(data []byte) (map[string]string, error) {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		digest, name := strings.ToLower(fields[0]), strings.TrimPrefix(fields[1], "*")
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+			return nil, fmt.Errorf("%s 中的摘要无效: %s", checksumFilename, scanner.Text())
+		}
+		sums[filepath.Base(name)] = digest
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", checksumFilename, err)
+	}
+	return sums, nil
+}
+
+// releaseKeyring 返回用于校验 sha256sum.txt 签名的公钥
+func releaseKeyring() (openpgp.EntityList, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(redHatReleaseKey))
+	if err != nil {
+		return nil, fmt.Errorf("读取 Red Hat 发布公钥失败: %w", err)
+	}
+	return keyring, nil
+}
+
+// verifySignature 校验二进制或 ASCII armor 格式的分离签名
+func verifySignature(keyring openpgp.EntityList, data, signature []byte) error {
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN PGP")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(signature), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(signature), nil)
+	}
+	return err
+}
+
+// fileSHA256 计算文件的 sha256 摘要和大小
+func fileSHA256(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("计算 %s 的摘要失败: %w", filepath.Base(path), err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
@@ -0,0 +1,140 @@
+package download
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+func TestReleaseKeyring(t *testing.T) {
+	keyring, err := releaseKeyring()
+	if err != nil || len(keyring) == 0 {
+		t.Fatalf("releaseKeyring() = %v, %v", keyring, err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("0000  file.tar.gz\n")
+	var signature bytes.Buffer
+	if err := openpgp.DetachSign(&signature, entity, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := openpgp.EntityList{entity}
+	if err := verifySignature(keyring, data, signature.Bytes()); err != nil {
+		t.Errorf("verifySignature() error = %v", err)
+	}
+	if err := verifySignature(keyring, []byte("tampered"), signature.Bytes()); err == nil {
+		t.Error("verifySignature() accepted tampered data")
+	}
+}
+
+func TestDownloadTaskVerifiesChecksum(t *testing.T) {
+	content := []byte("openshift-install archive")
+	sum := sha256.Sum256(content)
+	checksums := hex.EncodeToString(sum[:]) + "  openshift-install-linux.tar.gz\n"
+
+	served := content
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openshift-install-linux.tar.gz", func(w http.ResponseWriter, r *http.Request) { w.Write(served) })
+	mux.HandleFunc("/sha256sum.txt", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(checksums)) })
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	d := NewDownloader(nil, dir)
+	d.manifest = &Manifest{Files: map[string]ManifestEntry{}}
+	d.retryDelay = time.Millisecond
+	task := DownloadTask{
+		URL:         server.URL + "/openshift-install-linux.tar.gz",
+		FileName:    "openshift-install-linux.tar.gz",
+		ChecksumURL: server.URL + "/sha256sum.txt",
+	}
+	destPath := filepath.Join(dir, task.FileName)
+
+	// 已存在但被截断的文件会重新下载
+	if err := os.WriteFile(destPath, content[:5], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.downloadTask(task); err != nil {
+		t.Fatalf("downloadTask() error = %v", err)
+	}
+	if data, _ := os.ReadFile(destPath); !bytes.Equal(data, content) {
+		t.Errorf("file content = %q, expected re-downloaded content", data)
+	}
+
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := manifest.Files[task.FileName]; entry.SHA256 != hex.EncodeToString(sum[:]) || entry.Verified != VerifiedChecksum {
+		t.Errorf("manifest entry = %+v", entry)
+	}
+
+	// 服务端返回的内容与摘要不符时下载失败，且不留下文件
+	os.Remove(destPath)
+	served = []byte("corrupted")
+	if err := d.downloadTask(task); err == nil {
+		t.Error("downloadTask() accepted a file with a wrong digest")
+	}
+	for _, path := range []string{destPath, destPath + partSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("downloadTask() left a corrupted file behind: %s", path)
+		}
+	}
+}
+
+func TestFetchChecksumsSignatureErrors(t *testing.T) {
+	var statuses []int // 依次返回的签名状态码，用完后返回最后一个
+	var requests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sha256sum.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("0", 64) + "  openshift-install-linux.tar.gz\n"))
+	})
+	mux.HandleFunc("/sha256sum.txt.gpg", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[min(requests, len(statuses)-1)])
+		requests++
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	d := NewDownloader(nil, t.TempDir())
+	d.retryDelay = time.Millisecond
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		requests int
+	}{
+		{"not published", []int{http.StatusNotFound}, false, 1},
+		{"forbidden", []int{http.StatusForbidden}, true, 1},
+		{"server error retried", []int{http.StatusServiceUnavailable, http.StatusNotFound}, false, 2},
+		{"server error", []int{http.StatusInternalServerError}, true, d.retries + 1},
+	}
+	for _, tt := range tests {
+		statuses, requests = tt.statuses, 0
+		set, err := d.fetchChecksums(server.URL + "/sha256sum.txt")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: fetchChecksums() error = %v", tt.name, err)
+		}
+		if !tt.wantErr && (set == nil || set.verified != VerifiedChecksum) {
+			t.Errorf("%s: fetchChecksums() = %+v", tt.name, set)
+		}
+		if requests != tt.requests {
+			t.Errorf("%s: signature requested %d times, want %d", tt.name, requests, tt.requests)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+// Package extract 从本地 Registry 中同步的 release payload 提取 openshift-install 和 oc，
+// 相当于 oc adm release extract --tools。提取的 openshift-install 内置本地 Registry 中的 release 镜像。
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/types"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/credentials"
+	"ocpack/pkg/toolchain"
+	"ocpack/pkg/utils"
+)
+
+// --- Constants ---
+const (
+	registryDirName    = "registry"
+	rootCACertFilename = "rootCA.pem"
+	imageReferences    = "release-manifests/image-references"
+
+	// oc-mirror 同步 release 时使用的仓库，与 pkg/mirror/release 一致
+	releaseImageRepository     = "openshift/release-images"
+	releaseComponentRepository = "openshift/release"
+)
+
+// Options 提取选项
+type Options struct {
+	// Tools 要提取的工具，默认为 openshift-install 和 oc
+	Tools []string
+	// Force 覆盖已经提取的工具
+	Force bool
+}
+
+// Extractor 从本地 Registry 提取与集群版本一致的工具
+type Extractor struct {
+	Config      *config.ClusterConfig
+	ClusterName string
+	ProjectRoot string
+	ClusterDir  string
+	toolchain   *toolchain.Toolchain
+}
+
+// NewExtractor 创建新的工具提取器
+func NewExtractor(clusterName, projectRoot string) (*Extractor, error) {
+	clusterDir := filepath.Join(projectRoot, clusterName)
+	configPath := filepath.Join(clusterDir, "config.toml")
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载配置文件失败: %w", err)
+	}
+	if !cfg.VersionResolved() {
+		return nil, fmt.Errorf("版本通道 %s 尚未解析为具体版本，请先运行 'ocpack download'", cfg.ClusterInfo.OpenShiftVersion)
+	}
+
+	return &Extractor{
+		Config:      cfg,
+		ClusterName: clusterName,
+		ProjectRoot: projectRoot,
+		ClusterDir:  clusterDir,
+		toolchain:   toolchain.New(cfg, clusterDir),
+	}, nil
+}
+
+// ReleaseImage 返回 oc-mirror 同步到本地 Registry 的 release 镜像
+func (e *Extractor) ReleaseImage() string {
+	return fmt.Sprintf("%s/%s:%s-%s", credentials.RegistryHost(e.Config), releaseImageRepository,
+		e.Config.ClusterInfo.OpenShiftVersion, e.Config.TargetArch())
+}
+
+// Extract 从 release payload 提取工具并检查版本
+func (e *Extractor) Extract(opts Options) error {
+	tools := opts.Tools
+	if len(tools) == 0 {
+		tools = []string{toolchain.OpenShiftInstall, toolchain.OC}
+	}
+	fmt.Printf("▶️  开始从 Registry 提取 %s 的工具\n", e.Config.ClusterInfo.OpenShiftVersion)
+
+	var pending []string
+	for _, name := range tools {
+		dest := e.toolchain.ExtractedPath(name)
+		if utils.FileExists(dest) && !opts.Force {
+			fmt.Printf("ℹ️  %s 已存在，跳过 (使用 --force 重新提取)\n", filepath.Base(dest))
+			continue
+		}
+		pending = append(pending, name)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	sys, cleanup, err := e.systemContext()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	ctx := context.Background()
+
+	// 1. 读取 release 镜像的摘要和组件镜像列表
+	releaseImage := e.ReleaseImage()
+	fmt.Printf("➡️  读取 release 镜像 %s...\n", releaseImage)
+	releaseDigest, refsData, err := readReleaseImage(ctx, sys, releaseImage)
+	if err != nil {
+		return fmt.Errorf("读取 release 镜像失败: %w", err)
+	}
+	refs, err := parseImageReferences(refsData)
+	if err != nil {
+		return err
+	}
+	if refs.Metadata.Name != e.Config.ClusterInfo.OpenShiftVersion {
+		return fmt.Errorf("Registry 中的 release 版本为 %s，与 openshift_version %s 不一致", refs.Metadata.Name, e.Config.ClusterInfo.OpenShiftVersion)
+	}
+	pinnedImage := fmt.Sprintf("%s/%s@%s", credentials.RegistryHost(e.Config), releaseImageRepository, releaseDigest)
+
+	// 2. 提取工具
+	clientArch, err := e.Config.ClientArch()
+	if err != nil {
+		return err
+	}
+	for _, name := range pending {
+		source, err := sourceFor(name, e.Config.ClientOS(), clientArch, e.Config.TargetArch())
+		if err != nil {
+			return err
+		}
+		componentImage, err := refs.componentImage(source.Tag, credentials.RegistryHost(e.Config))
+		if err != nil {
+			return err
+		}
+		dest := e.toolchain.ExtractedPath(name)
+		fmt.Printf("➡️  从 %s 提取 %s...\n", componentImage, source.Path)
+		pin := ""
+		if name == toolchain.OpenShiftInstall {
+			pin = pinnedImage
+		}
+		if err := e.extractTool(ctx, sys, componentImage, source.Path, dest, pin, refs.Metadata.Name); err != nil {
+			return fmt.Errorf("提取 %s 失败: %w", name, err)
+		}
+		fmt.Printf("✅ 已提取 %s\n", filepath.Base(dest))
+	}
+
+	// 3. 检查提取的工具
+	result := toolchain.New(e.Config, e.ClusterDir).Check(tools...)
+	for _, warning := range result.Warnings() {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	if err := result.Err(); err != nil {
+		return err
+	}
+	fmt.Printf("🎉 工具提取完成，openshift-install 使用的 release 镜像: %s\n", pinnedImage)
+	return nil
+}
+
+// extractTool 把组件镜像中的文件写入 dest，pin 不为空时写入 openshift-install 的 release 镜像
+func (e *Extractor) extractTool(ctx context.Context, sys *types.SystemContext, ref, source, dest, pin, version string) error {
+	partPath := dest + ".part"
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0755)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer os.Remove(partPath)
+	defer f.Close()
+
+	err = readImageFile(ctx, sys, ref, source, func(r io.Reader) error {
+		_, err := io.Copy(f, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if pin != "" {
+		if err := pinRelease(f, pin, version); err != nil {
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Rename(partPath, dest); err != nil {
+		return fmt.Errorf("保存文件失败: %w", err)
+	}
+	return nil
+}
+
+// systemContext 准备访问本地 Registry 的认证文件和 CA 证书，只包含 Registry 凭据
+func (e *Extractor) systemContext() (*types.SystemContext, func(), error) {
+	creds, err := credentials.LoadRegistry(e.ClusterDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth, err := credentials.MergeAuth([]byte(`{"auths":{}}`), credentials.RegistryHost(e.Config), creds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "ocpack-extract-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+
+	authPath := filepath.Join(tmpDir, "auth.json")
+	if err := os.WriteFile(authPath, auth, 0600); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("写入认证文件失败: %w", err)
+	}
+
+	// DockerCertPath 目录中的 *.crt 作为 Registry 的 CA 证书
+	certDir := filepath.Join(tmpDir, "certs")
+	if err := os.MkdirAll(certDir, 0700); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("创建证书目录失败: %w", err)
+	}
+	if caPath := e.findRootCA(); caPath != "" {
+		if err := utils.CopyFile(caPath, filepath.Join(certDir, "ca.crt")); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("复制 CA 证书失败: %w", err)
+		}
+	} else {
+		fmt.Println("⚠️  未找到 Registry 的 CA 证书，将使用系统信任的证书")
+	}
+
+	return &types.SystemContext{
+		AuthFilePath:       authPath,
+		DockerCertPath:     certDir,
+		OSChoice:           "linux",
+		ArchitectureChoice: config.GoArch(e.Config.TargetArch()),
+	}, cleanup, nil
+}
+
+// findRootCA 查找部署 Registry 时生成的 CA 证书
+func (e *Extractor) findRootCA() string {
+	hostname := fmt.Sprintf("registry.%s.%s", e.Config.ClusterInfo.Name, e.Config.ClusterInfo.Domain)
+	for _, candidate := range []string{
+		filepath.Join(e.ClusterDir, registryDirName, e.Config.Registry.IP, rootCACertFilename),
+		filepath.Join(e.ClusterDir, registryDirName, hostname, rootCACertFilename),
+		filepath.Join(e.ClusterDir, registryDirName, rootCACertFilename),
+	} {
+		if utils.FileExists(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// readReleaseImage 返回 release 镜像的摘要和 image-references 的内容
+func readReleaseImage(ctx context.Context, sys *types.SystemContext, ref string) (string, []byte, error) {
+	var refs bytes.Buffer
+	var releaseDigest string
+	err := withImage(ctx, sys, ref, func(src types.ImageSource, img types.Image) error {
+		raw, _, err := src.GetManifest(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("获取镜像清单失败: %w", err)
+		}
+		d, err := manifest.Digest(raw)
+		if err != nil {
+			return fmt.Errorf("计算镜像摘要失败: %w", err)
+		}
+		releaseDigest = d.String()
+		return findInImage(ctx, src, img, imageReferences, func(r io.Reader) error {
+			_, err := io.Copy(&refs, r)
+			return err
+		})
+	})
+	return releaseDigest, refs.Bytes(), err
+}
+
+// readImageFile 读取镜像中的文件
+func readImageFile(ctx context.Context, sys *types.SystemContext, ref, name string, fn func(io.Reader) error) error {
+	return withImage(ctx, sys, ref, func(src types.ImageSource, img types.Image) error {
+		return findInImage(ctx, src, img, name, fn)
+	})
+}
+
+// withImage 打开 Registry 中的镜像，镜像为 manifest list 时选择集群架构的镜像
+func withImage(ctx context.Context, sys *types.SystemContext, ref string, fn func(types.ImageSource, types.Image) error) error {
+	imgRef, err := docker.ParseReference("//" + ref)
+	if err != nil {
+		return fmt.Errorf("无效的镜像地址 %s: %w", ref, err)
+	}
+	src, err := imgRef.NewImageSource(ctx, sys)
+	if err != nil {
+		return fmt.Errorf("访问镜像 %s 失败: %w", ref, err)
+	}
+	img, err := image.FromSource(ctx, sys, src)
+	if err != nil {
+		src.Close()
+		return fmt.Errorf("读取镜像 %s 失败: %w", ref, err)
+	}
+	defer img.Close()
+	return fn(src, img)
+}
+
+// findInImage 从上层到下层查找文件
+func findInImage(ctx context.Context, src types.ImageSource, img types.Image, name string, fn func(io.Reader) error) error {
+	layers := img.LayerInfos()
+	for i := len(layers) - 1; i >= 0; i-- {
+		found, err := func() (bool, error) {
+			blob, _, err := src.GetBlob(ctx, layers[i], none.NoCache)
+			if err != nil {
+				return false, fmt.Errorf("下载镜像层 %s 失败: %w", layers[i].Digest, err)
+			}
+			defer blob.Close()
+			layer, _, err := compression.AutoDecompress(blob)
+			if err != nil {
+				return false, fmt.Errorf("解压镜像层 %s 失败: %w", layers[i].Digest, err)
+			}
+			defer layer.Close()
+			return findInLayer(layer, name, fn)
+		}()
+		if errors.Is(err, errFileDeleted) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+	}
+	return fmt.Errorf("镜像中没有 %s", name)
+}
@@ -0,0 +1,199 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/toolchain"
+)
+
+// openshift-install 中预留的占位符，后面是 X 填充并以 NUL 结束。
+// 与 oc adm release extract 相同，把 release 镜像和版本写入占位符，安装程序默认使用写入的镜像。
+const (
+	releaseImageMarker   = "\x00_RELEASE_IMAGE_LOCATION_\x00"
+	releaseVersionMarker = "\x00_RELEASE_VERSION_LOCATION_\x00"
+	markerChunkSize      = 1 << 20
+	maxMarkerPadding     = 4096
+)
+
+// errFileDeleted 文件在较上层的镜像层中被删除 (whiteout)
+var errFileDeleted = errors.New("文件已在镜像层中删除")
+
+// imageReferences release payload 中 release-manifests/image-references 的内容
+type imageReferences struct {
+	Metadata struct {
+		Name string `json:"name"` // release 版本
+	} `json:"metadata"`
+	Spec struct {
+		Tags []struct {
+			Name string `json:"name"`
+			From struct {
+				Name string `json:"name"`
+			} `json:"from"`
+		} `json:"tags"`
+	} `json:"spec"`
+}
+
+// toolSource 工具在 release payload 中所在的组件镜像和文件路径
+type toolSource struct {
+	Tag  string
+	Path string
+}
+
+func parseImageReferences(data []byte) (*imageReferences, error) {
+	var refs imageReferences
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return nil, fmt.Errorf("解析 image-references 失败: %w", err)
+	}
+	if len(refs.Spec.Tags) == 0 {
+		return nil, errors.New("image-references 中没有组件镜像")
+	}
+	return &refs, nil
+}
+
+// componentImage 返回组件镜像在本地 Registry 中的位置。
+// oc-mirror 把 release 的组件镜像按摘要同步到 <registry>/openshift/release。
+func (r *imageReferences) componentImage(tag, registry string) (string, error) {
+	for _, t := range r.Spec.Tags {
+		if t.Name != tag {
+			continue
+		}
+		idx := strings.LastIndex(t.From.Name, "@sha256:")
+		if idx == -1 {
+			return "", fmt.Errorf("组件 %s 的镜像 %s 没有摘要", tag, t.From.Name)
+		}
+		return fmt.Sprintf("%s/%s%s", registry, releaseComponentRepository, t.From.Name[idx:]), nil
+	}
+	return "", fmt.Errorf("release payload 中没有组件 %s", tag)
+}
+
+// sourceFor 返回工具在 release payload 中的位置。与集群架构相同的 Linux 使用组件镜像中的
+// /usr/bin，其他平台使用 *-artifacts 镜像中交叉编译的版本。
+func sourceFor(name, clientOS, clientArch, targetArch string) (toolSource, error) {
+	var image string
+	switch name {
+	case toolchain.OpenShiftInstall:
+		image = "installer"
+	case toolchain.OC:
+		image = "cli"
+	default:
+		return toolSource{}, fmt.Errorf("不支持从 release payload 提取 %s", name)
+	}
+	goArch := config.GoArch(clientArch)
+	if clientOS == "linux" && clientArch == targetArch {
+		return toolSource{Tag: image, Path: path.Join("usr/bin", name)}, nil
+	}
+
+	var platform string
+	switch {
+	case clientOS == "linux":
+		platform = "linux_" + goArch
+	case clientOS == "darwin" && goArch == "amd64":
+		platform = "mac"
+	case clientOS == "darwin" && goArch == "arm64":
+		platform = "mac_arm64"
+	default:
+		return toolSource{}, fmt.Errorf("release payload 中没有 %s/%s 平台的 %s", clientOS, goArch, name)
+	}
+	return toolSource{Tag: image + "-artifacts", Path: path.Join("usr/share/openshift", platform, name)}, nil
+}
+
+// findInLayer 在解压后的镜像层中查找文件，找到后交给 fn 处理
+func findInLayer(layer io.Reader, name string, fn func(io.Reader) error) (bool, error) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	whiteout := path.Join(path.Dir(name), ".wh."+path.Base(name))
+	tr := tar.NewReader(layer)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("读取镜像层失败: %w", err)
+		}
+		switch path.Clean(strings.TrimPrefix(hdr.Name, "/")) {
+		case whiteout:
+			return true, errFileDeleted
+		case name:
+			if hdr.Typeflag != tar.TypeReg {
+				return true, fmt.Errorf("%s 不是普通文件", name)
+			}
+			return true, fn(tr)
+		}
+	}
+}
+
+// pinRelease 把 release 镜像和版本写入 openshift-install 的占位符
+func pinRelease(f *os.File, releaseImage, version string) error {
+	found, err := patchMarker(f, releaseImageMarker, releaseImage)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("openshift-install 中没有 release 镜像占位符，可能已经写入了其他 release 镜像")
+	}
+	// 较旧的版本在构建时已经写入版本，没有版本占位符
+	if _, err := patchMarker(f, releaseVersionMarker, version); err != nil {
+		return err
+	}
+	return nil
+}
+
+// patchMarker 用 value 覆盖占位符及其 X 填充，剩余部分填充 NUL，占位符不存在时返回 false
+func patchMarker(f *os.File, marker, value string) (bool, error) {
+	offset, err := findMarker(f, []byte(marker))
+	if err != nil || offset < 0 {
+		return false, err
+	}
+
+	tail := make([]byte, maxMarkerPadding)
+	n, err := f.ReadAt(tail, offset+int64(len(marker)))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("读取占位符失败: %w", err)
+	}
+	padding := 0
+	for padding < n && tail[padding] == 'X' {
+		padding++
+	}
+	if padding == n || tail[padding] != 0 {
+		return false, fmt.Errorf("占位符 %q 格式无效", strings.Trim(marker, "\x00"))
+	}
+
+	size := len(marker) + padding + 1
+	if len(value)+1 > size {
+		return false, fmt.Errorf("%s 超过占位符长度 %d", value, size-1)
+	}
+	patched := make([]byte, size)
+	copy(patched, value)
+	if _, err := f.WriteAt(patched, offset); err != nil {
+		return false, fmt.Errorf("写入占位符失败: %w", err)
+	}
+	return true, nil
+}
+
+// findMarker 分块查找 marker 的位置，没有找到时返回 -1
+func findMarker(r io.ReaderAt, marker []byte) (int64, error) {
+	buf := make([]byte, markerChunkSize+len(marker)-1)
+	var offset int64
+	for {
+		n, err := r.ReadAt(buf, offset)
+		if i := bytes.Index(buf[:n], marker); i >= 0 {
+			return offset + int64(i), nil
+		}
+		if errors.Is(err, io.EOF) {
+			return -1, nil
+		}
+		if err != nil {
+			return -1, fmt.Errorf("读取文件失败: %w", err)
+		}
+		offset += int64(n - len(marker) + 1)
+	}
+}
@@ -0,0 +1,172 @@
+package extract
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ocpack/pkg/toolchain"
+)
+
+const testImageReferences = `{
+  "kind": "ImageStream",
+  "apiVersion": "image.openshift.io/v1",
+  "metadata": {"name": "4.16.3"},
+  "spec": {"tags": [
+    {"name": "installer", "from": {"kind": "DockerImage", "name": "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111"}},
+    {"name": "cli-artifacts", "from": {"kind": "DockerImage", "name": "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:2222"}},
+    {"name": "tools", "from": {"kind": "DockerImage", "name": "quay.io/openshift-release-dev/ocp-v4.0-art-dev:latest"}}
+  ]}
+}`
+
+func TestComponentImage(t *testing.T) {
+	refs, err := parseImageReferences([]byte(testImageReferences))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs.Metadata.Name != "4.16.3" {
+		t.Errorf("release version = %s", refs.Metadata.Name)
+	}
+
+	registry := "registry.demo.example.com:8443"
+	got, err := refs.componentImage("installer", registry)
+	if want := registry + "/openshift/release@sha256:1111"; err != nil || got != want {
+		t.Errorf("componentImage(installer) = %s, %v; want %s", got, err, want)
+	}
+	if _, err := refs.componentImage("tools", registry); err == nil {
+		t.Error("componentImage() should reject images without a digest")
+	}
+	if _, err := refs.componentImage("cli", registry); err == nil {
+		t.Error("componentImage() should fail for a missing component")
+	}
+}
+
+func TestSourceFor(t *testing.T) {
+	tests := []struct {
+		name, clientOS, clientArch, targetArch string
+		want                                   toolSource
+	}{
+		{toolchain.OpenShiftInstall, "linux", "x86_64", "x86_64", toolSource{"installer", "usr/bin/openshift-install"}},
+		{toolchain.OC, "linux", "aarch64", "aarch64", toolSource{"cli", "usr/bin/oc"}},
+		{toolchain.OC, "linux", "x86_64", "aarch64", toolSource{"cli-artifacts", "usr/share/openshift/linux_amd64/oc"}},
+		{toolchain.OpenShiftInstall, "darwin", "aarch64", "x86_64", toolSource{"installer-artifacts", "usr/share/openshift/mac_arm64/openshift-install"}},
+		{toolchain.OC, "darwin", "x86_64", "x86_64", toolSource{"cli-artifacts", "usr/share/openshift/mac/oc"}},
+	}
+	for _, tt := range tests {
+		got, err := sourceFor(tt.name, tt.clientOS, tt.clientArch, tt.targetArch)
+		if err != nil || got != tt.want {
+			t.Errorf("sourceFor(%s, %s/%s, %s) = %+v, %v; want %+v", tt.name, tt.clientOS, tt.clientArch, tt.targetArch, got, err, tt.want)
+		}
+	}
+	if _, err := sourceFor(toolchain.OcMirror, "linux", "x86_64", "x86_64"); err == nil {
+		t.Error("sourceFor(oc-mirror) should fail")
+	}
+	if _, err := sourceFor(toolchain.OC, "windows", "x86_64", "x86_64"); err == nil {
+		t.Error("sourceFor(windows) should fail")
+	}
+}
+
+// testLayer 创建包含指定文件的镜像层
+func testLayer(t *testing.T, files map[string]string) io.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestFindInLayer(t *testing.T) {
+	var got string
+	read := func(r io.Reader) error {
+		data, err := io.ReadAll(r)
+		got = string(data)
+		return err
+	}
+
+	found, err := findInLayer(testLayer(t, map[string]string{"usr/bin/oc": "oc-binary"}), "/usr/bin/oc", read)
+	if !found || err != nil || got != "oc-binary" {
+		t.Errorf("findInLayer() = %v, %v, content %q", found, err, got)
+	}
+	found, err = findInLayer(testLayer(t, map[string]string{"usr/bin/kubectl": "x"}), "/usr/bin/oc", read)
+	if found || err != nil {
+		t.Errorf("findInLayer() on a layer without the file = %v, %v", found, err)
+	}
+	_, err = findInLayer(testLayer(t, map[string]string{"usr/bin/.wh.oc": ""}), "/usr/bin/oc", read)
+	if !errors.Is(err, errFileDeleted) {
+		t.Errorf("findInLayer() on a whiteout = %v", err)
+	}
+}
+
+func TestPinRelease(t *testing.T) {
+	imagePlaceholder := releaseImageMarker + strings.Repeat("X", 200) + "\x00"
+	versionPlaceholder := releaseVersionMarker + strings.Repeat("X", 40) + "\x00"
+	// 占位符跨越读取的分块边界
+	prefix := strings.Repeat("A", markerChunkSize-5)
+	content := prefix + imagePlaceholder + "middle" + versionPlaceholder + "end"
+
+	path := filepath.Join(t.TempDir(), "openshift-install")
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	image := "registry.demo.example.com:8443/openshift/release-images@sha256:abcd"
+	if err := pinRelease(f, image, "4.16.3"); err != nil {
+		t.Fatalf("pinRelease() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != len(content) {
+		t.Fatalf("file size changed from %d to %d", len(content), len(data))
+	}
+	region := string(data[len(prefix) : len(prefix)+len(imagePlaceholder)])
+	if want := image + strings.Repeat("\x00", len(imagePlaceholder)-len(image)); region != want {
+		t.Errorf("release image region = %q", region)
+	}
+	if !strings.Contains(string(data), "middle4.16.3\x00") || !strings.HasSuffix(string(data), "\x00end") {
+		t.Error("release version was not written")
+	}
+
+	// 已经写入过的文件没有占位符
+	if err := pinRelease(f, image, "4.16.3"); err == nil {
+		t.Error("pinRelease() should fail without a placeholder")
+	}
+}
+
+func TestPatchMarkerRejectsLongValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openshift-install")
+	if err := os.WriteFile(path, []byte(releaseImageMarker+"XXXX\x00"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := patchMarker(f, releaseImageMarker, strings.Repeat("r", 100)); err == nil {
+		t.Error("patchMarker() should reject values longer than the placeholder")
+	}
+}
@@ -3,8 +3,6 @@ package iso
 import (
 	"bytes"
 	"embed"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -15,6 +13,9 @@ import (
 	"text/template"
 
 	"ocpack/pkg/config"
+	"ocpack/pkg/credentials"
+	"ocpack/pkg/preflight"
+	"ocpack/pkg/toolchain"
 	"ocpack/pkg/utils"
 
 	"gopkg.in/yaml.v3"
@@ -35,14 +36,10 @@ const (
 	installConfigFilename = "install-config.yaml"
 	agentConfigFilename   = "agent-config.yaml"
 	icspFilename          = "imageContentSourcePolicy.yaml"
-	pullSecretFilename    = "pull-secret.txt"
 	mergedAuthFilename    = "merged-auth.json"
 	tempIcspFilename      = ".icsp.yaml"
 	rootCACertFilename    = "rootCA.pem"
-	openshiftInstallCmd   = "openshift-install"
 	ocCmd                 = "oc"
-	defaultInterface      = "ens3"
-	defaultHostPrefix     = 23
 )
 
 // --- Struct Definitions ---
@@ -70,9 +67,11 @@ type InstallConfigData struct {
 	ClusterName           string
 	NumWorkers            int
 	NumMasters            int
-	MachineNetwork        string
-	PrefixLength          int
-	HostPrefix            int
+	MastersSchedulable    bool
+	Topology              string
+	ClusterNetworks       []config.ClusterNetworkEntry
+	MachineNetworks       []string
+	ServiceNetworks       []string
 	PullSecret            string
 	SSHKeyPub             string
 	AdditionalTrustBundle string
@@ -86,13 +85,10 @@ type InstallConfigData struct {
 
 // AgentConfigData agent-config.yaml 模板数据
 type AgentConfigData struct {
-	ClusterName    string
-	RendezvousIP   string
-	Hosts          []HostConfig
-	Port0          string
-	PrefixLength   int
-	NextHopAddress string
-	DNSServers     []string
+	ClusterName  string
+	RendezvousIP string
+	Hosts        []HostConfig
+	DNSServers   []string
 }
 
 // HostConfig 主机配置
@@ -101,7 +97,7 @@ type HostConfig struct {
 	Role       string
 	MACAddress string
 	IPAddress  string
-	Interface  string
+	Network    config.NodeNetwork
 }
 
 // ICSP a minimal struct for parsing ImageContentSourcePolicy
@@ -141,7 +137,7 @@ func (g *ISOGenerator) GenerateISO(options *GenerateOptions) error {
 
 	// --- 新增逻辑: 检查 ISO 是否已存在 ---
 	installDir := filepath.Join(g.ClusterDir, installDirName)
-	targetISOPath := filepath.Join(installDir, isoDirName, fmt.Sprintf("%s-agent.x86_64.iso", g.ClusterName))
+	targetISOPath := filepath.Join(installDir, isoDirName, fmt.Sprintf("%s-%s", g.ClusterName, g.agentISOName()))
 
 	if !options.Force {
 		if _, err := os.Stat(targetISOPath); err == nil {
@@ -196,18 +192,11 @@ func (g *ISOGenerator) GenerateISO(options *GenerateOptions) error {
 
 // ValidateConfig 验证所有前提条件
 func (g *ISOGenerator) ValidateConfig() error {
-	if err := config.ValidateConfig(g.Config); err != nil {
-		return err
-	}
-	toolPath := filepath.Join(g.DownloadDir, "bin", openshiftInstallCmd)
-	if _, err := os.Stat(toolPath); os.IsNotExist(err) {
-		return fmt.Errorf("缺少必需的工具: %s，请先运行 'ocpack download' 命令", openshiftInstallCmd)
-	}
-	pullSecretPath := filepath.Join(g.ClusterDir, pullSecretFilename)
-	if _, err := os.Stat(pullSecretPath); os.IsNotExist(err) {
-		return fmt.Errorf("缺少 %s 文件，请先获取 Red Hat pull-secret", pullSecretFilename)
+	result := preflight.Check(g.Config, g.ClusterDir, config.StageISO)
+	for _, warning := range result.Warnings() {
+		fmt.Printf("⚠️  %s\n", warning)
 	}
-	return nil
+	return result.Err()
 }
 
 // createInstallationDirs 创建所需的工作目录
@@ -249,14 +238,20 @@ func (g *ISOGenerator) generateInstallConfig(installDir string) error {
 		ClusterName:           g.Config.ClusterInfo.Name,
 		NumWorkers:            len(g.Config.Cluster.Worker),
 		NumMasters:            len(g.Config.Cluster.ControlPlane),
-		MachineNetwork:        utils.ExtractNetworkBase(g.Config.Cluster.Network.MachineNetwork),
-		PrefixLength:          utils.ExtractPrefixLength(g.Config.Cluster.Network.MachineNetwork),
-		HostPrefix:            defaultHostPrefix,
+		MastersSchedulable:    g.Config.MastersSchedulable(),
+		Topology:              g.Config.Topology(),
+		ClusterNetworks:       g.Config.ClusterNetworks(),
+		MachineNetworks:       utils.NormalizeCIDRs(g.Config.Cluster.Network.MachineNetwork),
+		ServiceNetworks:       utils.NormalizeCIDRs(g.Config.Cluster.Network.ServiceNetwork),
 		PullSecret:            pullSecret,
 		SSHKeyPub:             sshKey,
 		AdditionalTrustBundle: trustBundle,
 		ImageContentSources:   imageContentSources,
-		ArchShort:             "amd64",
+		ArchShort:             g.Config.InstallConfigArch(),
+		UseProxy:              g.Config.UseProxy(),
+		HTTPProxy:             g.Config.HTTPProxyURL(),
+		HTTPSProxy:            g.Config.HTTPSProxyURL(),
+		NoProxy:               g.Config.ClusterNoProxy(),
 	}
 
 	funcMap := template.FuncMap{
@@ -281,22 +276,20 @@ func (g *ISOGenerator) generateInstallConfig(installDir string) error {
 
 // generateAgentConfig 协调 agent-config.yaml 的生成
 func (g *ISOGenerator) generateAgentConfig(installDir string) error {
+	machineNetworks := g.Config.Cluster.Network.MachineNetwork
 	var hosts []HostConfig
 	for _, cp := range g.Config.Cluster.ControlPlane {
-		hosts = append(hosts, HostConfig{Hostname: cp.Name, Role: "master", MACAddress: cp.MAC, IPAddress: cp.IP, Interface: defaultInterface})
+		hosts = append(hosts, HostConfig{Hostname: cp.Name, Role: "master", MACAddress: cp.MAC, IPAddress: cp.IP, Network: cp.Network(machineNetworks)})
 	}
 	for _, worker := range g.Config.Cluster.Worker {
-		hosts = append(hosts, HostConfig{Hostname: worker.Name, Role: "worker", MACAddress: worker.MAC, IPAddress: worker.IP, Interface: defaultInterface})
+		hosts = append(hosts, HostConfig{Hostname: worker.Name, Role: "worker", MACAddress: worker.MAC, IPAddress: worker.IP, Network: worker.Network(machineNetworks)})
 	}
 
 	data := AgentConfigData{
-		ClusterName:    g.Config.ClusterInfo.Name,
-		RendezvousIP:   g.Config.Cluster.ControlPlane[0].IP,
-		Hosts:          hosts,
-		Port0:          defaultInterface,
-		PrefixLength:   utils.ExtractPrefixLength(g.Config.Cluster.Network.MachineNetwork),
-		NextHopAddress: utils.ExtractGateway(g.Config.Cluster.Network.MachineNetwork),
-		DNSServers:     []string{g.Config.Bastion.IP},
+		ClusterName:  g.Config.ClusterInfo.Name,
+		RendezvousIP: g.Config.Cluster.ControlPlane[0].IP,
+		Hosts:        hosts,
+		DNSServers:   g.Config.DNSServers(),
 	}
 
 	configPath := filepath.Join(installDir, agentConfigFilename)
@@ -305,7 +298,7 @@ func (g *ISOGenerator) generateAgentConfig(installDir string) error {
 
 // generateISOFiles 协调 ISO 文件的实际生成过程
 func (g *ISOGenerator) generateISOFiles(installDir, targetISOPath string) (string, error) {
-	openshiftInstallPath, err := g.findOpenshiftInstall()
+	openshiftInstallPath, err := toolchain.New(g.Config, g.ClusterDir).Path(toolchain.OpenShiftInstall)
 	if err != nil {
 		return "", fmt.Errorf("查找 openshift-install 失败: %w", err)
 	}
@@ -332,7 +325,7 @@ func (g *ISOGenerator) generateISOFiles(installDir, targetISOPath string) (strin
 		return "", fmt.Errorf("生成 agent ISO 失败: %w", err)
 	}
 
-	agentISOPath := filepath.Join(tempDir, "agent.x86_64.iso")
+	agentISOPath := filepath.Join(tempDir, g.agentISOName())
 	if err := utils.MoveFile(agentISOPath, targetISOPath); err != nil {
 		return "", fmt.Errorf("移动 ISO 文件失败: %w", err)
 	}
@@ -354,6 +347,11 @@ func (g *ISOGenerator) generateISOFiles(installDir, targetISOPath string) (strin
 
 // --- Helper Functions ---
 
+// agentISOName 返回 openshift-install 生成的 ISO 文件名，如 agent.aarch64.iso
+func (g *ISOGenerator) agentISOName() string {
+	return fmt.Sprintf("agent.%s.iso", g.Config.TargetArch())
+}
+
 // executeTemplate 通用的模板执行函数
 func (g *ISOGenerator) executeTemplate(templatePath, outputPath string, data interface{}, funcMap template.FuncMap) error {
 	tmplContent, err := templates.ReadFile(templatePath)
@@ -395,19 +393,34 @@ func (g *ISOGenerator) getPullSecret() (string, error) {
 		return strings.TrimSpace(string(secretBytes)), nil
 	}
 
+	// 启用密钥库时只在内存中合并，不写入明文的合并认证文件
+	if credentials.UseSecretsStore(g.ClusterDir) {
+		merged, err := credentials.MergedPullSecret(g.Config, g.ClusterDir)
+		if err == nil {
+			fmt.Println("ℹ️  使用密钥库中的凭据合并认证配置")
+			return string(merged), nil
+		}
+		fmt.Printf("⚠️  合并认证配置失败: %v。将回退到原始 pull-secret。\n", err)
+		return g.readPullSecret()
+	}
+
 	fmt.Println("ℹ️  合并认证文件不存在，将创建并使用它...")
 	if err := g.createMergedAuthConfig(); err != nil {
 		fmt.Printf("⚠️  创建合并认证文件失败: %v。将回退到原始 pull-secret。\n", err)
-		pullSecretPath := filepath.Join(g.ClusterDir, pullSecretFilename)
-		secretBytes, err := os.ReadFile(pullSecretPath)
-		if err != nil {
-			return "", fmt.Errorf("读取原始 pull-secret 失败: %w", err)
-		}
-		return strings.TrimSpace(string(secretBytes)), nil
+		return g.readPullSecret()
 	}
 	return g.getPullSecret()
 }
 
+// readPullSecret 读取未合并的原始 pull-secret
+func (g *ISOGenerator) readPullSecret() (string, error) {
+	secretBytes, err := credentials.ReadPullSecret(g.ClusterDir)
+	if err != nil {
+		return "", fmt.Errorf("读取原始 pull-secret 失败: %w", err)
+	}
+	return string(secretBytes), nil
+}
+
 // getSSHKey 获取用户的公钥
 func (g *ISOGenerator) getSSHKey() (string, error) {
 	home, err := os.UserHomeDir()
@@ -430,9 +443,12 @@ func (g *ISOGenerator) getAdditionalTrustBundle() (string, error) {
 	}
 	for _, certPath := range possibleCertPaths {
 		if caCertBytes, err := os.ReadFile(certPath); err == nil {
-			return string(caCertBytes), nil
+			return g.Config.WithCABundle(string(caCertBytes))
 		}
 	}
+	if g.Config.Download.CABundle != "" {
+		return g.Config.WithCABundle("")
+	}
 	return "", errors.New("在任何预期位置都未找到 " + rootCACertFilename)
 }
 
@@ -524,58 +540,13 @@ func (g *ISOGenerator) findLatestResultsDir(workspaceDir string) (string, error)
 	return latestDir, nil
 }
 
-// findOpenshiftInstall 查找可用的 openshift-install 二进制文件
-func (g *ISOGenerator) findOpenshiftInstall() (string, error) {
-	registryHost := fmt.Sprintf("registry.%s.%s", g.Config.ClusterInfo.Name, g.Config.ClusterInfo.Domain)
-	extractedBinary := filepath.Join(g.ClusterDir, fmt.Sprintf("%s-%s-%s", openshiftInstallCmd, g.Config.ClusterInfo.OpenShiftVersion, registryHost))
-	if _, err := os.Stat(extractedBinary); err == nil {
-		fmt.Printf("ℹ️  使用从 Registry 提取的 openshift-install: %s\n", extractedBinary)
-		return extractedBinary, nil
-	}
-
-	downloadedBinary := filepath.Join(g.DownloadDir, "bin", openshiftInstallCmd)
-	if _, err := os.Stat(downloadedBinary); err == nil {
-		fmt.Printf("ℹ️  使用下载的 openshift-install: %s\n", downloadedBinary)
-		return downloadedBinary, nil
-	}
-
-	return "", fmt.Errorf("在 %s 或 %s 中均未找到 %s 工具", extractedBinary, downloadedBinary, openshiftInstallCmd)
-}
-
 // createMergedAuthConfig 创建包含私有仓库认证的 pull-secret 文件
 func (g *ISOGenerator) createMergedAuthConfig() error {
 	fmt.Println("🔐 创建合并的认证配置文件...")
 
-	pullSecretPath := filepath.Join(g.ClusterDir, pullSecretFilename)
-	pullSecretContent, err := os.ReadFile(pullSecretPath)
+	mergedAuthContent, err := credentials.MergedPullSecret(g.Config, g.ClusterDir)
 	if err != nil {
-		return fmt.Errorf("读取 %s 失败: %w", pullSecretFilename, err)
-	}
-
-	var pullSecretData map[string]interface{}
-	if err := json.Unmarshal(pullSecretContent, &pullSecretData); err != nil {
-		return fmt.Errorf("解析 %s JSON 失败: %w", pullSecretFilename, err)
-	}
-
-	auths, ok := pullSecretData["auths"].(map[string]interface{})
-	if !ok {
-		return errors.New("pull-secret.txt 格式无效: 缺少 'auths' 字段")
-	}
-
-	registryHostname := fmt.Sprintf("registry.%s.%s", g.Config.ClusterInfo.Name, g.Config.ClusterInfo.Domain)
-	registryURL := fmt.Sprintf("%s:8443", registryHostname)
-
-	authString := fmt.Sprintf("%s:ztesoft123", g.Config.Registry.RegistryUser)
-	authBase64 := base64.StdEncoding.EncodeToString([]byte(authString))
-
-	auths[registryURL] = map[string]interface{}{
-		"auth":  authBase64,
-		"email": "user@example.com",
-	}
-
-	mergedAuthContent, err := json.MarshalIndent(pullSecretData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化合并后的认证配置失败: %w", err)
+		return err
 	}
 
 	registryDir := filepath.Join(g.ClusterDir, registryDirName)
@@ -0,0 +1,199 @@
+package iso
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ocpack/pkg/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateAgentConfigNetwork(t *testing.T) {
+	cfg := config.NewDefaultConfig("demo")
+	cfg.Bastion.IP = "192.168.1.10"
+	cfg.Cluster.Worker = nil
+	cfg.Cluster.ControlPlane = cfg.Cluster.ControlPlane[:2]
+	cfg.Cluster.ControlPlane[0].IP = "192.168.1.21"
+	cfg.Cluster.ControlPlane[0].MAC = "52:54:00:00:00:01"
+	cfg.Cluster.ControlPlane[1] = config.Node{
+		Name:       "master-1",
+		IP:         "192.168.1.22",
+		MAC:        "52:54:00:00:00:02",
+		Interface:  "eno1",
+		Interfaces: []config.NodeInterface{{Name: "eno2", MAC: "52:54:00:00:00:03"}},
+		Bond:       &config.Bond{Mode: "802.3ad", Options: map[string]string{"miimon": "100"}},
+		VLAN:       100,
+		Gateway:    "192.168.1.254",
+		Routes:     []config.Route{{Destination: "10.10.0.0/16"}},
+	}
+
+	dir := t.TempDir()
+	g := &ISOGenerator{Config: cfg}
+	if err := g.generateAgentConfig(dir); err != nil {
+		t.Fatalf("generateAgentConfig() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, agentConfigFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var agent struct {
+		Hosts []struct {
+			Interfaces []struct {
+				Name string `yaml:"name"`
+			} `yaml:"interfaces"`
+			NetworkConfig struct {
+				Interfaces []map[string]interface{} `yaml:"interfaces"`
+				Routes     struct {
+					Config []map[string]interface{} `yaml:"config"`
+				} `yaml:"routes"`
+			} `yaml:"networkConfig"`
+		} `yaml:"hosts"`
+	}
+	if err := yaml.Unmarshal(data, &agent); err != nil {
+		t.Fatalf("agent-config.yaml is not valid YAML: %v\n%s", err, data)
+	}
+	if len(agent.Hosts) != 2 {
+		t.Fatalf("expected 2 hosts, got %d", len(agent.Hosts))
+	}
+
+	// 默认节点: 单网卡 ens3，网关为机器网络第一个地址
+	simple := agent.Hosts[0].NetworkConfig
+	if len(simple.Interfaces) != 1 || simple.Interfaces[0]["name"] != config.DefaultNodeInterface {
+		t.Errorf("default host interfaces = %v", simple.Interfaces)
+	}
+	if route := simple.Routes.Config[0]; route["next-hop-address"] != "192.168.1.1" || route["next-hop-interface"] != "ens3" {
+		t.Errorf("default host route = %v", route)
+	}
+
+	// bond + VLAN 节点: 两块物理网卡，bond0 和 bond0.100，IP 位于 VLAN 接口
+	host := agent.Hosts[1]
+	if len(host.Interfaces) != 2 {
+		t.Errorf("bond host should list both NICs, got %v", host.Interfaces)
+	}
+	var names []string
+	for _, iface := range host.NetworkConfig.Interfaces {
+		names = append(names, iface["name"].(string)+"/"+iface["type"].(string))
+	}
+	if got := strings.Join(names, ","); got != "bond0/bond,bond0.100/vlan" {
+		t.Errorf("bond host NMState interfaces = %s", got)
+	}
+	routes := host.NetworkConfig.Routes.Config
+	if len(routes) != 2 || routes[0]["next-hop-address"] != "192.168.1.254" || routes[1]["destination"] != "10.10.0.0/16" || routes[1]["next-hop-interface"] != "bond0.100" {
+		t.Errorf("bond host routes = %v", routes)
+	}
+}
+
+func TestGenerateAgentConfigDualStack(t *testing.T) {
+	cfg := config.NewDefaultConfig("demo")
+	cfg.Bastion.IP = "192.168.1.10"
+	cfg.Bastion.IPv6 = "fd00:1::10"
+	cfg.Cluster.Network.MachineNetwork = []string{"192.168.1.0/24", "fd00:1::/64"}
+	cfg.Cluster.Worker = nil
+	cfg.Cluster.ControlPlane = []config.Node{{Name: "master-0", IP: "192.168.1.21", IPv6: "fd00:1::21", MAC: "52:54:00:00:00:01"}}
+
+	dir := t.TempDir()
+	g := &ISOGenerator{Config: cfg}
+	if err := g.generateAgentConfig(dir); err != nil {
+		t.Fatalf("generateAgentConfig() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, agentConfigFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var agent struct {
+		Hosts []struct {
+			NetworkConfig struct {
+				Interfaces []struct {
+					IPv6 struct {
+						Enabled bool `yaml:"enabled"`
+						Address []struct {
+							IP           string `yaml:"ip"`
+							PrefixLength int    `yaml:"prefix-length"`
+						} `yaml:"address"`
+					} `yaml:"ipv6"`
+				} `yaml:"interfaces"`
+				DNSResolver struct {
+					Config struct {
+						Server []string `yaml:"server"`
+					} `yaml:"config"`
+				} `yaml:"dns-resolver"`
+				Routes struct {
+					Config []map[string]interface{} `yaml:"config"`
+				} `yaml:"routes"`
+			} `yaml:"networkConfig"`
+		} `yaml:"hosts"`
+	}
+	if err := yaml.Unmarshal(data, &agent); err != nil {
+		t.Fatalf("agent-config.yaml is not valid YAML: %v\n%s", err, data)
+	}
+
+	network := agent.Hosts[0].NetworkConfig
+	ipv6 := network.Interfaces[0].IPv6
+	if !ipv6.Enabled || len(ipv6.Address) != 1 || ipv6.Address[0].IP != "fd00:1::21" || ipv6.Address[0].PrefixLength != 64 {
+		t.Errorf("ipv6 config = %+v", ipv6)
+	}
+	if got := strings.Join(network.DNSResolver.Config.Server, ","); got != "192.168.1.10,fd00:1::10" {
+		t.Errorf("dns servers = %s", got)
+	}
+	routes := network.Routes.Config
+	if len(routes) != 2 || routes[1]["destination"] != "::/0" || routes[1]["next-hop-address"] != "fd00:1::1" {
+		t.Errorf("routes = %v", routes)
+	}
+}
+
+func TestGenerateInstallConfigArchAndProxy(t *testing.T) {
+	cfg := config.NewDefaultConfig("demo")
+	cfg.ClusterInfo.TargetArch = "aarch64"
+	cfg.Download.HTTPSProxy = "http://proxy.example.com:3128"
+	cfg.Download.NoProxy = []string{".corp.example.com"}
+
+	clusterDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(clusterDir, "pull-secret.txt"), []byte(`{"auths":{}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	g := &ISOGenerator{Config: cfg, ClusterDir: clusterDir}
+	if name := g.agentISOName(); name != "agent.aarch64.iso" {
+		t.Errorf("agentISOName() = %s, expected agent.aarch64.iso", name)
+	}
+
+	installDir := t.TempDir()
+	if err := g.generateInstallConfig(installDir); err != nil {
+		t.Fatalf("generateInstallConfig() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(installDir, installConfigFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var installConfig struct {
+		Proxy   map[string]string `yaml:"proxy"`
+		Compute []struct {
+			Architecture string `yaml:"architecture"`
+		} `yaml:"compute"`
+		ControlPlane struct {
+			Architecture string `yaml:"architecture"`
+		} `yaml:"controlPlane"`
+	}
+	if err := yaml.Unmarshal(data, &installConfig); err != nil {
+		t.Fatalf("install-config.yaml is not valid YAML: %v\n%s", err, data)
+	}
+	if installConfig.ControlPlane.Architecture != "arm64" || installConfig.Compute[0].Architecture != "arm64" {
+		t.Errorf("architecture = %s/%s, expected arm64", installConfig.ControlPlane.Architecture, installConfig.Compute[0].Architecture)
+	}
+	expectedProxy := map[string]string{
+		"httpsProxy": "http://proxy.example.com:3128",
+		"noProxy":    ".corp.example.com,registry.demo.example.com",
+	}
+	if len(installConfig.Proxy) != len(expectedProxy) {
+		t.Errorf("proxy = %v, expected %v", installConfig.Proxy, expectedProxy)
+	}
+	for key, value := range expectedProxy {
+		if installConfig.Proxy[key] != value {
+			t.Errorf("proxy.%s = %q, expected %q", key, installConfig.Proxy[key], value)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package loadimage
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -12,19 +10,18 @@ import (
 	"strings"
 
 	"ocpack/pkg/config"
+	"ocpack/pkg/credentials"
+	"ocpack/pkg/toolchain"
 )
 
 // --- Constants ---
 const (
 	imagesDirName      = "images"
 	registryDirName    = "registry"
-	pullSecretFilename = "pull-secret.txt"
 	mergedAuthFilename = "merged-auth.json"
 	rootCACertFilename = "rootCA.pem"
-	ocMirrorCmd        = "oc-mirror"
 	podmanCmd          = "podman"
 	dockerCmd          = "docker"
-	registryPassword   = "ztesoft123" // Centralized fixed password
 )
 
 // ImageLoader is responsible for loading images from disk to a registry.
@@ -100,7 +97,7 @@ func (l *ImageLoader) LoadToRegistry() error {
 	registryHostname := fmt.Sprintf("registry.%s.%s", l.Config.ClusterInfo.Name, l.Config.ClusterInfo.Domain)
 	fmt.Printf("   Registry URL: https://%s:8443\n", registryHostname)
 	fmt.Printf("   用户名: %s\n", l.Config.Registry.RegistryUser)
-	fmt.Printf("   密码: %s\n", registryPassword)
+	fmt.Printf("   密码: 保存在 %s\n", credentials.RegistryLocation(l.ClusterDir))
 	return nil
 }
 
@@ -110,11 +107,18 @@ func (l *ImageLoader) validateRegistry() error {
 	registryURL := fmt.Sprintf("%s:8443", registryHostname)
 	fmt.Printf("ℹ️  正在验证 Quay registry 连接: %s\n", registryURL)
 
+	creds, err := credentials.LoadRegistry(l.ClusterDir)
+	if err != nil {
+		return err
+	}
+
+	// 密码通过 stdin 传递，不出现在进程参数中
 	containerTool := l.getContainerTool()
 	cmd := exec.Command(containerTool, "login",
-		"--username", l.Config.Registry.RegistryUser,
-		"--password", registryPassword, // 优化: 使用常量
+		"--username", creds.Username,
+		"--password-stdin",
 		registryURL)
+	cmd.Stdin = strings.NewReader(creds.Password)
 
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("使用 '%s' 登录失败: %w, 输出: %s", containerTool, err, string(output))
@@ -136,41 +140,16 @@ func (l *ImageLoader) getContainerTool() string {
 
 // createOrUpdateAuthConfig merges the Red Hat pull secret with the local registry credentials.
 func (l *ImageLoader) createOrUpdateAuthConfig() error {
-	pullSecretPath := filepath.Join(l.ClusterDir, pullSecretFilename)
-	pullSecretContent, err := os.ReadFile(pullSecretPath)
+	mergedAuthContent, err := credentials.MergedPullSecret(l.Config, l.ClusterDir)
 	if err != nil {
-		return fmt.Errorf("读取 pull-secret 失败: %w", err)
+		return err
 	}
 
-	var pullSecretData map[string]interface{}
-	if err := json.Unmarshal(pullSecretContent, &pullSecretData); err != nil {
-		return fmt.Errorf("解析 pull-secret JSON 失败: %w", err)
-	}
-
-	auths, ok := pullSecretData["auths"].(map[string]interface{})
-	if !ok {
-		return errors.New("pull-secret 格式无效: 缺少 'auths' 字段")
-	}
-
-	registryHostname := fmt.Sprintf("registry.%s.%s", l.Config.ClusterInfo.Name, l.Config.ClusterInfo.Domain)
-	registryURL := fmt.Sprintf("%s:8443", registryHostname)
-	authString := fmt.Sprintf("%s:%s", l.Config.Registry.RegistryUser, registryPassword)
-	authBase64 := base64.StdEncoding.EncodeToString([]byte(authString))
-
-	auths[registryURL] = map[string]interface{}{
-		"auth":  authBase64,
-		"email": "user@example.com", // email is a required field for some tools
-	}
-
-	mergedAuthContent, err := json.MarshalIndent(pullSecretData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("序列化合并后的认证配置失败: %w", err)
-	}
-
-	// Save to multiple conventional locations
-	authPaths := []string{
-		filepath.Join(l.ClusterDir, registryDirName, mergedAuthFilename),
-		filepath.Join(os.Getenv("HOME"), ".docker", "config.json"),
+	// Save to multiple conventional locations.
+	// With the secrets store enabled, no plaintext copy is written into the cluster directory.
+	authPaths := []string{dockerConfigPath()}
+	if !credentials.UseSecretsStore(l.ClusterDir) {
+		authPaths = append([]string{filepath.Join(l.ClusterDir, registryDirName, mergedAuthFilename)}, authPaths...)
 	}
 
 	for _, authPath := range authPaths {
@@ -186,11 +165,23 @@ func (l *ImageLoader) createOrUpdateAuthConfig() error {
 	return nil
 }
 
+// authFile returns the auth file passed to oc-mirror as REGISTRY_AUTH_FILE.
+func (l *ImageLoader) authFile() string {
+	if credentials.UseSecretsStore(l.ClusterDir) {
+		return dockerConfigPath()
+	}
+	return filepath.Join(l.ClusterDir, registryDirName, mergedAuthFilename)
+}
+
+func dockerConfigPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".docker", "config.json")
+}
+
 // runOcMirrorLoad executes the 'oc-mirror' command to load images.
 func (l *ImageLoader) runOcMirrorLoad() error {
-	ocMirrorPath := filepath.Join(l.DownloadDir, "bin", ocMirrorCmd)
-	if _, err := os.Stat(ocMirrorPath); os.IsNotExist(err) {
-		return fmt.Errorf("oc-mirror 工具不存在: %s", ocMirrorPath)
+	ocMirrorPath, err := toolchain.New(l.Config, l.ClusterDir).Path(toolchain.OcMirror)
+	if err != nil {
+		return err
 	}
 
 	registryHostname := fmt.Sprintf("registry.%s.%s", l.Config.ClusterInfo.Name, l.Config.ClusterInfo.Domain)
@@ -208,7 +199,7 @@ func (l *ImageLoader) runOcMirrorLoad() error {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Env = append(os.Environ(),
-		"REGISTRY_AUTH_FILE="+filepath.Join(l.ClusterDir, registryDirName, mergedAuthFilename),
+		"REGISTRY_AUTH_FILE="+l.authFile(),
 	)
 
 	if err := cmd.Run(); err != nil {
@@ -226,7 +217,7 @@ func (l *ImageLoader) runOcMirrorLoad() error {
 // printManualInstructions provides clear instructions for manual execution.
 func (l *ImageLoader) printManualInstructions(cmdPath string, args []string) {
 	fmt.Println("   请在与 oc-mirror 工具架构兼容的 Linux 系统上，手动执行以下命令:")
-	fmt.Printf("   export REGISTRY_AUTH_FILE=%s\n", filepath.Join(l.ClusterDir, registryDirName, mergedAuthFilename))
+	fmt.Printf("   export REGISTRY_AUTH_FILE=%s\n", l.authFile())
 	fmt.Printf("   %s %s\n", cmdPath, strings.Join(args, " "))
 }
 
@@ -0,0 +1,228 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Inputs 决定一个阶段是否需要重新执行的输入
+type Inputs struct {
+	Values map[string]interface{} // 配置项，序列化为 JSON 后计算哈希
+	Files  []string               // 相对于集群目录的文件或目录，按大小和修改时间计算哈希，目录计入其中的每个文件
+}
+
+// Stage 流水线中的一个阶段
+type Stage struct {
+	Name string
+	// DependsOn 使用其输出的阶段，这些阶段在此阶段之后重新执行过 (包括之前的运行) 时此阶段也会重新执行
+	DependsOn []string
+	Inputs    func() (Inputs, error)
+	// Run 执行阶段，返回生成的文件 (相对于集群目录)。文件缺失时阶段会重新执行
+	Run func() ([]string, error)
+}
+
+// Options 选择要执行的阶段
+type Options struct {
+	FromStage string // 从此阶段开始执行，不检查此阶段及之后阶段的记录
+	OnlyStage string // 只执行此阶段
+	Force     bool   // 忽略所有记录，执行全部阶段
+}
+
+// Pipeline 按顺序执行各阶段，把结果记录在集群目录的状态文件中。
+// 上次成功、输入未变化、输出仍然存在且依赖的阶段之后没有重新执行的阶段会被跳过，
+// 因此重新运行时从第一个失败的阶段继续。
+type Pipeline struct {
+	ClusterDir string
+	Stages     []Stage
+	State      *State
+
+	now func() time.Time
+}
+
+// New 创建流水线并读取集群目录中的状态
+func New(clusterDir string, stages []Stage) (*Pipeline, error) {
+	state, err := LoadState(clusterDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Pipeline{ClusterDir: clusterDir, Stages: stages, State: state, now: time.Now}, nil
+}
+
+// Run 执行流水线
+func (p *Pipeline) Run(opts Options) error {
+	names := make([]string, len(p.Stages))
+	for i, stage := range p.Stages {
+		names[i] = stage.Name
+	}
+	if opts.FromStage != "" && opts.OnlyStage != "" {
+		return fmt.Errorf("--from-stage 和 --only-stage 不能同时使用")
+	}
+	from := 0
+	for _, name := range []string{opts.FromStage, opts.OnlyStage} {
+		if name == "" {
+			continue
+		}
+		i := slices.Index(names, name)
+		if i < 0 {
+			return fmt.Errorf("未知的阶段 %q，可选: %s", name, strings.Join(names, ", "))
+		}
+		from = i
+	}
+
+	for i, stage := range p.Stages {
+		if i < from || (opts.OnlyStage != "" && stage.Name != opts.OnlyStage) {
+			continue
+		}
+		hash, err := p.inputsHash(stage)
+		if err != nil {
+			return fmt.Errorf("计算阶段 %s 的输入失败: %w", stage.Name, err)
+		}
+
+		explicit := opts.Force || opts.OnlyStage != "" || opts.FromStage != ""
+		if !explicit {
+			reason := p.upToDate(stage, hash)
+			if reason == "" {
+				fmt.Printf("⏭️  跳过阶段 %s: 输入未变化，上次完成于 %s\n", stage.Name, p.State.Stages[stage.Name].FinishedAt.Local().Format("2006-01-02 15:04:05"))
+				continue
+			}
+			fmt.Printf("ℹ️  阶段 %s 需要执行: %s\n", stage.Name, reason)
+		}
+
+		if err := p.runStage(stage, hash); err != nil {
+			return fmt.Errorf("阶段 %s 失败: %w (修复后重新运行将从此阶段继续)", stage.Name, err)
+		}
+	}
+	return nil
+}
+
+// upToDate 返回阶段需要重新执行的原因，可以跳过时返回空字符串
+func (p *Pipeline) upToDate(stage Stage, hash string) string {
+	s, ok := p.State.Stages[stage.Name]
+	switch {
+	case !ok:
+		return "尚未执行"
+	case s.Status == StatusFailed:
+		return "上次执行失败"
+	case s.Status != StatusSucceeded:
+		return "上次执行未完成"
+	case s.InputsHash != hash:
+		return "输入已变化"
+	}
+	for _, output := range s.Outputs {
+		if _, err := os.Stat(filepath.Join(p.ClusterDir, output)); err != nil {
+			return fmt.Sprintf("输出 %s 不存在", output)
+		}
+	}
+	for _, dep := range stage.DependsOn {
+		d, ok := p.State.Stages[dep]
+		if !ok || d.Status != StatusSucceeded {
+			continue
+		}
+		// 旧版本的状态文件没有记录依赖，按时间判断
+		recorded, ok := s.Dependencies[dep]
+		if (ok && !d.FinishedAt.Equal(recorded)) || (!ok && d.FinishedAt.After(s.StartedAt)) {
+			return fmt.Sprintf("阶段 %s 已重新执行", dep)
+		}
+	}
+	return ""
+}
+
+func (p *Pipeline) runStage(stage Stage, hash string) error {
+	s := &StageState{Status: StatusRunning, InputsHash: hash, StartedAt: p.now().UTC()}
+	for _, dep := range stage.DependsOn {
+		if d, ok := p.State.Stages[dep]; ok && d.Status == StatusSucceeded {
+			if s.Dependencies == nil {
+				s.Dependencies = map[string]time.Time{}
+			}
+			s.Dependencies[dep] = d.FinishedAt
+		}
+	}
+	p.State.Stages[stage.Name] = s
+	if err := p.State.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n▶️  阶段 %s\n", stage.Name)
+	outputs, runErr := stage.Run()
+	s.FinishedAt = p.now().UTC()
+	s.Outputs = outputs
+	if runErr != nil {
+		s.Status = StatusFailed
+		s.Error = runErr.Error()
+	} else {
+		s.Status = StatusSucceeded
+	}
+	if err := p.State.Save(); err != nil {
+		if runErr != nil {
+			return runErr
+		}
+		return err
+	}
+	return runErr
+}
+
+// inputsHash 计算阶段输入的哈希
+func (p *Pipeline) inputsHash(stage Stage) (string, error) {
+	h := sha256.New()
+	if stage.Inputs == nil {
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	inputs, err := stage.Inputs()
+	if err != nil {
+		return "", err
+	}
+	// json.Marshal 按键排序，结果与 map 的遍历顺序无关
+	values, err := json.Marshal(inputs.Values)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "values:%s\n", values)
+	for _, file := range inputs.Files {
+		if err := hashFile(h, p.ClusterDir, file); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile 把文件的大小和修改时间写入哈希，目录按文件名顺序写入其中的每个文件
+func hashFile(w io.Writer, clusterDir, file string) error {
+	root := filepath.Join(clusterDir, file)
+	info, err := os.Stat(root)
+	if err != nil {
+		fmt.Fprintf(w, "file:%s:missing\n", file)
+		return nil
+	}
+	if !info.IsDir() {
+		fmt.Fprintf(w, "file:%s:%d:%d\n", file, info.Size(), info.ModTime().UnixNano())
+		return nil
+	}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(clusterDir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "file:%s:%d:%d\n", filepath.ToSlash(rel), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("读取 %s 失败: %w", file, err)
+	}
+	return nil
+}
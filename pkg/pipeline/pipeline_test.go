@@ -0,0 +1,269 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"ocpack/pkg/config"
+)
+
+// testStages 记录每个阶段的执行，failing 中的阶段返回错误
+type testStages struct {
+	ran     []string
+	failing map[string]bool
+	inputs  map[string]string
+}
+
+func (ts *testStages) stages(clusterDir string) []Stage {
+	var stages []Stage
+	for _, name := range []string{"a", "b", "c"} {
+		stages = append(stages, Stage{
+			Name:      name,
+			DependsOn: map[string][]string{"c": {"b"}}[name],
+			Inputs: func() (Inputs, error) {
+				return Inputs{Values: map[string]interface{}{"v": ts.inputs[name]}}, nil
+			},
+			Run: func() ([]string, error) {
+				ts.ran = append(ts.ran, name)
+				if ts.failing[name] {
+					return nil, errors.New("boom")
+				}
+				output := name + ".out"
+				return []string{output}, os.WriteFile(filepath.Join(clusterDir, output), nil, 0644)
+			},
+		})
+	}
+	return stages
+}
+
+func (ts *testStages) run(t *testing.T, clusterDir string, opts Options) error {
+	t.Helper()
+	ts.ran = nil
+	p, err := New(clusterDir, ts.stages(clusterDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Run(opts)
+}
+
+func TestPipelineResume(t *testing.T) {
+	dir := t.TempDir()
+	ts := &testStages{failing: map[string]bool{"b": true}, inputs: map[string]string{}}
+
+	if err := ts.run(t, dir, Options{}); err == nil {
+		t.Fatal("Run() should fail when stage b fails")
+	}
+	if !slices.Equal(ts.ran, []string{"a", "b"}) {
+		t.Errorf("first run = %v", ts.ran)
+	}
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Stages["a"].Status != StatusSucceeded || state.Stages["b"].Status != StatusFailed || state.Stages["b"].Error != "boom" {
+		t.Errorf("state = a:%+v b:%+v", state.Stages["a"], state.Stages["b"])
+	}
+
+	// 从失败的阶段继续
+	ts.failing = nil
+	if err := ts.run(t, dir, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ts.ran, []string{"b", "c"}) {
+		t.Errorf("resumed run = %v", ts.ran)
+	}
+
+	// 全部完成后不执行任何阶段
+	if err := ts.run(t, dir, Options{}); err != nil || len(ts.ran) != 0 {
+		t.Errorf("up-to-date run = %v, %v", ts.ran, err)
+	}
+}
+
+func TestPipelineRerunsChangedStages(t *testing.T) {
+	dir := t.TempDir()
+	ts := &testStages{inputs: map[string]string{}}
+	if err := ts.run(t, dir, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// b 的输入变化，依赖 b 的 c 也重新执行
+	ts.inputs["b"] = "changed"
+	if err := ts.run(t, dir, Options{}); err != nil || !slices.Equal(ts.ran, []string{"b", "c"}) {
+		t.Errorf("after input change = %v, %v", ts.ran, err)
+	}
+
+	// 输出被删除
+	if err := os.Remove(filepath.Join(dir, "a.out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.run(t, dir, Options{}); err != nil || !slices.Equal(ts.ran, []string{"a"}) {
+		t.Errorf("after output removed = %v, %v", ts.ran, err)
+	}
+}
+
+func TestPipelineRerunsDependentsAcrossRuns(t *testing.T) {
+	dir := t.TempDir()
+	ts := &testStages{inputs: map[string]string{}}
+	if err := ts.run(t, dir, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 单独执行 b 后，下一次完整运行中依赖 b 的 c 重新执行
+	if err := ts.run(t, dir, Options{OnlyStage: "b"}); err != nil || !slices.Equal(ts.ran, []string{"b"}) {
+		t.Fatalf("only stage b = %v, %v", ts.ran, err)
+	}
+	if err := ts.run(t, dir, Options{}); err != nil || !slices.Equal(ts.ran, []string{"c"}) {
+		t.Errorf("after b reran = %v, %v", ts.ran, err)
+	}
+	if err := ts.run(t, dir, Options{}); err != nil || len(ts.ran) != 0 {
+		t.Errorf("second run after b reran = %v, %v", ts.ran, err)
+	}
+
+	// 旧版本的状态文件没有记录依赖，b 在 c 之后完成时 c 重新执行
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Stages["c"].Dependencies = nil
+	state.Stages["b"].FinishedAt = state.Stages["c"].StartedAt.Add(time.Second)
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.run(t, dir, Options{}); err != nil || !slices.Equal(ts.ran, []string{"c"}) {
+		t.Errorf("legacy state = %v, %v", ts.ran, err)
+	}
+}
+
+func TestPipelineStageSelection(t *testing.T) {
+	dir := t.TempDir()
+	ts := &testStages{inputs: map[string]string{}}
+	if err := ts.run(t, dir, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts Options
+		want []string
+	}{
+		{Options{FromStage: "b"}, []string{"b", "c"}},
+		{Options{OnlyStage: "a"}, []string{"a"}},
+		{Options{Force: true}, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		if err := ts.run(t, dir, tt.opts); err != nil || !slices.Equal(ts.ran, tt.want) {
+			t.Errorf("Run(%+v) = %v, %v, want %v", tt.opts, ts.ran, err, tt.want)
+		}
+	}
+
+	for _, opts := range []Options{{OnlyStage: "x"}, {FromStage: "a", OnlyStage: "b"}} {
+		if err := ts.run(t, dir, opts); err == nil || len(ts.ran) != 0 {
+			t.Errorf("Run(%+v) should fail without running stages, ran %v", opts, ts.ran)
+		}
+	}
+}
+
+func TestAllStages(t *testing.T) {
+	cfg := config.NewDefaultConfig("demo")
+	runners := map[string]Runner{}
+	for _, name := range []string{StageDownload, StageBastion, StageRegistry, StageSave, StageLoad, StagePXE} {
+		runners[name] = func() ([]string, error) { return nil, nil }
+	}
+	stages, err := AllStages(cfg, "pxe", runners)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stages) != 6 || stages[5].Name != StagePXE || !slices.Equal(stages[4].DependsOn, []string{StageRegistry, StageSave}) {
+		t.Errorf("AllStages() = %+v", stages)
+	}
+	if _, err := AllStages(cfg, "iso", runners); err == nil {
+		t.Error("AllStages() should fail when a runner is missing")
+	}
+
+	// 修改 Worker 节点不影响下载阶段的输入
+	before, _ := StageInputs(cfg, StageDownload)
+	cfg.Cluster.Worker = append(cfg.Cluster.Worker, config.Node{Name: "worker-9"})
+	after, _ := StageInputs(cfg, StageDownload)
+	p := &Pipeline{ClusterDir: t.TempDir()}
+	h1, _ := p.inputsHash(Stage{Inputs: func() (Inputs, error) { return before, nil }})
+	h2, _ := p.inputsHash(Stage{Inputs: func() (Inputs, error) { return after, nil }})
+	if h1 != h2 {
+		t.Error("download inputs changed after adding a worker")
+	}
+
+	// ISO 使用版本锁和下载的 openshift-install
+	iso, _ := StageInputs(cfg, StageISO)
+	for _, file := range []string{config.VersionLockFilename, filepath.Join("downloads", "bin", "openshift-install")} {
+		if !slices.Contains(iso.Files, file) {
+			t.Errorf("iso inputs %v do not include %s", iso.Files, file)
+		}
+	}
+}
+
+func TestInputsHashWalksDirectories(t *testing.T) {
+	dir := t.TempDir()
+	images := filepath.Join(dir, imagesDirName)
+	if err := os.MkdirAll(filepath.Join(images, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(images, "sub", "mirror_seq1_000000.tar")
+	if err := os.WriteFile(archive, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &Pipeline{ClusterDir: dir}
+	stage := Stage{Inputs: func() (Inputs, error) { return Inputs{Files: []string{imagesDirName}}, nil }}
+	before, err := p.inputsHash(stage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 目录中的文件变化，目录本身的修改时间不变
+	dirInfo, _ := os.Stat(images)
+	if err := os.WriteFile(archive, []byte("v2-longer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(images, dirInfo.ModTime(), dirInfo.ModTime())
+	if after, err := p.inputsHash(stage); err != nil || after == before {
+		t.Errorf("inputsHash() = %s, %v after a file in %s changed", after, err, imagesDirName)
+	}
+}
+
+func TestStageInputsOmitSecrets(t *testing.T) {
+	cfg := config.NewDefaultConfig("demo")
+	cfg.Bastion.Password = "bastion-s3cret"
+	cfg.Bastion.SSHKeyPassphrase = "key-s3cret"
+	cfg.Registry.Password = "registry-s3cret"
+	cfg.Download.ProxyPassword = "proxy-s3cret"
+	names, _ := Names(StageISO)
+	for _, name := range append(names, StagePXE) {
+		inputs, err := StageInputs(cfg, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, _ := json.Marshal(inputs.Values)
+		if strings.Contains(string(values), "s3cret") {
+			t.Errorf("%s inputs contain a password: %s", name, values)
+		}
+	}
+	if cfg.Bastion.Password != "bastion-s3cret" {
+		t.Error("StageInputs() modified the config")
+	}
+
+	// 状态文件只允许当前用户读取
+	dir := t.TempDir()
+	if err := (&testStages{inputs: map[string]string{}}).run(t, dir, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, StateFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("state file mode = %v", info.Mode())
+	}
+}
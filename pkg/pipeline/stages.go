@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"ocpack/pkg/config"
+)
+
+// ocpack all 的阶段
+const (
+	StageDownload = "download"
+	StageBastion  = "bastion"
+	StageRegistry = "registry"
+	StageSave     = "save"
+	StageLoad     = "load"
+	StageISO      = "iso"
+	StagePXE      = "pxe"
+)
+
+const (
+	pullSecretFilename = "pull-secret.txt"
+	imagesDirName      = "images"
+	installerFilename  = "openshift-install"
+)
+
+// Names 返回 ocpack all 按顺序执行的阶段，mode 为 iso 或 pxe，决定最后一个阶段
+func Names(mode string) ([]string, error) {
+	names := []string{StageDownload, StageBastion, StageRegistry, StageSave, StageLoad}
+	switch mode {
+	case "", StageISO:
+		return append(names, StageISO), nil
+	case StagePXE:
+		return append(names, StagePXE), nil
+	}
+	return nil, fmt.Errorf("不支持的部署模式: %s (可选: iso, pxe)", mode)
+}
+
+// Runner 执行一个阶段，返回生成的文件 (相对于集群目录)
+type Runner func() ([]string, error)
+
+// AllStages 组装 ocpack all 的流水线阶段，runners 为每个阶段的执行函数
+func AllStages(cfg *config.ClusterConfig, mode string, runners map[string]Runner) ([]Stage, error) {
+	names, err := Names(mode)
+	if err != nil {
+		return nil, err
+	}
+	stages := make([]Stage, 0, len(names))
+	for _, name := range names {
+		run, ok := runners[name]
+		if !ok {
+			return nil, fmt.Errorf("阶段 %s 没有执行函数", name)
+		}
+		stages = append(stages, Stage{
+			Name:      name,
+			DependsOn: Dependencies(name),
+			Inputs:    func() (Inputs, error) { return StageInputs(cfg, name) },
+			Run:       run,
+		})
+	}
+	return stages, nil
+}
+
+// dependencies 每个阶段使用哪些阶段的输出
+var dependencies = map[string][]string{
+	StageBastion:  {StageDownload}, // 安装 oc 和 kubectl
+	StageRegistry: {StageDownload}, // mirror-registry 安装包
+	StageSave:     {StageDownload}, // oc-mirror
+	StageLoad:     {StageRegistry, StageSave},
+	StageISO:      {StageDownload, StageLoad}, // openshift-install 和 Registry 中的镜像
+	StagePXE:      {StageDownload, StageLoad},
+}
+
+// Dependencies 返回阶段依赖的阶段
+func Dependencies(name string) []string {
+	return dependencies[name]
+}
+
+// StageInputs 返回阶段依赖的配置项和文件。只包含会影响阶段结果的部分，
+// 例如修改 Worker 节点不会重新下载安装介质。
+// 输入的哈希保存在状态文件中，密码和口令不计入哈希，使用密钥库时计入 secret: 引用
+func StageInputs(cfg *config.ClusterConfig, name string) (Inputs, error) {
+	cfg = cfg.Redacted()
+	version := map[string]interface{}{
+		"openshift_version": cfg.ClusterInfo.OpenShiftVersion,
+		"target_arch":       cfg.TargetArch(),
+	}
+	switch name {
+	case StageDownload:
+		return Inputs{Values: map[string]interface{}{
+			"version":  version,
+			"download": cfg.Download,
+		}}, nil
+	case StageBastion:
+		return Inputs{Values: map[string]interface{}{
+			"cluster_info":  cfg.ClusterInfo,
+			"bastion":       cfg.Bastion,
+			"registry_ip":   []string{cfg.Registry.IP, cfg.Registry.IPv6},
+			"cluster":       cfg.Cluster,
+			"ssh":           cfg.SSH,
+			"security":      cfg.Security,
+			"load_balancer": cfg.LoadBalancer,
+		}}, nil
+	case StageRegistry:
+		return Inputs{Values: map[string]interface{}{
+			"cluster":  []string{cfg.ClusterInfo.Name, cfg.ClusterInfo.Domain},
+			"registry": cfg.Registry,
+			"ssh":      cfg.SSH,
+			"security": cfg.Security,
+		}}, nil
+	case StageSave:
+		return Inputs{
+			Values: map[string]interface{}{"version": version, "save_image": cfg.SaveImage},
+			Files:  []string{pullSecretFilename},
+		}, nil
+	case StageLoad:
+		return Inputs{
+			Values: map[string]interface{}{"version": version, "save_image": cfg.SaveImage, "registry": cfg.Registry},
+			Files:  []string{imagesDirName},
+		}, nil
+	case StageISO, StagePXE:
+		return Inputs{
+			Values: map[string]interface{}{
+				"cluster_info": cfg.ClusterInfo,
+				"bastion_ip":   []string{cfg.Bastion.IP, cfg.Bastion.IPv6},
+				"registry":     cfg.Registry,
+				"cluster":      cfg.Cluster,
+				"security":     cfg.Security,
+			},
+			// 版本通道解析出的版本和下载阶段解压的 openshift-install
+			Files: []string{pullSecretFilename, config.VersionLockFilename, filepath.Join(cfg.Download.LocalPath, "bin", installerFilename)},
+		}, nil
+	}
+	return Inputs{}, fmt.Errorf("未知的阶段: %s", name)
+}
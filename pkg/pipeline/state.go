@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateFilename 集群目录中记录各阶段执行状态的文件
+const StateFilename = "ocpack-state.json"
+
+// Status 阶段的执行状态
+type Status string
+
+const (
+	StatusRunning   Status = "running" // 执行中或上次执行被中断
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// StageState 一个阶段最近一次执行的记录
+type StageState struct {
+	Status     Status    `json:"status"`
+	InputsHash string    `json:"inputs_hash"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Outputs    []string  `json:"outputs,omitempty"` // 相对于集群目录的路径
+	Error      string    `json:"error,omitempty"`
+	// Dependencies 执行时各依赖阶段的完成时间，依赖阶段之后重新执行过时此阶段需要重新执行
+	Dependencies map[string]time.Time `json:"dependencies,omitempty"`
+}
+
+// State 集群目录中的状态文件
+type State struct {
+	Stages map[string]*StageState `json:"stages"`
+
+	path string
+}
+
+// LoadState 读取集群目录中的状态文件，文件不存在时返回空状态
+func LoadState(clusterDir string) (*State, error) {
+	s := &State{Stages: map[string]*StageState{}, path: filepath.Join(clusterDir, StateFilename)}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", StateFilename, err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", StateFilename, err)
+	}
+	if s.Stages == nil {
+		s.Stages = map[string]*StageState{}
+	}
+	return s, nil
+}
+
+// Save 写入状态文件。先写临时文件再重命名，中断时不会留下损坏的文件
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 %s 失败: %w", StateFilename, err)
+	}
+	tmp := s.path + ".tmp"
+	// 状态文件只记录阶段输入的哈希，仍只允许当前用户读取
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", StateFilename, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", StateFilename, err)
+	}
+	return nil
+}
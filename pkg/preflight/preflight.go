@@ -0,0 +1,250 @@
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/credentials"
+	"ocpack/pkg/toolchain"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// --- Constants ---
+const (
+	configFilename       = "config.toml"
+	pullSecretFilename   = "pull-secret.txt"
+	registryDirName      = "registry"
+	rootCACertFilename   = "rootCA.pem"
+	imagesDirName        = "images"
+	ocMirrorWorkspaceDir = "oc-mirror-workspace"
+	icspFilename         = "imageContentSourcePolicy.yaml"
+)
+
+// 支持的输出格式
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// StageReport 单个阶段的检查结果
+type StageReport struct {
+	Stage    config.Stage             `json:"stage"`
+	Ready    bool                     `json:"ready"`
+	Errors   int                      `json:"errors"`
+	Warnings int                      `json:"warnings"`
+	Issues   []config.ValidationIssue `json:"issues"`
+}
+
+// Report 所有阶段的检查结果
+type Report struct {
+	Cluster string        `json:"cluster"`
+	Ready   bool          `json:"ready"`
+	Stages  []StageReport `json:"stages"`
+}
+
+// fileRequirement 描述阶段依赖的一个本地文件
+type fileRequirement struct {
+	description string
+	candidates  []string // 任意一个存在即满足
+	severity    config.Severity
+	hint        string
+}
+
+// Run 加载集群配置并执行所有阶段的检查
+func Run(clusterName, projectRoot string) (*Report, error) {
+	clusterDir := filepath.Join(projectRoot, clusterName)
+	cfg, err := config.LoadConfig(filepath.Join(clusterDir, configFilename))
+	if err != nil {
+		return nil, fmt.Errorf("加载配置文件失败: %w", err)
+	}
+
+	report := &Report{Cluster: clusterName, Ready: true}
+	for _, stage := range config.Stages() {
+		result := Check(cfg, clusterDir, stage)
+		stageReport := StageReport{
+			Stage:    stage,
+			Ready:    !result.HasErrors(),
+			Errors:   len(result.Errors()),
+			Warnings: len(result.Warnings()),
+			Issues:   result.Issues,
+		}
+		if stageReport.Issues == nil {
+			stageReport.Issues = []config.ValidationIssue{}
+		}
+		report.Ready = report.Ready && stageReport.Ready
+		report.Stages = append(report.Stages, stageReport)
+	}
+	return report, nil
+}
+
+// Check 执行单个阶段的配置校验和本地文件检查
+func Check(cfg *config.ClusterConfig, clusterDir string, stage config.Stage) *config.ValidationResult {
+	result := config.Validate(cfg, stage)
+	for _, req := range stageRequirements(cfg, clusterDir, stage) {
+		if found(req.candidates) {
+			continue
+		}
+		rel, err := filepath.Rel(clusterDir, req.candidates[0])
+		if err != nil {
+			rel = req.candidates[0]
+		}
+		result.Issues = append(result.Issues, config.ValidationIssue{
+			Path:     rel,
+			Message:  fmt.Sprintf("缺少%s", req.description),
+			Hint:     req.hint,
+			Severity: req.severity,
+		})
+	}
+	// 已存在的工具还要检查版本是否与 openshift_version 一致
+	if tools := stageTools[stage]; len(tools) > 0 {
+		check := toolchain.New(cfg, clusterDir).Check(tools...)
+		result.Issues = append(result.Issues, check.Issues...)
+	}
+	return result
+}
+
+// stageTools 声明每个阶段在本机执行的工具
+var stageTools = map[config.Stage][]string{
+	config.StageRegistry: {toolchain.OC, toolchain.OcMirror},
+	config.StageISO:      {toolchain.OpenShiftInstall, toolchain.OC},
+	config.StagePXE:      {toolchain.OpenShiftInstall, toolchain.OC},
+}
+
+// stageRequirements 声明每个阶段依赖的本地文件
+func stageRequirements(cfg *config.ClusterConfig, clusterDir string, stage config.Stage) []fileRequirement {
+	downloadDir := filepath.Join(clusterDir, cfg.Download.LocalPath)
+	tools := toolchain.New(cfg, clusterDir)
+	bin := func(name string) []string { return []string{tools.DownloadedPath(name)} }
+	const downloadHint = "请先运行 'ocpack download' 命令"
+
+	switch stage {
+	case config.StageBastion:
+		return []fileRequirement{
+			{"OpenShift 客户端工具 oc", bin("oc"), config.SeverityWarning, "Bastion 将不会安装 oc，" + downloadHint},
+			{"Kubernetes 客户端工具 kubectl", bin("kubectl"), config.SeverityWarning, "Bastion 将不会安装 kubectl，" + downloadHint},
+		}
+	case config.StageRegistry:
+		return []fileRequirement{
+			{"Quay 镜像仓库安装包", []string{filepath.Join(downloadDir, cfg.MirrorRegistryArchive())}, config.SeverityError, downloadHint},
+			{"OpenShift 客户端工具 oc", bin("oc"), config.SeverityError, downloadHint},
+			{"Kubernetes 客户端工具 kubectl", bin("kubectl"), config.SeverityError, downloadHint},
+			{"OpenShift 镜像同步工具 oc-mirror", bin("oc-mirror"), config.SeverityError, downloadHint},
+		}
+	case config.StageISO, config.StagePXE:
+		reqs := []fileRequirement{
+			{"安装程序 openshift-install", []string{tools.DownloadedPath(toolchain.OpenShiftInstall), tools.ExtractedInstallerPath()}, config.SeverityError, downloadHint},
+			{"Registry CA 证书", rootCACandidates(cfg, clusterDir), config.SeverityWarning, "请先运行 'ocpack deploy-registry'，否则 install-config 不包含 additionalTrustBundle"},
+			{"oc-mirror 结果目录", ocMirrorResultsCandidates(clusterDir), config.SeverityWarning, "请先运行 'ocpack load-image'，否则 install-config 不包含 imageContentSources"},
+		}
+		// 保存在密钥库中的 pull-secret 同样满足要求
+		if !credentials.HasPullSecret(clusterDir) {
+			reqs = append(reqs, fileRequirement{"Red Hat pull-secret", []string{filepath.Join(clusterDir, pullSecretFilename)}, config.SeverityError, "从 https://console.redhat.com/openshift/install/pull-secret 下载"})
+		}
+		return reqs
+	}
+	return nil
+}
+
+// rootCACandidates 返回 rootCA.pem 可能存放的位置
+func rootCACandidates(cfg *config.ClusterConfig, clusterDir string) []string {
+	registryHost := fmt.Sprintf("registry.%s.%s", cfg.ClusterInfo.Name, cfg.ClusterInfo.Domain)
+	return []string{
+		filepath.Join(clusterDir, registryDirName, cfg.Registry.IP, rootCACertFilename),
+		filepath.Join(clusterDir, registryDirName, registryHost, rootCACertFilename),
+		filepath.Join(clusterDir, registryDirName, rootCACertFilename),
+	}
+}
+
+// ocMirrorResultsCandidates 返回 oc-mirror results-* 目录中生成的 ICSP 文件
+func ocMirrorResultsCandidates(clusterDir string) []string {
+	var candidates []string
+	for _, workspace := range []string{
+		filepath.Join(clusterDir, ocMirrorWorkspaceDir),
+		filepath.Join(clusterDir, imagesDirName, ocMirrorWorkspaceDir),
+	} {
+		matches, _ := filepath.Glob(filepath.Join(workspace, "results-*", icspFilename))
+		candidates = append(candidates, matches...)
+	}
+	if len(candidates) == 0 {
+		// 用于报告的占位路径
+		return []string{filepath.Join(clusterDir, ocMirrorWorkspaceDir, "results-*")}
+	}
+	return candidates
+}
+
+func found(candidates []string) bool {
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Output ---
+
+// Write 以指定格式输出检查结果
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "", OutputTable:
+		r.PrintTable(w)
+		return nil
+	case OutputJSON:
+		return r.WriteJSON(w)
+	default:
+		return fmt.Errorf("不支持的输出格式: %s (可选: %s, %s)", format, OutputTable, OutputJSON)
+	}
+}
+
+// WriteJSON 以 JSON 格式输出检查结果，供 CI 使用
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// PrintTable 以表格形式输出每个阶段的就绪状态及问题详情
+func (r *Report) PrintTable(w io.Writer) {
+	fmt.Fprintf(w, "📋 集群 %s 部署前检查\n\n", r.Cluster)
+	fmt.Fprintf(w, "%s %s %s %s\n", padRight("阶段", 10), padRight("状态", 10), padRight("错误", 6), "警告")
+	fmt.Fprintln(w, strings.Repeat("─", 36))
+	for _, stage := range r.Stages {
+		status := "✅ 就绪"
+		if !stage.Ready {
+			status = "❌ 未就绪"
+		}
+		fmt.Fprintf(w, "%s %s %s %d\n", padRight(string(stage.Stage), 10), padRight(status, 10), padRight(fmt.Sprint(stage.Errors), 6), stage.Warnings)
+	}
+
+	for _, stage := range r.Stages {
+		if len(stage.Issues) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n[%s]\n", stage.Stage)
+		for _, issue := range stage.Issues {
+			icon := "❌"
+			if issue.Severity == config.SeverityWarning {
+				icon = "⚠️ "
+			}
+			fmt.Fprintf(w, "  %s %s\n", icon, issue)
+		}
+	}
+
+	if r.Ready {
+		fmt.Fprintln(w, "\n🎉 所有阶段均已就绪")
+	}
+}
+
+// padRight 按显示宽度右侧补齐空格，兼容中文字符
+func padRight(s string, width int) string {
+	if pad := width - runewidth.StringWidth(s); pad > 0 {
+		return s + strings.Repeat(" ", pad)
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package preflight
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ocpack/pkg/config"
+)
+
+func TestRunReportsMissingFiles(t *testing.T) {
+	projectRoot := t.TempDir()
+	clusterDir := filepath.Join(projectRoot, "demo")
+	if err := os.MkdirAll(clusterDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.GenerateDefaultConfig(filepath.Join(clusterDir, configFilename), "demo"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Run("demo", projectRoot)
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if report.Ready {
+		t.Error("Run() reported ready for an unconfigured cluster")
+	}
+
+	stages := map[config.Stage]StageReport{}
+	for _, stage := range report.Stages {
+		stages[stage.Stage] = stage
+	}
+	if !stages[config.StageDownload].Ready {
+		t.Errorf("download stage should be ready, issues: %v", stages[config.StageDownload].Issues)
+	}
+
+	var hasPullSecret bool
+	for _, issue := range stages[config.StageISO].Issues {
+		if issue.Path == pullSecretFilename && issue.Severity == config.SeverityError {
+			hasPullSecret = true
+		}
+	}
+	if !hasPullSecret {
+		t.Errorf("iso stage should report missing %s, issues: %v", pullSecretFilename, stages[config.StageISO].Issues)
+	}
+
+	var buf bytes.Buffer
+	if err := report.Write(&buf, OutputJSON); err != nil {
+		t.Fatalf("Write(json) error: %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if len(decoded.Stages) != len(config.Stages()) {
+		t.Errorf("JSON output has %d stages, expected %d", len(decoded.Stages), len(config.Stages()))
+	}
+}
@@ -13,6 +13,9 @@ import (
 	"text/template"
 
 	"ocpack/pkg/config"
+	"ocpack/pkg/credentials"
+	"ocpack/pkg/preflight"
+	"ocpack/pkg/toolchain"
 	"ocpack/pkg/utils"
 
 	"github.com/mattn/go-runewidth"
@@ -36,8 +39,6 @@ const (
 	registryDirName         = "registry"
 	ocMirrorWorkspaceDir    = "oc-mirror-workspace"
 	imagesDirName           = "images"
-	openshiftInstallCmd     = "openshift-install"
-	defaultInterface        = "ens3"
 	uploadScriptPath        = "/usr/local/bin/upload-pxe-files.sh"
 	defaultPxeWebServerPort = 8080
 )
@@ -64,9 +65,6 @@ type AgentConfigDataPXE struct {
 	ClusterName          string
 	RendezvousIP         string
 	Hosts                []HostConfig
-	Port0                string
-	PrefixLength         int
-	NextHopAddress       string
 	DNSServers           []string
 	BootArtifactsBaseURL string
 }
@@ -77,7 +75,7 @@ type HostConfig struct {
 	Role       string
 	MACAddress string
 	IPAddress  string
-	Interface  string
+	Network    config.NodeNetwork
 }
 
 // --- Main Logic ---
@@ -161,25 +159,13 @@ func (g *PXEGenerator) GeneratePXE(options *GenerateOptions) error {
 
 // --- Step Implementations ---
 
-// ValidateConfig checks for required configurations and tools.
+// ValidateConfig checks for required configurations, tools and files.
 func (g *PXEGenerator) ValidateConfig() error {
-	if err := config.ValidateConfig(g.Config); err != nil {
-		return err
-	}
-
-	// Validate required tools
-	toolPath := filepath.Join(g.DownloadDir, "bin", openshiftInstallCmd)
-	if _, err := os.Stat(toolPath); os.IsNotExist(err) {
-		return fmt.Errorf("缺少必需的工具: %s，请先运行 'ocpack download' 命令", openshiftInstallCmd)
-	}
-
-	// Validate pull-secret file
-	pullSecretPath := filepath.Join(g.ClusterDir, pullSecretFilename)
-	if _, err := os.Stat(pullSecretPath); errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("缺少 %s 文件，请先获取 Red Hat pull-secret", pullSecretFilename)
+	result := preflight.Check(g.Config, g.ClusterDir, config.StagePXE)
+	for _, warning := range result.Warnings() {
+		g.printInfo(fmt.Sprintf("警告: %s", warning))
 	}
-
-	return nil
+	return result.Err()
 }
 
 // createPXEDirs creates the necessary directory structure for PXE files.
@@ -213,7 +199,7 @@ func (g *PXEGenerator) generateAgentConfig(pxeDir, assetServerURL string) error
 
 // generatePXEFiles runs 'openshift-install' to create boot files.
 func (g *PXEGenerator) generatePXEFiles(pxeDir, assetServerURL string) error {
-	openshiftInstallPath, err := g.findOpenshiftInstall()
+	openshiftInstallPath, err := toolchain.New(g.Config, g.ClusterDir).Path(toolchain.OpenShiftInstall)
 	if err != nil {
 		return fmt.Errorf("查找 openshift-install 失败: %w", err)
 	}
@@ -278,11 +264,21 @@ func (g *PXEGenerator) uploadPXEFiles(pxeDir string) error {
 	uploadCmdStr := fmt.Sprintf("sudo %s %s", uploadScriptPath, filesDir)
 	sshUserHost := fmt.Sprintf("%s@%s", g.Config.Bastion.Username, g.Config.Bastion.IP)
 
+	// 端口和主机密钥校验与 Go SSH 客户端、Ansible 使用相同的配置
+	sshOpts := g.Config.BastionSSH(g.ClusterDir)
+	sshArgs := sshOpts.SSHArgs()
+	if sshOpts.KeyPath != "" {
+		sshArgs = append(sshArgs, "-i", sshOpts.KeyPath)
+	}
+	sshArgs = append(sshArgs, sshUserHost, uploadCmdStr)
+
 	var sshCmd *exec.Cmd
-	if g.Config.Bastion.SSHKeyPath != "" {
-		sshCmd = exec.Command("ssh", "-i", g.Config.Bastion.SSHKeyPath, "-o", "StrictHostKeyChecking=no", sshUserHost, uploadCmdStr)
+	if sshOpts.KeyPath != "" || sshOpts.UseAgent {
+		sshCmd = exec.Command("ssh", sshArgs...)
 	} else {
-		sshCmd = exec.Command("sshpass", "-p", g.Config.Bastion.Password, "ssh", "-o", "StrictHostKeyChecking=no", sshUserHost, uploadCmdStr)
+		// sshpass -e 从环境变量读取密码，避免密码出现在进程参数中
+		sshCmd = exec.Command("sshpass", append([]string{"-e", "ssh"}, sshArgs...)...)
+		sshCmd.Env = append(os.Environ(), "SSHPASS="+sshOpts.Password)
 	}
 
 	sshCmd.Stdout = os.Stdout
@@ -324,9 +320,11 @@ func (g *PXEGenerator) generateInstallConfigFromTemplate(configPath string) erro
 		ClusterName           string
 		NumWorkers            int
 		NumMasters            int
-		MachineNetwork        string
-		PrefixLength          int
-		HostPrefix            int
+		MastersSchedulable    bool
+		Topology              string
+		ClusterNetworks       []config.ClusterNetworkEntry
+		MachineNetworks       []string
+		ServiceNetworks       []string
 		PullSecret            string
 		SSHKeyPub             string
 		AdditionalTrustBundle string
@@ -341,15 +339,20 @@ func (g *PXEGenerator) generateInstallConfigFromTemplate(configPath string) erro
 		ClusterName:           g.Config.ClusterInfo.Name,
 		NumWorkers:            len(g.Config.Cluster.Worker),
 		NumMasters:            len(g.Config.Cluster.ControlPlane),
-		MachineNetwork:        utils.ExtractNetworkBase(g.Config.Cluster.Network.MachineNetwork),
-		PrefixLength:          utils.ExtractPrefixLength(g.Config.Cluster.Network.MachineNetwork),
-		HostPrefix:            23, // Default host prefix
+		MastersSchedulable:    g.Config.MastersSchedulable(),
+		Topology:              g.Config.Topology(),
+		ClusterNetworks:       g.Config.ClusterNetworks(),
+		MachineNetworks:       utils.NormalizeCIDRs(g.Config.Cluster.Network.MachineNetwork),
+		ServiceNetworks:       utils.NormalizeCIDRs(g.Config.Cluster.Network.ServiceNetwork),
 		PullSecret:            pullSecret,
 		SSHKeyPub:             sshKey,
 		AdditionalTrustBundle: trustBundle,
 		ImageContentSources:   icsp,
-		ArchShort:             "amd64",
-		UseProxy:              false, // Proxy settings can be added here
+		ArchShort:             g.Config.InstallConfigArch(),
+		UseProxy:              g.Config.UseProxy(),
+		HTTPProxy:             g.Config.HTTPProxyURL(),
+		HTTPSProxy:            g.Config.HTTPSProxyURL(),
+		NoProxy:               g.Config.ClusterNoProxy(),
 	}
 
 	funcMap := template.FuncMap{
@@ -373,6 +376,7 @@ func (g *PXEGenerator) generateInstallConfigFromTemplate(configPath string) erro
 
 // generateAgentConfigFromTemplate fills and writes the agent-config.yaml template.
 func (g *PXEGenerator) generateAgentConfigFromTemplate(configPath, assetServerURL string) error {
+	machineNetworks := g.Config.Cluster.Network.MachineNetwork
 	var hosts []HostConfig
 	for _, cp := range g.Config.Cluster.ControlPlane {
 		hosts = append(hosts, HostConfig{
@@ -380,7 +384,7 @@ func (g *PXEGenerator) generateAgentConfigFromTemplate(configPath, assetServerUR
 			Role:       "master",
 			MACAddress: cp.MAC,
 			IPAddress:  cp.IP,
-			Interface:  defaultInterface,
+			Network:    cp.Network(machineNetworks),
 		})
 	}
 	for _, worker := range g.Config.Cluster.Worker {
@@ -389,7 +393,7 @@ func (g *PXEGenerator) generateAgentConfigFromTemplate(configPath, assetServerUR
 			Role:       "worker",
 			MACAddress: worker.MAC,
 			IPAddress:  worker.IP,
-			Interface:  defaultInterface,
+			Network:    worker.Network(machineNetworks),
 		})
 	}
 
@@ -401,10 +405,7 @@ func (g *PXEGenerator) generateAgentConfigFromTemplate(configPath, assetServerUR
 		ClusterName:          g.Config.ClusterInfo.Name,
 		RendezvousIP:         g.Config.Cluster.ControlPlane[0].IP,
 		Hosts:                hosts,
-		Port0:                defaultInterface,
-		PrefixLength:         utils.ExtractPrefixLength(g.Config.Cluster.Network.MachineNetwork),
-		NextHopAddress:       utils.ExtractGateway(g.Config.Cluster.Network.MachineNetwork),
-		DNSServers:           []string{g.Config.Bastion.IP},
+		DNSServers:           g.Config.DNSServers(),
 		BootArtifactsBaseURL: assetServerURL,
 	}
 
@@ -415,7 +416,7 @@ func (g *PXEGenerator) generateAgentConfigFromTemplate(configPath, assetServerUR
 	return err
 }
 
-// getPullSecret reads the pull secret from either merged-auth.json or pull-secret.txt.
+// getPullSecret reads the pull secret from merged-auth.json, the secrets store or pull-secret.txt.
 func (g *PXEGenerator) getPullSecret() (string, error) {
 	mergedAuthPath := filepath.Join(g.ClusterDir, registryDirName, mergedAuthFilename)
 	if _, err := os.Stat(mergedAuthPath); err == nil {
@@ -427,13 +428,22 @@ func (g *PXEGenerator) getPullSecret() (string, error) {
 		return strings.TrimSpace(string(secretBytes)), nil
 	}
 
-	pullSecretPath := filepath.Join(g.ClusterDir, pullSecretFilename)
+	// 启用密钥库时在内存中合并 Registry 凭据，不写入明文文件
+	if credentials.UseSecretsStore(g.ClusterDir) {
+		merged, err := credentials.MergedPullSecret(g.Config, g.ClusterDir)
+		if err == nil {
+			g.printInfo("使用密钥库中的凭据合并认证配置")
+			return string(merged), nil
+		}
+		g.printInfo(fmt.Sprintf("合并认证配置失败: %v，将使用原始 pull-secret", err))
+	}
+
 	g.printInfo("使用 " + pullSecretFilename)
-	secretBytes, err := os.ReadFile(pullSecretPath)
+	secretBytes, err := credentials.ReadPullSecret(g.ClusterDir)
 	if err != nil {
 		return "", fmt.Errorf("读取 pull-secret 失败: %w", err)
 	}
-	return strings.TrimSpace(string(secretBytes)), nil
+	return string(secretBytes), nil
 }
 
 // getSSHKey reads the user's public SSH key.
@@ -460,9 +470,12 @@ func (g *PXEGenerator) getAdditionalTrustBundle() (string, error) {
 
 	for _, certPath := range possibleCertPaths {
 		if caCertBytes, err := os.ReadFile(certPath); err == nil {
-			return string(caCertBytes), nil
+			return g.Config.WithCABundle(string(caCertBytes))
 		}
 	}
+	if g.Config.Download.CABundle != "" {
+		return g.Config.WithCABundle("")
+	}
 	return "", errors.New("未在任何预期位置找到 rootCA.pem")
 }
 
@@ -631,24 +644,6 @@ func (g *PXEGenerator) updateIPXEScript(filesDir, assetServerURL string) error {
 	return nil
 }
 
-// findOpenshiftInstall locates the openshift-install binary.
-func (g *PXEGenerator) findOpenshiftInstall() (string, error) {
-	// Prioritize the version extracted from the local registry
-	registryHost := fmt.Sprintf("registry.%s.%s", g.Config.ClusterInfo.Name, g.Config.ClusterInfo.Domain)
-	extractedBinary := filepath.Join(g.ClusterDir, fmt.Sprintf("%s-%s-%s", openshiftInstallCmd, g.Config.ClusterInfo.OpenShiftVersion, registryHost))
-	if _, err := os.Stat(extractedBinary); err == nil {
-		return extractedBinary, nil
-	}
-
-	// Fallback to the one in the download directory
-	downloadedBinary := filepath.Join(g.DownloadDir, "bin", openshiftInstallCmd)
-	if _, err := os.Stat(downloadedBinary); err == nil {
-		return downloadedBinary, nil
-	}
-
-	return "", fmt.Errorf("在 %s 或 %s 中未找到 %s 工具", extractedBinary, downloadedBinary, openshiftInstallCmd)
-}
-
 // moveAndCountFiles moves files from src to dst, ignoring specified files, and returns the count.
 func (g *PXEGenerator) moveAndCountFiles(srcDir, dstDir string, ignore map[string]bool) (int, error) {
 	entries, err := os.ReadDir(srcDir)
@@ -718,7 +713,7 @@ func (g *PXEGenerator) printManualUploadInstructions(pxeDir string) {
 	fmt.Printf("│                                                             │\n")
 	fmt.Printf("│ 2. 或手动复制文件:                                          │\n")
 	fmt.Printf("│    scp %s/* %s@%s:/tmp/                │\n", filesPath, sshUser, sshIP)
-	fmt.Printf("│    ssh %s@%s 'sudo cp /tmp/agent.x86_64-* /var/www/html/pxe/%s/' │\n", sshUser, sshIP, g.ClusterName)
+	fmt.Printf("│    ssh %s@%s 'sudo cp /tmp/agent.%s* /var/www/html/pxe/%s/' │\n", sshUser, sshIP, g.Config.TargetArch(), g.ClusterName)
 	fmt.Printf("│                                                             │\n")
 	fmt.Printf("│ 3. 验证文件访问:                                            │\n")
 	fmt.Printf("│    curl http://%s:%d/pxe/%s/                         │\n", sshIP, defaultPxeWebServerPort, g.ClusterName)
@@ -14,6 +14,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"ocpack/pkg/catalognew"
+	"ocpack/pkg/cincinnati"
 	"ocpack/pkg/config"
 	"ocpack/pkg/mirror/cli"
 	clog "ocpack/pkg/mirror/log"
@@ -89,6 +90,9 @@ func NewSaver(clusterName, projectRoot string) (*Saver, error) {
 	if err != nil {
 		return nil, fmt.Errorf("加载配置文件失败: %w", err)
 	}
+	if _, err := cincinnati.ResolveVersion(context.Background(), cfg, clusterDir, false); err != nil {
+		return nil, fmt.Errorf("解析 OpenShift 版本失败: %w", err)
+	}
 
 	logger := clog.New("info")
 
@@ -169,6 +173,10 @@ func (s *Saver) checkExistingMirrorFiles(imagesDir string) bool {
 func (s *Saver) generateImageSetConfig(configPath string) error {
 	version := s.Config.ClusterInfo.OpenShiftVersion
 	majorVersion := utils.ExtractMajorVersion(version)
+	channel := ocpDefaultChannel
+	if channelType := s.Config.ChannelType(); channelType != "" {
+		channel = channelType
+	}
 
 	// 从配置文件读取镜像保存配置
 	saveImageConfig := s.Config.SaveImage
@@ -205,7 +213,7 @@ func (s *Saver) generateImageSetConfig(configPath string) error {
 	}
 
 	imagesetConfig := ImageSetConfig{
-		OCPChannel:       ocpDefaultChannel,
+		OCPChannel:       channel,
 		OCPVerMajor:      majorVersion,
 		OCPVer:           version,
 		IncludeOperators: saveImageConfig.IncludeOperators,
@@ -2,6 +2,7 @@ package saveimage
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"encoding/json"
 	"errors"
@@ -13,7 +14,10 @@ import (
 	"text/template"
 
 	"ocpack/pkg/catalog"
+	"ocpack/pkg/cincinnati"
 	"ocpack/pkg/config"
+	"ocpack/pkg/credentials"
+	"ocpack/pkg/toolchain"
 	"ocpack/pkg/utils"
 )
 
@@ -26,7 +30,6 @@ const (
 	imagesDirName               = "images"
 	registryDirName             = "registry"
 	ocMirrorWorkspaceDir        = "oc-mirror-workspace"
-	ocMirrorCmd                 = "oc-mirror"
 	pullSecretFilename          = "pull-secret.txt"
 	pullSecretFormattedFilename = "pull-secret-formatted.json"
 	dockerConfigFilename        = "config.json"
@@ -88,6 +91,9 @@ func NewImageSaver(clusterName, projectRoot string) (*ImageSaver, error) {
 		// 优化: 使用 %w 进行错误包装
 		return nil, fmt.Errorf("加载配置文件失败: %w", err)
 	}
+	if _, err := cincinnati.ResolveVersion(context.Background(), cfg, clusterDir, false); err != nil {
+		return nil, fmt.Errorf("解析 OpenShift 版本失败: %w", err)
+	}
 
 	return &ImageSaver{
 		Config:      cfg,
@@ -168,18 +174,23 @@ func (s *ImageSaver) checkExistingMirrorFiles(imagesDir string) bool {
 // 优化: 拆分职责，此函数现在是协调者
 func (s *ImageSaver) handlePullSecret() error {
 	pullSecretPath := filepath.Join(s.ClusterDir, pullSecretFilename)
-	if _, err := os.Stat(pullSecretPath); os.IsNotExist(err) {
+	if !credentials.HasPullSecret(s.ClusterDir) {
 		return fmt.Errorf(`%s 文件不存在
 
 请按照以下步骤获取 pull-secret:
 1. 访问 https://console.redhat.com/openshift/install/pull-secret
 2. 登录您的 Red Hat 账户
 3. 下载 pull-secret 文件
-4. 将文件保存为: %s`, pullSecretFilename, pullSecretPath)
+4. 将文件保存为: %s，或使用 'ocpack secrets set %s %s' 保存到密钥库`, pullSecretFilename, pullSecretPath, filepath.Base(s.ClusterDir), credentials.PullSecretName)
 	}
-	fmt.Printf("ℹ️  找到 pull-secret 文件: %s\n", pullSecretPath)
 
-	formattedContent, err := s.validateAndFormatPullSecret(pullSecretPath)
+	content, err := credentials.ReadPullSecret(s.ClusterDir)
+	if err != nil {
+		return err
+	}
+	fmt.Println("ℹ️  已读取 pull-secret")
+
+	formattedContent, err := s.validateAndFormatPullSecret(content)
 	if err != nil {
 		return fmt.Errorf("pull-secret 文件处理失败: %w", err)
 	}
@@ -191,6 +202,10 @@ func (s *ImageSaver) handlePullSecret() error {
 func (s *ImageSaver) generateImageSetConfig(configPath string) error {
 	version := s.Config.ClusterInfo.OpenShiftVersion
 	majorVersion := utils.ExtractMajorVersion(version)
+	channel := ocpDefaultChannel
+	if channelType := s.Config.ChannelType(); channelType != "" {
+		channel = channelType
+	}
 
 	workspacePath := filepath.Join(s.ClusterDir, imagesDirName, ocMirrorWorkspaceDir)
 	if err := os.MkdirAll(workspacePath, 0755); err != nil {
@@ -214,7 +229,7 @@ func (s *ImageSaver) generateImageSetConfig(configPath string) error {
 
 		// 创建目录管理器
 		cacheDir := filepath.Join(s.ClusterDir, ".catalog-cache")
-		ocMirrorPath := filepath.Join(s.DownloadDir, "bin", ocMirrorCmd)
+		ocMirrorPath := toolchain.New(s.Config, s.ClusterDir).DownloadedPath(toolchain.OcMirror)
 		catalogManager := catalog.NewCatalogManager(catalogImage, cacheDir, ocMirrorPath)
 
 		// 为每个配置的 Operator 获取默认 channel
@@ -237,7 +252,7 @@ func (s *ImageSaver) generateImageSetConfig(configPath string) error {
 	}
 
 	imagesetConfig := ImageSetConfig{
-		OCPChannel:       ocpDefaultChannel,
+		OCPChannel:       channel,
 		OCPVerMajor:      majorVersion,
 		OCPVer:           version,
 		IncludeOperators: saveImageConfig.IncludeOperators,
@@ -268,9 +283,9 @@ func (s *ImageSaver) generateImageSetConfig(configPath string) error {
 
 // runOcMirrorSave executes the 'oc-mirror' command to save images to disk.
 func (s *ImageSaver) runOcMirrorSave(configPath, imagesDir string) error {
-	ocMirrorPath := filepath.Join(s.DownloadDir, "bin", ocMirrorCmd)
-	if _, err := os.Stat(ocMirrorPath); os.IsNotExist(err) {
-		return fmt.Errorf("oc-mirror 工具不存在: %s", ocMirrorPath)
+	ocMirrorPath, err := toolchain.New(s.Config, s.ClusterDir).Path(toolchain.OcMirror)
+	if err != nil {
+		return err
 	}
 
 	args := []string{
@@ -299,14 +314,9 @@ func (s *ImageSaver) runOcMirrorSave(configPath, imagesDir string) error {
 
 // --- Helper Functions ---
 
-// validateAndFormatPullSecret reads, validates, and formats the pull secret JSON.
+// validateAndFormatPullSecret validates and formats the pull secret JSON.
 // 优化: 职责更单一的辅助函数
-func (s *ImageSaver) validateAndFormatPullSecret(filePath string) ([]byte, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("读取文件失败: %w", err)
-	}
-
+func (s *ImageSaver) validateAndFormatPullSecret(content []byte) ([]byte, error) {
 	var pullSecret map[string]interface{}
 	if err := json.Unmarshal(bytes.TrimSpace(content), &pullSecret); err != nil {
 		return nil, fmt.Errorf("pull-secret 不是有效的 JSON 格式: %w", err)
@@ -327,6 +337,11 @@ func (s *ImageSaver) saveFormattedPullSecret(content []byte) error {
 		"docker config":    filepath.Join(os.Getenv("HOME"), ".docker", dockerConfigFilename),
 		"formatted backup": filepath.Join(s.ClusterDir, pullSecretFormattedFilename),
 	}
+	// 启用密钥库时不在集群目录中保存明文副本
+	if credentials.UseSecretsStore(s.ClusterDir) {
+		delete(savePaths, "registry config")
+		delete(savePaths, "formatted backup")
+	}
 
 	for name, path := range savePaths {
 		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
@@ -0,0 +1,315 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// --- Constants ---
+const (
+	// StoreFilename 集群目录下的加密密钥库文件
+	StoreFilename = "secrets.enc"
+	// RefPrefix 配置文件中引用密钥库条目的前缀，如 password = "secret:bastion"
+	RefPrefix = "secret:"
+
+	// PassphraseEnv 密钥库口令的环境变量
+	PassphraseEnv = "OCPACK_SECRETS_PASSPHRASE"
+	// PassphraseFileEnv 保存密钥库口令的文件路径的环境变量
+	PassphraseFileEnv = "OCPACK_SECRETS_PASSPHRASE_FILE"
+
+	storeVersion = 1
+	keyLength    = 32
+	saltLength   = 16
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+)
+
+// ErrNoPassphrase 没有可用的口令 (未设置环境变量且不在终端中运行)
+var ErrNoPassphrase = fmt.Errorf("未提供密钥库口令，请设置 %s 或 %s", PassphraseEnv, PassphraseFileEnv)
+
+// 终端输入的口令在进程内缓存，避免一次命令中多次提示
+var (
+	passphraseMu     sync.Mutex
+	cachedPassphrase []byte
+)
+
+// storeFile 密钥库文件格式。条目名称以明文保存，便于在不解密的情况下检查和列出
+type storeFile struct {
+	Version    int      `json:"version"`
+	KDF        string   `json:"kdf"`
+	Salt       []byte   `json:"salt"`
+	N          int      `json:"n"`
+	R          int      `json:"r"`
+	P          int      `json:"p"`
+	Nonce      []byte   `json:"nonce"`
+	Names      []string `json:"names"`
+	Ciphertext []byte   `json:"ciphertext"`
+}
+
+// Store 已解密的集群密钥库
+type Store struct {
+	path       string
+	passphrase []byte
+	entries    map[string]string
+}
+
+// StorePath 返回集群密钥库文件路径
+func StorePath(clusterDir string) string {
+	return filepath.Join(clusterDir, StoreFilename)
+}
+
+// Exists 判断集群是否启用了密钥库
+func Exists(clusterDir string) bool {
+	_, err := os.Stat(StorePath(clusterDir))
+	return err == nil
+}
+
+// IsRef 判断配置值是否为密钥库引用
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, RefPrefix)
+}
+
+// Ref 返回指定条目的引用字符串
+func Ref(name string) string {
+	return RefPrefix + name
+}
+
+// Names 不解密地列出密钥库中的条目名称
+func Names(clusterDir string) ([]string, error) {
+	file, err := readStoreFile(StorePath(clusterDir))
+	if err != nil {
+		return nil, err
+	}
+	return file.Names, nil
+}
+
+// Has 不解密地检查密钥库中是否存在指定条目
+func Has(clusterDir, name string) bool {
+	names, err := Names(clusterDir)
+	if err != nil {
+		return false
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Create 使用口令创建新的空密钥库，文件已存在时返回错误
+func Create(clusterDir string, passphrase []byte) (*Store, error) {
+	path := StorePath(clusterDir)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("密钥库 %s 已存在", path)
+	}
+	store := &Store{path: path, passphrase: passphrase, entries: map[string]string{}}
+	if err := store.Save(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Open 使用环境变量或终端输入的口令打开集群密钥库
+func Open(clusterDir string) (*Store, error) {
+	passphrase, err := ReadPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	return OpenWithPassphrase(clusterDir, passphrase)
+}
+
+// OpenWithPassphrase 使用指定口令打开集群密钥库
+func OpenWithPassphrase(clusterDir string, passphrase []byte) (*Store, error) {
+	path := StorePath(clusterDir)
+	file, err := readStoreFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if file.Version != storeVersion || file.KDF != "scrypt" {
+		return nil, fmt.Errorf("不支持的密钥库格式: version %d, kdf %s", file.Version, file.KDF)
+	}
+
+	gcm, err := newGCM(passphrase, file.Salt, file.N, file.R, file.P)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("解密密钥库失败: 口令错误或文件已损坏")
+	}
+
+	entries := map[string]string{}
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fmt.Errorf("解析密钥库内容失败: %w", err)
+	}
+	return &Store{path: path, passphrase: passphrase, entries: entries}, nil
+}
+
+// Get 返回条目的值
+func (s *Store) Get(name string) (string, error) {
+	value, ok := s.entries[name]
+	if !ok {
+		return "", fmt.Errorf("密钥库中不存在条目 %q", name)
+	}
+	return value, nil
+}
+
+// Set 设置条目的值，需要调用 Save 写入文件
+func (s *Store) Set(name, value string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("无效的条目名称 %q", name)
+	}
+	s.entries[name] = value
+	return nil
+}
+
+// Delete 删除条目，需要调用 Save 写入文件
+func (s *Store) Delete(name string) {
+	delete(s.entries, name)
+}
+
+// Names 返回已排序的条目名称
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save 使用新的 salt 和 nonce 重新加密并以 0600 权限写入密钥库
+func (s *Store) Save() error {
+	plaintext, err := json.Marshal(s.entries)
+	if err != nil {
+		return fmt.Errorf("序列化密钥库失败: %w", err)
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("生成 salt 失败: %w", err)
+	}
+	gcm, err := newGCM(s.passphrase, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("生成 nonce 失败: %w", err)
+	}
+
+	file := storeFile{
+		Version:    storeVersion,
+		KDF:        "scrypt",
+		Salt:       salt,
+		N:          scryptN,
+		R:          scryptR,
+		P:          scryptP,
+		Nonce:      nonce,
+		Names:      s.Names(),
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化密钥库失败: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("保存密钥库失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("保存密钥库失败: %w", err)
+	}
+	return nil
+}
+
+// Resolve 解析配置值: 密钥库引用返回条目的值，其它值原样返回
+func Resolve(clusterDir, value string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+	store, err := Open(clusterDir)
+	if err != nil {
+		return "", fmt.Errorf("解析 %s 失败: %w", value, err)
+	}
+	return store.Get(strings.TrimPrefix(value, RefPrefix))
+}
+
+// ReadPassphrase 依次从环境变量、口令文件和终端读取密钥库口令
+func ReadPassphrase() ([]byte, error) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if path := os.Getenv(PassphraseFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取口令文件失败: %w", err)
+		}
+		return []byte(strings.TrimRight(string(data), "\r\n")), nil
+	}
+
+	if cachedPassphrase != nil {
+		return cachedPassphrase, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, ErrNoPassphrase
+	}
+	fmt.Fprint(os.Stderr, "🔑 请输入密钥库口令: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("读取口令失败: %w", err)
+	}
+	cachedPassphrase = passphrase
+	return passphrase, nil
+}
+
+func readStoreFile(path string) (*storeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("密钥库 %s 不存在，请先运行 'ocpack secrets init %s': %w", path, filepath.Base(filepath.Dir(path)), err)
+		}
+		return nil, fmt.Errorf("读取密钥库失败: %w", err)
+	}
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析密钥库 %s 失败: %w", path, err)
+	}
+	return &file, nil
+}
+
+func newGCM(passphrase, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("密钥库口令不能为空")
+	}
+	key, err := scrypt.Key(passphrase, salt, n, r, p, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("派生密钥失败: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Create(dir, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := store.Set("bastion", "s3cret-password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(StorePath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret-password") {
+		t.Error("store file contains the plaintext value")
+	}
+	if info, _ := os.Stat(StorePath(dir)); info.Mode().Perm() != 0600 {
+		t.Errorf("store file mode = %v, expected 0600", info.Mode().Perm())
+	}
+	if !Has(dir, "bastion") || Has(dir, "registry") {
+		t.Errorf("Has() without passphrase returned wrong result, names in file: %s", data)
+	}
+
+	if _, err := OpenWithPassphrase(dir, []byte("wrong")); err == nil {
+		t.Error("OpenWithPassphrase() expected error for a wrong passphrase")
+	}
+
+	t.Setenv(PassphraseEnv, "correct horse")
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain-password", "plain-password", false},
+		{"secret:bastion", "s3cret-password", false},
+		{"secret:missing", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(dir, tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, %v; expected %q", tt.value, got, err, tt.want)
+		}
+	}
+}
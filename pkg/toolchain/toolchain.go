@@ -0,0 +1,327 @@
+// Package toolchain 统一查找 openshift-install、oc 和 oc-mirror，并检查它们的版本
+// 是否与 openshift_version 以及 release payload 一致。
+package toolchain
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"ocpack/pkg/config"
+	"ocpack/pkg/utils"
+)
+
+// 工具名称
+const (
+	OpenShiftInstall = "openshift-install"
+	OC               = "oc"
+	OcMirror         = "oc-mirror"
+)
+
+// 工具来源，按查找顺序排列
+const (
+	SourceRegistry = "registry" // 从本地 Registry 中的 release payload 提取
+	SourceDownload = "download" // ocpack download 下载到 downloads/bin
+	SourcePath     = "PATH"
+)
+
+// versionTimeout 执行 version 子命令的超时时间
+const versionTimeout = 30 * time.Second
+
+// ocMirrorVersionPattern 匹配 oc-mirror version 输出中的 GitVersion:"4.16.0-202406..."
+var ocMirrorVersionPattern = regexp.MustCompile(`GitVersion:"v?([0-9][^"]*)"`)
+
+// Tool 找到的工具及其版本信息
+type Tool struct {
+	Name         string
+	Path         string
+	Source       string
+	Version      string // 无法获取时为空
+	ReleaseImage string // openshift-install 内置的 release payload 摘要，如 @sha256:...
+	ProbeError   error  // 执行 version 子命令失败的原因
+}
+
+// Toolchain 查找集群使用的工具，所有命令都通过这里获取工具路径
+type Toolchain struct {
+	Config      *config.ClusterConfig
+	ClusterDir  string
+	DownloadDir string
+
+	// runVersion 执行工具的 version 子命令，测试中替换
+	runVersion func(path string, args ...string) (string, error)
+	tools      map[string]*Tool
+}
+
+// New 创建集群的工具链
+func New(cfg *config.ClusterConfig, clusterDir string) *Toolchain {
+	return &Toolchain{
+		Config:      cfg,
+		ClusterDir:  clusterDir,
+		DownloadDir: filepath.Join(clusterDir, cfg.Download.LocalPath),
+		runVersion:  runVersion,
+		tools:       map[string]*Tool{},
+	}
+}
+
+// RegistryHost 返回本地 Registry 的主机名
+func (t *Toolchain) RegistryHost() string {
+	return fmt.Sprintf("registry.%s.%s", t.Config.ClusterInfo.Name, t.Config.ClusterInfo.Domain)
+}
+
+// ExtractedPath 返回从本地 Registry 提取的工具的位置，文件名带有版本和 Registry 主机名
+func (t *Toolchain) ExtractedPath(name string) string {
+	return filepath.Join(t.ClusterDir, fmt.Sprintf("%s-%s-%s", name, t.Config.ClusterInfo.OpenShiftVersion, t.RegistryHost()))
+}
+
+// ExtractedInstallerPath 返回从本地 Registry 提取的 openshift-install 的位置
+func (t *Toolchain) ExtractedInstallerPath() string {
+	return t.ExtractedPath(OpenShiftInstall)
+}
+
+// DownloadedPath 返回 downloads/bin 中工具的位置
+func (t *Toolchain) DownloadedPath(name string) string {
+	return filepath.Join(t.DownloadDir, "bin", name)
+}
+
+// candidate 工具可能的位置
+type candidate struct {
+	path   string
+	source string
+}
+
+// candidates 返回工具可能的位置，按优先级排列
+func (t *Toolchain) candidates(name string) []candidate {
+	var list []candidate
+	if name == OpenShiftInstall || name == OC {
+		list = append(list, candidate{t.ExtractedPath(name), SourceRegistry})
+	}
+	list = append(list, candidate{t.DownloadedPath(name), SourceDownload})
+	if path, err := exec.LookPath(name); err == nil {
+		list = append(list, candidate{path, SourcePath})
+	}
+	return list
+}
+
+// Find 查找工具并读取版本，结果会被缓存
+func (t *Toolchain) Find(name string) (*Tool, error) {
+	if tool, ok := t.tools[name]; ok {
+		return tool, nil
+	}
+	var searched []string
+	for _, c := range t.candidates(name) {
+		if !utils.FileExists(c.path) {
+			searched = append(searched, c.path)
+			continue
+		}
+		tool := t.probe(name, c.path, c.source)
+		t.tools[name] = tool
+		return tool, nil
+	}
+	return nil, fmt.Errorf("未找到 %s 工具 (已查找 %s 和 PATH)，请先运行 'ocpack download'", name, strings.Join(searched, "、"))
+}
+
+// Path 查找工具并检查版本：版本不一致的 openshift-install 返回错误，其他问题打印警告
+func (t *Toolchain) Path(name string) (string, error) {
+	tool, err := t.Find(name)
+	if err != nil {
+		return "", err
+	}
+	result := &config.ValidationResult{}
+	t.checkTool(tool, result)
+	for _, warning := range result.Warnings() {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	if err := result.Err(); err != nil {
+		return "", err
+	}
+	fmt.Printf("ℹ️  使用 %s\n", tool)
+	return tool.Path, nil
+}
+
+// Check 检查已存在的工具版本，缺少的工具由 preflight 的文件检查报告
+func (t *Toolchain) Check(names ...string) *config.ValidationResult {
+	result := &config.ValidationResult{}
+	for _, name := range names {
+		tool, err := t.Find(name)
+		if err != nil {
+			continue
+		}
+		t.checkTool(tool, result)
+	}
+	if contains(names, OpenShiftInstall) {
+		t.checkReleasePayload(result)
+	}
+	return result
+}
+
+// checkTool 检查单个工具的版本与 openshift_version 的关系
+func (t *Toolchain) checkTool(tool *Tool, result *config.ValidationResult) {
+	expected := t.Config.ClusterInfo.OpenShiftVersion
+	issue := func(severity config.Severity, message, hint string) {
+		result.Issues = append(result.Issues, config.ValidationIssue{
+			Path: t.relPath(tool.Path), Message: message, Hint: hint, Severity: severity,
+		})
+	}
+
+	if tool.Version == "" {
+		if tool.ProbeError != nil {
+			issue(config.SeverityWarning, fmt.Sprintf("无法获取 %s 的版本: %v", tool.Name, tool.ProbeError), "工具可能不是当前平台的版本，跳过版本检查")
+		}
+		return
+	}
+	if !t.Config.VersionResolved() {
+		return
+	}
+
+	switch tool.Name {
+	case OpenShiftInstall:
+		// openshift-install 内置 release payload，版本不一致会安装错误的版本
+		if tool.Version != expected {
+			issue(config.SeverityError,
+				fmt.Sprintf("%s 的版本 %s 与 openshift_version %s 不一致", tool.Name, tool.Version, expected),
+				hintFor(tool))
+		}
+	case OC:
+		if tool.Version != expected {
+			issue(config.SeverityWarning,
+				fmt.Sprintf("oc 的版本 %s 与 openshift_version %s 不一致", tool.Version, expected),
+				hintFor(tool))
+		}
+	case OcMirror:
+		// oc-mirror 可以同步较旧的版本，只要求不低于集群的次版本
+		if utils.CompareVersion(utils.ExtractMajorVersion(tool.Version), utils.ExtractMajorVersion(expected)) < 0 {
+			issue(config.SeverityWarning,
+				fmt.Sprintf("oc-mirror 的版本 %s 低于 openshift_version %s", tool.Version, expected),
+				hintFor(tool))
+		}
+	}
+}
+
+// checkReleasePayload 检查从 Registry 提取的和下载的 openshift-install 使用同一个 release payload
+func (t *Toolchain) checkReleasePayload(result *config.ValidationResult) {
+	extractedPath := t.ExtractedInstallerPath()
+	downloadedPath := t.DownloadedPath(OpenShiftInstall)
+	if !utils.FileExists(extractedPath) || !utils.FileExists(downloadedPath) {
+		return
+	}
+	extracted := t.probe(OpenShiftInstall, extractedPath, SourceRegistry)
+	downloaded := t.probe(OpenShiftInstall, downloadedPath, SourceDownload)
+	if extracted.ReleaseImage == "" || downloaded.ReleaseImage == "" || extracted.ReleaseImage == downloaded.ReleaseImage {
+		return
+	}
+	result.Issues = append(result.Issues, config.ValidationIssue{
+		Path:     t.relPath(extractedPath),
+		Message:  fmt.Sprintf("从 Registry 提取的 openshift-install 使用的 release payload (%s) 与下载的版本 (%s) 不一致", extracted.ReleaseImage, downloaded.ReleaseImage),
+		Hint:     "Registry 中同步的 release 可能不是 openshift_version，请重新同步镜像或删除提取的 openshift-install",
+		Severity: config.SeverityError,
+	})
+}
+
+// probe 执行工具的 version 子命令并解析版本
+func (t *Toolchain) probe(name, path, source string) *Tool {
+	tool := &Tool{Name: name, Path: path, Source: source}
+	// 为其他平台下载或提取的工具无法在本机执行
+	if source != SourcePath && !t.runsLocally() {
+		return tool
+	}
+
+	var args []string
+	switch name {
+	case OC:
+		args = []string{"version", "--client"}
+	default:
+		args = []string{"version"}
+	}
+	output, err := t.runVersion(path, args...)
+	if err != nil {
+		tool.ProbeError = err
+		return tool
+	}
+	tool.Version = parseVersion(name, filepath.Base(path), output)
+	if name == OpenShiftInstall {
+		tool.ReleaseImage = utils.ExtractSHAFromOutput(output)
+	}
+	return tool
+}
+
+// runsLocally 判断 downloads/bin 中和从 Registry 提取的工具是否为当前平台的版本
+func (t *Toolchain) runsLocally() bool {
+	arch, err := t.Config.ClientArch()
+	if err != nil {
+		return false
+	}
+	local, _ := config.NormalizeArch(runtime.GOARCH)
+	return t.Config.ClientOS() == runtime.GOOS && arch == local
+}
+
+// relPath 返回相对于集群目录的路径，用于报告
+func (t *Toolchain) relPath(path string) string {
+	if rel, err := filepath.Rel(t.ClusterDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// parseVersion 从工具 version 子命令的输出中解析版本号。openshift-install 以调用时的路径
+// 输出自身名称，从 Registry 提取的版本文件名带有版本和主机名后缀，因此使用实际的文件名匹配。
+func parseVersion(name, binary, output string) string {
+	switch name {
+	case OC:
+		return utils.ExtractVersionFromOutput(output, "Client Version:")
+	case OcMirror:
+		if m := ocMirrorVersionPattern.FindStringSubmatch(output); m != nil {
+			version := m[1]
+			// 去掉 -202406130907.p0... 构建后缀
+			if idx := strings.Index(version, "-"); idx != -1 {
+				version = version[:idx]
+			}
+			if utils.IsValidVersionFormat(version) {
+				return version
+			}
+		}
+		return utils.ExtractVersionFromOutput(output, "Client Version:")
+	}
+	return utils.ExtractVersionFromOutput(output, binary)
+}
+
+func (tool *Tool) String() string {
+	s := fmt.Sprintf("%s (%s", tool.Path, tool.Source)
+	if tool.Version != "" {
+		s += ", " + tool.Version
+	}
+	return s + ")"
+}
+
+func hintFor(tool *Tool) string {
+	switch tool.Source {
+	case SourceRegistry:
+		return fmt.Sprintf("删除 %s 后重新从 Registry 提取", filepath.Base(tool.Path))
+	case SourcePath:
+		return "运行 'ocpack download' 下载与集群版本一致的工具，downloads/bin 中的工具优先于 PATH"
+	}
+	return "重新运行 'ocpack download' 下载与集群版本一致的工具"
+}
+
+func runVersion(path string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
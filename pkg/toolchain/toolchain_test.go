@@ -0,0 +1,150 @@
+package toolchain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ocpack/pkg/config"
+)
+
+// newTestToolchain 创建带有假工具的集群目录，outputs 按文件名返回 version 子命令的输出
+func newTestToolchain(t *testing.T, outputs map[string]string) *Toolchain {
+	t.Helper()
+	clusterDir := t.TempDir()
+	cfg := &config.ClusterConfig{}
+	cfg.ClusterInfo.Name = "demo"
+	cfg.ClusterInfo.Domain = "example.com"
+	cfg.ClusterInfo.OpenShiftVersion = "4.16.3"
+	cfg.Download.LocalPath = "downloads"
+	cfg.Download.ClientOS = runtime.GOOS
+	cfg.Download.ClientArch = runtime.GOARCH
+
+	tc := New(cfg, clusterDir)
+	if err := os.MkdirAll(filepath.Join(tc.DownloadDir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name := range outputs {
+		path := filepath.Join(tc.DownloadDir, "bin", name)
+		if strings.Contains(name, "-registry.") {
+			path = filepath.Join(clusterDir, name)
+		}
+		if err := os.WriteFile(path, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tc.runVersion = func(path string, args ...string) (string, error) {
+		output, ok := outputs[filepath.Base(path)]
+		if !ok {
+			return "", fmt.Errorf("unexpected tool %s", path)
+		}
+		return strings.ReplaceAll(output, "$0", path), nil
+	}
+	return tc
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name, binary, output, want string
+	}{
+		{OpenShiftInstall, "openshift-install", "/root/demo/downloads/bin/openshift-install 4.16.3\nbuilt from commit abc\nrelease image quay.io/openshift-release-dev/ocp-release@sha256:aaa", "4.16.3"},
+		{OpenShiftInstall, "openshift-install-4.16.3-registry.demo.example.com", "/root/demo/openshift-install-4.16.3-registry.demo.example.com 4.16.3", "4.16.3"},
+		{OC, "oc", "Client Version: 4.16.3\nKustomize Version: v5.0.4-0.20230601165947-6ce0bf390ce3", "4.16.3"},
+		{OcMirror, "oc-mirror", `Client Version: version.Info{Major:"", Minor:"", GitVersion:"4.16.0-202406131906.p0.g3f7a7f7.assembly.stream.el9-3f7a7f7", GitCommit:"3f7a7f7"}`, "4.16.0"},
+		{OC, "oc", "error: unknown flag", ""},
+	}
+	for _, tt := range tests {
+		if got := parseVersion(tt.name, tt.binary, tt.output); got != tt.want {
+			t.Errorf("parseVersion(%s, %q) = %q, want %q", tt.name, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestPathRefusesMismatchedInstaller(t *testing.T) {
+	tc := newTestToolchain(t, map[string]string{
+		OpenShiftInstall: "$0 4.15.9\nrelease image quay.io/openshift-release-dev/ocp-release@sha256:aaa",
+		OC:               "Client Version: 4.15.9",
+	})
+
+	if _, err := tc.Path(OpenShiftInstall); err == nil || !strings.Contains(err.Error(), "4.15.9") {
+		t.Errorf("Path(openshift-install) error = %v, want version mismatch", err)
+	}
+	// oc 的版本不一致只警告
+	path, err := tc.Path(OC)
+	if err != nil || path != tc.DownloadedPath(OC) {
+		t.Errorf("Path(oc) = %s, %v", path, err)
+	}
+	result := tc.Check(OC)
+	if len(result.Warnings()) != 1 || result.HasErrors() {
+		t.Errorf("Check(oc) issues = %v", result.Issues)
+	}
+}
+
+func TestPathPrefersRegistryExtractedInstaller(t *testing.T) {
+	extracted := "openshift-install-4.16.3-registry.demo.example.com"
+	tc := newTestToolchain(t, map[string]string{
+		extracted:        "$0 4.16.3\nrelease image registry.demo.example.com:8443/openshift/release-images@sha256:aaa",
+		OpenShiftInstall: "$0 4.16.3\nrelease image quay.io/openshift-release-dev/ocp-release@sha256:aaa",
+	})
+
+	path, err := tc.Path(OpenShiftInstall)
+	if err != nil {
+		t.Fatalf("Path() error = %v", err)
+	}
+	if path != tc.ExtractedInstallerPath() {
+		t.Errorf("Path() = %s, want %s", path, tc.ExtractedInstallerPath())
+	}
+	if result := tc.Check(OpenShiftInstall); len(result.Issues) != 0 {
+		t.Errorf("Check() issues = %v", result.Issues)
+	}
+}
+
+func TestPathPrefersRegistryExtractedOC(t *testing.T) {
+	tc := newTestToolchain(t, map[string]string{
+		"oc-4.16.3-registry.demo.example.com": "Client Version: 4.16.3",
+		OC:                                    "Client Version: 4.15.9",
+	})
+
+	path, err := tc.Path(OC)
+	if err != nil || path != tc.ExtractedPath(OC) {
+		t.Errorf("Path(oc) = %s, %v; want %s", path, err, tc.ExtractedPath(OC))
+	}
+	if result := tc.Check(OC); len(result.Issues) != 0 {
+		t.Errorf("Check(oc) issues = %v", result.Issues)
+	}
+}
+
+func TestCheckReleasePayloadMismatch(t *testing.T) {
+	tc := newTestToolchain(t, map[string]string{
+		"openshift-install-4.16.3-registry.demo.example.com": "$0 4.16.3\nrelease image registry.demo.example.com:8443/openshift/release-images@sha256:bbb",
+		OpenShiftInstall: "$0 4.16.3\nrelease image quay.io/openshift-release-dev/ocp-release@sha256:aaa",
+	})
+
+	result := tc.Check(OpenShiftInstall)
+	if !result.HasErrors() || !strings.Contains(result.Err().Error(), "release payload") {
+		t.Errorf("Check() issues = %v, want release payload mismatch", result.Issues)
+	}
+}
+
+func TestProbeSkipsOtherPlatforms(t *testing.T) {
+	tc := newTestToolchain(t, map[string]string{OC: "Client Version: 4.16.3"})
+	tc.Config.Download.ClientOS = "darwin"
+	if runtime.GOOS == "darwin" {
+		tc.Config.Download.ClientOS = "linux"
+	}
+	tc.runVersion = func(path string, args ...string) (string, error) {
+		t.Errorf("runVersion(%s) called for a tool built for another platform", path)
+		return "", nil
+	}
+
+	tool, err := tc.Find(OC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tool.Version != "" || len(tc.Check(OC).Issues) != 0 {
+		t.Errorf("tool = %+v", tool)
+	}
+}
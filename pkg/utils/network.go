@@ -2,6 +2,8 @@ package utils
 
 import (
 	"fmt"
+	"net"
+	"net/netip"
 	"strings"
 )
 
@@ -14,33 +16,22 @@ func ExtractNetworkBase(cidr string) string {
 	return cidr
 }
 
-// ExtractPrefixLength 提取前缀长度
+// ExtractPrefixLength 提取前缀长度，无法解析时 IPv4 默认为 24，IPv6 默认为 64
 func ExtractPrefixLength(cidr string) int {
-	parts := strings.Split(cidr, "/")
-	if len(parts) == 2 {
-		switch parts[1] {
-		case "8":
-			return 8
-		case "16":
-			return 16
-		case "24":
-			return 24
-		case "32":
-			return 32
-		default:
-			// 尝试转换为整数
-			var prefix int
-			if _, err := fmt.Sscanf(parts[1], "%d", &prefix); err == nil {
-				return prefix
-			}
-			return 24 // 默认值
-		}
+	if prefix, err := netip.ParsePrefix(cidr); err == nil {
+		return prefix.Bits()
+	}
+	if IsIPv6(ExtractNetworkBase(cidr)) {
+		return 64
 	}
 	return 24 // 默认值
 }
 
-// ExtractGateway 提取网关地址（假设是网络的第一个地址）
+// ExtractGateway 提取网关地址（假设是网络的第一个地址），同时支持 IPv4 和 IPv6
 func ExtractGateway(cidr string) string {
+	if prefix, err := netip.ParsePrefix(cidr); err == nil {
+		return prefix.Masked().Addr().Next().String()
+	}
 	networkBase := ExtractNetworkBase(cidr)
 	parts := strings.Split(networkBase, ".")
 	if len(parts) == 4 {
@@ -50,19 +41,22 @@ func ExtractGateway(cidr string) string {
 	return networkBase
 }
 
-// GetNetworkClass 获取网络类别 (A, B, C)
+// GetNetworkClass 获取网络类别 (A, B, C)，IPv6 网络返回 "IPv6"
 func GetNetworkClass(cidr string) string {
 	ip := ExtractNetworkBase(cidr)
+	if IsIPv6(ip) {
+		return "IPv6"
+	}
 	parts := strings.Split(ip, ".")
 	if len(parts) != 4 {
 		return "Unknown"
 	}
-	
+
 	var firstOctet int
 	if _, err := fmt.Sscanf(parts[0], "%d", &firstOctet); err != nil {
 		return "Unknown"
 	}
-	
+
 	if firstOctet >= 1 && firstOctet <= 126 {
 		return "A"
 	} else if firstOctet >= 128 && firstOctet <= 191 {
@@ -74,6 +68,130 @@ func GetNetworkClass(cidr string) string {
 	} else if firstOctet >= 240 && firstOctet <= 255 {
 		return "E"
 	}
-	
+
 	return "Unknown"
-} 
\ No newline at end of file
+}
+
+// IsValidIP 检查字符串是否为合法的 IP 地址
+func IsValidIP(ip string) bool {
+	_, err := netip.ParseAddr(ip)
+	return err == nil
+}
+
+// IsValidMAC 检查字符串是否为合法的 MAC 地址
+func IsValidMAC(mac string) bool {
+	hw, err := net.ParseMAC(mac)
+	return err == nil && len(hw) == 6
+}
+
+// IsValidCIDR 检查字符串是否为合法的 CIDR
+func IsValidCIDR(cidr string) bool {
+	_, err := netip.ParsePrefix(cidr)
+	return err == nil
+}
+
+// CIDRContainsIP 检查 IP 是否位于 CIDR 网段内
+func CIDRContainsIP(cidr, ip string) (bool, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return false, fmt.Errorf("无效的 CIDR %s: %w", cidr, err)
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false, fmt.Errorf("无效的 IP %s: %w", ip, err)
+	}
+	return prefix.Masked().Contains(addr), nil
+}
+
+// CIDRsOverlap 检查两个 CIDR 网段是否重叠
+func CIDRsOverlap(a, b string) (bool, error) {
+	pa, err := netip.ParsePrefix(a)
+	if err != nil {
+		return false, fmt.Errorf("无效的 CIDR %s: %w", a, err)
+	}
+	pb, err := netip.ParsePrefix(b)
+	if err != nil {
+		return false, fmt.Errorf("无效的 CIDR %s: %w", b, err)
+	}
+	return pa.Masked().Overlaps(pb.Masked()), nil
+}
+
+// NormalizeCIDR 去掉 CIDR 中的主机位 (例如 "192.168.1.5/24" -> "192.168.1.0/24")
+func NormalizeCIDR(cidr string) string {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return cidr
+	}
+	return prefix.Masked().String()
+}
+
+// NormalizeCIDRs 对列表中的每个 CIDR 调用 NormalizeCIDR
+func NormalizeCIDRs(cidrs []string) []string {
+	normalized := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		normalized = append(normalized, NormalizeCIDR(cidr))
+	}
+	return normalized
+}
+
+// IsIPv6 检查字符串是否为 IPv6 地址
+func IsIPv6(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && addr.Is6() && !addr.Is4In6()
+}
+
+// IsIPv6CIDR 检查 CIDR 是否为 IPv6 网段
+func IsIPv6CIDR(cidr string) bool {
+	prefix, err := netip.ParsePrefix(cidr)
+	return err == nil && prefix.Addr().Is6()
+}
+
+// ReversePointer 返回 IP 地址的 PTR 记录名称
+// (例如 "192.168.1.21" -> "21.1.168.192.in-addr.arpa"，IPv6 按半字节展开到 ip6.arpa)
+func ReversePointer(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", fmt.Errorf("无效的 IP %s: %w", ip, err)
+	}
+	return reverseLabels(addr, addr.BitLen()), nil
+}
+
+// ReverseZone 返回覆盖 CIDR 的反向解析区域名称，
+// IPv4 按 8 位、IPv6 按 4 位向下对齐 (例如 "192.168.0.0/23" -> "168.192.in-addr.arpa")
+func ReverseZone(cidr string) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", fmt.Errorf("无效的 CIDR %s: %w", cidr, err)
+	}
+	step := 8
+	if prefix.Addr().Is6() {
+		step = 4
+	}
+	bits := prefix.Bits() / step * step
+	if bits == 0 {
+		return "", fmt.Errorf("网段 %s 过大，无法生成反向解析区域", cidr)
+	}
+	return reverseLabels(prefix.Addr(), bits), nil
+}
+
+// reverseLabels 将地址的前 bits 位按反向解析格式展开
+func reverseLabels(addr netip.Addr, bits int) string {
+	var labels []string
+	if addr.Is4() {
+		octets := addr.As4()
+		for i := bits/8 - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprint(octets[i]))
+		}
+		return strings.Join(append(labels, "in-addr.arpa"), ".")
+	}
+
+	bytes := addr.As16()
+	for i := bits/4 - 1; i >= 0; i-- {
+		nibble := bytes[i/2] >> 4
+		if i%2 == 1 {
+			nibble = bytes[i/2] & 0x0f
+		}
+		labels = append(labels, fmt.Sprintf("%x", nibble))
+	}
+	return strings.Join(append(labels, "ip6.arpa"), ".")
+}
@@ -2,61 +2,309 @@ package utils
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"ocpack/pkg/secrets"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSH 主机密钥校验策略
+const (
+	// HostKeyPolicyStrict 只接受 known_hosts 中已有的主机密钥
+	HostKeyPolicyStrict = "strict"
+	// HostKeyPolicyTOFU 首次连接时记录主机密钥 (trust-on-first-use)，之后严格校验
+	HostKeyPolicyTOFU = "tofu"
+	// HostKeyPolicyInsecure 不校验主机密钥，只应在隔离的测试环境中使用
+	HostKeyPolicyInsecure = "insecure"
+
+	// DefaultSSHPort SSH 默认端口
+	DefaultSSHPort = 22
 )
 
+// HostKeyPolicies 返回所有支持的主机密钥校验策略
+func HostKeyPolicies() []string {
+	return []string{HostKeyPolicyStrict, HostKeyPolicyTOFU, HostKeyPolicyInsecure}
+}
+
+// SSHOptions SSH 连接选项
+type SSHOptions struct {
+	Host          string
+	Port          int // 为 0 时使用 22
+	User          string
+	Password      string
+	KeyPath       string
+	KeyPassphrase string // 加密私钥的口令
+	UseAgent      bool   // 使用 SSH_AUTH_SOCK 指向的 ssh-agent
+
+	HostKeyPolicy  string // strict、tofu 或 insecure，为空时使用 tofu
+	KnownHostsFile string // strict 和 tofu 策略使用的 known_hosts 文件
+}
+
+// Address 返回 host:port 形式的连接地址
+func (o SSHOptions) Address() string {
+	port := o.Port
+	if port == 0 {
+		port = DefaultSSHPort
+	}
+	return net.JoinHostPort(o.Host, strconv.Itoa(port))
+}
+
+// Policy 返回主机密钥校验策略，未设置时为 tofu
+func (o SSHOptions) Policy() string {
+	if o.HostKeyPolicy == "" {
+		return HostKeyPolicyTOFU
+	}
+	return o.HostKeyPolicy
+}
+
+// HostKeyOptions 返回与主机密钥策略对应的 OpenSSH -o 选项，
+// 用于 ssh 命令和 Ansible 的 ansible_ssh_common_args
+func (o SSHOptions) HostKeyOptions() []string {
+	switch o.Policy() {
+	case HostKeyPolicyInsecure:
+		return []string{"StrictHostKeyChecking=no", "UserKnownHostsFile=/dev/null"}
+	case HostKeyPolicyStrict:
+		return []string{"StrictHostKeyChecking=yes", "UserKnownHostsFile=" + o.KnownHostsFile}
+	default:
+		// OpenSSH 7.6+ 的 accept-new 即 trust-on-first-use
+		return []string{"StrictHostKeyChecking=accept-new", "UserKnownHostsFile=" + o.KnownHostsFile}
+	}
+}
+
+// SSHArgs 返回 ssh 命令的端口和主机密钥参数
+func (o SSHOptions) SSHArgs() []string {
+	var args []string
+	if o.Port != 0 && o.Port != DefaultSSHPort {
+		args = append(args, "-p", strconv.Itoa(o.Port))
+	}
+	for _, opt := range o.HostKeyOptions() {
+		args = append(args, "-o", opt)
+	}
+	return args
+}
+
 // SSHClient 封装 SSH 连接
 type SSHClient struct {
-	client  *ssh.Client
-	host    string
-	user    string
+	client *ssh.Client
+	host   string
+	user   string
 }
 
 // NewSSHClient 创建一个新的 SSH 客户端
-func NewSSHClient(host, user, password, keyPath string) (*SSHClient, error) {
+//
+// 认证方式依次为 ssh-agent、私钥和密码。Password 必须是明文密码，
+// 配置文件中的 secret: 引用由 config.LoadConfig 解析。
+func NewSSHClient(opts SSHOptions) (*SSHClient, error) {
+	if secrets.IsRef(opts.Password) || secrets.IsRef(opts.KeyPassphrase) {
+		return nil, fmt.Errorf("SSH 凭据是未解析的密钥库引用，请使用 config.LoadConfig 加载配置")
+	}
+
+	auth, closeAgent, err := sshAuthMethods(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	hostKeyCallback, hostKeyAlgorithms, err := hostKeyCallback(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:              opts.User,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+	}
+
+	client, err := ssh.Dial("tcp", opts.Address(), config)
+	if err != nil {
+		return nil, fmt.Errorf("SSH连接失败: %w", err)
+	}
+
+	return &SSHClient{
+		client: client,
+		host:   opts.Host,
+		user:   opts.User,
+	}, nil
+}
+
+// sshAuthMethods 按 ssh-agent、私钥、密码的顺序构造认证方式
+func sshAuthMethods(opts SSHOptions) ([]ssh.AuthMethod, func(), error) {
 	var auth []ssh.AuthMethod
+	closeAgent := func() {}
 
-	// 如果提供了密钥路径，优先使用密钥认证
-	if keyPath != "" {
-		key, err := ioutil.ReadFile(keyPath)
+	if opts.UseAgent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, closeAgent, errors.New("已启用 ssh-agent，但未设置 SSH_AUTH_SOCK")
+		}
+		conn, err := net.Dial("unix", socket)
 		if err != nil {
-			return nil, fmt.Errorf("读取SSH密钥失败: %w", err)
+			return nil, closeAgent, fmt.Errorf("连接 ssh-agent 失败: %w", err)
 		}
+		// 认证在握手期间完成，连接建立后即可关闭 agent 连接
+		closeAgent = func() { conn.Close() }
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if opts.KeyPath != "" {
+		signer, err := loadPrivateKey(opts.KeyPath, opts.KeyPassphrase)
+		switch {
+		case err == nil:
+			auth = append(auth, ssh.PublicKeys(signer))
+		case opts.UseAgent && errors.As(err, new(*ssh.PassphraseMissingError)):
+			// 加密私钥由 ssh-agent 提供
+		default:
+			closeAgent()
+			return nil, func() {}, err
+		}
+	}
 
-		signer, err := ssh.ParsePrivateKey(key)
+	if opts.Password != "" {
+		auth = append(auth, ssh.Password(opts.Password))
+	}
+
+	if len(auth) == 0 {
+		return nil, closeAgent, fmt.Errorf("必须提供密码、SSH密钥或 ssh-agent")
+	}
+	return auth, closeAgent, nil
+}
+
+// loadPrivateKey 读取私钥，加密的私钥使用 passphrase 解密
+func loadPrivateKey(keyPath, passphrase string) (ssh.Signer, error) {
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取SSH密钥失败: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("SSH密钥 %s 已加密，请配置 ssh_key_passphrase 或使用 ssh-agent: %w", keyPath, err)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析SSH密钥失败: %w", err)
+	}
+	return signer, nil
+}
+
+// hostKeyCallback 根据主机密钥策略构造校验函数，同时返回 known_hosts 中为该主机记录的密钥算法
+func hostKeyCallback(opts SSHOptions) (ssh.HostKeyCallback, []string, error) {
+	policy := opts.Policy()
+	switch policy {
+	case HostKeyPolicyInsecure:
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	case HostKeyPolicyStrict, HostKeyPolicyTOFU:
+	default:
+		return nil, nil, fmt.Errorf("不支持的主机密钥策略: %s", policy)
+	}
+	if opts.KnownHostsFile == "" {
+		return nil, nil, fmt.Errorf("%s 策略需要 known_hosts 文件", policy)
+	}
+
+	if policy == HostKeyPolicyTOFU {
+		// 首次使用时创建空的 known_hosts 文件
+		if err := os.MkdirAll(filepath.Dir(opts.KnownHostsFile), 0700); err != nil {
+			return nil, nil, fmt.Errorf("创建 known_hosts 目录失败: %w", err)
+		}
+		file, err := os.OpenFile(opts.KnownHostsFile, os.O_CREATE|os.O_RDONLY, 0600)
 		if err != nil {
-			return nil, fmt.Errorf("解析SSH密钥失败: %w", err)
+			return nil, nil, fmt.Errorf("创建 known_hosts 文件失败: %w", err)
 		}
+		file.Close()
+	}
 
-		auth = append(auth, ssh.PublicKeys(signer))
-	} else if password != "" {
-		// 否则使用密码认证
-		auth = append(auth, ssh.Password(password))
-	} else {
-		return nil, fmt.Errorf("必须提供密码或SSH密钥")
+	known, err := knownhosts.New(opts.KnownHostsFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取 known_hosts 文件失败: %w", err)
 	}
 
-	config := &ssh.ClientConfig{
-		User:            user,
-		Auth:            auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if err == nil || !errors.As(err, &keyErr) {
+			return err
+		}
+		fingerprint := ssh.FingerprintSHA256(key)
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("%s 的主机密钥与 %s 中记录的不一致 (当前 %s)，可能存在中间人攻击；如主机已重装，请删除 %s:%d 的旧记录",
+				hostname, opts.KnownHostsFile, fingerprint, keyErr.Want[0].Filename, keyErr.Want[0].Line)
+		}
+		if policy == HostKeyPolicyStrict {
+			return fmt.Errorf("%s 的主机密钥 %s 不在 %s 中，请先核对指纹后将其加入 known_hosts", hostname, fingerprint, opts.KnownHostsFile)
+		}
+		if err := appendKnownHost(opts.KnownHostsFile, hostname, key); err != nil {
+			return err
+		}
+		fmt.Printf("🔑 首次连接 %s，已记录主机密钥 %s 到 %s\n", hostname, fingerprint, opts.KnownHostsFile)
+		return nil
 	}
+	return callback, knownHostKeyAlgorithms(known, opts.Address()), nil
+}
 
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:22", host), config)
+// knownHostKeyAlgorithms 返回 known_hosts 中为 address 记录的主机密钥算法，没有记录时返回 nil (使用默认算法)。
+// Ansible 通过 OpenSSH 先连接时记录的是 ed25519 密钥，而 x/crypto/ssh 优先协商 ecdsa，
+// 不限制算法时会拿到未记录的密钥类型并被当作密钥不一致
+func knownHostKeyAlgorithms(known ssh.HostKeyCallback, address string) []string {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
-		return nil, fmt.Errorf("SSH连接失败: %w", err)
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+	// 随机生成的密钥不会在 known_hosts 中，KeyError.Want 即为该主机的全部记录
+	var keyErr *knownhosts.KeyError
+	if !errors.As(known(address, &net.TCPAddr{}, probe), &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, want := range keyErr.Want {
+		for _, algorithm := range keyTypeAlgorithms(want.Key.Type()) {
+			if !slices.Contains(algorithms, algorithm) {
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+	return algorithms
+}
+
+// keyTypeAlgorithms 返回可以协商出该类型主机密钥的算法，ssh-rsa 密钥优先使用 SHA-2 签名
+func keyTypeAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
 	}
+	return []string{keyType}
+}
 
-	return &SSHClient{
-		client: client,
-		host:   host,
-		user:   user,
-	}, nil
+func appendKnownHost(knownHostsFile, hostname string, key ssh.PublicKey) error {
+	file, err := os.OpenFile(knownHostsFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("写入 known_hosts 文件失败: %w", err)
+	}
+	defer file.Close()
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(file, line); err != nil {
+		return fmt.Errorf("写入 known_hosts 文件失败: %w", err)
+	}
+	return nil
 }
 
 // Close 关闭 SSH 连接
@@ -175,4 +423,4 @@ func (s *SSHClient) UploadString(content, remotePath string) error {
 
 	fmt.Printf("内容已上传至 %s:%s\n", s.host, remotePath)
 	return nil
-} 
\ No newline at end of file
+}
@@ -1,7 +1,19 @@
 package utils
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 func TestCompareVersion(t *testing.T) {
@@ -58,6 +70,8 @@ func TestExtractPrefixLength(t *testing.T) {
 		{"192.168.1.1/32", 32},
 		{"192.168.1.1", 24}, // 默认值
 		{"192.168.1.0/28", 28},
+		{"fd00:10::/64", 64},
+		{"fd00::1", 64}, // IPv6 默认值
 	}
 
 	for _, test := range tests {
@@ -76,6 +90,8 @@ func TestExtractGateway(t *testing.T) {
 		{"192.168.1.0/24", "192.168.1.1"},
 		{"10.0.0.0/8", "10.0.0.1"},
 		{"172.16.0.0/16", "172.16.0.1"},
+		{"192.168.1.0/23", "192.168.0.1"},
+		{"fd00:10::/64", "fd00:10::1"},
 	}
 
 	for _, test := range tests {
@@ -119,6 +135,17 @@ Release Image: quay.io/openshift-release-dev/ocp-release@sha256:12345
 	if result != expected {
 		t.Errorf("ExtractVersionFromOutput() = %s, expected %s", result, expected)
 	}
+
+	// openshift-install 以完整路径输出自身名称，oc 的前缀包含空格
+	if result := ExtractVersionFromOutput("/root/ocpack/dr/downloads/bin/openshift-install 4.17.0\nbuilt from commit dfd4c08", "openshift-install"); result != "4.17.0" {
+		t.Errorf("ExtractVersionFromOutput(path) = %s, expected 4.17.0", result)
+	}
+	if result := ExtractVersionFromOutput("Client Version: 4.17.0\nKustomize Version: v5.0.4", "Client Version:"); result != "4.17.0" {
+		t.Errorf("ExtractVersionFromOutput(oc) = %s, expected 4.17.0", result)
+	}
+	if result := ExtractVersionFromOutput("error: openshift-install 4.17.0 not found", "openshift-install"); result != "" {
+		t.Errorf("ExtractVersionFromOutput(unrelated) = %s, expected empty", result)
+	}
 }
 
 func TestExtractSHAFromOutput(t *testing.T) {
@@ -203,3 +230,218 @@ func TestSupportsOcMirror(t *testing.T) {
 		}
 	}
 }
+
+func TestCIDRsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"10.128.0.0/14", "172.30.0.0/16", false},
+		{"10.128.0.0/14", "10.130.0.0/16", true},
+		{"192.168.1.5/24", "192.168.1.0/28", true},
+	}
+
+	for _, test := range tests {
+		result, err := CIDRsOverlap(test.a, test.b)
+		if err != nil || result != test.expected {
+			t.Errorf("CIDRsOverlap(%s, %s) = %v, %v, expected %v", test.a, test.b, result, err, test.expected)
+		}
+	}
+}
+
+func TestCIDRContainsIP(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		ip       string
+		expected bool
+	}{
+		{"192.168.1.0/24", "192.168.1.20", true},
+		{"192.168.1.0/24", "192.168.2.20", false},
+		{"10.0.0.0/8", "10.255.0.1", true},
+	}
+
+	for _, test := range tests {
+		result, err := CIDRContainsIP(test.cidr, test.ip)
+		if err != nil || result != test.expected {
+			t.Errorf("CIDRContainsIP(%s, %s) = %v, %v, expected %v", test.cidr, test.ip, result, err, test.expected)
+		}
+	}
+}
+
+func TestReverseDNS(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		ip      string
+		zone    string
+		pointer string
+	}{
+		{"192.168.1.0/24", "192.168.1.21", "1.168.192.in-addr.arpa", "21.1.168.192.in-addr.arpa"},
+		{"192.168.0.0/23", "192.168.1.21", "168.192.in-addr.arpa", "21.1.168.192.in-addr.arpa"},
+		{"fd00:10::/64", "fd00:10::21", "0.0.0.0.0.0.0.0.0.1.0.0.0.0.d.f.ip6.arpa",
+			"1.2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.1.0.0.0.0.d.f.ip6.arpa"},
+	}
+
+	for _, test := range tests {
+		zone, err := ReverseZone(test.cidr)
+		if err != nil || zone != test.zone {
+			t.Errorf("ReverseZone(%s) = %s, %v, expected %s", test.cidr, zone, err, test.zone)
+		}
+		pointer, err := ReversePointer(test.ip)
+		if err != nil || pointer != test.pointer {
+			t.Errorf("ReversePointer(%s) = %s, %v, expected %s", test.ip, pointer, err, test.pointer)
+		}
+	}
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	newKey := func() ssh.PublicKey {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	hostKey, otherKey := newKey(), newKey()
+	remote := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 2222}
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+
+	// strict: known_hosts 中没有记录时拒绝连接
+	if err := os.WriteFile(knownHosts, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	strict, _, err := hostKeyCallback(SSHOptions{HostKeyPolicy: HostKeyPolicyStrict, KnownHostsFile: knownHosts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := strict("192.168.1.10:2222", remote, hostKey); err == nil {
+		t.Error("strict policy accepted an unknown host key")
+	}
+
+	// tofu: 首次连接记录主机密钥，之后拒绝不同的密钥
+	tofu, _, err := hostKeyCallback(SSHOptions{KnownHostsFile: knownHosts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tofu("192.168.1.10:2222", remote, hostKey); err != nil {
+		t.Fatalf("tofu policy rejected the first host key: %v", err)
+	}
+	data, _ := os.ReadFile(knownHosts)
+	if !strings.HasPrefix(string(data), "[192.168.1.10]:2222 ssh-ed25519 ") {
+		t.Errorf("known_hosts = %q", data)
+	}
+
+	for _, policy := range []string{HostKeyPolicyStrict, HostKeyPolicyTOFU} {
+		callback, _, err := hostKeyCallback(SSHOptions{HostKeyPolicy: policy, KnownHostsFile: knownHosts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := callback("192.168.1.10:2222", remote, hostKey); err != nil {
+			t.Errorf("%s policy rejected the recorded host key: %v", policy, err)
+		}
+		if err := callback("192.168.1.10:2222", remote, otherKey); err == nil {
+			t.Errorf("%s policy accepted a changed host key", policy)
+		}
+	}
+}
+
+func TestNewSSHClientUsesKnownHostKeyAlgorithm(t *testing.T) {
+	// 服务端同时提供 ecdsa 和 ed25519 主机密钥，known_hosts 中只有 OpenSSH 记录的 ed25519 密钥
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "root" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	}
+	var edSigner ssh.Signer
+	for _, key := range []interface{}{ecPriv, edPriv} {
+		signer, err := ssh.NewSignerFromKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.AddHostKey(signer)
+		edSigner = signer
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				sconn, chans, reqs, err := ssh.NewServerConn(conn, server)
+				if err != nil {
+					return
+				}
+				defer sconn.Close()
+				go ssh.DiscardRequests(reqs)
+				for ch := range chans {
+					ch.Reject(ssh.Prohibited, "no channels")
+				}
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr.String())}, edSigner.PublicKey())
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []string{HostKeyPolicyStrict, HostKeyPolicyTOFU} {
+		client, err := NewSSHClient(SSHOptions{
+			Host:           "127.0.0.1",
+			Port:           addr.Port,
+			User:           "root",
+			Password:       "secret",
+			HostKeyPolicy:  policy,
+			KnownHostsFile: knownHosts,
+		})
+		if err != nil {
+			t.Fatalf("%s policy: NewSSHClient() error = %v", policy, err)
+		}
+		client.Close()
+	}
+	if data, _ := os.ReadFile(knownHosts); string(data) != line+"\n" {
+		t.Errorf("known_hosts changed: %q", data)
+	}
+}
+
+func TestSSHArgs(t *testing.T) {
+	tests := []struct {
+		opts     SSHOptions
+		expected string
+	}{
+		{SSHOptions{KnownHostsFile: "/c/known_hosts"}, "-o StrictHostKeyChecking=accept-new -o UserKnownHostsFile=/c/known_hosts"},
+		{SSHOptions{Port: 2222, HostKeyPolicy: HostKeyPolicyStrict, KnownHostsFile: "/c/known_hosts"}, "-p 2222 -o StrictHostKeyChecking=yes -o UserKnownHostsFile=/c/known_hosts"},
+		{SSHOptions{Port: 22, HostKeyPolicy: HostKeyPolicyInsecure}, "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"},
+	}
+
+	for _, test := range tests {
+		result := strings.Join(test.opts.SSHArgs(), " ")
+		if result != test.expected {
+			t.Errorf("SSHArgs(%+v) = %s, expected %s", test.opts, result, test.expected)
+		}
+	}
+}
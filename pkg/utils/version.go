@@ -85,27 +85,31 @@ func ParseVersion(version string) []int {
 }
 
 // ExtractVersionFromOutput 从命令输出中提取版本号
+//
+// prefix 之后的第一个字段为版本号，如 "openshift-install 4.14.0" 或 oc 的 "Client Version: 4.14.0"。
+// openshift-install 会以完整路径输出自身名称，prefix 之前允许有路径。
 func ExtractVersionFromOutput(output, prefix string) string {
 	lines := strings.Split(output, "\n")
+	lowerPrefix := strings.ToLower(prefix)
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		// 支持不区分大小写的前缀匹配
-		lowerLine := strings.ToLower(line)
-		lowerPrefix := strings.ToLower(prefix)
-		if strings.HasPrefix(lowerLine, lowerPrefix) {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				// 提取版本号，去掉可能的前缀
-				version := parts[1]
-				// 如果版本号包含 "v" 前缀，去掉它
-				if strings.HasPrefix(version, "v") {
-					version = version[1:]
-				}
-				// 验证提取的版本号格式
-				if IsValidVersionFormat(version) {
-					return version
-				}
-			}
+		idx := strings.Index(strings.ToLower(line), lowerPrefix)
+		if idx == -1 {
+			continue
+		}
+		if before := line[:idx]; before != "" && (!strings.HasSuffix(before, "/") || strings.ContainsAny(before, " \t")) {
+			continue
+		}
+		parts := strings.Fields(line[idx+len(prefix):])
+		if len(parts) == 0 {
+			continue
+		}
+		// 如果版本号包含 "v" 前缀，去掉它
+		version := strings.TrimPrefix(parts[0], "v")
+		// 验证提取的版本号格式
+		if IsValidVersionFormat(version) {
+			return version
 		}
 	}
 	return ""